@@ -0,0 +1,135 @@
+package simpleai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// APIClient sends JSON requests to a provider's HTTP API and classifies what goes wrong,
+// for providers that talk to their API directly rather than through an SDK
+type APIClient struct {
+	HTTPClient *http.Client // Optional: http.DefaultClient if nil
+	BaseURL    string       // Prefixed to request paths
+
+	// URL returns the full URL of an API path. Optional: BaseURL+path by default.
+	URL func(path string) string
+
+	// Authorize adds credentials to a request. Optional; errors that are not LLMErrors are
+	// reported as ErrInvalidConfig.
+	Authorize func(req *http.Request) error
+
+	// ClassifyStatus classifies an error response, whose body it may read. Optional: by
+	// default the body is used as the message and the status code picks the error type.
+	ClassifyStatus func(resp *http.Response, attempt int, operation string) *LLMError
+
+	Service  string // Names the API in error messages, such as "OpenAI API"
+	Provider string // Provider name set on errors
+	Model    string // Model set on errors
+}
+
+// Do sends a request and decodes the JSON response into out, if out is non-nil
+func (c *APIClient) Do(ctx context.Context, method, path string, body any, out any, attempt int, operation string) error {
+	resp, err := c.Send(ctx, method, path, body, attempt, operation)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return c.TransportError(ctxErr, attempt, operation)
+		}
+		return NewLLMError(ErrInvalidResponse,
+			"invalid response from "+c.Service, operation, true, attempt, err).
+			WithProvider(c.Provider, c.Model)
+	}
+	return nil
+}
+
+// Send sends a request with body encoded as JSON, if non-nil, and returns the response if
+// its status is successful. Error responses are read, closed and classified.
+func (c *APIClient) Send(ctx context.Context, method, path string, body any, attempt int, operation string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, NewLLMError(ErrOperationFailed,
+				"failed to encode request", operation, false, attempt, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	url := c.BaseURL + path
+	if c.URL != nil {
+		url = c.URL(path)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, NewLLMError(ErrInvalidConfig,
+			"invalid request URL", operation, false, attempt, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Authorize != nil {
+		if err := c.Authorize(req); err != nil {
+			var llmErr *LLMError
+			if errors.As(err, &llmErr) {
+				return nil, err
+			}
+			return nil, NewLLMError(ErrInvalidConfig,
+				"cannot authorize request", operation, false, attempt, err).
+				WithProvider(c.Provider, c.Model)
+		}
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, c.TransportError(err, attempt, operation)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, c.statusError(resp, attempt, operation)
+	}
+	return resp, nil
+}
+
+// TransportError classifies errors from reaching the API at all, including reading a
+// response body. Unrecognized errors are retryable connection failures.
+func (c *APIClient) TransportError(err error, attempt int, operation string) *LLMError {
+	llmErr, ok := NewTransportError(err, c.Service, operation, attempt)
+	if !ok {
+		llmErr = NewLLMError(ErrConnectionFailed,
+			"connection to "+c.Service+" failed", operation, true, attempt, err)
+	}
+	return llmErr.WithProvider(c.Provider, c.Model)
+}
+
+// statusError classifies an error response
+func (c *APIClient) statusError(resp *http.Response, attempt int, operation string) *LLMError {
+	if c.ClassifyStatus != nil {
+		return c.ClassifyStatus(resp, attempt, operation)
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	message := http.StatusText(resp.StatusCode)
+	if text := strings.TrimSpace(string(data)); text != "" {
+		message = text
+	}
+	return NewStatusError(resp.StatusCode, fmt.Sprintf("%s error: %s", c.Service, message), operation, attempt, nil).
+		WithProvider(c.Provider, c.Model).
+		WithRetryAfter(ParseRetryAfter(resp.Header.Get("Retry-After")))
+}
@@ -0,0 +1,61 @@
+package simpleai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"answer": 42}`))
+		case "/busy":
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("try later"))
+		default:
+			w.Write([]byte("not json"))
+		}
+	}))
+	defer server.Close()
+
+	client := &APIClient{
+		BaseURL: server.URL,
+		Authorize: func(req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer key")
+			return nil
+		},
+		Service:  "Test API",
+		Provider: "test",
+	}
+	ctx := context.Background()
+
+	var out struct{ Answer int }
+	if err := client.Do(ctx, http.MethodPost, "/ok", map[string]string{"q": "?"}, &out, 0, "chat"); err != nil || out.Answer != 42 {
+		t.Errorf("Expected answer 42, got %d (%v)", out.Answer, err)
+	}
+
+	err := client.Do(ctx, http.MethodGet, "/busy", nil, &out, 1, "chat")
+	var llmErr *LLMError
+	if !errors.As(err, &llmErr) || llmErr.Type != ErrConnectionFailed || llmErr.StatusCode != 503 || llmErr.RetryAfter == 0 {
+		t.Errorf("Expected a classified 503 with a retry hint, got %v", err)
+	}
+
+	if err := client.Do(ctx, http.MethodGet, "/garbled", nil, &out, 0, "chat"); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("Expected ErrInvalidResponse, got %v", err)
+	}
+
+	server.Close()
+	err = client.Do(ctx, http.MethodGet, "/ok", nil, nil, 0, "health_check")
+	if !errors.As(err, &llmErr) || llmErr.Type != ErrConnectionFailed || !llmErr.Retryable || llmErr.Provider != "test" {
+		t.Errorf("Expected a retryable connection failure, got %v", err)
+	}
+}
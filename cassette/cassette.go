@@ -0,0 +1,171 @@
+// Package cassette records chat interactions with a provider and replays them in tests.
+//
+// In record mode a Recorder passes requests through to the wrapped provider and writes each
+// normalized request with its response, usage or error to a JSON cassette file. In replay
+// mode it serves those interactions without contacting the provider, and fails requests that
+// match no recorded interaction. Secrets such as API keys are redacted before anything is
+// written.
+//
+//	recorder, err := cassette.New(provider, "testdata/weather.json", cassette.Options{
+//		Mode: cassette.ModeAuto,
+//	})
+//	response, err := recorder.Chat(request)
+package cassette
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"simpleai"
+	"strings"
+	"time"
+)
+
+// FormatVersion is the version written to new cassette files
+const FormatVersion = 1
+
+// Cassette is the content of a cassette file
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and its outcome
+type Interaction struct {
+	Request  Request   `json:"request"`
+	Response *Response `json:"response,omitempty"`
+	Error    *Error    `json:"error,omitempty"`
+}
+
+// Request is a normalized chat request
+type Request struct {
+	Provider     string             `json:"provider"`
+	SystemPrompt string             `json:"system_prompt,omitempty"`
+	Messages     []simpleai.Message `json:"messages"`
+	Target       string             `json:"target,omitempty"` // Go type of the structured output target, if any
+}
+
+// Response is a recorded chat response. Structured data is not stored; it is decoded again
+// from the message on replay.
+type Response struct {
+	Message string          `json:"message"`
+	Chunks  []string        `json:"chunks,omitempty"` // Streamed deltas, if the response was streamed
+	Usage   *simpleai.Usage `json:"usage,omitempty"`
+
+	ToolCalls []simpleai.ToolCall `json:"tool_calls,omitempty"`
+}
+
+// Error is a recorded error. LLMErrors keep their type and details, so errors.Is and
+// IsRetryable behave the same on replay.
+type Error struct {
+	Type       string `json:"type,omitempty"` // Name of the simpleai error type, e.g. "rate_limit_exceeded"
+	Message    string `json:"message"`
+	Operation  string `json:"operation,omitempty"`
+	Retryable  bool   `json:"retryable"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Provider   string `json:"provider,omitempty"`
+	Model      string `json:"model,omitempty"`
+	RetryAfter string `json:"retry_after,omitempty"`
+}
+
+// Load reads a cassette file
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	if cassette.Version > FormatVersion {
+		return nil, fmt.Errorf("cassette %s has unsupported version %d", path, cassette.Version)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to path, creating parent directories as needed. The file is
+// replaced atomically so an interrupted test never leaves a truncated cassette.
+func (c *Cassette) Save(path string) error {
+	c.Version = FormatVersion
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// normalizeRequest converts a chat request to its recorded form
+func normalizeRequest(provider string, request simpleai.ChatRequest) Request {
+	normalized := Request{
+		Provider:     provider,
+		SystemPrompt: strings.TrimSpace(request.SystemPrompt.Content),
+		Messages:     make([]simpleai.Message, len(request.Messages)),
+	}
+	for i, message := range request.Messages {
+		normalized.Messages[i] = simpleai.Message{
+			Role:       strings.ToLower(strings.TrimSpace(message.Role)),
+			Content:    strings.TrimSpace(message.Content),
+			ToolCalls:  message.ToolCalls,
+			ToolCallID: message.ToolCallID,
+		}
+	}
+	if request.T != nil {
+		normalized.Target = reflect.TypeOf(request.T).String()
+	}
+	return normalized
+}
+
+// recordError converts an error to its recorded form
+func recordError(err error) *Error {
+	var llmErr *simpleai.LLMError
+	if !errors.As(err, &llmErr) {
+		return &Error{Message: err.Error()}
+	}
+
+	recorded := &Error{
+		Message:    llmErr.Message,
+		Operation:  llmErr.Operation,
+		Retryable:  llmErr.Retryable,
+		StatusCode: llmErr.StatusCode,
+		Provider:   llmErr.Provider,
+		Model:      llmErr.Model,
+	}
+	recorded.Type = simpleai.ErrorName(llmErr.Type)
+	if recorded.Type == "" && llmErr.Type != nil {
+		// Unknown error types keep their text so the message is not lost
+		recorded.Message = llmErr.Type.Error() + ": " + recorded.Message
+	}
+	if llmErr.RetryAfter > 0 {
+		recorded.RetryAfter = llmErr.RetryAfter.String()
+	}
+	return recorded
+}
+
+// replayError rebuilds a recorded error
+func replayError(recorded *Error) error {
+	errType, ok := simpleai.ErrorByName(recorded.Type)
+	if !ok {
+		return errors.New(recorded.Message)
+	}
+
+	llmErr := simpleai.NewLLMError(errType, recorded.Message, recorded.Operation, recorded.Retryable, 0, nil).
+		WithStatusCode(recorded.StatusCode).
+		WithProvider(recorded.Provider, recorded.Model)
+	if delay, err := time.ParseDuration(recorded.RetryAfter); err == nil {
+		llmErr.WithRetryAfter(delay)
+	}
+	return llmErr
+}
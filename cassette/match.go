@@ -0,0 +1,52 @@
+package cassette
+
+import "reflect"
+
+// Matcher reports whether a recorded request matches an incoming one. Both requests are
+// normalized and redacted before they are compared.
+type Matcher func(recorded, actual Request) bool
+
+// MatchProvider matches requests sent to the same provider
+func MatchProvider(recorded, actual Request) bool {
+	return recorded.Provider == actual.Provider
+}
+
+// MatchSystemPrompt matches requests with the same system prompt
+func MatchSystemPrompt(recorded, actual Request) bool {
+	return recorded.SystemPrompt == actual.SystemPrompt
+}
+
+// MatchMessages matches requests with the same messages
+func MatchMessages(recorded, actual Request) bool {
+	return reflect.DeepEqual(recorded.Messages, actual.Messages)
+}
+
+// MatchLastMessage matches requests whose final message is the same, ignoring earlier
+// conversation history
+func MatchLastMessage(recorded, actual Request) bool {
+	if len(recorded.Messages) == 0 || len(actual.Messages) == 0 {
+		return len(recorded.Messages) == len(actual.Messages)
+	}
+	return reflect.DeepEqual(recorded.Messages[len(recorded.Messages)-1], actual.Messages[len(actual.Messages)-1])
+}
+
+// MatchTarget matches requests with the same structured output type
+func MatchTarget(recorded, actual Request) bool {
+	return recorded.Target == actual.Target
+}
+
+// DefaultMatchers returns the matchers used when Options.Matchers is empty: provider, system
+// prompt, messages and structured output type must all be equal
+func DefaultMatchers() []Matcher {
+	return []Matcher{MatchProvider, MatchSystemPrompt, MatchMessages, MatchTarget}
+}
+
+// matches reports whether all matchers accept the pair
+func matches(matchers []Matcher, recorded, actual Request) bool {
+	for _, matcher := range matchers {
+		if !matcher(recorded, actual) {
+			return false
+		}
+	}
+	return true
+}
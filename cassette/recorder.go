@@ -0,0 +1,383 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"simpleai"
+	"sync"
+)
+
+// Mode selects whether a Recorder records or replays
+type Mode int
+
+const (
+	// ModeReplay serves recorded interactions and never contacts the provider
+	ModeReplay Mode = iota
+	// ModeRecord calls the provider and records every interaction, replacing the cassette
+	ModeRecord
+	// ModeAuto replays if the cassette file exists and records otherwise
+	ModeAuto
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeRecord:
+		return "record"
+	case ModeAuto:
+		return "auto"
+	default:
+		return "replay"
+	}
+}
+
+// ErrNoInteraction is the cause of the error returned when replaying a request that matches
+// no recorded interaction
+var ErrNoInteraction = errors.New("cassette: no recorded interaction matches request")
+
+// Options configure a Recorder
+type Options struct {
+	Mode     Mode      // Record or replay (ModeReplay by default)
+	Matchers []Matcher // How requests are matched on replay (DefaultMatchers if empty)
+
+	// AllowRepeats lets a recorded interaction answer more than one request. By default each
+	// interaction is used once, in recorded order, so repeated identical requests get their
+	// own recorded responses.
+	AllowRepeats bool
+
+	Secrets        []string         // Values, such as API keys, replaced with [REDACTED] in recordings
+	RedactPatterns []*regexp.Regexp // Extra patterns to redact, in addition to common key formats
+}
+
+// Recorder wraps a provider and records or replays its chat interactions
+type Recorder struct {
+	provider simpleai.Provider
+	path     string
+	mode     Mode
+	options  Options
+	redactor *redactor
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// New wraps provider with a recorder backed by the cassette file at path. In replay mode the
+// file must exist. provider may be nil in replay mode, in which case the recorder reports
+// the provider name found in the cassette.
+func New(provider simpleai.Provider, path string, options Options) (*Recorder, error) {
+	mode := resolveMode(options.Mode, path)
+	if len(options.Matchers) == 0 {
+		options.Matchers = DefaultMatchers()
+	}
+
+	r := &Recorder{
+		provider: provider,
+		path:     path,
+		mode:     mode,
+		options:  options,
+		redactor: newRedactor(options.Secrets, options.RedactPatterns),
+	}
+
+	if mode == ModeReplay {
+		cassette, err := Load(path)
+		if err != nil {
+			return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+				fmt.Sprintf("cannot load cassette %s", path), "cassette_load", false, 0, err)
+		}
+		r.cassette = cassette
+		r.used = make([]bool, len(cassette.Interactions))
+		return r, nil
+	}
+
+	if provider == nil {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			"a provider is required to record", "cassette_load", false, 0, nil)
+	}
+	r.cassette = &Cassette{Version: FormatVersion}
+	return r, nil
+}
+
+// resolveMode resolves ModeAuto to replay if the cassette at path exists and to record
+// otherwise
+func resolveMode(mode Mode, path string) Mode {
+	if mode != ModeAuto {
+		return mode
+	}
+	if _, err := os.Stat(path); err == nil {
+		return ModeReplay
+	}
+	return ModeRecord
+}
+
+// Constructor wraps a provider constructor so every provider it creates is recorded to or
+// replayed from the cassette at path. The config's api_key is redacted automatically. The
+// wrapped constructor is only called when recording, so replaying needs no credentials.
+func Constructor(constructor simpleai.ProviderConstructor, path string, options Options) simpleai.ProviderConstructor {
+	return func(config map[string]interface{}) (simpleai.Provider, error) {
+		options := options
+		options.Mode = resolveMode(options.Mode, path)
+		if key, ok := config["api_key"].(string); ok && key != "" {
+			options.Secrets = append(append([]string(nil), options.Secrets...), key)
+		}
+
+		var provider simpleai.Provider
+		if options.Mode == ModeRecord {
+			var err error
+			if provider, err = constructor(config); err != nil {
+				return nil, err
+			}
+		}
+		return New(provider, path, options)
+	}
+}
+
+// Mode returns the mode the recorder is running in; ModeAuto resolves to record or replay
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Interactions returns the interactions recorded or loaded so far
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.cassette.Interactions...)
+}
+
+// Unused returns the recorded interactions no request has replayed yet, which usually means
+// the code under test changed
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Interaction
+	for i, interaction := range r.cassette.Interactions {
+		if i < len(r.used) && !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// Chat records or replays a chat request
+func (r *Recorder) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return r.ChatContext(context.Background(), request)
+}
+
+// ChatContext records or replays a chat request, passing ctx to the provider when recording
+func (r *Recorder) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	if r.mode == ModeReplay {
+		interaction, err := r.replay(request)
+		if err != nil {
+			return simpleai.ChatResponse{}, err
+		}
+		return r.respond(request, interaction)
+	}
+
+	response, err := simpleai.ChatContext(ctx, r.provider, request)
+	interaction := Interaction{Request: normalizeRequest(r.provider.Name(), request)}
+	if err != nil {
+		interaction.Error = recordError(err)
+	} else {
+		interaction.Response = &Response{Message: response.Message, Usage: response.Usage, ToolCalls: response.ToolCalls}
+	}
+	if saveErr := r.record(interaction); saveErr != nil {
+		return response, saveErr
+	}
+	return response, err
+}
+
+// ChatStream records or replays a streamed chat request. Recorded chunks are replayed as
+// they were received; interactions recorded through Chat replay as a single chunk.
+func (r *Recorder) ChatStream(ctx context.Context, request simpleai.ChatRequest, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	if r.mode == ModeReplay {
+		interaction, err := r.replay(request)
+		if err != nil {
+			return simpleai.ChatResponse{}, err
+		}
+		if interaction.Response != nil {
+			if err := replayChunks(ctx, interaction.Response, handler); err != nil {
+				return simpleai.ChatResponse{}, err
+			}
+		}
+		return r.respond(request, interaction)
+	}
+
+	var chunks []string
+	record := func(chunk simpleai.StreamChunk) error {
+		if chunk.Delta != "" {
+			chunks = append(chunks, chunk.Delta)
+		}
+		return handler(chunk)
+	}
+
+	var response simpleai.ChatResponse
+	var err error
+	if streaming, ok := r.provider.(simpleai.StreamingProvider); ok {
+		response, err = streaming.ChatStream(ctx, request, record)
+	} else if response, err = simpleai.ChatContext(ctx, r.provider, request); err == nil {
+		err = record(simpleai.StreamChunk{Delta: response.Message, Text: response.Message, Done: true})
+	}
+
+	interaction := Interaction{Request: normalizeRequest(r.provider.Name(), request)}
+	if err != nil {
+		interaction.Error = recordError(err)
+	} else {
+		interaction.Response = &Response{Message: response.Message, Chunks: chunks, Usage: response.Usage, ToolCalls: response.ToolCalls}
+	}
+	if saveErr := r.record(interaction); saveErr != nil {
+		return response, saveErr
+	}
+	return response, err
+}
+
+// ListModels passes through to the provider, or returns no models on replay
+func (r *Recorder) ListModels() ([]simpleai.Model, error) {
+	if r.mode == ModeReplay {
+		return nil, nil
+	}
+	return r.provider.ListModels()
+}
+
+// Name returns the provider's name, or the name recorded in the cassette on replay
+func (r *Recorder) Name() string {
+	if r.provider != nil {
+		return r.provider.Name()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.cassette.Interactions) > 0 {
+		return r.cassette.Interactions[0].Request.Provider
+	}
+	return "cassette"
+}
+
+// IsAvailable is always true on replay
+func (r *Recorder) IsAvailable() bool {
+	if r.mode == ModeReplay {
+		return true
+	}
+	return r.provider.IsAvailable()
+}
+
+// SupportedFeatures returns the provider's features, or streaming and structured output on
+// replay without a provider
+func (r *Recorder) SupportedFeatures() simpleai.ProviderFeatures {
+	if r.provider != nil {
+		return r.provider.SupportedFeatures()
+	}
+	return simpleai.ProviderFeatures{
+		StructuredOutput: true,
+		Streaming:        true,
+		SupportedRoles:   []string{"system", "user", "assistant"},
+	}
+}
+
+// Unwrap returns the wrapped provider, which is nil when replaying without one
+func (r *Recorder) Unwrap() simpleai.Provider {
+	return r.provider
+}
+
+// record appends an interaction and saves the cassette, so recordings survive a test that
+// fails or panics midway
+func (r *Recorder) record(interaction Interaction) error {
+	interaction = r.redactor.interaction(interaction)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if err := r.cassette.Save(r.path); err != nil {
+		return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+			fmt.Sprintf("cannot save cassette %s", r.path), "cassette_save", false, 0, err)
+	}
+	return nil
+}
+
+// replay finds the first unused interaction matching request
+func (r *Recorder) replay(request simpleai.ChatRequest) (Interaction, error) {
+	name := r.Name()
+	actual := r.redactor.request(normalizeRequest(name, request))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repeat := -1
+	for i, interaction := range r.cassette.Interactions {
+		if !matches(r.options.Matchers, interaction.Request, actual) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return interaction, nil
+		}
+		if repeat == -1 {
+			repeat = i
+		}
+	}
+	if r.options.AllowRepeats && repeat != -1 {
+		return r.cassette.Interactions[repeat], nil
+	}
+
+	message := "unmatched request"
+	if n := len(actual.Messages); n > 0 {
+		message = fmt.Sprintf("unmatched request with last message %q", preview(actual.Messages[n-1].Content))
+	}
+	return Interaction{}, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+		message, "cassette_replay", false, 0, ErrNoInteraction).WithProvider(name, "")
+}
+
+// respond turns a replayed interaction into the provider's result
+func (r *Recorder) respond(request simpleai.ChatRequest, interaction Interaction) (simpleai.ChatResponse, error) {
+	if interaction.Error != nil {
+		return simpleai.ChatResponse{}, replayError(interaction.Error)
+	}
+	if interaction.Response == nil {
+		return simpleai.ChatResponse{}, nil
+	}
+
+	response := simpleai.ChatResponse{
+		Message:   interaction.Response.Message,
+		Usage:     interaction.Response.Usage,
+		ToolCalls: interaction.Response.ToolCalls,
+	}
+	if len(response.ToolCalls) > 0 {
+		// The model asked for tools instead of answering, so there is nothing to decode
+		return response, nil
+	}
+	data, err := simpleai.ParseStructuredOutput(response.Message, request.T, request.Extractor, 0)
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+	response.Data = data
+	return response, nil
+}
+
+// replayChunks streams a recorded response to handler
+func replayChunks(ctx context.Context, response *Response, handler simpleai.StreamHandler) error {
+	chunks := response.Chunks
+	if len(chunks) == 0 {
+		chunks = []string{response.Message}
+	}
+
+	text := ""
+	for i, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		text += chunk
+		if err := handler(simpleai.StreamChunk{Delta: chunk, Text: text, Done: i == len(chunks)-1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preview truncates long text for error messages
+func preview(text string) string {
+	if len(text) > 80 {
+		return text[:80] + "..."
+	}
+	return text
+}
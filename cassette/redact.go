@@ -0,0 +1,71 @@
+package cassette
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secrets in recordings
+const Redacted = "[REDACTED]"
+
+// secretPatterns match common credential formats that may show up in error messages or
+// echoed prompts
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`AIza[0-9A-Za-z_\-]{35}`),                          // Google API keys
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}`),                        // OpenAI-style secret keys
+	regexp.MustCompile(`(?i)\bBearer\s+[A-Za-z0-9._~+/\-]+=*`),            // Authorization headers
+	regexp.MustCompile(`(?i)([?&](?:key|api_key|apikey|token)=)[^&\s"]+`), // Keys in URLs
+}
+
+// redactor removes secret values and known credential formats from text
+type redactor struct {
+	secrets  []string
+	patterns []*regexp.Regexp
+}
+
+func newRedactor(secrets []string, patterns []*regexp.Regexp) *redactor {
+	r := &redactor{patterns: append(append([]*regexp.Regexp(nil), secretPatterns...), patterns...)}
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	return r
+}
+
+func (r *redactor) redact(text string) string {
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, Redacted)
+	}
+	for _, pattern := range r.patterns {
+		if pattern.NumSubexp() > 0 {
+			// Keep the prefix (such as "?key=") and redact what follows it
+			text = pattern.ReplaceAllString(text, "${1}"+Redacted)
+		} else {
+			text = pattern.ReplaceAllString(text, Redacted)
+		}
+	}
+	return text
+}
+
+func (r *redactor) request(request Request) Request {
+	request.SystemPrompt = r.redact(request.SystemPrompt)
+	for i := range request.Messages {
+		request.Messages[i].Content = r.redact(request.Messages[i].Content)
+	}
+	return request
+}
+
+func (r *redactor) interaction(interaction Interaction) Interaction {
+	interaction.Request = r.request(interaction.Request)
+	if response := interaction.Response; response != nil {
+		response.Message = r.redact(response.Message)
+		for i := range response.Chunks {
+			response.Chunks[i] = r.redact(response.Chunks[i])
+		}
+	}
+	if recorded := interaction.Error; recorded != nil {
+		recorded.Message = r.redact(recorded.Message)
+	}
+	return interaction
+}
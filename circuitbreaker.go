@@ -0,0 +1,316 @@
+package simpleai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets all calls through and counts failures
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all calls until the cool-down elapses
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe calls through to test recovery
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitBreakerConfig holds circuit breaker configuration
+type CircuitBreakerConfig struct {
+	FailureThreshold int                                      // Consecutive failures that open the circuit
+	CoolDown         time.Duration                            // How long the circuit stays open before probing
+	HalfOpenMaxCalls int                                      // Concurrent probe calls allowed while half-open
+	SuccessThreshold int                                      // Successful probes needed to close the circuit
+	IsFailure        func(err error) bool                     // Which errors count as failures (default: retryable errors)
+	OnStateChange    func(name string, from, to CircuitState) // Called after every state transition
+}
+
+// DefaultCircuitBreakerConfig returns default circuit breaker configuration
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 5,
+		CoolDown:         30 * time.Second,
+		HalfOpenMaxCalls: 1,
+		SuccessThreshold: 1,
+	}
+}
+
+// CircuitBreaker stops calling a failing provider until it has had time to recover
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	name     string
+	config   CircuitBreakerConfig
+	state    CircuitState
+	failures int
+	probes   int // Probe calls in flight while half-open
+	recovery int // Successful probes since entering half-open
+	epoch    int // Incremented on every transition, so results of calls from an earlier state are ignored
+	openedAt time.Time
+	now      func() time.Time
+}
+
+// NewCircuitBreaker creates a new circuit breaker; zero config values fall back to the defaults
+func NewCircuitBreaker(name string, config CircuitBreakerConfig) *CircuitBreaker {
+	defaults := DefaultCircuitBreakerConfig()
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.CoolDown <= 0 {
+		config.CoolDown = defaults.CoolDown
+	}
+	if config.HalfOpenMaxCalls <= 0 {
+		config.HalfOpenMaxCalls = defaults.HalfOpenMaxCalls
+	}
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = defaults.SuccessThreshold
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = IsRetryable
+	}
+
+	return &CircuitBreaker{
+		name:   name,
+		config: config,
+		state:  CircuitClosed,
+		now:    time.Now,
+	}
+}
+
+// Name returns the name of the provider the breaker guards
+func (cb *CircuitBreaker) Name() string {
+	return cb.name
+}
+
+// State returns the current state, moving an open circuit to half-open once the cool-down has elapsed
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	state, notify := cb.currentStateLocked()
+	cb.mu.Unlock()
+	notify()
+	return state
+}
+
+// Call runs fn if the circuit allows it and records the outcome. Calls rejected by an
+// open circuit fail immediately with ErrConnectionFailed.
+func (cb *CircuitBreaker) Call(operation string, fn func() error) error {
+	epoch, err := cb.allow(operation)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	cb.record(epoch, err)
+	return err
+}
+
+// Reset closes the circuit and clears all counters
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	notify := cb.transitionLocked(CircuitClosed)
+	cb.mu.Unlock()
+	notify()
+}
+
+// allow reserves a slot for a call or returns an error if the circuit rejects it. It
+// returns the epoch the call belongs to.
+func (cb *CircuitBreaker) allow(operation string) (int, error) {
+	cb.mu.Lock()
+	state, notify := cb.currentStateLocked()
+
+	var err error
+	switch state {
+	case CircuitOpen:
+		err = cb.openError(operation)
+	case CircuitHalfOpen:
+		if cb.probes >= cb.config.HalfOpenMaxCalls {
+			err = cb.openError(operation)
+		} else {
+			cb.probes++
+		}
+	}
+	epoch := cb.epoch
+	cb.mu.Unlock()
+
+	notify()
+	return epoch, err
+}
+
+// record updates the breaker with the outcome of a call allowed in epoch. Outcomes of
+// calls allowed before the last transition, such as probes still running when the breaker
+// was reset, no longer describe the current state and are ignored.
+func (cb *CircuitBreaker) record(epoch int, err error) {
+	failed := err != nil && cb.config.IsFailure(err)
+
+	cb.mu.Lock()
+	if epoch != cb.epoch {
+		cb.mu.Unlock()
+		return
+	}
+	notify := func() {}
+	switch cb.state {
+	case CircuitClosed:
+		if failed {
+			cb.failures++
+			if cb.failures >= cb.config.FailureThreshold {
+				notify = cb.transitionLocked(CircuitOpen)
+			}
+		} else {
+			cb.failures = 0
+		}
+	case CircuitHalfOpen:
+		cb.probes--
+		if failed {
+			notify = cb.transitionLocked(CircuitOpen)
+		} else {
+			cb.recovery++
+			if cb.recovery >= cb.config.SuccessThreshold {
+				notify = cb.transitionLocked(CircuitClosed)
+			}
+		}
+	}
+	cb.mu.Unlock()
+
+	notify()
+}
+
+// currentStateLocked returns the state, applying the open to half-open transition when due
+func (cb *CircuitBreaker) currentStateLocked() (CircuitState, func()) {
+	if cb.state == CircuitOpen && cb.now().Sub(cb.openedAt) >= cb.config.CoolDown {
+		notify := cb.transitionLocked(CircuitHalfOpen)
+		return cb.state, notify
+	}
+	return cb.state, func() {}
+}
+
+// transitionLocked moves to a new state and returns a function that fires the state change callback.
+// The callback is invoked outside the lock so it may safely inspect the breaker.
+func (cb *CircuitBreaker) transitionLocked(to CircuitState) func() {
+	from := cb.state
+	cb.state = to
+	cb.failures = 0
+	cb.probes = 0
+	cb.recovery = 0
+	cb.epoch++
+	if to == CircuitOpen {
+		cb.openedAt = cb.now()
+	}
+
+	if from == to || cb.config.OnStateChange == nil {
+		return func() {}
+	}
+	callback, name := cb.config.OnStateChange, cb.name
+	return func() {
+		callback(name, from, to)
+	}
+}
+
+// openError builds the error returned for calls rejected by the breaker
+func (cb *CircuitBreaker) openError(operation string) *LLMError {
+	return NewLLMError(ErrConnectionFailed,
+		fmt.Sprintf("circuit breaker open for provider %s", cb.name),
+		operation, false, 0, nil)
+}
+
+// CircuitBreakerProvider wraps a Provider so calls go through a circuit breaker
+type CircuitBreakerProvider struct {
+	provider Provider
+	breaker  *CircuitBreaker
+}
+
+// NewCircuitBreakerProvider wraps provider with the given circuit breaker
+func NewCircuitBreakerProvider(provider Provider, breaker *CircuitBreaker) *CircuitBreakerProvider {
+	return &CircuitBreakerProvider{
+		provider: provider,
+		breaker:  breaker,
+	}
+}
+
+// Chat sends a chat request through the circuit breaker
+func (p *CircuitBreakerProvider) Chat(request ChatRequest) (ChatResponse, error) {
+	var response ChatResponse
+	err := p.breaker.Call("chat", func() error {
+		var err error
+		response, err = p.provider.Chat(request)
+		return err
+	})
+	return response, err
+}
+
+// ChatContext sends a cancellable chat request through the circuit breaker
+func (p *CircuitBreakerProvider) ChatContext(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	var response ChatResponse
+	err := p.breaker.Call("chat", func() error {
+		var err error
+		response, err = ChatContext(ctx, p.provider, request)
+		return err
+	})
+	return response, err
+}
+
+// ChatStream streams a chat response through the circuit breaker. Providers that cannot
+// stream deliver the whole response as a single chunk.
+func (p *CircuitBreakerProvider) ChatStream(ctx context.Context, request ChatRequest, handler StreamHandler) (ChatResponse, error) {
+	var response ChatResponse
+	err := p.breaker.Call("chat_stream", func() error {
+		var err error
+		response, err = chatStream(ctx, p.provider, request, handler)
+		return err
+	})
+	return response, err
+}
+
+// ListModels lists models through the circuit breaker
+func (p *CircuitBreakerProvider) ListModels() ([]Model, error) {
+	var models []Model
+	err := p.breaker.Call("list_models", func() error {
+		var err error
+		models, err = p.provider.ListModels()
+		return err
+	})
+	return models, err
+}
+
+// Name returns the wrapped provider's name
+func (p *CircuitBreakerProvider) Name() string {
+	return p.provider.Name()
+}
+
+// IsAvailable reports false while the circuit is open without contacting the provider
+func (p *CircuitBreakerProvider) IsAvailable() bool {
+	if p.breaker.State() == CircuitOpen {
+		return false
+	}
+	return p.provider.IsAvailable()
+}
+
+// SupportedFeatures returns the wrapped provider's features
+func (p *CircuitBreakerProvider) SupportedFeatures() ProviderFeatures {
+	return p.provider.SupportedFeatures()
+}
+
+// Breaker returns the circuit breaker guarding the provider
+func (p *CircuitBreakerProvider) Breaker() *CircuitBreaker {
+	return p.breaker
+}
+
+// Unwrap returns the wrapped provider
+func (p *CircuitBreakerProvider) Unwrap() Provider {
+	return p.provider
+}
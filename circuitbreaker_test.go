@@ -0,0 +1,194 @@
+package simpleai
+
+import (
+	"testing"
+	"time"
+)
+
+// stubProvider is a minimal Provider whose Chat result is controlled by the test
+type stubProvider struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (p *stubProvider) Chat(request ChatRequest) (ChatResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return ChatResponse{}, p.err
+	}
+	return ChatResponse{Message: "hello from " + p.name}, nil
+}
+
+func (p *stubProvider) ListModels() ([]Model, error) {
+	return []Model{{Name: "stub"}}, nil
+}
+
+func (p *stubProvider) Name() string {
+	return p.name
+}
+
+func (p *stubProvider) IsAvailable() bool {
+	return p.err == nil
+}
+
+func (p *stubProvider) SupportedFeatures() ProviderFeatures {
+	return ProviderFeatures{SupportedRoles: []string{"system", "user", "assistant"}}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	now := time.Now()
+	var transitions []CircuitState
+
+	breaker := NewCircuitBreaker("stub", CircuitBreakerConfig{
+		FailureThreshold: 2,
+		CoolDown:         time.Minute,
+		OnStateChange: func(name string, from, to CircuitState) {
+			transitions = append(transitions, to)
+		},
+	})
+	breaker.now = func() time.Time { return now }
+
+	inner := &stubProvider{name: "stub", err: NewLLMError(ErrConnectionFailed, "refused", "chat", true, 0, nil)}
+	provider := NewCircuitBreakerProvider(inner, breaker)
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Chat(ChatRequest{}); err == nil {
+			t.Fatal("Expected error from failing provider")
+		}
+	}
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected circuit to be open, got %s", breaker.State())
+	}
+
+	// Calls to an open circuit fail without reaching the provider
+	_, err := provider.Chat(ChatRequest{})
+	llmErr, ok := err.(*LLMError)
+	if !ok || llmErr.Type != ErrConnectionFailed {
+		t.Fatalf("Expected ErrConnectionFailed from open circuit, got %v", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("Expected provider to be called 2 times, got %d", inner.calls)
+	}
+
+	// After the cool-down a successful probe closes the circuit
+	now = now.Add(time.Minute)
+	inner.err = nil
+	if _, err := provider.Chat(ChatRequest{}); err != nil {
+		t.Fatalf("Expected probe to succeed, got %v", err)
+	}
+	if breaker.State() != CircuitClosed {
+		t.Errorf("Expected circuit to be closed, got %s", breaker.State())
+	}
+
+	expected := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected transitions %v, got %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("Expected transition %d to be %s, got %s", i, expected[i], transitions[i])
+		}
+	}
+}
+
+func TestCircuitBreakerFailedProbeReopens(t *testing.T) {
+	now := time.Now()
+	breaker := NewCircuitBreaker("stub", CircuitBreakerConfig{FailureThreshold: 1, CoolDown: time.Second})
+	breaker.now = func() time.Time { return now }
+
+	failure := NewLLMError(ErrTimeout, "timed out", "chat", true, 0, nil)
+	_ = breaker.Call("chat", func() error { return failure })
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected circuit to be open, got %s", breaker.State())
+	}
+
+	now = now.Add(time.Second)
+	_ = breaker.Call("chat", func() error { return failure })
+	if breaker.State() != CircuitOpen {
+		t.Errorf("Expected failed probe to reopen circuit, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreakerIgnoresStaleProbe(t *testing.T) {
+	now := time.Now()
+	breaker := NewCircuitBreaker("stub", CircuitBreakerConfig{FailureThreshold: 1, CoolDown: time.Second})
+	breaker.now = func() time.Time { return now }
+	failure := NewLLMError(ErrTimeout, "timed out", "chat", true, 0, nil)
+
+	_ = breaker.Call("chat", func() error { return failure })
+	now = now.Add(time.Second)
+
+	// While this probe runs, the breaker is reset, opens again and is half-open once more
+	_ = breaker.Call("chat", func() error {
+		breaker.Reset()
+		_ = breaker.Call("chat", func() error { return failure })
+		now = now.Add(time.Second)
+		if breaker.State() != CircuitHalfOpen {
+			t.Fatalf("Expected circuit to be half-open, got %s", breaker.State())
+		}
+		return nil
+	})
+	if breaker.State() != CircuitHalfOpen {
+		t.Fatalf("Expected the stale probe to be ignored, got %s", breaker.State())
+	}
+
+	var second error
+	_ = breaker.Call("chat", func() error {
+		second = breaker.Call("chat", func() error { return nil })
+		return nil
+	})
+	if second == nil {
+		t.Error("Expected a second concurrent probe to be rejected")
+	}
+}
+
+func TestCircuitBreakerIgnoresNonRetryableErrors(t *testing.T) {
+	breaker := NewCircuitBreaker("stub", CircuitBreakerConfig{FailureThreshold: 1})
+
+	_ = breaker.Call("chat", func() error {
+		return NewLLMError(ErrInvalidConfig, "bad request", "chat", false, 0, nil)
+	})
+	if breaker.State() != CircuitClosed {
+		t.Errorf("Expected non-retryable error to leave circuit closed, got %s", breaker.State())
+	}
+}
+
+func TestChatWithFallbackSkipsOpenCircuit(t *testing.T) {
+	primary := &stubProvider{name: "primary", err: NewLLMError(ErrConnectionFailed, "refused", "chat", true, 0, nil)}
+	secondary := &stubProvider{name: "secondary"}
+
+	factory := NewLLMFactory()
+	factory.RegisterProvider("primary", func(config map[string]interface{}) (Provider, error) { return primary, nil })
+	factory.RegisterProvider("secondary", func(config map[string]interface{}) (Provider, error) { return secondary, nil })
+	factory.EnableCircuitBreakers(CircuitBreakerConfig{FailureThreshold: 1, CoolDown: time.Minute})
+
+	err := factory.LoadConfig(FactoryConfig{
+		DefaultProvider: "primary",
+		Providers: map[string]ProviderConfig{
+			"primary":   {DefaultModel: "stub", Timeout: 1},
+			"secondary": {DefaultModel: "stub", Timeout: 1},
+		},
+		FallbackProviders: []string{"secondary"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		response, err := factory.ChatWithFallback(ChatRequest{})
+		if err != nil {
+			t.Fatalf("Expected fallback to succeed, got %v", err)
+		}
+		if response.Message != "hello from secondary" {
+			t.Errorf("Expected response from secondary, got %q", response.Message)
+		}
+	}
+
+	if primary.calls != 1 {
+		t.Errorf("Expected open circuit to stop calls to primary after 1 failure, got %d calls", primary.calls)
+	}
+	if state := factory.CircuitBreaker("primary").State(); state != CircuitOpen {
+		t.Errorf("Expected primary circuit to be open, got %s", state)
+	}
+}
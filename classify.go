@@ -0,0 +1,87 @@
+package simpleai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// ClassifyStatusCode maps an HTTP status code returned by a provider API to an error type
+// and whether the request is worth retrying
+func ClassifyStatusCode(statusCode int) (errType error, retryable bool) {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrInvalidConfig, false
+	case statusCode == http.StatusNotFound:
+		return ErrModelNotAvailable, false
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		return ErrTimeout, true
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimitExceeded, true
+	case statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable:
+		return ErrConnectionFailed, true
+	case statusCode >= 500:
+		return ErrOperationFailed, true
+	default:
+		return ErrOperationFailed, false
+	}
+}
+
+// NewStatusError creates an LLMError classified by the HTTP status code a provider returned
+func NewStatusError(statusCode int, message, operation string, retryCount int, cause error) *LLMError {
+	errType, retryable := ClassifyStatusCode(statusCode)
+	return NewLLMError(errType, message, operation, retryable, retryCount, cause).WithStatusCode(statusCode)
+}
+
+// ClassifyTransportError maps network and context errors to an error type and whether the
+// request is worth retrying. ok is false when err is not a recognized transport error.
+func ClassifyTransportError(err error) (errType error, retryable bool, ok bool) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout, true, true
+	case errors.Is(err, context.Canceled):
+		// The caller gave up; retrying would ignore their cancellation
+		return ErrOperationFailed, false, true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout, true, true
+	}
+
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr),
+		errors.As(err, &opErr),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrConnectionFailed, true, true
+	}
+
+	return nil, false, false
+}
+
+// NewTransportError creates an LLMError for a failure to reach service, such as
+// "OpenAI API", classified by ClassifyTransportError. ok is false when err is not a
+// recognized transport error, leaving its classification to the caller.
+func NewTransportError(err error, service, operation string, retryCount int) (llmErr *LLMError, ok bool) {
+	errType, retryable, ok := ClassifyTransportError(err)
+	if !ok {
+		return nil, false
+	}
+
+	message := "connection to " + service + " failed"
+	if errType == ErrTimeout {
+		message = "request timed out"
+	} else if errType != ErrConnectionFailed {
+		message = "request cancelled"
+	}
+	return NewLLMError(errType, message, operation, retryable, retryCount, err), true
+}
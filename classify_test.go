@@ -0,0 +1,114 @@
+package simpleai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLLMErrorIsAndUnwrap(t *testing.T) {
+	cause := errors.New("dial tcp: connection refused")
+	err := fmt.Errorf("failed to create provider: %w",
+		NewLLMError(ErrTimeout, "request timed out", "chat", true, 2, cause))
+
+	if !errors.Is(err, ErrTimeout) {
+		t.Error("Expected errors.Is to match ErrTimeout through wrapping")
+	}
+	if errors.Is(err, ErrConnectionFailed) {
+		t.Error("Expected errors.Is not to match ErrConnectionFailed")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Expected errors.Is to reach the underlying cause")
+	}
+	if !IsRetryable(err) {
+		t.Error("Expected IsRetryable to see through wrapped errors")
+	}
+
+	var llmErr *LLMError
+	if !errors.As(err, &llmErr) || llmErr.RetryCount != 2 {
+		t.Errorf("Expected errors.As to find the LLMError, got %v", llmErr)
+	}
+}
+
+func TestErrorNames(t *testing.T) {
+	for _, errType := range []error{ErrConnectionFailed, ErrTimeout, ErrInvalidResponse, ErrJSONParseFailed,
+		ErrModelNotAvailable, ErrRateLimitExceeded, ErrInvalidConfig, ErrOperationFailed, ErrContentFiltered} {
+		name := ErrorName(errType)
+		if got, ok := ErrorByName(name); name == "" || !ok || got != errType {
+			t.Errorf("Expected %v to round trip through its name %q, got %v", errType, name, got)
+		}
+	}
+	if name := ErrorName(errors.New("other")); name != "" {
+		t.Errorf("Expected no name for other errors, got %q", name)
+	}
+	if _, ok := ErrorByName("unknown"); ok {
+		t.Error("Expected no error type for an unknown name")
+	}
+}
+
+func TestLLMErrorDetails(t *testing.T) {
+	err := NewStatusError(http.StatusTooManyRequests, "slow down", "chat", 1, nil).
+		WithProvider("ollama", "llama3.1:latest")
+
+	if err.Type != ErrRateLimitExceeded || !err.Retryable {
+		t.Errorf("Expected retryable ErrRateLimitExceeded, got %v (retryable: %v)", err.Type, err.Retryable)
+	}
+	for _, want := range []string{"provider: ollama", "model: llama3.1:latest", "status: 429"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error text to contain %q, got %q", want, err.Error())
+		}
+	}
+}
+
+func TestClassifyStatusCode(t *testing.T) {
+	tests := []struct {
+		status    int
+		errType   error
+		retryable bool
+	}{
+		{http.StatusUnauthorized, ErrInvalidConfig, false},
+		{http.StatusForbidden, ErrInvalidConfig, false},
+		{http.StatusNotFound, ErrModelNotAvailable, false},
+		{http.StatusBadRequest, ErrOperationFailed, false},
+		{http.StatusTooManyRequests, ErrRateLimitExceeded, true},
+		{http.StatusGatewayTimeout, ErrTimeout, true},
+		{http.StatusServiceUnavailable, ErrConnectionFailed, true},
+		{http.StatusInternalServerError, ErrOperationFailed, true},
+	}
+
+	for _, tt := range tests {
+		errType, retryable := ClassifyStatusCode(tt.status)
+		if errType != tt.errType || retryable != tt.retryable {
+			t.Errorf("Status %d: expected (%v, %v), got (%v, %v)", tt.status, tt.errType, tt.retryable, errType, retryable)
+		}
+	}
+}
+
+func TestClassifyTransportError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		errType   error
+		retryable bool
+	}{
+		{"deadline", fmt.Errorf("post: %w", context.DeadlineExceeded), ErrTimeout, true},
+		{"cancelled", context.Canceled, ErrOperationFailed, false},
+		{"dns", &net.DNSError{Err: "no such host", Name: "ollama.invalid"}, ErrConnectionFailed, true},
+		{"dial", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ErrConnectionFailed, true},
+	}
+
+	for _, tt := range tests {
+		errType, retryable, ok := ClassifyTransportError(tt.err)
+		if !ok || errType != tt.errType || retryable != tt.retryable {
+			t.Errorf("%s: expected (%v, %v), got (%v, %v, ok=%v)", tt.name, tt.errType, tt.retryable, errType, retryable, ok)
+		}
+	}
+
+	if _, _, ok := ClassifyTransportError(errors.New("something else")); ok {
+		t.Error("Expected unrecognized error not to be classified")
+	}
+}
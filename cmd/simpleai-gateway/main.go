@@ -0,0 +1,112 @@
+// Command simpleai-gateway serves the providers in a factory configuration over the OpenAI
+// Chat Completions API.
+//
+//	simpleai-gateway -config providers.yaml -profile prod -addr :8080 -api-keys key1,key2
+//
+// With -watch the config file is checked at that interval and reloaded when it changes.
+//
+// Clients set model to "provider/model", a provider name, or leave it empty for the default
+// provider and its fallbacks.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"simpleai"
+	"simpleai/config"
+	"simpleai/gateway"
+	"simpleai/providers/all"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	configPath := flag.String("config", "", "path to a JSON, YAML or TOML factory configuration (required)")
+	profile := flag.String("profile", os.Getenv("SIMPLEAI_PROFILE"), "config profile to apply")
+	addr := flag.String("addr", ":8080", "address to listen on")
+	apiKeys := flag.String("api-keys", os.Getenv("SIMPLEAI_GATEWAY_API_KEYS"), "comma-separated API keys clients must send; empty disables authentication")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "how long to wait for in-flight requests on shutdown")
+	watch := flag.Duration("watch", 0, "how often to check the config file for changes and reload it; 0 disables reloading")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if err := run(*configPath, *profile, *addr, *apiKeys, *shutdownTimeout, *watch, logger); err != nil {
+		logger.Error("gateway failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(configPath, profile, addr, apiKeys string, shutdownTimeout, watch time.Duration, logger *slog.Logger) error {
+	if configPath == "" {
+		return errors.New("-config is required")
+	}
+	factory := simpleai.NewLLMFactory()
+	all.Register(factory)
+
+	// Settings are checked against each provider's config struct, on reloads too
+	options := config.Options{Profile: profile, Validate: factory.ValidateConfig}
+	cfg, err := config.LoadWithOptions(configPath, options)
+	if err != nil {
+		return err
+	}
+	if err := factory.LoadConfig(cfg); err != nil {
+		return err
+	}
+
+	var keys []string
+	for _, key := range strings.Split(apiKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		logger.Warn("no API keys configured, authentication is disabled")
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           gateway.New(factory, gateway.Options{APIKeys: keys, Logger: logger}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if watch > 0 {
+		watcher := config.NewWatcher(configPath, factory, config.WatchOptions{
+			Options:  options,
+			Interval: watch,
+			OnReload: func(event config.ReloadEvent) {
+				if event.Err != nil {
+					logger.Error("config reload failed, keeping the previous config", "path", event.Path, "error", event.Err)
+				} else if len(event.Changed) > 0 {
+					logger.Info("config reloaded", "path", event.Path, "changed", event.Changed)
+				}
+			},
+		})
+		go watcher.Run(ctx)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", addr, "providers", len(cfg.Providers))
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"simpleai"
+	"simpleai/schema"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// chatOutput is the JSON output of chat
+type chatOutput struct {
+	Provider  string              `json:"provider"`
+	Model     string              `json:"model"`
+	Message   string              `json:"message"`
+	ToolCalls []simpleai.ToolCall `json:"tool_calls,omitempty"`
+	Usage     *simpleai.Usage     `json:"usage,omitempty"`
+}
+
+func (c *cli) chat(ctx context.Context, args []string) error {
+	fs := c.flags("chat")
+	providerName := fs.String("provider", "", "provider to use (default: the config's default provider)")
+	model := fs.String("model", "", "model to use (default: the provider's default model)")
+	system := fs.String("system", "", "system prompt")
+	stream := fs.Bool("stream", true, "print text output as it arrives")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	message, err := c.input(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	factory, err := c.factory()
+	if err != nil {
+		return err
+	}
+	provider, modelName, err := c.provider(factory, *providerName, *model)
+	if err != nil {
+		return err
+	}
+
+	request := simpleai.ChatRequest{
+		SystemPrompt: simpleai.SystemPrompt{Content: *system},
+		Messages:     []simpleai.Message{{Role: "user", Content: message}},
+	}
+
+	if c.output == "json" || !*stream {
+		response, err := simpleai.ChatContext(ctx, provider, request)
+		if err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return c.printJSON(chatOutput{
+				Provider:  provider.Name(),
+				Model:     modelName,
+				Message:   response.Message,
+				ToolCalls: response.ToolCalls,
+				Usage:     response.Usage,
+			})
+		}
+		fmt.Fprintln(c.stdout, response.Message)
+		return nil
+	}
+
+	_, err = simpleai.ChatStream(ctx, provider, request, func(chunk simpleai.StreamChunk) error {
+		_, err := fmt.Fprint(c.stdout, chunk.Delta)
+		return err
+	})
+	fmt.Fprintln(c.stdout)
+	return err
+}
+
+// providerOutput is the JSON output of providers
+type providerOutput struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	DefaultModel string `json:"default_model"`
+	Host         string `json:"host,omitempty"`
+	Default      bool   `json:"default"`
+	Fallback     int    `json:"fallback,omitempty"` // Position in the fallback order, from 1
+}
+
+func (c *cli) providers(ctx context.Context, args []string) error {
+	fs := c.flags("providers")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	config, err := c.readConfig()
+	if err != nil {
+		return err
+	}
+
+	fallback := make(map[string]int)
+	for i, name := range config.FallbackProviders {
+		if _, seen := fallback[name]; !seen {
+			fallback[name] = i + 1
+		}
+	}
+	var list []providerOutput
+	for _, name := range sortedProviders(config) {
+		providerConfig := config.Providers[name]
+		list = append(list, providerOutput{
+			Name:         name,
+			Type:         simpleai.ProviderType(name, providerConfig),
+			DefaultModel: providerConfig.DefaultModel,
+			Host:         providerConfig.Host,
+			Default:      name == config.DefaultProvider,
+			Fallback:     fallback[name],
+		})
+	}
+
+	if c.output == "json" {
+		return c.printJSON(list)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tDEFAULT MODEL\tHOST\tROLE")
+	for _, p := range list {
+		role := ""
+		switch {
+		case p.Default:
+			role = "default"
+		case p.Fallback > 0:
+			role = fmt.Sprintf("fallback %d", p.Fallback)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Name, p.Type, p.DefaultModel, p.Host, role)
+	}
+	return w.Flush()
+}
+
+// modelOutput is an entry of the JSON output of models
+type modelOutput struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+func (c *cli) models(ctx context.Context, args []string) error {
+	fs := c.flags("models")
+	fs.Usage = func() {
+		fmt.Fprintln(c.stderr, "Usage: simpleai models [flags] [provider...]")
+		fs.PrintDefaults()
+	}
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	factory, err := c.factory()
+	if err != nil {
+		return err
+	}
+
+	names := fs.Args()
+	if len(names) == 0 {
+		names = sortedProviders(factory.GetConfig())
+	}
+
+	// A provider that cannot be reached should not hide the models of the others
+	list := []modelOutput{}
+	var errs []error
+	for _, name := range names {
+		models, err := factory.ListModels(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, model := range models {
+			list = append(list, modelOutput{Provider: name, Model: model.Name})
+		}
+	}
+
+	if c.output == "json" {
+		if err := c.printJSON(list); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PROVIDER\tMODEL")
+		for _, m := range list {
+			fmt.Fprintf(w, "%s\t%s\n", m.Provider, m.Model)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *cli) features(ctx context.Context, args []string) error {
+	fs := c.flags("features")
+	fs.Usage = func() {
+		fmt.Fprintln(c.stderr, "Usage: simpleai features [flags] [provider]")
+		fs.PrintDefaults()
+	}
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("%w: at most one provider may be named", errUsage)
+	}
+	factory, err := c.factory()
+	if err != nil {
+		return err
+	}
+	provider, _, err := c.provider(factory, fs.Arg(0), "")
+	if err != nil {
+		return err
+	}
+
+	features := provider.SupportedFeatures()
+	if c.output == "json" {
+		return c.printJSON(features)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Structured output\t%s\n", yesNo(features.StructuredOutput))
+	fmt.Fprintf(w, "Streaming\t%s\n", yesNo(features.Streaming))
+	fmt.Fprintf(w, "Function calling\t%s\n", yesNo(features.FunctionCalling))
+	fmt.Fprintf(w, "Vision\t%s\n", yesNo(features.Vision))
+	fmt.Fprintf(w, "Temperature\t%s\n", yesNo(features.Temperature))
+	fmt.Fprintf(w, "Top-p\t%s\n", yesNo(features.TopP))
+	fmt.Fprintf(w, "Max tokens\t%d\n", features.MaxTokens)
+	fmt.Fprintf(w, "Roles\t%v\n", features.SupportedRoles)
+	return w.Flush()
+}
+
+// validateOutput is the JSON output of validate
+type validateOutput struct {
+	Valid  bool     `json:"valid"`
+	Legacy bool     `json:"legacy,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+func (c *cli) validate(ctx context.Context, args []string) error {
+	fs := c.flags("validate")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	// Loading validates the config and each provider's settings, and reports errors with
+	// their file and line
+	var result validateOutput
+	config, err := c.readConfig()
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	// Entries without a type name their provider, which only fails when it is first used,
+	// so catch unknown names here
+	factory := c.registered()
+	registered := make(map[string]bool)
+	for _, name := range factory.ListProviders() {
+		registered[name] = true
+	}
+	for _, name := range sortedProviders(config) {
+		if providerConfig := config.Providers[name]; providerConfig.Type == "" && !registered[name] {
+			result.Errors = append(result.Errors, fmt.Sprintf("provider %s is not a known provider type; set type to one of %s", name, strings.Join(sortedNames(registered), ", ")))
+		}
+	}
+	result.Valid = len(result.Errors) == 0
+	if data, err := os.ReadFile(c.configPath); err == nil {
+		result.Legacy = simpleai.NewConfigMigrator().IsOldConfigFormat(data)
+	}
+
+	if c.output == "json" {
+		if err := c.printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		for _, msg := range result.Errors {
+			fmt.Fprintf(c.stdout, "error: %s\n", msg)
+		}
+		if result.Legacy {
+			fmt.Fprintln(c.stdout, "note: legacy config format; convert it with 'simpleai migrate'")
+		}
+		if result.Valid {
+			fmt.Fprintf(c.stdout, "%s is valid (%d providers, default %s)\n", c.configPath, len(config.Providers), config.DefaultProvider)
+		}
+	}
+	if !result.Valid {
+		return fmt.Errorf("%s is not valid", c.configPath)
+	}
+	return nil
+}
+
+func (c *cli) migrate(ctx context.Context, args []string) error {
+	fs := c.flags("migrate")
+	out := fs.String("write", "", "write the migrated config to this file instead of stdout")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(c.configPath)
+	if err != nil {
+		return err
+	}
+
+	migrator := simpleai.NewConfigMigrator()
+	if !migrator.IsOldConfigFormat(data) {
+		fmt.Fprintf(c.stderr, "%s is already in the factory format\n", c.configPath)
+	}
+	config, err := migrator.MigrateFromJSON(data)
+	if err != nil {
+		return err
+	}
+	if err := simpleai.ValidateFactoryConfig(config); err != nil {
+		return fmt.Errorf("migrated config is not valid: %w", err)
+	}
+
+	// The migrated config keeps its keys, which json.Marshal would redact
+	encoded, err := simpleai.MarshalConfig(config)
+	if err != nil {
+		return err
+	}
+	encoded = append(encoded, '\n')
+	if *out == "" {
+		_, err := c.stdout.Write(encoded)
+		return err
+	}
+	return os.WriteFile(*out, encoded, 0o644)
+}
+
+func (c *cli) schema(ctx context.Context, args []string) error {
+	fs := c.flags("schema")
+	fs.Usage = func() {
+		fmt.Fprintln(c.stderr, "Usage: simpleai schema [flags] [provider]")
+		fs.PrintDefaults()
+	}
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("%w: at most one provider may be named", errUsage)
+	}
+
+	// Schemas describe provider types, so no config file is needed
+	factory := c.registered()
+	names := factory.ListProviders()
+	sort.Strings(names)
+	if fs.NArg() == 1 {
+		names = []string{fs.Arg(0)}
+	}
+	schemas := make(map[string]schema.Schema, len(names))
+	for _, name := range names {
+		s, err := factory.ConfigSchema(name)
+		if err != nil {
+			if fs.NArg() == 1 {
+				return err
+			}
+			continue
+		}
+		schemas[name] = s
+	}
+
+	if c.output == "json" {
+		if fs.NArg() == 1 {
+			return c.printJSON(schemas[fs.Arg(0)])
+		}
+		return c.printJSON(schemas)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tSETTING\tTYPE\tDESCRIPTION")
+	for _, name := range names {
+		s, ok := schemas[name]
+		if !ok {
+			continue
+		}
+		properties, _ := s["properties"].(schema.Schema)
+		settings := make([]string, 0, len(properties))
+		for setting := range properties {
+			settings = append(settings, setting)
+		}
+		sort.Strings(settings)
+		for _, setting := range settings {
+			property, _ := properties[setting].(schema.Schema)
+			description, _ := property["description"].(string)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, setting, settingType(property), description)
+		}
+		if s["additionalProperties"] == true {
+			fmt.Fprintf(w, "%s\t*\tany\tOther settings are passed through\n", name)
+		}
+	}
+	return w.Flush()
+}
+
+// settingType names the type of a setting from its schema
+func settingType(property schema.Schema) string {
+	switch t, _ := property["type"].(string); t {
+	case "":
+		return "any"
+	case "array":
+		items, _ := property["items"].(schema.Schema)
+		return "list of " + settingType(items)
+	default:
+		return t
+	}
+}
+
+// sortedNames returns the keys of a set in order
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedProviders returns the names of the configured providers in order
+func sortedProviders(config simpleai.FactoryConfig) []string {
+	names := make([]string, 0, len(config.Providers))
+	for name := range config.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
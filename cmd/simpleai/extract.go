@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"simpleai"
+	"sort"
+)
+
+// extractPrompt asks for JSON matching the schema that follows it
+const extractPrompt = "Extract the requested information from the user's text. Respond with only JSON that matches this JSON Schema:\n\n"
+
+func (c *cli) extract(ctx context.Context, args []string) error {
+	fs := c.flags("extract")
+	schemaPath := fs.String("schema", "", "path to a JSON Schema file describing the output (required)")
+	providerName := fs.String("provider", "", "provider to use (default: the config's default provider)")
+	model := fs.String("model", "", "model to use (default: the provider's default model)")
+	system := fs.String("system", "", "instructions added before the schema")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if *schemaPath == "" {
+		return fmt.Errorf("%w: -schema is required", errUsage)
+	}
+
+	data, err := os.ReadFile(*schemaPath)
+	if err != nil {
+		return err
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("%s: %w", *schemaPath, err)
+	}
+	text, err := c.input(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	factory, err := c.factory()
+	if err != nil {
+		return err
+	}
+	provider, _, err := c.provider(factory, *providerName, *model)
+	if err != nil {
+		return err
+	}
+
+	prompt := extractPrompt + string(data)
+	if *system != "" {
+		prompt = *system + "\n\n" + prompt
+	}
+	// The target's Go type tells providers and JSON extraction what kind of value to expect
+	target := targetFor(schema)
+	_, err = simpleai.ChatContext(ctx, provider, simpleai.ChatRequest{
+		SystemPrompt: simpleai.SystemPrompt{Content: prompt},
+		Messages:     []simpleai.Message{{Role: "user", Content: text}},
+		T:            target,
+	})
+	if err != nil {
+		return err
+	}
+
+	value := deref(target)
+	if err := validate(schema, value, "$"); err != nil {
+		return fmt.Errorf("response does not match %s: %w", *schemaPath, err)
+	}
+	return c.printJSON(value)
+}
+
+// targetFor returns a structured output target of the schema's top-level type
+func targetFor(schema map[string]any) any {
+	switch schema["type"] {
+	case "object":
+		return &map[string]any{}
+	case "array":
+		return &[]any{}
+	default:
+		var value any
+		return &value
+	}
+}
+
+func deref(target any) any {
+	switch t := target.(type) {
+	case *map[string]any:
+		return *t
+	case *[]any:
+		return *t
+	case *any:
+		return *t
+	}
+	return nil
+}
+
+// validate reports the first place value does not match schema. It checks type, enum,
+// required, properties and items, which covers the schemas extraction is used with; other
+// keywords are ignored.
+func validate(schema map[string]any, value any, path string) error {
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		return fmt.Errorf("%s: expected %v, got %s", path, t, jsonType(value))
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			if equalJSON(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if key, ok := name.(string); ok {
+					if _, present := v[key]; !present {
+						return fmt.Errorf("%s: missing required property %q", path, key)
+					}
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		keys := make([]string, 0, len(properties))
+		for key := range properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			propertySchema, ok := properties[key].(map[string]any)
+			if property, present := v[key]; ok && present {
+				if err := validate(propertySchema, property, path+"."+key); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchesType reports whether value has the JSON type, or one of the types, in t
+func matchesType(t any, value any) bool {
+	switch t := t.(type) {
+	case string:
+		actual := jsonType(value)
+		return actual == t || (t == "number" && actual == "integer")
+	case []any:
+		for _, alternative := range t {
+			if matchesType(alternative, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func equalJSON(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
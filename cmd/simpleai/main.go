@@ -0,0 +1,225 @@
+// Command simpleai is a command-line client for the providers in a factory configuration,
+// for trying out and debugging provider setups.
+//
+//	simpleai chat -provider openai "Write a haiku about Go"
+//	simpleai chat < prompt.txt
+//	simpleai repl -provider anthropic -temperature 0.2
+//	simpleai models -output json
+//	simpleai extract -schema invoice.schema.json < invoice.txt
+//	simpleai validate -config providers.json
+//	simpleai schema anthropic
+//
+// Every command accepts -config, -profile and -output text|json. The config is a JSON, YAML
+// or TOML file read by the config package; it defaults to $SIMPLEAI_CONFIG, then the first
+// of simpleai.json, simpleai.yaml, simpleai.yml and simpleai.toml that exists.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"simpleai"
+	"simpleai/config"
+	"simpleai/providers/all"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// errUsage marks errors that are reported with the command's usage
+var errUsage = errors.New("usage")
+
+// command is a subcommand of the CLI
+type command struct {
+	summary string
+	run     func(c *cli, ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"chat":      {"send a message, from the arguments or stdin, and print the reply", (*cli).chat},
+	"providers": {"list the configured providers", (*cli).providers},
+	"models":    {"list the models of the configured providers", (*cli).models},
+	"features":  {"show the features a provider supports", (*cli).features},
+	"validate":  {"check a config file", (*cli).validate},
+	"migrate":   {"convert a legacy config file to the factory format", (*cli).migrate},
+	"extract":   {"extract JSON matching a schema file from text", (*cli).extract},
+	"repl":      {"chat interactively, with history and slash commands", (*cli).repl},
+	"schema":    {"describe the settings a provider accepts", (*cli).schema},
+}
+
+// cli holds the streams and provider registrations commands run with
+type cli struct {
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+	register func(factory *simpleai.LLMFactory)
+
+	configPath string
+	profile    string
+	output     string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, register: all.Register}
+	code := c.run(ctx, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+// run runs the command named by args[0] and returns the exit code
+func (c *cli) run(ctx context.Context, args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		c.usage()
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(c.stderr, "simpleai: unknown command %q\n\n", args[0])
+		c.usage()
+		return 2
+	}
+
+	err := cmd.run(c, ctx, args[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(c.stderr, "simpleai %s: %v\n", args[0], err)
+		return 2
+	default:
+		fmt.Fprintf(c.stderr, "simpleai %s: %v\n", args[0], err)
+		return 1
+	}
+}
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "Usage: simpleai <command> [flags] [arguments]")
+	fmt.Fprintln(c.stderr, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(c.stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(c.stderr, "\nRun 'simpleai <command> -h' for the flags of a command.")
+}
+
+// flags returns a flag set with the flags every command accepts
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("simpleai "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+
+	fs.StringVar(&c.configPath, "config", defaultConfigPath(), "path to the factory config file")
+	fs.StringVar(&c.profile, "profile", os.Getenv("SIMPLEAI_PROFILE"), "config profile to apply")
+	fs.StringVar(&c.output, "output", "text", "output format: text or json")
+	return fs
+}
+
+// defaultConfigPath returns $SIMPLEAI_CONFIG or the first simpleai config file in the
+// working directory
+func defaultConfigPath() string {
+	if path := os.Getenv("SIMPLEAI_CONFIG"); path != "" {
+		return path
+	}
+	for _, path := range []string{"simpleai.json", "simpleai.yaml", "simpleai.yml", "simpleai.toml"} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return "simpleai.json"
+}
+
+// parse parses args and checks the common flags
+func (c *cli) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if c.output != "text" && c.output != "json" {
+		return fmt.Errorf("%w: -output must be text or json, got %q", errUsage, c.output)
+	}
+	return nil
+}
+
+// registered returns a factory with the providers registered and no config loaded
+func (c *cli) registered() *simpleai.LLMFactory {
+	factory := simpleai.NewLLMFactory()
+	c.register(factory)
+	return factory
+}
+
+// readConfig loads and validates the config file with the selected profile, checking the
+// settings of each provider against its config struct
+func (c *cli) readConfig() (simpleai.FactoryConfig, error) {
+	return config.LoadWithOptions(c.configPath, config.Options{
+		Profile:  c.profile,
+		Validate: c.registered().ValidateConfig,
+	})
+}
+
+// factory returns a factory with the built-in providers and the config file loaded
+func (c *cli) factory() (*simpleai.LLMFactory, error) {
+	cfg, err := c.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	factory := c.registered()
+	if err := factory.LoadConfig(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", c.configPath, err)
+	}
+	return factory, nil
+}
+
+// provider returns the named provider, or the default provider when name is empty, using
+// model in place of its default model when set
+func (c *cli) provider(factory *simpleai.LLMFactory, name, model string) (simpleai.Provider, string, error) {
+	cfg := factory.GetConfig()
+	if name == "" {
+		name = cfg.DefaultProvider
+	}
+	if _, ok := cfg.Providers[name]; !ok {
+		return nil, "", fmt.Errorf("provider %q is not configured in %s", name, c.configPath)
+	}
+	if model == "" {
+		model = cfg.Providers[name].DefaultModel
+	}
+	provider, err := factory.CreateProviderWithModel(name, model)
+	return provider, model, err
+}
+
+// printJSON writes value as indented JSON
+func (c *cli) printJSON(value any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// input returns the arguments joined by spaces, or stdin when there are none or the only
+// argument is "-"
+func (c *cli) input(args []string) (string, error) {
+	if len(args) > 0 && !(len(args) == 1 && args[0] == "-") {
+		return strings.Join(args, " "), nil
+	}
+	data, err := io.ReadAll(c.stdin)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return "", fmt.Errorf("%w: no input; pass a message or pipe it on stdin", errUsage)
+	}
+	return text, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"simpleai"
+	"simpleai/providers/all"
+	"simpleai/simpleaitest"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{
+	"default_provider": "mock",
+	"providers": {
+		"mock": {"default_model": "small", "timeout": 30, "retry_attempts": 1},
+		"other": {"default_model": "large", "timeout": 30, "retry_attempts": 1}
+	},
+	"fallback_providers": ["other"]
+}`
+
+// runCLI runs the CLI with mock providers registered as "mock" and "other"
+func runCLI(t *testing.T, mock *simpleaitest.MockProvider, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	c := &cli{
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+		register: func(factory *simpleai.LLMFactory) {
+			factory.RegisterProvider("mock", mock.Constructor())
+			factory.RegisterProvider("other", simpleaitest.NewMockProvider("other").Constructor())
+		},
+	}
+	code := c.run(context.Background(), args)
+	return stdout.String(), stderr.String(), code
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestChat(t *testing.T) {
+	config := writeFile(t, "config.json", testConfig)
+	mock := simpleaitest.NewMockProvider("mock").Default(simpleaitest.Text("Hello there"))
+
+	stdout, stderr, code := runCLI(t, mock, "", "chat", "-config", config, "-system", "Be brief", "Hi", "you")
+	if code != 0 || stdout != "Hello there\n" {
+		t.Fatalf("Expected the streamed reply, got %d %q %q", code, stdout, stderr)
+	}
+	request, _ := mock.LastRequest()
+	if request.Messages[0].Content != "Hi you" || request.SystemPrompt.Content != "Be brief" {
+		t.Errorf("Unexpected request %+v", request)
+	}
+
+	stdout, _, code = runCLI(t, mock, "from stdin\n", "chat", "-config", config, "-output", "json", "-model", "tiny")
+	var output chatOutput
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil || code != 0 {
+		t.Fatalf("Expected JSON output, got %d %q", code, stdout)
+	}
+	if output.Model != "tiny" || output.Message != "Hello there" {
+		t.Errorf("Unexpected output %+v", output)
+	}
+	if request, _ := mock.LastRequest(); request.Messages[0].Content != "from stdin" {
+		t.Errorf("Expected the message from stdin, got %q", request.Messages[0].Content)
+	}
+}
+
+func TestProvidersAndModels(t *testing.T) {
+	config := writeFile(t, "config.json", testConfig)
+	mock := simpleaitest.NewMockProvider("mock").WithModels(simpleai.Model{Name: "small"}, simpleai.Model{Name: "tiny"})
+
+	stdout, _, code := runCLI(t, mock, "", "providers", "-config", config)
+	if code != 0 || !strings.Contains(stdout, "default") || !strings.Contains(stdout, "fallback 1") {
+		t.Errorf("Expected provider roles, got %d %q", code, stdout)
+	}
+
+	stdout, _, code = runCLI(t, mock, "", "models", "-config", config, "-output", "json", "mock")
+	var models []modelOutput
+	json.Unmarshal([]byte(stdout), &models)
+	if code != 0 || len(models) != 2 || models[1] != (modelOutput{Provider: "mock", Model: "tiny"}) {
+		t.Errorf("Unexpected models %d %q", code, stdout)
+	}
+}
+
+func TestValidateAndMigrate(t *testing.T) {
+	mock := simpleaitest.NewMockProvider("mock")
+
+	valid := writeFile(t, "valid.json", testConfig)
+	if stdout, _, code := runCLI(t, mock, "", "validate", "-config", valid); code != 0 || !strings.Contains(stdout, "is valid") {
+		t.Errorf("Expected the config to be valid, got %d %q", code, stdout)
+	}
+
+	invalid := writeFile(t, "invalid.yaml", "default_provider: mock\nproviders:\n  mock:\n    default_model: small\n    timeout: 0\n")
+	stdout, _, code := runCLI(t, mock, "", "validate", "-config", invalid, "-output", "json")
+	var result validateOutput
+	json.Unmarshal([]byte(stdout), &result)
+	if code != 1 || result.Valid || len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "invalid.yaml:5:") {
+		t.Errorf("Expected a timeout error at its line, got %d %+v", code, result)
+	}
+
+	unknown := writeFile(t, "unknown.toml", "default_provider = \"mock\"\n[providers.mock]\ndefault_model = \"small\"\ntimeout = 5\n[providers.unknown]\ndefault_model = \"x\"\ntimeout = 5\n")
+	if stdout, _, code := runCLI(t, mock, "", "validate", "-config", unknown); code != 1 || !strings.Contains(stdout, "unknown is not a known provider type") {
+		t.Errorf("Expected an unknown provider error, got %d %q", code, stdout)
+	}
+
+	legacy := writeFile(t, "legacy.json", `{"ollama_host":"http://gpu:11434","default_model":"llama3"}`)
+	out := filepath.Join(t.TempDir(), "migrated.json")
+	if _, stderr, code := runCLI(t, mock, "", "migrate", "-config", legacy, "-write", out); code != 0 {
+		t.Fatalf("Migrate failed: %s", stderr)
+	}
+	data, _ := os.ReadFile(out)
+	var migrated simpleai.FactoryConfig
+	json.Unmarshal(data, &migrated)
+	if migrated.DefaultProvider != "ollama" || migrated.Providers["ollama"].Host != "http://gpu:11434" {
+		t.Errorf("Unexpected migrated config %s", data)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	c := &cli{stdout: &stdout, stderr: &stderr, register: all.Register}
+	if code := c.run(context.Background(), []string{"schema", "anthropic"}); code != 0 {
+		t.Fatalf("Schema failed: %s", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "max_tokens") || !strings.Contains(stdout.String(), "integer") {
+		t.Errorf("Expected the anthropic settings, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := c.run(context.Background(), []string{"schema", "-output", "json", "exec"}); code != 0 {
+		t.Fatalf("Schema failed: %s", stderr.String())
+	}
+	var s map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &s); err != nil || s["additionalProperties"] != true {
+		t.Errorf("Expected the exec schema to allow plugin settings, got %q", stdout.String())
+	}
+
+	// Misspelled settings of the built-in providers are reported by validate
+	config := writeFile(t, "typo.yaml", "default_provider: anthropic\nproviders:\n  anthropic:\n    default_model: claude\n    timeout: 30\n    extra_settings:\n      max_token: 512\n")
+	stdout.Reset()
+	if code := c.run(context.Background(), []string{"validate", "-config", config}); code != 1 || !strings.Contains(stdout.String(), "typo.yaml:7:") {
+		t.Errorf("Expected the misspelled setting at its line, got %d %q", code, stdout.String())
+	}
+}
+
+func TestExtract(t *testing.T) {
+	config := writeFile(t, "config.json", testConfig)
+	schema := writeFile(t, "schema.json", `{
+		"type": "object",
+		"required": ["name", "total"],
+		"properties": {
+			"name": {"type": "string"},
+			"total": {"type": "number"},
+			"status": {"enum": ["paid", "open"]}
+		}
+	}`)
+
+	mock := simpleaitest.NewMockProvider("mock").Reply(
+		simpleaitest.Text("Here it is: {\"name\": \"ACME\", \"total\": 12.5, \"status\": \"paid\"}"),
+		simpleaitest.Text(`{"name": "ACME", "status": "late"}`),
+	)
+
+	stdout, stderr, code := runCLI(t, mock, "Invoice from ACME", "extract", "-config", config, "-schema", schema)
+	var value map[string]any
+	if err := json.Unmarshal([]byte(stdout), &value); err != nil || code != 0 || value["total"] != 12.5 {
+		t.Fatalf("Expected the extracted object, got %d %q %q", code, stdout, stderr)
+	}
+	if request, _ := mock.LastRequest(); !strings.Contains(request.SystemPrompt.Content, `"required"`) {
+		t.Errorf("Expected the schema in the system prompt, got %q", request.SystemPrompt.Content)
+	}
+
+	_, stderr, code = runCLI(t, mock, "Invoice from ACME", "extract", "-config", config, "-schema", schema)
+	if code != 1 || !strings.Contains(stderr, `missing required property "total"`) {
+		t.Errorf("Expected a validation error, got %d %q", code, stderr)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	mock := simpleaitest.NewMockProvider("mock")
+	if _, _, code := runCLI(t, mock, ""); code != 2 {
+		t.Errorf("Expected usage exit code without a command, got %d", code)
+	}
+	if _, stderr, code := runCLI(t, mock, "", "bogus"); code != 2 || !strings.Contains(stderr, "unknown command") {
+		t.Errorf("Expected an unknown command error, got %d %q", code, stderr)
+	}
+	if _, _, code := runCLI(t, mock, "", "extract", "-config", "x.json"); code != 2 {
+		t.Errorf("Expected a usage error without -schema, got %d", code)
+	}
+}
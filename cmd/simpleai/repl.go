@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"simpleai"
+	"strconv"
+	"strings"
+)
+
+const replHelp = `Type a message to chat. Commands:
+  /provider [name]         show or switch the provider
+  /model [name]            show or switch the model
+  /temperature [value]     show or set the temperature; "default" unsets it
+  /system [prompt]         set the system prompt, or clear it without a prompt
+  /usage                   show token usage of the last reply and the session
+  /save <file>             save the transcript as JSON
+  /load <file>             load a transcript saved with /save
+  /clear                   forget the conversation
+  /help                    show this help
+  /quit                    leave
+End a line with \ to continue the message on the next line.`
+
+// transcript is a conversation saved by /save
+type transcript struct {
+	Provider    string             `json:"provider"`
+	Model       string             `json:"model"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	System      string             `json:"system,omitempty"`
+	Messages    []simpleai.Message `json:"messages"`
+}
+
+// session is the state of a REPL
+type session struct {
+	c        *cli
+	factory  *simpleai.LLMFactory
+	provider simpleai.Provider
+
+	providerName string
+	model        string
+	temperature  *float64
+	system       string
+	history      []simpleai.Message
+
+	last  simpleai.Usage // Usage of the last reply
+	total simpleai.Usage // Usage of the session
+}
+
+func (c *cli) repl(ctx context.Context, args []string) error {
+	fs := c.flags("repl")
+	providerName := fs.String("provider", "", "provider to start with (default: the config's default provider)")
+	model := fs.String("model", "", "model to start with (default: the provider's default model)")
+	system := fs.String("system", "", "system prompt")
+	var temperature *float64
+	fs.Func("temperature", "sampling temperature (default: the provider's)", func(value string) error {
+		t, err := strconv.ParseFloat(value, 64)
+		temperature = &t
+		return err
+	})
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+
+	factory, err := c.factory()
+	if err != nil {
+		return err
+	}
+	s := &session{c: c, factory: factory, system: *system, temperature: temperature}
+	if err := s.use(*providerName, *model); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "Chatting with %s/%s. Type /help for commands.\n", s.providerName, s.model)
+	scanner := bufio.NewScanner(c.stdin)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var pending []string
+	for {
+		if len(pending) == 0 {
+			fmt.Fprint(c.stdout, "> ")
+		} else {
+			fmt.Fprint(c.stdout, ". ")
+		}
+		if !scanner.Scan() {
+			fmt.Fprintln(c.stdout)
+			return scanner.Err()
+		}
+
+		line := scanner.Text()
+		if strings.HasSuffix(line, `\`) {
+			pending = append(pending, strings.TrimSuffix(line, `\`))
+			continue
+		}
+		input := strings.TrimSpace(strings.Join(append(pending, line), "\n"))
+		pending = nil
+
+		switch {
+		case input == "":
+		case strings.HasPrefix(input, "/"):
+			quit, err := s.command(input)
+			if err != nil {
+				fmt.Fprintf(c.stdout, "error: %v\n", err)
+			}
+			if quit {
+				return nil
+			}
+		default:
+			if err := s.send(ctx, input); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				fmt.Fprintf(c.stdout, "error: %v\n", err)
+			}
+		}
+	}
+}
+
+// use switches to the named provider and model; empty names keep the current provider and
+// select its default model
+func (s *session) use(providerName, model string) error {
+	if providerName == "" {
+		providerName = s.providerName
+	}
+	provider, model, err := s.c.provider(s.factory, providerName, model)
+	if err != nil {
+		return err
+	}
+	s.provider, s.model = provider, model
+	s.providerName = providerName
+	if s.providerName == "" {
+		s.providerName = s.factory.GetConfig().DefaultProvider
+	}
+	return nil
+}
+
+// send streams the reply to a message and adds both to the history. A failed request
+// leaves the history as it was.
+func (s *session) send(ctx context.Context, message string) error {
+	messages := append(append([]simpleai.Message(nil), s.history...), simpleai.Message{Role: "user", Content: message})
+	request := simpleai.ChatRequest{
+		SystemPrompt: simpleai.SystemPrompt{Content: s.system},
+		Messages:     messages,
+		Temperature:  s.temperature,
+	}
+
+	response, err := simpleai.ChatStream(ctx, s.provider, request, func(chunk simpleai.StreamChunk) error {
+		_, err := fmt.Fprint(s.c.stdout, chunk.Delta)
+		return err
+	})
+	fmt.Fprintln(s.c.stdout)
+	if err != nil {
+		return err
+	}
+
+	s.history = append(messages, simpleai.Message{Role: "assistant", Content: response.Message, ToolCalls: response.ToolCalls})
+	s.last = simpleai.Usage{}
+	if response.Usage != nil {
+		s.last = *response.Usage
+		s.total.PromptTokens += s.last.PromptTokens
+		s.total.CompletionTokens += s.last.CompletionTokens
+		s.total.TotalTokens += s.last.TotalTokens
+	}
+	return nil
+}
+
+// command runs a slash command and reports whether the REPL should end
+func (s *session) command(input string) (bool, error) {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+	out := s.c.stdout
+
+	switch name {
+	case "/quit", "/exit":
+		return true, nil
+	case "/help":
+		fmt.Fprintln(out, replHelp)
+	case "/provider":
+		if arg != "" {
+			if err := s.use(arg, ""); err != nil {
+				return false, err
+			}
+		}
+		fmt.Fprintf(out, "provider %s, model %s\n", s.providerName, s.model)
+	case "/model":
+		if arg != "" {
+			if err := s.use(s.providerName, arg); err != nil {
+				return false, err
+			}
+		}
+		fmt.Fprintf(out, "model %s\n", s.model)
+	case "/temperature":
+		switch arg {
+		case "":
+		case "default":
+			s.temperature = nil
+		default:
+			t, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return false, fmt.Errorf("temperature must be a number or \"default\"")
+			}
+			if !s.provider.SupportedFeatures().Temperature {
+				fmt.Fprintf(out, "note: %s does not support temperature\n", s.providerName)
+			}
+			s.temperature = &t
+		}
+		if s.temperature == nil {
+			fmt.Fprintln(out, "temperature: provider default")
+		} else {
+			fmt.Fprintf(out, "temperature: %g\n", *s.temperature)
+		}
+	case "/system":
+		s.system = arg
+		if arg == "" {
+			fmt.Fprintln(out, "system prompt cleared")
+		} else {
+			fmt.Fprintln(out, "system prompt set")
+		}
+	case "/usage":
+		fmt.Fprintf(out, "last reply: %d prompt + %d completion = %d tokens\n", s.last.PromptTokens, s.last.CompletionTokens, s.last.TotalTokens)
+		fmt.Fprintf(out, "session:    %d prompt + %d completion = %d tokens\n", s.total.PromptTokens, s.total.CompletionTokens, s.total.TotalTokens)
+	case "/clear":
+		s.history = nil
+		fmt.Fprintln(out, "conversation cleared")
+	case "/save":
+		if arg == "" {
+			return false, errors.New("usage: /save <file>")
+		}
+		if err := s.save(arg); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(out, "saved %d messages to %s\n", len(s.history), arg)
+	case "/load":
+		if arg == "" {
+			return false, errors.New("usage: /load <file>")
+		}
+		if err := s.load(arg); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(out, "loaded %d messages, provider %s, model %s\n", len(s.history), s.providerName, s.model)
+	default:
+		return false, fmt.Errorf("unknown command %s; type /help for commands", name)
+	}
+	return false, nil
+}
+
+func (s *session) save(path string) error {
+	data, err := json.MarshalIndent(transcript{
+		Provider:    s.providerName,
+		Model:       s.model,
+		Temperature: s.temperature,
+		System:      s.system,
+		Messages:    s.history,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// load restores a saved transcript, including its provider and model when they are
+// configured
+func (s *session) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var t transcript
+	if err := json.Unmarshal(data, &t); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if t.Provider != "" {
+		if err := s.use(t.Provider, t.Model); err != nil {
+			return err
+		}
+	}
+	s.temperature, s.system, s.history = t.Temperature, t.System, t.Messages
+	return nil
+}
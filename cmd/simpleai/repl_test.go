@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"simpleai"
+	"simpleai/simpleaitest"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	config := writeFile(t, "config.json", testConfig)
+	saved := filepath.Join(t.TempDir(), "transcript.json")
+	reply := func(text string, tokens int) simpleaitest.Reply {
+		return simpleaitest.Reply{Response: simpleai.ChatResponse{Message: text, Usage: &simpleai.Usage{PromptTokens: tokens, TotalTokens: tokens}}}
+	}
+	mock := simpleaitest.NewMockProvider("mock").Reply(reply("Hi!", 3), reply("Oslo", 5))
+
+	input := strings.Join([]string{
+		"Hello",
+		"/temperature 0.3",
+		"/system Answer in one word",
+		"/model tiny",
+		"Capital of \\",
+		"Norway?",
+		"/usage",
+		"/save " + saved,
+		"/bogus",
+		"/quit",
+	}, "\n")
+	stdout, stderr, code := runCLI(t, mock, input, "repl", "-config", config)
+	if code != 0 {
+		t.Fatalf("REPL failed: %d %q", code, stderr)
+	}
+	for _, want := range []string{"Hi!\n", "Oslo\n", "temperature: 0.3", "session:    8 prompt", "unknown command /bogus"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in the output:\n%s", want, stdout)
+		}
+	}
+
+	requests := mock.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("Expected two requests, got %d", len(requests))
+	}
+	second := requests[1]
+	if len(second.Messages) != 3 || second.Messages[1].Content != "Hi!" || second.Messages[2].Content != "Capital of \nNorway?" {
+		t.Errorf("Expected the history and the continued line, got %+v", second.Messages)
+	}
+	if second.SystemPrompt.Content != "Answer in one word" || second.Temperature == nil || *second.Temperature != 0.3 {
+		t.Errorf("Expected the session settings, got %+v", second)
+	}
+	if configs := mock.Configs(); configs[len(configs)-1]["default_model"] != "tiny" {
+		t.Errorf("Expected the switched model, got %v", configs[len(configs)-1])
+	}
+
+	data, _ := os.ReadFile(saved)
+	var tr transcript
+	if err := json.Unmarshal(data, &tr); err != nil || tr.Model != "tiny" || len(tr.Messages) != 4 {
+		t.Fatalf("Unexpected transcript %s", data)
+	}
+
+	// Loading restores the conversation, which the next message continues
+	mock.Reply(simpleaitest.Text("Bergen"))
+	if _, stderr, code := runCLI(t, mock, "/load "+saved+"\nAnd the second city?\n", "repl", "-config", config); code != 0 {
+		t.Fatalf("REPL failed: %q", stderr)
+	}
+	last, _ := mock.LastRequest()
+	if len(last.Messages) != 5 || last.SystemPrompt.Content != "Answer in one word" {
+		t.Errorf("Expected the loaded conversation, got %+v", last)
+	}
+}
@@ -3,6 +3,7 @@ package simpleai
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 )
 
 // Config holds basic configuration (legacy format for migration)
@@ -70,6 +71,20 @@ func (m *ConfigMigrator) IsOldConfigFormat(jsonData []byte) bool {
 	return oldConfig.OllamaHost != "" || oldConfig.DefaultModel != ""
 }
 
+// MarshalConfig encodes config as indented JSON for a config file. Unlike json.Marshal,
+// which redacts literal API keys, it keeps them.
+func MarshalConfig(config FactoryConfig) ([]byte, error) {
+	type plainProviderConfig ProviderConfig
+	file := struct {
+		FactoryConfig
+		Providers map[string]plainProviderConfig `json:"providers"`
+	}{FactoryConfig: config, Providers: make(map[string]plainProviderConfig, len(config.Providers))}
+	for name, providerConfig := range config.Providers {
+		file.Providers[name] = plainProviderConfig(providerConfig)
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
 // GenerateDefaultFactoryConfig creates a default factory configuration
 func GenerateDefaultFactoryConfig() FactoryConfig {
 	return FactoryConfig{
@@ -89,44 +104,56 @@ func GenerateDefaultFactoryConfig() FactoryConfig {
 	}
 }
 
-// ValidateFactoryConfig validates a factory configuration
+// ValidateFactoryConfig validates a factory configuration. The returned LLMError wraps a
+// *ValidationError naming the offending field, such as "providers.ollama.timeout".
 func ValidateFactoryConfig(config FactoryConfig) error {
 	if config.DefaultProvider == "" {
-		return NewLLMError(ErrInvalidConfig, "default provider must be specified", "config_validation", false, 0, nil)
+		return invalidConfig("default_provider", config.DefaultProvider,
+			"default provider must be specified", "is required")
 	}
 
 	if len(config.Providers) == 0 {
-		return NewLLMError(ErrInvalidConfig, "at least one provider must be configured", "config_validation", false, 0, nil)
+		return invalidConfig("providers", len(config.Providers),
+			"at least one provider must be configured", "must not be empty")
 	}
 
 	// Check if default provider exists in configuration
 	if _, exists := config.Providers[config.DefaultProvider]; !exists {
-		return NewLLMError(ErrInvalidConfig,
+		return invalidConfig("default_provider", config.DefaultProvider,
 			fmt.Sprintf("default provider %s not found in provider configurations", config.DefaultProvider),
-			"config_validation", false, 0, nil)
+			"must name a configured provider")
 	}
 
-	// Validate each provider configuration
-	for name, providerConfig := range config.Providers {
+	// Validate each provider configuration, in order so the first error is stable
+	names := make([]string, 0, len(config.Providers))
+	for name := range config.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		providerConfig := config.Providers[name]
+		field := "providers." + name + "."
 		if providerConfig.DefaultModel == "" {
-			return NewLLMError(ErrInvalidConfig,
-				fmt.Sprintf("provider %s must have a default model specified", name),
-				"config_validation", false, 0, nil)
+			return invalidConfig(field+"default_model", providerConfig.DefaultModel,
+				fmt.Sprintf("provider %s must have a default model specified", name), "is required")
 		}
 
 		if providerConfig.Timeout <= 0 {
-			return NewLLMError(ErrInvalidConfig,
-				fmt.Sprintf("provider %s must have a positive timeout value", name),
-				"config_validation", false, 0, nil)
+			return invalidConfig(field+"timeout", providerConfig.Timeout,
+				fmt.Sprintf("provider %s must have a positive timeout value", name), "must be positive")
 		}
 
 		if providerConfig.RetryAttempts < 0 {
-			return NewLLMError(ErrInvalidConfig,
-				fmt.Sprintf("provider %s cannot have negative retry attempts", name),
-				"config_validation", false, 0, nil)
+			return invalidConfig(field+"retry_attempts", providerConfig.RetryAttempts,
+				fmt.Sprintf("provider %s cannot have negative retry attempts", name), "must not be negative")
 		}
 	}
 
 	return nil
 }
 
+// invalidConfig returns the validation error for a config field
+func invalidConfig(field string, value interface{}, message, problem string) error {
+	return NewLLMError(ErrInvalidConfig, message, "config_validation", false, 0,
+		NewValidationError(field, value, problem))
+}
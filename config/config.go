@@ -0,0 +1,255 @@
+// Package config loads a simpleai.FactoryConfig from JSON, YAML or TOML files.
+//
+// The format follows the file extension (.json, .yaml, .yml or .toml) and the keys are the
+// FactoryConfig JSON names. On top of them a file may use:
+//
+//   - ${NAME} and ${NAME:-default} in any string to read environment variables, e.g.
+//     default_model: ${OPENAI_MODEL:-gpt-4o-mini}. $$ is a literal $. Only the settings that
+//     end up in the config are expanded, so profiles that are not selected may name unset
+//     variables. An api_key of just ${NAME} becomes the secret reference env:NAME, which
+//     the factory resolves when it creates the provider, so the key is not stored in the
+//     config or shown by GetConfig. With a custom Options.LookupEnv it is expanded instead.
+//   - include: a file or list of files, relative to the including file, that are loaded
+//     first and overlaid by this file.
+//   - profiles: named overlays, such as dev, staging and prod, of which the one selected by
+//     Options.Profile is applied last.
+//
+// Overlays merge tables key by key, replace other values, and remove keys set to null.
+// Errors, including those of Options.Validate, are *simpleai.ValidationError
+// values carrying the file and line of the offending setting.
+//
+//	cfg, err := config.LoadWithOptions("simpleai.yaml", config.Options{Profile: "prod"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	factory.LoadConfig(cfg)
+package config
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"simpleai"
+	"strings"
+)
+
+// Keys handled by the loader rather than decoded into the config
+const (
+	includeKey  = "include"
+	profilesKey = "profiles"
+)
+
+// Options control how a config file is loaded
+type Options struct {
+	// Profile names the entry of profiles to apply. Empty applies none.
+	Profile string
+
+	// LookupEnv resolves ${...} references. Defaults to os.LookupEnv, in which case an
+	// api_key of just ${NAME} is left to the factory as the secret reference env:NAME.
+	LookupEnv func(name string) (string, bool)
+
+	// Validate checks the decoded config. Defaults to simpleai.ValidateFactoryConfig; pass
+	// a factory's ValidateConfig to also check each provider's settings against its config
+	// struct.
+	Validate func(config simpleai.FactoryConfig) error
+}
+
+// Load reads and validates the config file at path
+func Load(path string) (simpleai.FactoryConfig, error) {
+	return LoadWithOptions(path, Options{})
+}
+
+// LoadWithOptions reads and validates the config file at path
+func LoadWithOptions(path string, options Options) (simpleai.FactoryConfig, error) {
+	config, _, err := load(path, options)
+	return config, err
+}
+
+// load reads and validates the config file at path, and returns the hashes of the files it
+// read, taken from the contents it parsed
+func load(path string, options Options) (simpleai.FactoryConfig, map[string][sha256.Size]byte, error) {
+	// The factory's env resolver reads the process environment, not a custom LookupEnv
+	envSecrets := options.LookupEnv == nil
+	if options.LookupEnv == nil {
+		options.LookupEnv = os.LookupEnv
+	}
+	if options.Validate == nil {
+		options.Validate = simpleai.ValidateFactoryConfig
+	}
+	l := &loader{options: options, loading: make(map[string]bool), hashes: make(map[string][sha256.Size]byte), envSecrets: envSecrets}
+	config, err := l.build(path)
+	return config, l.hashes, err
+}
+
+// build loads path with its includes and profile into a validated config
+func (l *loader) build(path string) (simpleai.FactoryConfig, error) {
+	options := l.options
+	root, profiles, err := l.load(path)
+	if err != nil {
+		return simpleai.FactoryConfig{}, err
+	}
+	if options.Profile != "" {
+		profile, ok := profiles[options.Profile]
+		if !ok {
+			return simpleai.FactoryConfig{}, &simpleai.ValidationError{
+				Field: profilesKey, Value: options.Profile, File: path,
+				Message: fmt.Sprintf("profile %q is not defined", options.Profile),
+			}
+		}
+		root = merge(root, profile)
+	}
+	// Expanding last means only the settings in use need their variables set
+	if l.envSecrets {
+		referenceSecrets(root, options.LookupEnv)
+	}
+	if err := expandNode(root, "", options.LookupEnv); err != nil {
+		return simpleai.FactoryConfig{}, err
+	}
+
+	config, err := decodeConfig(root)
+	if err != nil {
+		return simpleai.FactoryConfig{}, err
+	}
+	if err := options.Validate(config); err != nil {
+		var validationErr *simpleai.ValidationError
+		if !errors.As(err, &validationErr) {
+			return simpleai.FactoryConfig{}, err
+		}
+		located := *validationErr
+		at := root.lookup(located.Field)
+		located.File, located.Line = at.file, at.line
+		return simpleai.FactoryConfig{}, &located
+	}
+	return config, nil
+}
+
+// loader loads a file and its includes
+type loader struct {
+	options Options
+	loading map[string]bool              // Files being loaded, to detect include cycles
+	hashes  map[string][sha256.Size]byte // Contents of the files read, for watching
+
+	envSecrets bool // Whether api_key references can be left to the factory's env resolver
+}
+
+// load returns the merged tree of path and its includes, without profiles, and the
+// profiles they define
+func (l *loader) load(path string) (*node, map[string]*node, error) {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if l.loading[absolute] {
+		return nil, nil, &simpleai.ValidationError{Field: includeKey, Value: path, File: path, Message: "include cycle"}
+	}
+	l.loading[absolute] = true
+	defer delete(l.loading, absolute)
+
+	// A file that cannot be read is watched as empty, so creating it is noticed
+	data, err := os.ReadFile(path)
+	l.hashes[path] = sha256.Sum256(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := parseFile(path, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if root.kind != mapNode {
+		return nil, nil, root.errorAt("", "expected a table of settings at the top level")
+	}
+	var base *node
+	profiles := make(map[string]*node)
+	if include, ok := root.fields[includeKey]; ok {
+		root.remove(includeKey)
+		if err := expandNode(include, includeKey, l.options.LookupEnv); err != nil {
+			return nil, nil, err
+		}
+		paths, err := includePaths(include)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, included := range paths {
+			if !filepath.IsAbs(included) {
+				included = filepath.Join(filepath.Dir(path), included)
+			}
+			tree, includedProfiles, err := l.load(included)
+			if err != nil {
+				return nil, nil, err
+			}
+			base = merge(base, tree)
+			for name, profile := range includedProfiles {
+				profiles[name] = combine(profiles[name], profile)
+			}
+		}
+	}
+
+	if defined, ok := root.fields[profilesKey]; ok {
+		root.remove(profilesKey)
+		if defined.kind != mapNode {
+			return nil, nil, defined.errorAt(profilesKey, "expected a table of profiles")
+		}
+		for _, name := range defined.keys {
+			profile := defined.fields[name]
+			if profile.kind != mapNode {
+				return nil, nil, profile.errorAt(profilesKey+"."+name, "expected a table of settings")
+			}
+			profiles[name] = combine(profiles[name], profile)
+		}
+	}
+
+	return merge(base, root), profiles, nil
+}
+
+// includePaths reads the include setting, a path or a list of paths
+func includePaths(include *node) ([]string, error) {
+	if s, ok := include.value.(string); ok && include.kind == scalarNode {
+		return []string{s}, nil
+	}
+	if include.kind != listNode {
+		return nil, include.errorAt(includeKey, "expected a path or a list of paths")
+	}
+	var paths []string
+	for _, item := range include.items {
+		s, ok := item.value.(string)
+		if !ok || item.kind != scalarNode {
+			return nil, item.errorAt(includeKey, "expected a path")
+		}
+		paths = append(paths, s)
+	}
+	return paths, nil
+}
+
+// parseFile parses the contents of a file in the format its extension names
+func parseFile(path string, data []byte) (*node, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseJSON(data, path)
+	case ".yaml", ".yml":
+		return parseYAML(data, path)
+	case ".toml":
+		return parseTOML(data, path)
+	default:
+		return nil, &simpleai.ValidationError{File: path,
+			Message: fmt.Sprintf("unsupported config format %q; use .json, .yaml, .yml or .toml", filepath.Ext(path))}
+	}
+}
+
+// decodeConfig decodes the merged tree, migrating the legacy format of ConfigMigrator
+func decodeConfig(root *node) (simpleai.FactoryConfig, error) {
+	if _, ok := root.fields["providers"]; !ok {
+		if _, legacy := root.fields["ollama_host"]; legacy {
+			var old simpleai.Config
+			if err := decode(root, &old); err != nil {
+				return simpleai.FactoryConfig{}, err
+			}
+			return simpleai.NewConfigMigrator().MigrateFromOldConfig(old), nil
+		}
+	}
+
+	var config simpleai.FactoryConfig
+	err := decode(root, &config)
+	return config, err
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"simpleai"
+	"strings"
+	"testing"
+)
+
+// env is a fixed environment for ${...} references
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFormats(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.json": `{
+	"default_provider": "openai",
+	"providers": {
+		"openai": {
+			"api_key": "${OPENAI_KEY}",
+			"default_model": "gpt-4o-mini",
+			"timeout": 30,
+			"retry_attempts": "${RETRIES:-2}",
+			"extra_settings": {"max_tokens": 512}
+		}
+	},
+	"fallback_providers": ["openai"]
+}`,
+		"config.yaml": `default_provider: openai
+providers:
+  openai:
+    api_key: ${OPENAI_KEY}
+    default_model: gpt-4o-mini
+    timeout: 30
+    retry_attempts: ${RETRIES:-2}
+    extra_settings:
+      max_tokens: 512
+fallback_providers: [openai]
+`,
+		"config.toml": `default_provider = "openai"
+fallback_providers = ["openai"]
+
+[providers.openai]
+api_key = "${OPENAI_KEY}"
+default_model = "gpt-4o-mini"
+timeout = 30
+retry_attempts = "${RETRIES:-2}"
+extra_settings = { max_tokens = 512 }
+`,
+	})
+
+	for _, name := range []string{"config.json", "config.yaml", "config.toml"} {
+		config, err := LoadWithOptions(filepath.Join(dir, name), Options{LookupEnv: env(map[string]string{"OPENAI_KEY": "sk-test"})})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		openai := config.Providers["openai"]
+		if config.DefaultProvider != "openai" || openai.APIKey != "sk-test" || openai.RetryAttempts != 2 || openai.Timeout != 30 {
+			t.Errorf("%s: unexpected config %+v", name, config)
+		}
+		if openai.ExtraSettings["max_tokens"] != "512" || len(config.FallbackProviders) != 1 {
+			t.Errorf("%s: unexpected extra settings or fallbacks %+v", name, config)
+		}
+	}
+}
+
+func TestAPIKeyReferences(t *testing.T) {
+	t.Setenv("SIMPLEAI_TEST_KEY", "sk-secret-value")
+	dir := writeFiles(t, map[string]string{"config.yaml": `default_provider: openai
+providers:
+  openai:
+    api_key: ${SIMPLEAI_TEST_KEY}
+    default_model: m
+    timeout: 30
+  anthropic:
+    api_key: ${SIMPLEAI_TEST_UNSET:-none}
+    default_model: m
+    timeout: 30
+  groq:
+    api_key: Bearer ${SIMPLEAI_TEST_KEY}
+    default_model: m
+    timeout: 30
+`})
+
+	config, err := Load(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"openai":    "env:SIMPLEAI_TEST_KEY",
+		"anthropic": "none",
+		"groq":      "Bearer sk-secret-value",
+	} {
+		if got := config.Providers[name].APIKey; got != want {
+			t.Errorf("%s: expected api_key %q, got %q", name, want, got)
+		}
+	}
+
+	// The factory resolves the reference when it creates the provider
+	var resolved string
+	factory := simpleai.NewLLMFactory()
+	factory.RegisterProvider("openai", func(settings map[string]interface{}) (simpleai.Provider, error) {
+		resolved, _ = settings["api_key"].(string)
+		return nil, errors.New("not needed")
+	})
+	if err := factory.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	factory.CreateProviderFromConfig("openai")
+	if resolved != "sk-secret-value" || strings.Contains(factory.GetConfig().Providers["openai"].APIKey, "sk-") {
+		t.Errorf("Expected the key to reach only the provider, got %q", resolved)
+	}
+}
+
+func TestIncludesAndProfiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yaml": `default_provider: ollama
+providers:
+  ollama:
+    host: http://localhost:11434
+    default_model: llama3
+    timeout: 60
+    retry_attempts: 3
+profiles:
+  prod:
+    providers:
+      ollama:
+        host: http://gpu:11434
+`,
+		"app.yaml": `include: base.yaml
+providers:
+  ollama:
+    default_model: llama3.1
+  openai:
+    default_model: gpt-4o-mini
+    timeout: 30
+    rate_limit: 100
+profiles:
+  prod:
+    providers:
+      openai: null
+    fallback_providers: [ollama]
+  staging:
+    providers:
+      ollama:
+        api_key: ${STAGING_KEY}
+`,
+	})
+	path := filepath.Join(dir, "app.yaml")
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ollama := config.Providers["ollama"]
+	if ollama.Host != "http://localhost:11434" || ollama.DefaultModel != "llama3.1" || ollama.RetryAttempts != 3 {
+		t.Errorf("Expected the include overlaid by the file, got %+v", ollama)
+	}
+	if config.Providers["openai"].RateLimit != 100 {
+		t.Errorf("Expected openai from the file, got %+v", config.Providers)
+	}
+
+	config, err = LoadWithOptions(path, Options{Profile: "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := config.Providers["openai"]; ok || config.Providers["ollama"].Host != "http://gpu:11434" || len(config.FallbackProviders) != 1 {
+		t.Errorf("Expected the prod profiles of both files, got %+v", config)
+	}
+
+	if _, err := LoadWithOptions(path, Options{Profile: "qa"}); err == nil || !strings.Contains(err.Error(), `profile "qa"`) {
+		t.Errorf("Expected an unknown profile error, got %v", err)
+	}
+}
+
+func TestErrorsHaveFileAndLine(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"unknown.yaml": "default_provider: ollama\nproviders:\n  ollama:\n    default_model: llama3\n    timout: 30\n",
+		"type.json":    "{\n  \"default_provider\": \"ollama\",\n  \"providers\": {\n    \"ollama\": {\"default_model\": \"llama3\", \"timeout\": \"soon\"}\n  }\n}\n",
+		"invalid.toml": "default_provider = \"ollama\"\n\n[providers.ollama]\ndefault_model = \"llama3\"\ntimeout = 0\n",
+		"missing.yaml": "default_provider: ollama\nproviders:\n  ollama:\n    api_key: ${NOT_SET}\n",
+		"syntax.json":  "{\n  \"default_provider\": \"ollama\",\n  \"providers\": {,}\n}\n",
+		"syntax.toml":  "default_provider = \"ollama\"\nproviders = [\n",
+		"cycle.yaml":   "include: cycle.yaml\n",
+	})
+
+	for _, tc := range []struct {
+		file  string
+		field string
+		line  int
+		text  string
+	}{
+		{"unknown.yaml", "providers.ollama.timout", 5, "unknown setting"},
+		{"type.json", "providers.ollama.timeout", 4, "whole number"},
+		{"invalid.toml", "providers.ollama.timeout", 5, "must be positive"},
+		{"missing.yaml", "providers.ollama.api_key", 4, "NOT_SET is not set"},
+		{"syntax.json", "", 3, "invalid character"},
+		{"syntax.toml", "", 2, "unexpected EOF"},
+		{"cycle.yaml", "include", 0, "include cycle"},
+	} {
+		path := filepath.Join(dir, tc.file)
+		_, err := Load(path)
+		var validationErr *simpleai.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: expected a ValidationError, got %v", tc.file, err)
+			continue
+		}
+		if validationErr.File != path || validationErr.Field != tc.field || (tc.line > 0 && validationErr.Line != tc.line) || !strings.Contains(err.Error(), tc.text) {
+			t.Errorf("%s: expected %s at line %d, got %+v", tc.file, tc.field, tc.line, validationErr)
+		}
+	}
+}
+
+func TestValidateOption(t *testing.T) {
+	type anthropicConfig struct {
+		simpleai.ProviderSettings
+		MaxTokens int `json:"max_tokens,omitempty"`
+	}
+	factory := simpleai.NewLLMFactory()
+	factory.RegisterProviderWithConfig("anthropic", nil, anthropicConfig{})
+
+	dir := writeFiles(t, map[string]string{
+		"simpleai.yaml": "default_provider: anthropic\nproviders:\n  anthropic:\n    default_model: claude\n    timeout: 30\n    extra_settings:\n      max_token: 512\n",
+	})
+	path := filepath.Join(dir, "simpleai.yaml")
+	_, err := LoadWithOptions(path, Options{Validate: factory.ValidateConfig})
+	var validationErr *simpleai.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Line != 7 || !strings.Contains(err.Error(), "unknown setting") {
+		t.Errorf("Expected the misspelled setting at line 7, got %v", err)
+	}
+
+	// Without the factory's validation the setting is not checked
+	if _, err := Load(path); err != nil {
+		t.Errorf("Expected the config to load, got %v", err)
+	}
+}
+
+func TestLegacyConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"old.yaml": "ollama_host: http://gpu:11434\ndefault_model: llama3\n"})
+	config, err := Load(filepath.Join(dir, "old.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DefaultProvider != "ollama" || config.Providers["ollama"].Host != "http://gpu:11434" {
+		t.Errorf("Expected the legacy config to be migrated, got %+v", config)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	lookup := env(map[string]string{"HOST": "gpu", "EMPTY": ""})
+	for input, want := range map[string]string{
+		"http://${HOST}:11434":   "http://gpu:11434",
+		"${EMPTY:-fallback}":     "fallback",
+		"${MISSING:-}":           "",
+		"$$HOME and $PATH":       "$HOME and $PATH",
+		"${HOST}${HOST:-x}-tail": "gpugpu-tail",
+	} {
+		got, _, err := expand(input, lookup)
+		if err != nil || got != want {
+			t.Errorf("expand(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"${MISSING}", "${HOST", "${1BAD}"} {
+		if _, _, err := expand(input, lookup); err == nil {
+			t.Errorf("Expected expand(%q) to fail", input)
+		}
+	}
+}
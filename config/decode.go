@@ -0,0 +1,161 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// decode checks a tree against the Go type of target and stores it there. Unknown keys and
+// values of the wrong type are reported at their line. Numbers and booleans are accepted
+// where a string is expected, and strings from ${...} interpolation are parsed where a
+// number or boolean is expected, since environment variables are always strings.
+func decode(n *node, target any) error {
+	value, err := convert(n, reflect.TypeOf(target).Elem(), "")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+func convert(n *node, t reflect.Type, path string) (any, error) {
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.kind != mapNode {
+			return nil, n.errorAt(path, "expected a table of settings")
+		}
+		fields := jsonFields(t)
+		out := make(map[string]any, len(n.keys))
+		for _, key := range n.keys {
+			field, ok := fields[key]
+			if !ok {
+				return nil, n.fields[key].errorAt(join(path, key), "unknown setting")
+			}
+			value, err := convert(n.fields[key], field.Type, join(path, key))
+			if err != nil {
+				return nil, err
+			}
+			out[key] = value
+		}
+		return out, nil
+
+	case reflect.Map:
+		if n.kind == scalarNode && n.value == nil {
+			return nil, nil
+		}
+		if n.kind != mapNode {
+			return nil, n.errorAt(path, "expected a table")
+		}
+		out := make(map[string]any, len(n.keys))
+		for _, key := range n.keys {
+			value, err := convert(n.fields[key], t.Elem(), join(path, key))
+			if err != nil {
+				return nil, err
+			}
+			out[key] = value
+		}
+		return out, nil
+
+	case reflect.Slice:
+		if n.kind == scalarNode && n.value == nil {
+			return nil, nil
+		}
+		if n.kind != listNode {
+			return nil, n.errorAt(path, "expected a list")
+		}
+		out := make([]any, len(n.items))
+		for i, item := range n.items {
+			value, err := convert(item, t.Elem(), join(path, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = value
+		}
+		return out, nil
+
+	case reflect.Interface:
+		return n.plain(), nil
+	}
+
+	if n.kind != scalarNode {
+		return nil, n.errorAt(path, "expected a single value")
+	}
+	switch t.Kind() {
+	case reflect.String:
+		switch v := n.value.(type) {
+		case nil:
+			return "", nil
+		case string:
+			return v, nil
+		default:
+			return fmt.Sprint(v), nil
+		}
+
+	case reflect.Bool:
+		switch v := n.value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil && n.expanded {
+				return b, nil
+			}
+		}
+		return nil, n.errorAt(path, "expected true or false")
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := n.value.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int64(v), nil
+			}
+		case string:
+			if i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil && n.expanded {
+				return i, nil
+			}
+		}
+		return nil, n.errorAt(path, "expected a whole number")
+
+	case reflect.Float32, reflect.Float64:
+		switch v := n.value.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && n.expanded {
+				return f, nil
+			}
+		}
+		return nil, n.errorAt(path, "expected a number")
+	}
+	return nil, n.errorAt(path, fmt.Sprintf("unsupported setting type %s", t))
+}
+
+// jsonFields maps the JSON names of a struct's fields to the fields
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
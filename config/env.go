@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expandNode expands ${...} references in the strings of a tree
+func expandNode(n *node, path string, lookup func(string) (string, bool)) error {
+	switch n.kind {
+	case mapNode:
+		for _, key := range n.keys {
+			if err := expandNode(n.fields[key], join(path, key), lookup); err != nil {
+				return err
+			}
+		}
+	case listNode:
+		for i, item := range n.items {
+			if err := expandNode(item, join(path, fmt.Sprint(i)), lookup); err != nil {
+				return err
+			}
+		}
+	default:
+		s, ok := n.value.(string)
+		if !ok || !strings.Contains(s, "$") {
+			return nil
+		}
+		expanded, changed, err := expand(s, lookup)
+		if err != nil {
+			return n.errorAt(path, err.Error())
+		}
+		n.value, n.expanded = expanded, changed
+	}
+	return nil
+}
+
+// referenceSecrets turns each api_key that is a single ${NAME} reference to a set variable
+// into the secret reference env:NAME, so the factory reads the key when it creates the
+// provider and the config never holds it. Other references are left to expandNode.
+func referenceSecrets(root *node, lookup func(string) (string, bool)) {
+	providers, ok := root.fields["providers"]
+	if !ok || providers.kind != mapNode {
+		return
+	}
+	for _, provider := range providers.fields {
+		if provider.kind != mapNode {
+			continue
+		}
+		key, ok := provider.fields["api_key"]
+		if !ok {
+			continue
+		}
+		s, _ := key.value.(string)
+		if !strings.HasPrefix(s, "${") || strings.IndexByte(s, '}') != len(s)-1 {
+			continue
+		}
+		name, _, _ := strings.Cut(s[2:len(s)-1], ":-")
+		if value, ok := lookup(name); ok && value != "" && validName(name) {
+			key.value = "env:" + name
+		}
+	}
+}
+
+// expand replaces ${NAME} with the variable's value and ${NAME:-default} with the value, or
+// default when the variable is unset or empty. $$ is a literal $. It reports whether any
+// reference was replaced.
+func expand(s string, lookup func(string) (string, bool)) (string, bool, error) {
+	var out strings.Builder
+	changed := false
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i == len(s)-1 {
+			out.WriteString(s)
+			return out.String(), changed, nil
+		}
+		out.WriteString(s[:i])
+
+		switch s[i+1] {
+		case '$':
+			out.WriteByte('$')
+			s = s[i+2:]
+			continue
+		case '{':
+		default:
+			out.WriteByte('$')
+			s = s[i+1:]
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", false, fmt.Errorf("unterminated reference in %q", s)
+		}
+		reference := s[i+2 : i+end]
+		s = s[i+end+1:]
+
+		name, fallback, hasDefault := strings.Cut(reference, ":-")
+		if !validName(name) {
+			return "", false, fmt.Errorf("invalid environment variable name %q", name)
+		}
+		value, ok := lookup(name)
+		switch {
+		case (!ok || value == "") && hasDefault:
+			value = fallback
+		case !ok:
+			return "", false, fmt.Errorf("environment variable %s is not set", name)
+		}
+		out.WriteString(value)
+		changed = true
+	}
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if !(c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// join appends a key to a dotted path
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,301 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"simpleai"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kind is the shape of a node
+type kind int
+
+const (
+	scalarNode kind = iota
+	mapNode
+	listNode
+)
+
+// node is a parsed config value that remembers where it was defined, so errors can point
+// at the file and line of the offending value whichever format it was written in
+type node struct {
+	kind   kind
+	value  any      // Scalars: string, int64, float64, bool or nil
+	keys   []string // Maps: keys in file order
+	fields map[string]*node
+	items  []*node
+
+	file     string
+	line     int
+	expanded bool // A string produced by ${...} interpolation
+}
+
+func newMap(file string, line int) *node {
+	return &node{kind: mapNode, fields: make(map[string]*node), file: file, line: line}
+}
+
+// set adds or replaces a map entry
+func (n *node) set(key string, value *node) {
+	if _, exists := n.fields[key]; !exists {
+		n.keys = append(n.keys, key)
+	}
+	n.fields[key] = value
+}
+
+// remove deletes a map entry
+func (n *node) remove(key string) {
+	if _, exists := n.fields[key]; !exists {
+		return
+	}
+	delete(n.fields, key)
+	for i, k := range n.keys {
+		if k == key {
+			n.keys = append(n.keys[:i:i], n.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// lookup returns the node at a dotted path, or the deepest node on the way to it, so a
+// missing field can still be reported at its parent
+func (n *node) lookup(path string) *node {
+	current := n
+	if path == "" {
+		return current
+	}
+	for _, key := range strings.Split(path, ".") {
+		if current.kind != mapNode {
+			return current
+		}
+		child, ok := current.fields[key]
+		if !ok {
+			return current
+		}
+		current = child
+	}
+	return current
+}
+
+// merge returns overlay applied on top of base. Maps are merged key by key, a null value
+// in overlay removes the key, and anything else in overlay replaces the base value.
+func merge(base, overlay *node) *node {
+	return mergeNodes(base, overlay, true)
+}
+
+// combine merges two overlays into one, keeping nulls so the result still removes keys
+// when it is applied
+func combine(base, overlay *node) *node {
+	return mergeNodes(base, overlay, false)
+}
+
+func mergeNodes(base, overlay *node, removeNulls bool) *node {
+	if base == nil || base.kind != mapNode || overlay.kind != mapNode {
+		return overlay
+	}
+	merged := newMap(overlay.file, overlay.line)
+	for _, key := range base.keys {
+		merged.set(key, base.fields[key])
+	}
+	for _, key := range overlay.keys {
+		value := overlay.fields[key]
+		if removeNulls && value.kind == scalarNode && value.value == nil {
+			merged.remove(key)
+			continue
+		}
+		merged.set(key, mergeNodes(merged.fields[key], value, removeNulls))
+	}
+	return merged
+}
+
+// plain converts the node to the values encoding/json produces
+func (n *node) plain() any {
+	switch n.kind {
+	case mapNode:
+		m := make(map[string]any, len(n.fields))
+		for key, value := range n.fields {
+			m[key] = value.plain()
+		}
+		return m
+	case listNode:
+		l := make([]any, len(n.items))
+		for i, item := range n.items {
+			l[i] = item.plain()
+		}
+		return l
+	default:
+		return n.value
+	}
+}
+
+// errorAt returns a validation error for the value at n
+func (n *node) errorAt(field, message string) error {
+	var value any
+	if n.kind == scalarNode {
+		value = n.value
+	}
+	return &simpleai.ValidationError{Field: field, Value: value, Message: message, File: n.file, Line: n.line}
+}
+
+// parseJSON parses a JSON document, tracking lines through the decoder's input offset
+func parseJSON(data []byte, file string) (*node, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	line := func() int {
+		offset := int(decoder.InputOffset())
+		// The offset is just past the token, which cannot span lines
+		return bytes.Count(data[:offset], []byte("\n")) + 1
+	}
+
+	var parse func(token json.Token) (*node, error)
+	parse = func(token json.Token) (*node, error) {
+		start := line()
+		switch t := token.(type) {
+		case json.Delim:
+			if t == '[' {
+				list := &node{kind: listNode, file: file, line: start}
+				for decoder.More() {
+					next, err := decoder.Token()
+					if err != nil {
+						return nil, err
+					}
+					item, err := parse(next)
+					if err != nil {
+						return nil, err
+					}
+					list.items = append(list.items, item)
+				}
+				_, err := decoder.Token()
+				return list, err
+			}
+			object := newMap(file, start)
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				next, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := parse(next)
+				if err != nil {
+					return nil, err
+				}
+				object.set(key.(string), value)
+			}
+			_, err := decoder.Token()
+			return object, err
+		case json.Number:
+			if i, err := t.Int64(); err == nil {
+				return &node{value: i, file: file, line: start}, nil
+			}
+			f, err := t.Float64()
+			return &node{value: f, file: file, line: start}, err
+		default:
+			return &node{value: t, file: file, line: start}, nil
+		}
+	}
+
+	token, err := decoder.Token()
+	if err == io.EOF {
+		return newMap(file, 1), nil
+	}
+	if err != nil {
+		return nil, syntaxError(file, data, decoder.InputOffset(), err)
+	}
+	root, err := parse(token)
+	if err != nil {
+		return nil, syntaxError(file, data, decoder.InputOffset(), err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, syntaxError(file, data, decoder.InputOffset(), fmt.Errorf("unexpected data after the top-level value"))
+	}
+	return root, nil
+}
+
+// syntaxError reports a JSON parse error at the line of offset
+func syntaxError(file string, data []byte, offset int64, err error) error {
+	if syntax, ok := err.(*json.SyntaxError); ok {
+		offset = syntax.Offset
+	}
+	offset = min(offset, int64(len(data)))
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	return &simpleai.ValidationError{Message: err.Error(), File: file, Line: line}
+}
+
+// parseYAML parses a YAML document
+func parseYAML(data []byte, file string) (*node, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, &simpleai.ValidationError{Message: err.Error(), File: file, Line: yamlErrorLine(err)}
+	}
+	if len(document.Content) == 0 {
+		return newMap(file, 1), nil
+	}
+	return fromYAML(document.Content[0], file)
+}
+
+func fromYAML(y *yaml.Node, file string) (*node, error) {
+	switch y.Kind {
+	case yaml.AliasNode:
+		return fromYAML(y.Alias, file)
+	case yaml.MappingNode:
+		m := newMap(file, y.Line)
+		for i := 0; i+1 < len(y.Content); i += 2 {
+			value, err := fromYAML(y.Content[i+1], file)
+			if err != nil {
+				return nil, err
+			}
+			m.set(y.Content[i].Value, value)
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		list := &node{kind: listNode, file: file, line: y.Line}
+		for _, item := range y.Content {
+			converted, err := fromYAML(item, file)
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, converted)
+		}
+		return list, nil
+	}
+
+	scalar := &node{file: file, line: y.Line}
+	var err error
+	switch y.ShortTag() {
+	case "!!null":
+	case "!!bool":
+		var b bool
+		err = y.Decode(&b)
+		scalar.value = b
+	case "!!int":
+		var i int64
+		err = y.Decode(&i)
+		scalar.value = i
+	case "!!float":
+		var f float64
+		err = y.Decode(&f)
+		scalar.value = f
+	default:
+		scalar.value = y.Value
+	}
+	if err != nil {
+		return nil, &simpleai.ValidationError{Message: err.Error(), File: file, Line: y.Line}
+	}
+	return scalar, nil
+}
+
+// yamlErrorLine finds the line in a yaml.v3 error, which reports it as "line N:"
+func yamlErrorLine(err error) int {
+	var line int
+	message := err.Error()
+	if i := strings.Index(message, "line "); i >= 0 {
+		fmt.Sscanf(message[i:], "line %d", &line)
+	}
+	return line
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"errors"
+	"simpleai"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// parseTOML parses a TOML document
+func parseTOML(data []byte, file string) (*node, error) {
+	var document map[string]any
+	if _, err := toml.Decode(string(data), &document); err != nil {
+		line := 0
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			line = parseErr.Position.Line
+		}
+		return nil, &simpleai.ValidationError{Message: err.Error(), File: file, Line: line}
+	}
+	return fromTOML(document, nil, tomlLines(data), file, 1), nil
+}
+
+// fromTOML converts a decoded TOML value. Keys are sorted, since decoded tables do not keep
+// their order.
+func fromTOML(value any, path []string, lines map[string]int, file string, parentLine int) *node {
+	line := parentLine
+	if l, ok := lines[strings.Join(path, ".")]; ok {
+		line = l
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		m := newMap(file, line)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			m.set(key, fromTOML(v[key], append(path[:len(path):len(path)], key), lines, file, line))
+		}
+		return m
+	case []map[string]any:
+		list := &node{kind: listNode, file: file, line: line}
+		for i, item := range v {
+			list.items = append(list.items, fromTOML(item, append(path[:len(path):len(path)], strconv.Itoa(i)), lines, file, line))
+		}
+		return list
+	case []any:
+		list := &node{kind: listNode, file: file, line: line}
+		for i, item := range v {
+			list.items = append(list.items, fromTOML(item, append(path[:len(path):len(path)], strconv.Itoa(i)), lines, file, line))
+		}
+		return list
+	case time.Time:
+		return &node{value: v.Format(time.RFC3339Nano), file: file, line: line}
+	default:
+		return &node{value: v, file: file, line: line}
+	}
+}
+
+// tomlLines maps dotted key paths to the line that defines them. The TOML decoder does not
+// expose key positions, so they are recovered from the source: table headers set the
+// current table and key/value lines add keys to it. Values inside multi-line strings,
+// arrays and inline tables are attributed to the line their key is on.
+func tomlLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	arrayTables := make(map[string]int)
+	var table []string
+	depth := 0     // Open brackets and braces of a multi-line value
+	inString := "" // Delimiter of an open multi-line string
+	for i, text := range strings.Split(string(data), "\n") {
+		number := i + 1
+		text = strings.TrimSpace(text)
+
+		if inString != "" {
+			if strings.Contains(text, inString) {
+				inString = ""
+			}
+			continue
+		}
+		if depth > 0 {
+			depth += bracketDepth(text)
+			continue
+		}
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(text, "[["):
+			name := tomlKey(strings.TrimSuffix(strings.TrimSpace(text[2:strings.Index(text+"]]", "]]")]), "]"))
+			key := strings.Join(name, ".")
+			index := arrayTables[key]
+			arrayTables[key] = index + 1
+			table = append(name, strconv.Itoa(index))
+			lines[key] = firstLine(lines[key], number)
+			lines[strings.Join(table, ".")] = number
+		case text[0] == '[':
+			table = tomlKey(text[1:strings.Index(text+"]", "]")])
+			lines[strings.Join(table, ".")] = number
+		default:
+			eq := strings.Index(text, "=")
+			if eq < 0 {
+				continue
+			}
+			key := append(append([]string(nil), table...), tomlKey(text[:eq])...)
+			lines[strings.Join(key, ".")] = number
+
+			value := strings.TrimSpace(text[eq+1:])
+			for _, delimiter := range []string{`"""`, `'''`} {
+				if strings.HasPrefix(value, delimiter) && !strings.Contains(value[3:], delimiter) {
+					inString = delimiter
+				}
+			}
+			depth = bracketDepth(value)
+		}
+	}
+	return lines
+}
+
+// firstLine keeps an existing line, which is earlier in the file
+func firstLine(existing, line int) int {
+	if existing > 0 {
+		return existing
+	}
+	return line
+}
+
+// tomlKey splits a possibly dotted and quoted key into its parts
+func tomlKey(text string) []string {
+	var parts []string
+	var current strings.Builder
+	quote := byte(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			parts = append(parts, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	return append(parts, strings.TrimSpace(current.String()))
+}
+
+// bracketDepth counts the brackets and braces a line opens but does not close, ignoring
+// those in strings and comments
+func bracketDepth(text string) int {
+	depth := 0
+	quote := byte(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return depth
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"simpleai"
+	"sync"
+	"time"
+)
+
+// defaultInterval is how often a Watcher checks its files by default
+const defaultInterval = 2 * time.Second
+
+// ReloadEvent reports the outcome of a reload
+type ReloadEvent struct {
+	Path    string
+	Time    time.Time
+	Changed []string // Providers that were added, changed or removed
+	Err     error    // Why the reload failed; the previous config stays in use
+}
+
+// WatchOptions control a Watcher
+type WatchOptions struct {
+	Options // How the config file is loaded
+
+	// Interval is how often the files are checked for changes. Defaults to two seconds.
+	Interval time.Duration
+
+	// OnReload is called after every reload, successful or not
+	OnReload func(event ReloadEvent)
+}
+
+// Watcher reloads a factory's configuration when its config file, or a file it includes,
+// changes. Files are polled, which works the same on every platform and with editors and
+// orchestrators that replace files rather than write them in place. New configs are
+// applied with LLMFactory.ReloadConfig, so only the providers whose settings changed are
+// rebuilt and requests in flight are not interrupted. A config that fails to load or
+// validate is reported and skipped; the factory keeps the last good one.
+type Watcher struct {
+	path    string
+	factory *simpleai.LLMFactory
+	options WatchOptions
+
+	mu     sync.Mutex
+	hashes map[string][sha256.Size]byte // Contents of the files the config was loaded from
+}
+
+// NewWatcher creates a watcher for the config file at path
+func NewWatcher(path string, factory *simpleai.LLMFactory, options WatchOptions) *Watcher {
+	if options.Interval <= 0 {
+		options.Interval = defaultInterval
+	}
+	return &Watcher{path: path, factory: factory, options: options}
+}
+
+// Run reloads the config, then polls for changes until ctx is done. The first reload
+// brings the factory up to date with the file even if it changed since it was loaded.
+func (w *Watcher) Run(ctx context.Context) error {
+	w.Reload()
+
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if w.modified() {
+				w.Reload()
+			}
+		}
+	}
+}
+
+// Reload loads the config file and applies it to the factory
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// The files read are watched as they were parsed, so a write landing after they were
+	// read is picked up by the next poll
+	config, hashes, err := load(w.path, w.options.Options)
+	if err != nil {
+		// Keep watching the files of the last good config, so fixing any of them reloads
+		for file := range w.hashes {
+			if _, read := hashes[file]; !read {
+				hashes[file] = hashFile(file)
+			}
+		}
+	}
+	w.hashes = hashes
+
+	event := ReloadEvent{Path: w.path, Time: time.Now(), Err: err}
+	if err == nil {
+		event.Changed, event.Err = w.factory.ReloadConfig(config)
+	}
+	if w.options.OnReload != nil {
+		w.options.OnReload(event)
+	}
+	return event.Err
+}
+
+// modified reports whether any watched file changed since the last reload
+func (w *Watcher) modified() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.hashes) == 0 {
+		return true
+	}
+	for file, hash := range w.hashes {
+		if hashFile(file) != hash {
+			return true
+		}
+	}
+	return false
+}
+
+// hashFile returns the hash of a file's contents, or of nothing if it cannot be read
+func hashFile(path string) [sha256.Size]byte {
+	data, _ := os.ReadFile(path)
+	return sha256.Sum256(data)
+}
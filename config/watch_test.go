@@ -0,0 +1,106 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"simpleai"
+	"testing"
+	"time"
+)
+
+func TestWatcherReloadsOnChange(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"simpleai.yaml": "default_provider: ollama\nproviders:\n  ollama:\n    default_model: llama3\n    timeout: 60\n",
+	})
+	path := filepath.Join(dir, "simpleai.yaml")
+
+	factory := simpleai.NewLLMFactory()
+	events := make(chan ReloadEvent, 10)
+	watcher := NewWatcher(path, factory, WatchOptions{
+		Interval: 10 * time.Millisecond,
+		OnReload: func(event ReloadEvent) { events <- event },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Run(ctx)
+
+	next := func() ReloadEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reload event")
+			return ReloadEvent{}
+		}
+	}
+
+	if event := next(); event.Err != nil || len(event.Changed) != 1 || event.Changed[0] != "ollama" {
+		t.Fatalf("initial reload = %+v", event)
+	}
+
+	update := "default_provider: ollama\nproviders:\n  ollama:\n    default_model: llama3\n    timeout: 60\n  openai:\n    default_model: gpt-4o-mini\n    timeout: 30\n"
+	if err := os.WriteFile(path, []byte(update), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(); event.Err != nil || len(event.Changed) != 1 || event.Changed[0] != "openai" {
+		t.Fatalf("reload after change = %+v", event)
+	}
+	if _, ok := factory.GetConfig().Providers["openai"]; !ok {
+		t.Error("factory config was not updated")
+	}
+
+	// An invalid config is reported and the previous one is kept
+	if err := os.WriteFile(path, []byte("default_provider: missing\nproviders:\n  ollama:\n    default_model: llama3\n    timeout: 60\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(); event.Err == nil {
+		t.Fatal("invalid config reloaded without error")
+	}
+	if config := factory.GetConfig(); config.DefaultProvider != "ollama" || len(config.Providers) != 2 {
+		t.Errorf("config after failed reload = %+v", config)
+	}
+
+	// Fixing the file reloads it
+	if err := os.WriteFile(path, []byte(update), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(); event.Err != nil || len(event.Changed) != 0 {
+		t.Fatalf("reload after fix = %+v", event)
+	}
+}
+
+func TestWatcherNoticesWriteDuringReload(t *testing.T) {
+	first := "default_provider: ollama\nproviders:\n  ollama:\n    default_model: llama3\n    timeout: 60\n"
+	dir := writeFiles(t, map[string]string{"simpleai.yaml": first})
+	path := filepath.Join(dir, "simpleai.yaml")
+
+	// The file is rewritten after it was read but before the reload finishes
+	written := false
+	factory := simpleai.NewLLMFactory()
+	watcher := NewWatcher(path, factory, WatchOptions{Options: Options{
+		Validate: func(config simpleai.FactoryConfig) error {
+			if !written {
+				written = true
+				second := "default_provider: ollama\nproviders:\n  ollama:\n    default_model: qwen2.5\n    timeout: 60\n"
+				if err := os.WriteFile(path, []byte(second), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return simpleai.ValidateFactoryConfig(config)
+		},
+	}})
+	if err := watcher.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !watcher.modified() {
+		t.Fatal("Expected the write during the reload to count as a change")
+	}
+	if err := watcher.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if model := factory.GetConfig().Providers["ollama"].DefaultModel; model != "qwen2.5" {
+		t.Errorf("Expected the rewritten file to be applied, got model %q", model)
+	}
+}
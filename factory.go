@@ -1,7 +1,12 @@
 package simpleai
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"simpleai/schema"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -48,6 +53,13 @@ type LLMFactory struct {
 	config        FactoryConfig
 	providerCache map[string]Provider // Cache for created providers
 	modelCache    map[string][]Model  // Cache for model lists
+	providerKeys  map[string]string   // API keys of cached and failed providers, by cache key
+	generation    int                 // Bumped when cached providers may be out of date
+
+	breakerConfig *CircuitBreakerConfig      // Circuit breaker settings, nil when disabled
+	breakers      map[string]*CircuitBreaker // Per-provider breakers, kept across cache clears
+
+	secretResolvers map[string]SecretResolver // Resolvers of api_key references, by scheme
 }
 
 // NewLLMFactory creates a new LLM factory with default configuration
@@ -57,7 +69,44 @@ func NewLLMFactory() *LLMFactory {
 		config:        FactoryConfig{},
 		providerCache: make(map[string]Provider),
 		modelCache:    make(map[string][]Model),
+		providerKeys:  make(map[string]string),
+		breakers:      make(map[string]*CircuitBreaker),
+
+		secretResolvers: defaultSecretResolvers(),
+	}
+}
+
+// EnableCircuitBreakers wraps every provider the factory creates in a per-provider circuit breaker
+func (f *LLMFactory) EnableCircuitBreakers(config CircuitBreakerConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.breakerConfig = &config
+	f.breakers = make(map[string]*CircuitBreaker)
+
+	// Cached providers were created without breakers
+	f.clearProvidersUnsafe()
+}
+
+// CircuitBreaker returns the circuit breaker for a provider, or nil if circuit breakers are disabled
+func (f *LLMFactory) CircuitBreaker(providerName string) *CircuitBreaker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.breakerConfig == nil {
+		return nil
+	}
+	return f.breakerUnsafe(providerName)
+}
+
+// breakerUnsafe returns the breaker for a provider, creating it if needed (internal use only)
+func (f *LLMFactory) breakerUnsafe(providerName string) *CircuitBreaker {
+	breaker, exists := f.breakers[providerName]
+	if !exists {
+		breaker = NewCircuitBreaker(providerName, *f.breakerConfig)
+		f.breakers[providerName] = breaker
 	}
+	return breaker
 }
 
 // RegisterProvider registers a new provider constructor with the factory
@@ -67,45 +116,186 @@ func (f *LLMFactory) RegisterProvider(name string, constructor ProviderConstruct
 	f.registry.Register(name, constructor)
 }
 
-// CreateProvider creates a provider instance with the given configuration
-func (f *LLMFactory) CreateProvider(providerName string, config map[string]interface{}) (Provider, error) {
+// RegisterProviderWithConfig registers a provider constructor along with the config struct
+// its settings decode into, such as openai.Config{}. The struct describes the provider's
+// settings to ConfigSchema and ValidateConfig.
+func (f *LLMFactory) RegisterProviderWithConfig(name string, constructor ProviderConstructor, config interface{}) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	return f.createProviderUnsafe(providerName, config)
+	f.registry.RegisterWithConfig(name, constructor, config)
+}
+
+// ConfigSchema returns the JSON Schema of a provider's settings, for providers registered
+// with RegisterProviderWithConfig
+func (f *LLMFactory) ConfigSchema(providerName string) (schema.Schema, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	configType := f.registry.ConfigType(providerName)
+	if configType == nil {
+		return nil, NewLLMError(ErrInvalidConfig,
+			fmt.Sprintf("provider %s has no config schema", providerName),
+			"config_schema", false, 0, nil)
+	}
+	return providerConfigSchema(reflect.New(configType).Interface()), nil
+}
+
+// CreateProvider creates a provider instance with the given configuration
+func (f *LLMFactory) CreateProvider(providerName string, config map[string]interface{}) (Provider, error) {
+	return f.createCached(providerName, func() (string, map[string]interface{}, error) {
+		return providerName, config, nil
+	})
+}
+
+// createCached returns the provider cached under the key lookup returns, creating it with
+// the config lookup returns if needed. lookup runs under the read lock. Secret resolvers
+// may be slow or call back into the factory, so api_key is resolved without the lock, and
+// the lookup starts over if the cache was cleared in the meantime.
+func (f *LLMFactory) createCached(providerName string, lookup func() (cacheKey string, config map[string]interface{}, err error)) (Provider, error) {
+	for {
+		f.mu.RLock()
+		generation := f.generation
+		cacheKey, config, err := lookup()
+		provider, cached := f.providerCache[cacheKey]
+		resolver := f.secretResolverUnsafe(config)
+		f.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		if cached {
+			return provider, nil
+		}
+
+		config, key, err := resolveSecrets(providerName, config, resolver)
+		if err != nil {
+			return nil, err
+		}
+
+		f.mu.Lock()
+		if f.generation != generation {
+			f.mu.Unlock()
+			continue
+		}
+		provider, err = f.createCachedUnsafe(cacheKey, providerName, config, key)
+		f.mu.Unlock()
+		return provider, err
+	}
 }
 
-// createProviderUnsafe creates a provider without acquiring locks (internal use only)
-func (f *LLMFactory) createProviderUnsafe(providerName string, config map[string]interface{}) (Provider, error) {
-	// Check if provider is cached
-	if provider, exists := f.providerCache[providerName]; exists {
+// createCachedUnsafe creates a provider from resolved settings and caches it under
+// cacheKey, unless another caller cached one first (internal use only)
+func (f *LLMFactory) createCachedUnsafe(cacheKey, providerName string, config map[string]interface{}, key string) (Provider, error) {
+	if provider, exists := f.providerCache[cacheKey]; exists {
 		return provider, nil
 	}
 
+	// Remembered before creating, so errors echoing the key are redacted. A failed creation
+	// keeps the key under cacheKey too, since its error may be logged later, until the entry
+	// is dropped or replaced by the next attempt.
+	rememberSecret(key)
+	forgetSecret(f.providerKeys[cacheKey])
+	f.providerKeys[cacheKey] = key
+
 	// Create new provider instance
-	provider, err := f.registry.Create(providerName, config)
+	provider, err := f.registry.Create(f.providerTypeUnsafe(providerName), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create provider %s: %w", providerName, err)
 	}
+	provider = trackCalls(provider)
+
+	if f.breakerConfig != nil {
+		provider = NewCircuitBreakerProvider(provider, f.breakerUnsafe(providerName))
+	}
 
 	// Cache the provider for reuse
-	f.providerCache[providerName] = provider
+	f.providerCache[cacheKey] = provider
 
 	return provider, nil
 }
 
+// dropProviderUnsafe removes a provider from the cache, closing it once its running calls
+// have finished, and forgets its API key (internal use only)
+func (f *LLMFactory) dropProviderUnsafe(cacheKey string) {
+	retireProvider(f.providerCache[cacheKey])
+	forgetSecret(f.providerKeys[cacheKey])
+	delete(f.providerCache, cacheKey)
+	delete(f.providerKeys, cacheKey)
+	f.generation++
+}
+
+// providerTypeUnsafe returns the registered provider an instance is created with (internal
+// use only)
+func (f *LLMFactory) providerTypeUnsafe(providerName string) string {
+	return ProviderType(providerName, f.config.Providers[providerName])
+}
+
+// ProviderType returns the registered provider a config entry is created with: its Type,
+// or for entries without one, the entry's own name
+func ProviderType(providerName string, providerConfig ProviderConfig) string {
+	if providerConfig.Type != "" {
+		return providerConfig.Type
+	}
+	return providerName
+}
+
 // CreateProviderFromConfig creates a provider using the factory's stored configuration
 func (f *LLMFactory) CreateProviderFromConfig(providerName string) (Provider, error) {
+	return f.CreateProviderWithModel(providerName, "")
+}
+
+// CreateProviderWithModel creates a provider using the factory's stored configuration with
+// model in place of its default model. Instances are cached per provider and model, and
+// share the provider's circuit breaker. Instances are kept until the provider's config
+// changes, so callers passing model names from untrusted clients should check them first,
+// as the gateway does against ListModels.
+func (f *LLMFactory) CreateProviderWithModel(providerName, model string) (Provider, error) {
+	return f.createCached(providerName, func() (string, map[string]interface{}, error) {
+		providerConfig, exists := f.config.Providers[providerName]
+		if !exists {
+			return "", nil, NewLLMError(ErrInvalidConfig,
+				fmt.Sprintf("no configuration found for provider: %s", providerName),
+				"provider_creation", false, 0, nil)
+		}
+		if model == "" || model == providerConfig.DefaultModel {
+			return providerName, providerConfigMap(providerConfig), nil
+		}
+
+		providerConfig.DefaultModel = model
+		return providerName + "/" + model, providerConfigMap(providerConfig), nil
+	})
+}
+
+// CreateProviderForTask creates the provider for a task named in ModelPreferences, such as
+// "coding". A preference is "instance/model", an instance name for its default model, or a
+// model of the default provider. Tasks without a preference use the "default" preference,
+// and without that the default provider.
+func (f *LLMFactory) CreateProviderForTask(task string) (Provider, error) {
 	f.mu.RLock()
-	providerConfig, exists := f.config.Providers[providerName]
+	preference, exists := f.config.ModelPreferences[task]
+	if !exists {
+		preference = f.config.ModelPreferences["default"]
+	}
+	providerName, model := f.config.DefaultProvider, preference
+	if _, isInstance := f.config.Providers[preference]; isInstance {
+		providerName, model = preference, ""
+	} else if instance, instanceModel, found := strings.Cut(preference, "/"); found {
+		// Model names may contain slashes too, so only configured instances are split off
+		if _, isInstance := f.config.Providers[instance]; isInstance {
+			providerName, model = instance, instanceModel
+		}
+	}
 	f.mu.RUnlock()
 
-	if !exists {
+	if providerName == "" {
 		return nil, NewLLMError(ErrInvalidConfig,
-			fmt.Sprintf("no configuration found for provider: %s", providerName),
+			fmt.Sprintf("no provider configured for task: %s", task),
 			"provider_creation", false, 0, nil)
 	}
+	return f.CreateProviderWithModel(providerName, model)
+}
 
-	// Convert ProviderConfig to map[string]interface{}
+// providerConfigMap converts a ProviderConfig to the map provider constructors receive
+func providerConfigMap(providerConfig ProviderConfig) map[string]interface{} {
 	configMap := map[string]interface{}{
 		"host":           providerConfig.Host,
 		"api_key":        providerConfig.APIKey,
@@ -119,8 +309,7 @@ func (f *LLMFactory) CreateProviderFromConfig(providerName string) (Provider, er
 	for key, value := range providerConfig.ExtraSettings {
 		configMap[key] = value
 	}
-
-	return f.CreateProvider(providerName, configMap)
+	return configMap
 }
 
 // ListProviders returns the names of all registered providers
@@ -130,9 +319,12 @@ func (f *LLMFactory) ListProviders() []string {
 	return f.registry.List()
 }
 
-// ListModels returns available models for a specific provider
+// ListModels returns available models for a specific provider. The list is only cached if
+// the config has not been reloaded while it was fetched, so a list from a replaced config
+// is never kept.
 func (f *LLMFactory) ListModels(providerName string) ([]Model, error) {
 	f.mu.RLock()
+	generation := f.generation
 	// Check cache first
 	if models, exists := f.modelCache[providerName]; exists {
 		f.mu.RUnlock()
@@ -153,7 +345,9 @@ func (f *LLMFactory) ListModels(providerName string) ([]Model, error) {
 
 	// Cache the results
 	f.mu.Lock()
-	f.modelCache[providerName] = models
+	if f.generation == generation {
+		f.modelCache[providerName] = models
+	}
 	f.mu.Unlock()
 
 	return models, nil
@@ -179,7 +373,7 @@ func (f *LLMFactory) SetDefaultProvider(providerName string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	if !f.registry.Exists(providerName) {
+	if !f.registry.Exists(f.providerTypeUnsafe(providerName)) {
 		return NewLLMError(ErrInvalidConfig,
 			fmt.Sprintf("provider not registered: %s", providerName),
 			"set_default_provider", false, 0, nil)
@@ -199,7 +393,7 @@ func (f *LLMFactory) GetDefaultProviderName() string {
 // IsProviderAvailable checks if a provider is registered and available
 func (f *LLMFactory) IsProviderAvailable(providerName string) bool {
 	f.mu.RLock()
-	registryExists := f.registry.Exists(providerName)
+	registryExists := f.registry.Exists(f.providerTypeUnsafe(providerName))
 	f.mu.RUnlock()
 
 	if !registryExists {
@@ -226,6 +420,56 @@ func (f *LLMFactory) GetProviderFeatures(providerName string) (ProviderFeatures,
 	return provider.SupportedFeatures(), nil
 }
 
+// ChatWithFallback sends a chat request to the default provider, then to each of the
+// configured fallback providers in order until one succeeds. A provider is skipped when it
+// cannot be created or fails with a connection, timeout, rate limit or other retryable error.
+func (f *LLMFactory) ChatWithFallback(request ChatRequest) (ChatResponse, error) {
+	f.mu.RLock()
+	candidates := append([]string{f.config.DefaultProvider}, f.config.FallbackProviders...)
+	f.mu.RUnlock()
+
+	var lastErr error
+	tried := make(map[string]bool)
+	for _, providerName := range candidates {
+		if providerName == "" || tried[providerName] {
+			continue
+		}
+		tried[providerName] = true
+
+		provider, err := f.CreateProviderFromConfig(providerName)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		response, err := provider.Chat(request)
+		if err == nil {
+			return response, nil
+		}
+		lastErr = err
+
+		if !ShouldFallback(err) {
+			return ChatResponse{}, err
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = NewLLMError(ErrInvalidConfig,
+			"no default or fallback providers configured",
+			"chat_with_fallback", false, 0, nil)
+	}
+	return ChatResponse{}, lastErr
+}
+
+// ShouldFallback reports whether a failed request should be retried on the next provider
+func ShouldFallback(err error) bool {
+	switch errorType(err) {
+	case ErrConnectionFailed, ErrTimeout, ErrRateLimitExceeded, ErrModelNotAvailable:
+		return true
+	}
+	return IsRetryable(err)
+}
+
 // LoadConfig loads factory configuration from a FactoryConfig struct
 func (f *LLMFactory) LoadConfig(config FactoryConfig) error {
 	f.mu.Lock()
@@ -254,17 +498,114 @@ func (f *LLMFactory) LoadConfig(config FactoryConfig) error {
 	f.config = config
 
 	// Clear caches when config changes
-	f.providerCache = make(map[string]Provider)
+	f.clearProvidersUnsafe()
 	f.modelCache = make(map[string][]Model)
 
 	return nil
 }
 
-// GetConfig returns the current factory configuration
+// ReloadConfig validates config with ValidateConfig and swaps it in atomically. Unlike
+// LoadConfig it keeps the cached providers, model lists and circuit breakers of providers
+// whose configuration is unchanged; the others are rebuilt on their next use. Requests
+// already running on a replaced provider finish on it. Replaced providers that implement
+// io.Closer, such as exec plugins, are closed once their in-flight requests have finished.
+// It returns the names of the providers that were added, changed or removed, in order.
+func (f *LLMFactory) ReloadConfig(config FactoryConfig) ([]string, error) {
+	if err := f.ValidateConfig(config); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var changed []string
+	for name, providerConfig := range config.Providers {
+		if old, exists := f.config.Providers[name]; !exists || !reflect.DeepEqual(old, providerConfig) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range f.config.Providers {
+		if _, exists := config.Providers[name]; !exists {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+
+	for _, name := range changed {
+		for key := range f.providerKeys {
+			// Providers created for other models are cached as name/model
+			if key == name || strings.HasPrefix(key, name+"/") {
+				f.dropProviderUnsafe(key)
+			}
+		}
+		delete(f.modelCache, name)
+		delete(f.breakers, name)
+	}
+	f.config = config
+	f.generation++
+
+	return changed, nil
+}
+
+// ValidateConfig checks config with ValidateFactoryConfig and that every type names a
+// registered provider, then decodes the settings of each provider registered with a config
+// struct, so unknown settings, such as a misspelled ExtraSettings key, and values of the
+// wrong type are reported before the provider is used. Errors wrap a *ValidationError
+// naming the field, such as "providers.anthropic.extra_settings.max_tokens".
+func (f *LLMFactory) ValidateConfig(config FactoryConfig) error {
+	if err := ValidateFactoryConfig(config); err != nil {
+		return err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	names := make([]string, 0, len(config.Providers))
+	for name := range config.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		providerConfig := config.Providers[name]
+		if providerConfig.Type != "" && !f.registry.Exists(providerConfig.Type) {
+			return invalidConfig("providers."+name+".type", providerConfig.Type,
+				fmt.Sprintf("provider %s has unknown type %s", name, providerConfig.Type),
+				"must name a registered provider")
+		}
+		configType := f.registry.ConfigType(ProviderType(name, providerConfig))
+		if configType == nil {
+			continue
+		}
+		err := DecodeProviderConfig(providerConfigMap(providerConfig), reflect.New(configType).Interface())
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			continue
+		}
+		field := "providers." + name + "." + validationErr.Field
+		if _, extra := providerConfig.ExtraSettings[validationErr.Field]; extra {
+			field = "providers." + name + ".extra_settings." + validationErr.Field
+		}
+		return invalidConfig(field, validationErr.Value,
+			fmt.Sprintf("provider %s has an invalid setting %s: %s", name, validationErr.Field, validationErr.Message),
+			validationErr.Message)
+	}
+	return nil
+}
+
+// GetConfig returns a copy of the current factory configuration with literal API keys
+// replaced by Redacted. Secret references this factory resolves, such as env:NAME, are
+// returned as they are.
 func (f *LLMFactory) GetConfig() FactoryConfig {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
-	return f.config
+
+	config := f.config
+	config.Providers = make(map[string]ProviderConfig, len(f.config.Providers))
+	for name, providerConfig := range f.config.Providers {
+		providerConfig.APIKey = redactKey(providerConfig.APIKey, f.secretResolvers)
+		config.Providers[name] = providerConfig
+	}
+	return config
 }
 
 // ClearModelCache clears the cached model lists (useful for refreshing)
@@ -274,10 +615,20 @@ func (f *LLMFactory) ClearModelCache() {
 	f.modelCache = make(map[string][]Model)
 }
 
-// ClearProviderCache clears the cached provider instances
+// ClearProviderCache clears the cached provider instances, closing those that implement
+// io.Closer once their running calls have finished
 func (f *LLMFactory) ClearProviderCache() {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	f.providerCache = make(map[string]Provider)
+	f.clearProvidersUnsafe()
+}
+
+// clearProvidersUnsafe empties the provider cache, closing each provider that implements
+// io.Closer once its running calls have finished (internal use only)
+func (f *LLMFactory) clearProvidersUnsafe() {
+	for cacheKey := range f.providerKeys {
+		f.dropProviderUnsafe(cacheKey)
+	}
+	f.generation++
 }
 
@@ -0,0 +1,294 @@
+package simpleai
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReloadConfigRebuildsOnlyChangedProviders(t *testing.T) {
+	created := map[string]int{}
+	factory := NewLLMFactory()
+	for _, name := range []string{"ollama", "openai"} {
+		name := name
+		factory.RegisterProvider(name, func(config map[string]interface{}) (Provider, error) {
+			created[name]++
+			return &stubProvider{name: name}, nil
+		})
+	}
+
+	config := FactoryConfig{
+		DefaultProvider: "ollama",
+		Providers: map[string]ProviderConfig{
+			"ollama": {DefaultModel: "llama3", Timeout: 60},
+			"openai": {DefaultModel: "gpt-4o-mini", Timeout: 30},
+		},
+	}
+	if err := factory.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	ollama, _ := factory.CreateProviderFromConfig("ollama")
+	factory.CreateProviderFromConfig("openai")
+	factory.CreateProviderWithModel("openai", "gpt-4o")
+
+	updated := FactoryConfig{
+		DefaultProvider: "ollama",
+		Providers: map[string]ProviderConfig{
+			"ollama": {DefaultModel: "llama3", Timeout: 60},
+			"openai": {DefaultModel: "gpt-4o-mini", Timeout: 30, RateLimit: 10},
+		},
+	}
+	changed, err := factory.ReloadConfig(updated)
+	if err != nil || len(changed) != 1 || changed[0] != "openai" {
+		t.Fatalf("Expected only openai to change, got %v, %v", changed, err)
+	}
+
+	if again, _ := factory.CreateProviderFromConfig("ollama"); again != ollama || created["ollama"] != 1 {
+		t.Errorf("Expected the unchanged provider to stay cached, created %d times", created["ollama"])
+	}
+	factory.CreateProviderFromConfig("openai")
+	factory.CreateProviderWithModel("openai", "gpt-4o")
+	if created["openai"] != 4 {
+		t.Errorf("Expected both openai instances to be rebuilt, created %d times", created["openai"])
+	}
+	if factory.GetConfig().Providers["openai"].RateLimit != 10 {
+		t.Error("Expected the new config to be in use")
+	}
+
+	// An invalid config leaves the current one in place
+	invalid := FactoryConfig{DefaultProvider: "missing", Providers: updated.Providers}
+	if _, err := factory.ReloadConfig(invalid); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Expected an invalid config error, got %v", err)
+	}
+	if factory.GetDefaultProviderName() != "ollama" {
+		t.Error("Expected the invalid config to be rejected")
+	}
+}
+
+func TestNamedInstances(t *testing.T) {
+	hosts := map[string]string{}
+	factory := NewLLMFactory()
+	factory.RegisterProvider("ollama", func(config map[string]interface{}) (Provider, error) {
+		host, _ := config["host"].(string)
+		model, _ := config["default_model"].(string)
+		hosts[host+"|"+model] = host
+		provider := &stubProvider{name: host}
+		if host == "http://gpu:11434" {
+			provider.err = NewLLMError(ErrConnectionFailed, "down", "chat", true, 0, nil)
+		}
+		return provider, nil
+	})
+
+	config := FactoryConfig{
+		DefaultProvider: "gpu",
+		Providers: map[string]ProviderConfig{
+			"gpu":    {Type: "ollama", Host: "http://gpu:11434", DefaultModel: "llama3:70b", Timeout: 60},
+			"cpu":    {Type: "ollama", Host: "http://cpu:11434", DefaultModel: "llama3:8b", Timeout: 60},
+			"ollama": {Host: "http://localhost:11434", DefaultModel: "llama3", Timeout: 60}, // No type, as before
+		},
+		ModelPreferences:  map[string]string{"coding": "cpu/qwen2.5-coder", "default": "llama3:70b"},
+		FallbackProviders: []string{"cpu"},
+	}
+	if err := factory.ValidateConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := factory.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	gpu, _ := factory.CreateProviderFromConfig("gpu")
+	cpu, _ := factory.CreateProviderFromConfig("cpu")
+	local, err := factory.CreateProviderFromConfig("ollama")
+	if err != nil || gpu.Name() != "http://gpu:11434" || cpu.Name() != "http://cpu:11434" || local.Name() != "http://localhost:11434" {
+		t.Fatalf("Expected one provider per instance, got %v %v %v %v", gpu, cpu, local, err)
+	}
+	if again, _ := factory.CreateProviderFromConfig("gpu"); again != gpu {
+		t.Error("Expected instances to be cached by name")
+	}
+
+	response, err := factory.ChatWithFallback(ChatRequest{})
+	if err != nil || response.Message != "hello from http://cpu:11434" {
+		t.Errorf("Expected the cpu instance to take over, got %q, %v", response.Message, err)
+	}
+
+	if _, err := factory.CreateProviderForTask("coding"); err != nil || hosts["http://cpu:11434|qwen2.5-coder"] == "" {
+		t.Errorf("Expected the coding model on the cpu instance, got %v %v", hosts, err)
+	}
+	if _, err := factory.CreateProviderForTask("summaries"); err != nil || hosts["http://gpu:11434|llama3:70b"] == "" {
+		t.Errorf("Expected the default preference on the default instance, got %v %v", hosts, err)
+	}
+
+	if err := factory.SetDefaultProvider("cpu"); err != nil {
+		t.Errorf("Expected an instance to be a valid default, got %v", err)
+	}
+
+	config.Providers["gpu"] = ProviderConfig{Type: "olama", DefaultModel: "llama3:70b", Timeout: 60}
+	err = factory.ValidateConfig(config)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "providers.gpu.type" {
+		t.Errorf("Expected an unknown type error, got %v", err)
+	}
+}
+
+// closingStub is a stubProvider holding a resource. Chat and Close wait for release.
+type closingStub struct {
+	stubProvider
+	started chan struct{}
+	release chan struct{}
+	closing chan struct{}
+	closed  chan struct{}
+}
+
+func newClosingStub() *closingStub {
+	return &closingStub{
+		stubProvider: stubProvider{name: "exec"},
+		started:      make(chan struct{}),
+		release:      make(chan struct{}),
+		closing:      make(chan struct{}),
+		closed:       make(chan struct{}),
+	}
+}
+
+func (p *closingStub) Chat(request ChatRequest) (ChatResponse, error) {
+	close(p.started)
+	<-p.release
+	return ChatResponse{Message: "hello from " + p.name}, nil
+}
+
+func (p *closingStub) Close() error {
+	close(p.closing)
+	<-p.release
+	close(p.closed)
+	return nil
+}
+
+// await fails the test unless ch is closed within a few seconds
+func await(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for %s", what)
+	}
+}
+
+func TestReloadConfigClosesReplacedProviders(t *testing.T) {
+	var stubs []*closingStub
+	factory := NewLLMFactory()
+	factory.EnableCircuitBreakers(CircuitBreakerConfig{})
+	factory.RegisterProvider("exec", func(config map[string]interface{}) (Provider, error) {
+		stub := newClosingStub()
+		stubs = append(stubs, stub)
+		return stub, nil
+	})
+
+	config := FactoryConfig{
+		DefaultProvider: "exec",
+		Providers:       map[string]ProviderConfig{"exec": {DefaultModel: "a", Timeout: 60}},
+	}
+	if err := factory.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	provider, _ := factory.CreateProviderFromConfig("exec")
+	idle, _ := factory.CreateProviderWithModel("exec", "b")
+	if _, ok := provider.(StreamingProvider); !ok {
+		t.Error("Expected the cached provider to keep streaming through the breaker")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		provider.Chat(ChatRequest{})
+	}()
+	busy, idleStub := stubs[0], stubs[1]
+	<-busy.started
+
+	// The idle provider is closed at once, without holding up the factory while it closes
+	config.Providers = map[string]ProviderConfig{"exec": {DefaultModel: "a", Timeout: 30}}
+	reloaded := make(chan struct{})
+	go func() {
+		defer close(reloaded)
+		if _, err := factory.ReloadConfig(config); err != nil {
+			t.Error(err)
+		}
+		factory.GetConfig()
+		factory.CreateProviderFromConfig("exec")
+	}()
+	await(t, idleStub.closing, "the idle provider to be closed")
+	await(t, reloaded, "the factory while a provider closes")
+	select {
+	case <-busy.closing:
+		t.Error("Expected the busy provider to stay open")
+	default:
+	}
+	close(idleStub.release)
+	await(t, idleStub.closed, "the idle provider to finish closing")
+
+	// The running request finishes on the replaced provider, which is then closed
+	close(busy.release)
+	<-done
+	await(t, busy.closed, "the replaced provider to be closed after its request")
+	if idle.Name() != "exec" {
+		t.Error("Expected the replaced provider to stay usable")
+	}
+}
+
+// listingStub is a stubProvider whose ListModels waits for release
+type listingStub struct {
+	stubProvider
+	model   string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *listingStub) ListModels() ([]Model, error) {
+	close(p.started)
+	<-p.release
+	return []Model{{Name: p.model}}, nil
+}
+
+func TestListModelsDuringReloadIsNotCached(t *testing.T) {
+	created := make(chan *listingStub, 2)
+	factory := NewLLMFactory()
+	factory.RegisterProvider("ollama", func(config map[string]interface{}) (Provider, error) {
+		model, _ := config["default_model"].(string)
+		stub := &listingStub{stubProvider: stubProvider{name: "ollama"}, model: model,
+			started: make(chan struct{}), release: make(chan struct{})}
+		created <- stub
+		return stub, nil
+	})
+
+	config := FactoryConfig{
+		DefaultProvider: "ollama",
+		Providers:       map[string]ProviderConfig{"ollama": {DefaultModel: "llama3", Timeout: 60}},
+	}
+	if err := factory.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	listed := make(chan []Model)
+	go func() {
+		models, _ := factory.ListModels("ollama")
+		listed <- models
+	}()
+	old := <-created
+	await(t, old.started, "the model list to be fetched")
+
+	// The config changes while the old provider is still listing
+	config.Providers = map[string]ProviderConfig{"ollama": {DefaultModel: "qwen2.5", Timeout: 60}}
+	if _, err := factory.ReloadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	close(old.release)
+	if models := <-listed; len(models) != 1 || models[0].Name != "llama3" {
+		t.Fatalf("Expected the old list to be returned to its caller, got %v", models)
+	}
+
+	go func() {
+		replaced := <-created
+		close(replaced.release)
+	}()
+	if models, err := factory.ListModels("ollama"); err != nil || len(models) != 1 || models[0].Name != "qwen2.5" {
+		t.Errorf("Expected the list of the reloaded config, got %v, %v", models, err)
+	}
+}
@@ -0,0 +1,441 @@
+// Package gateway serves an LLMFactory over the OpenAI Chat Completions API, so tools that
+// only speak OpenAI can use any configured provider with its fallbacks and rate limits.
+//
+// The model field routes requests:
+//   - "provider/model" uses the provider with that model, if the provider lists it
+//   - "provider" uses the provider with its default model
+//   - "" or "default" uses the default provider, then the fallback providers
+//
+// Each provider's RateLimit (requests per minute) is enforced by the gateway.
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"simpleai"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBodySize bounds request bodies
+const maxBodySize = 8 << 20
+
+// Options configure a Server
+type Options struct {
+	// APIKeys are the bearer tokens clients may authenticate with. No keys disables
+	// authentication.
+	APIKeys []string
+
+	// Logger receives a line per request. Nil disables request logging.
+	Logger *slog.Logger
+}
+
+// Server is an http.Handler serving /v1/chat/completions and /v1/models
+type Server struct {
+	factory *simpleai.LLMFactory
+	options Options
+	limiter *rateLimiter
+	mux     *http.ServeMux
+	keys    [][]byte
+	newID   func() string
+	clock   func() time.Time
+}
+
+// New creates a gateway serving the providers configured in factory
+func New(factory *simpleai.LLMFactory, options Options) *Server {
+	s := &Server{
+		factory: factory,
+		options: options,
+		limiter: newRateLimiter(),
+		mux:     http.NewServeMux(),
+		newID:   completionID,
+		clock:   time.Now,
+	}
+	for _, key := range options.APIKeys {
+		s.keys = append(s.keys, []byte(key))
+	}
+	s.mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	s.mux.HandleFunc("GET /v1/models", s.handleModels)
+	return s
+}
+
+// ServeHTTP authenticates and logs the request, then serves it
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	if s.authorized(r) {
+		s.mux.ServeHTTP(recorder, r)
+	} else {
+		writeError(recorder, http.StatusUnauthorized, "authentication_error", "invalid_api_key", "invalid or missing API key", 0)
+	}
+
+	if s.options.Logger != nil {
+		s.options.Logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"model", recorder.model,
+			"duration", time.Since(start),
+			"remote", r.RemoteAddr)
+	}
+}
+
+// authorized checks the request's bearer token against the configured keys
+func (s *Server) authorized(r *http.Request) bool {
+	if len(s.keys) == 0 {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	for _, key := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(token), key) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// route is a provider and model to try a request on; an empty model is the provider's default
+type route struct {
+	provider string
+	model    string
+}
+
+// routes resolves the model field to the providers to try, in order
+func (s *Server) routes(model string) ([]route, error) {
+	config := s.factory.GetConfig()
+
+	if providerName, providerModel, ok := strings.Cut(model, "/"); ok {
+		providerConfig, exists := config.Providers[providerName]
+		if !exists {
+			return nil, fmt.Errorf("unknown provider %q", providerName)
+		}
+		if providerModel != "" && providerModel != providerConfig.DefaultModel {
+			if err := s.checkModel(providerName, providerModel); err != nil {
+				return nil, err
+			}
+		}
+		return []route{{provider: providerName, model: providerModel}}, nil
+	}
+	if _, exists := config.Providers[model]; exists {
+		return []route{{provider: model}}, nil
+	}
+	if model != "" && model != "default" {
+		return nil, fmt.Errorf("unknown model %q; use provider/model", model)
+	}
+
+	var routes []route
+	seen := make(map[string]bool)
+	for _, providerName := range append([]string{config.DefaultProvider}, config.FallbackProviders...) {
+		if providerName == "" || seen[providerName] {
+			continue
+		}
+		seen[providerName] = true
+		routes = append(routes, route{provider: providerName})
+	}
+	return routes, nil
+}
+
+// checkModel returns an error unless the provider lists model. The factory caches an
+// instance per provider and model, so clients must not be able to name arbitrary models.
+func (s *Server) checkModel(providerName, model string) error {
+	models, err := s.factory.ListModels(providerName)
+	if err != nil {
+		return fmt.Errorf("cannot check model %q: %w", providerName+"/"+model, err)
+	}
+	for _, listed := range models {
+		// Ollama lists untagged models with the :latest tag they imply
+		if listed.Name == model || listed.Name == model+":latest" {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown model %q; see /v1/models", providerName+"/"+model)
+}
+
+// modelName is the provider/model name a route is reported as
+func (s *Server) modelName(r route) string {
+	model := r.model
+	if model == "" {
+		model = s.factory.GetConfig().Providers[r.provider].DefaultModel
+	}
+	return r.provider + "/" + model
+}
+
+// provider returns the provider for a route after taking a token from its rate limit
+func (s *Server) provider(r route) (simpleai.Provider, error) {
+	perMinute := s.factory.GetConfig().Providers[r.provider].RateLimit
+	if ok, wait := s.limiter.allow(r.provider, perMinute); !ok {
+		return nil, simpleai.NewLLMError(simpleai.ErrRateLimitExceeded,
+			fmt.Sprintf("rate limit of %d requests per minute exceeded for provider %s", perMinute, r.provider),
+			"gateway", true, 0, nil).WithRetryAfter(wait)
+	}
+	return s.factory.CreateProviderWithModel(r.provider, r.model)
+}
+
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var body chatCompletionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "invalid request body: "+err.Error(), 0)
+		return
+	}
+	request, err := convertRequest(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error(), 0)
+		return
+	}
+	routes, err := s.routes(body.Model)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "model_not_found", err.Error(), 0)
+		return
+	}
+
+	if body.Stream {
+		includeUsage := body.StreamOptions != nil && body.StreamOptions.IncludeUsage
+		s.stream(w, r.Context(), routes, request, includeUsage)
+		return
+	}
+
+	var lastErr error
+	for _, route := range routes {
+		if recorder, ok := w.(*statusRecorder); ok {
+			recorder.model = s.modelName(route)
+		}
+		provider, err := s.provider(route)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		response, err := simpleai.ChatContext(r.Context(), provider, request)
+		if err != nil {
+			lastErr = err
+			if !simpleai.ShouldFallback(err) {
+				break
+			}
+			continue
+		}
+
+		text := response.Message
+		writeJSON(w, http.StatusOK, chatCompletion{
+			ID:      s.newID(),
+			Object:  "chat.completion",
+			Created: s.clock().Unix(),
+			Model:   s.modelName(route),
+			Choices: []choice{{
+				Message:      &responseMessage{Role: "assistant", Content: &text, ToolCalls: convertToolCalls(response.ToolCalls, false)},
+				FinishReason: finishReason(response),
+			}},
+			Usage: convertUsage(response.Usage),
+		})
+		return
+	}
+	writeLLMError(w, lastErr)
+}
+
+// stream serves a request as server-sent events. Later routes are only tried while nothing
+// has been sent.
+func (s *Server) stream(w http.ResponseWriter, ctx context.Context, routes []route, request simpleai.ChatRequest, includeUsage bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "server_error", "", "streaming is not supported", 0)
+		return
+	}
+
+	id := s.newID()
+	created := s.clock().Unix()
+	started := false
+	var model string
+
+	send := func(chunk chatCompletion) error {
+		chunk.ID, chunk.Object, chunk.Created, chunk.Model = id, "chat.completion.chunk", created, model
+		if chunk.Choices == nil {
+			chunk.Choices = []choice{}
+		}
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	var lastErr error
+	for _, route := range routes {
+		model = s.modelName(route)
+		if recorder, ok := w.(*statusRecorder); ok {
+			recorder.model = model
+		}
+		provider, err := s.provider(route)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		response, err := simpleai.ChatStream(ctx, provider, request, func(chunk simpleai.StreamChunk) error {
+			if !started {
+				if err := send(chatCompletion{Choices: []choice{{Delta: &delta{Role: "assistant"}}}}); err != nil {
+					return err
+				}
+			}
+			if chunk.Delta == "" {
+				return nil
+			}
+			return send(chatCompletion{Choices: []choice{{Delta: &delta{Content: chunk.Delta}}}})
+		})
+		if err != nil {
+			lastErr = err
+			if !started && simpleai.ShouldFallback(err) {
+				continue
+			}
+			break
+		}
+
+		if !started {
+			// Tool calls alone produce no text chunks
+			send(chatCompletion{Choices: []choice{{Delta: &delta{Role: "assistant"}}}})
+		}
+		if len(response.ToolCalls) > 0 {
+			send(chatCompletion{Choices: []choice{{Delta: &delta{ToolCalls: convertToolCalls(response.ToolCalls, true)}}}})
+		}
+		send(chatCompletion{Choices: []choice{{Delta: &delta{}, FinishReason: finishReason(response)}}})
+		if includeUsage {
+			send(chatCompletion{Usage: convertUsage(response.Usage)})
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		flusher.Flush()
+		return
+	}
+
+	if !started {
+		writeLLMError(w, lastErr)
+		return
+	}
+	// Headers are gone, so the error is reported in the stream
+	status, errType, code := classify(lastErr)
+	if recorder, ok := w.(*statusRecorder); ok {
+		recorder.status = status
+	}
+	data, _ := json.Marshal(errorResponse{Error: errorObject{Message: lastErr.Error(), Type: errType, Code: code}})
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	flusher.Flush()
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	config := s.factory.GetConfig()
+	names := make([]string, 0, len(config.Providers))
+	for name := range config.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := modelList{Object: "list", Data: []modelObject{}}
+	for _, name := range names {
+		models, err := s.factory.ListModels(name)
+		if err != nil {
+			// An unreachable provider should not hide the others
+			if s.options.Logger != nil {
+				s.options.Logger.Warn("cannot list models", "provider", name, "error", err)
+			}
+			continue
+		}
+		for _, model := range models {
+			list.Data = append(list.Data, modelObject{ID: name + "/" + model.Name, Object: "model", OwnedBy: name})
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// classify maps an error to an HTTP status and OpenAI error type and code
+func classify(err error) (status int, errType, code string) {
+	var llmErr *simpleai.LLMError
+	if !errors.As(err, &llmErr) {
+		return http.StatusInternalServerError, "server_error", ""
+	}
+	switch llmErr.Type {
+	case simpleai.ErrRateLimitExceeded:
+		return http.StatusTooManyRequests, "rate_limit_error", "rate_limit_exceeded"
+	case simpleai.ErrModelNotAvailable:
+		return http.StatusNotFound, "invalid_request_error", "model_not_found"
+	case simpleai.ErrContentFiltered:
+		return http.StatusBadRequest, "invalid_request_error", "content_filter"
+	case simpleai.ErrTimeout:
+		return http.StatusGatewayTimeout, "server_error", "timeout"
+	case simpleai.ErrConnectionFailed, simpleai.ErrInvalidResponse, simpleai.ErrJSONParseFailed:
+		return http.StatusBadGateway, "server_error", ""
+	default:
+		return http.StatusInternalServerError, "server_error", ""
+	}
+}
+
+// writeLLMError writes err as an OpenAI error response
+func writeLLMError(w http.ResponseWriter, err error) {
+	status, errType, code := classify(err)
+	var retryAfter time.Duration
+	var llmErr *simpleai.LLMError
+	if errors.As(err, &llmErr) {
+		retryAfter = llmErr.RetryAfter
+	}
+	writeError(w, status, errType, code, err.Error(), retryAfter)
+}
+
+// writeError writes an OpenAI error response
+func writeError(w http.ResponseWriter, status int, errType, code, message string, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		// Round up so clients do not retry too early
+		w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+	}
+	writeJSON(w, status, errorResponse{Error: errorObject{Message: message, Type: errType, Code: code}})
+}
+
+// writeJSON writes value as a JSON response
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// completionID returns a random completion ID
+func completionID() string {
+	var b [12]byte
+	rand.Read(b[:])
+	return "chatcmpl-" + hex.EncodeToString(b[:])
+}
+
+// statusRecorder records the status and routed model for the request log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	model  string
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
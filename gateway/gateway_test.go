@@ -0,0 +1,242 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"simpleai"
+	"simpleai/simpleaitest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestGateway serves a primary and a backup mock, with backup as the fallback
+func newTestGateway(t *testing.T, options Options) (*httptest.Server, *simpleaitest.MockProvider, *simpleaitest.MockProvider) {
+	t.Helper()
+	primary := simpleaitest.NewMockProvider("primary")
+	backup := simpleaitest.NewMockProvider("backup")
+
+	factory := simpleai.NewLLMFactory()
+	factory.RegisterProvider("primary", primary.Constructor())
+	factory.RegisterProvider("backup", backup.Constructor())
+	err := factory.LoadConfig(simpleai.FactoryConfig{
+		DefaultProvider: "primary",
+		Providers: map[string]simpleai.ProviderConfig{
+			"primary": {DefaultModel: "small"},
+			"backup":  {DefaultModel: "large", RateLimit: 1},
+		},
+		FallbackProviders: []string{"backup"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	server := httptest.NewServer(New(factory, options))
+	t.Cleanup(server.Close)
+	return server, primary, backup
+}
+
+func post(t *testing.T, server *httptest.Server, key, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decode[T any](t *testing.T, resp *http.Response) T {
+	t.Helper()
+	var value T
+	if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return value
+}
+
+func TestChatCompletionRouting(t *testing.T) {
+	server, primary, backup := newTestGateway(t, Options{})
+	primary.Default(simpleaitest.Text("from primary"))
+	primary.WithModels(simpleai.Model{Name: "small"}, simpleai.Model{Name: "tiny"})
+	backup.Default(simpleaitest.Text("from backup"))
+
+	for _, tc := range []struct {
+		model, want, reply string
+	}{
+		{"", "primary/small", "from primary"},
+		{"backup", "backup/large", "from backup"},
+		{"primary/tiny", "primary/tiny", "from primary"},
+	} {
+		resp := post(t, server, "", `{"model":"`+tc.model+`","messages":[{"role":"developer","content":"Be brief"},{"role":"user","content":[{"type":"text","text":"Hi"}]}]}`)
+		completion := decode[chatCompletion](t, resp)
+		if resp.StatusCode != http.StatusOK || completion.Model != tc.want || *completion.Choices[0].Message.Content != tc.reply {
+			t.Errorf("Model %q: expected %s from %s, got %d %+v", tc.model, tc.reply, tc.want, resp.StatusCode, completion)
+		}
+	}
+
+	// The model override reaches the provider as its default model
+	configs := primary.Configs()
+	if last := configs[len(configs)-1]; last["default_model"] != "tiny" {
+		t.Errorf("Expected the routed model in the provider config, got %v", last)
+	}
+	request, _ := primary.LastRequest()
+	if request.Messages[0].Role != "system" || request.Messages[1].Content != "Hi" {
+		t.Errorf("Expected converted messages, got %+v", request.Messages)
+	}
+
+	// Unknown providers and models the provider doesn't list are rejected before any
+	// instance is created for them
+	created := len(primary.Configs())
+	for _, model := range []string{"missing/model", "primary/bogus"} {
+		resp := post(t, server, "", `{"model":"`+model+`","messages":[{"role":"user","content":"Hi"}]}`)
+		if body := decode[errorResponse](t, resp); resp.StatusCode != http.StatusNotFound || body.Error.Code != "model_not_found" {
+			t.Errorf("Model %q: expected model_not_found, got %d %+v", model, resp.StatusCode, body)
+		}
+	}
+	if got := len(primary.Configs()); got != created {
+		t.Errorf("Expected no provider for an unlisted model, got %d new", got-created)
+	}
+}
+
+func TestFallbackAndRateLimit(t *testing.T) {
+	server, primary, backup := newTestGateway(t, Options{})
+	primary.Default(simpleaitest.Error(simpleai.ErrConnectionFailed))
+	backup.Default(simpleaitest.Text("from backup"))
+
+	resp := post(t, server, "", `{"messages":[{"role":"user","content":"Hi"}]}`)
+	if completion := decode[chatCompletion](t, resp); completion.Model != "backup/large" {
+		t.Fatalf("Expected the fallback to answer, got %d %+v", resp.StatusCode, completion)
+	}
+
+	// The backup allows one request a minute, so the next request runs out of providers
+	resp = post(t, server, "", `{"messages":[{"role":"user","content":"Hi"}]}`)
+	body := decode[errorResponse](t, resp)
+	if resp.StatusCode != http.StatusTooManyRequests || body.Error.Type != "rate_limit_error" || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("Expected a rate limit error with Retry-After, got %d %+v %v", resp.StatusCode, body, resp.Header)
+	}
+	if backup.Calls() != 1 {
+		t.Errorf("Expected the limited request not to reach the backup, got %d calls", backup.Calls())
+	}
+}
+
+func TestNonFallbackErrorIsReturned(t *testing.T) {
+	server, primary, backup := newTestGateway(t, Options{})
+	primary.Default(simpleaitest.Error(simpleai.NewLLMError(simpleai.ErrContentFiltered, "blocked", "chat", false, 0, nil)))
+
+	resp := post(t, server, "", `{"messages":[{"role":"user","content":"Hi"}]}`)
+	if body := decode[errorResponse](t, resp); resp.StatusCode != http.StatusBadRequest || body.Error.Code != "content_filter" {
+		t.Errorf("Expected a content filter error, got %d %+v", resp.StatusCode, body)
+	}
+	if backup.Calls() != 0 {
+		t.Error("Expected no fallback for a filtered request")
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	server, primary, _ := newTestGateway(t, Options{})
+	primary.Default(simpleaitest.Reply{
+		Response: simpleai.ChatResponse{Usage: &simpleai.Usage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5}},
+		Chunks:   []string{"Hello ", "world"},
+	})
+
+	resp := post(t, server, "", `{"model":"primary","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"Hi"}]}`)
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", resp.Header.Get("Content-Type"))
+	}
+
+	var chunks []chatCompletion
+	done := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk chatCompletion
+		json.Unmarshal([]byte(data), &chunk)
+		chunks = append(chunks, chunk)
+	}
+
+	if !done || len(chunks) != 5 {
+		t.Fatalf("Expected role, two deltas, finish and usage chunks then [DONE], got %d chunks", len(chunks))
+	}
+	if chunks[0].Choices[0].Delta.Role != "assistant" || chunks[1].Choices[0].Delta.Content != "Hello " || chunks[2].Choices[0].Delta.Content != "world" {
+		t.Errorf("Unexpected deltas %+v", chunks[:3])
+	}
+	if reason := chunks[3].Choices[0].FinishReason; reason == nil || *reason != "stop" {
+		t.Errorf("Expected finish reason stop, got %+v", chunks[3])
+	}
+	if chunks[4].Usage == nil || chunks[4].Usage.TotalTokens != 5 || len(chunks[4].Choices) != 0 {
+		t.Errorf("Expected a usage chunk, got %+v", chunks[4])
+	}
+	for _, chunk := range chunks {
+		if chunk.ID != chunks[0].ID || chunk.Object != "chat.completion.chunk" || chunk.Model != "primary/small" {
+			t.Errorf("Expected consistent chunk metadata, got %+v", chunk)
+		}
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	server, primary, _ := newTestGateway(t, Options{APIKeys: []string{"secret"}})
+	primary.Default(simpleaitest.Text("ok"))
+
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "secret": http.StatusOK} {
+		if resp := post(t, server, key, `{"messages":[{"role":"user","content":"Hi"}]}`); resp.StatusCode != want {
+			t.Errorf("Key %q: expected %d, got %d", key, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestModels(t *testing.T) {
+	server, primary, _ := newTestGateway(t, Options{})
+	primary.WithModels(simpleai.Model{Name: "small"}, simpleai.Model{Name: "tiny"})
+
+	resp, err := http.Get(server.URL + "/v1/models")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	list := decode[modelList](t, resp)
+	var ids []string
+	for _, model := range list.Data {
+		ids = append(ids, model.ID)
+	}
+	if got := strings.Join(ids, ","); got != "backup/mock-model,primary/small,primary/tiny" {
+		t.Errorf("Unexpected models %s", got)
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	limiter := newRateLimiter()
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow("p", 2); !ok {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+	ok, wait := limiter.allow("p", 2)
+	if ok || wait != 30*time.Second {
+		t.Fatalf("Expected to wait 30s, got %v %v", ok, wait)
+	}
+	now = now.Add(wait)
+	if ok, _ := limiter.allow("p", 2); !ok {
+		t.Error("Expected a token after waiting")
+	}
+	if ok, _ := limiter.allow("other", 0); !ok {
+		t.Error("Expected no limit to allow everything")
+	}
+}
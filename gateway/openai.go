@@ -0,0 +1,236 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"simpleai"
+	"strings"
+)
+
+// chatCompletionRequest is the body of POST /v1/chat/completions
+type chatCompletionRequest struct {
+	Model         string          `json:"model"`
+	Messages      []chatMessage   `json:"messages"`
+	Stream        bool            `json:"stream"`
+	StreamOptions *streamOptions  `json:"stream_options"`
+	Tools         []tool          `json:"tools"`
+	ToolChoice    json.RawMessage `json:"tool_choice"`
+	Temperature   *float64        `json:"temperature"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatMessage struct {
+	Role       string     `json:"role"`
+	Content    content    `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls"`
+	ToolCallID string     `json:"tool_call_id"`
+}
+
+// content is message content sent as a string, null or a list of parts, of which only the
+// text parts are kept
+type content string
+
+func (c *content) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*c = ""
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = content(text)
+		return nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.New("content must be a string or a list of content parts")
+	}
+	var joined strings.Builder
+	for _, part := range parts {
+		if part.Type == "text" {
+			joined.WriteString(part.Text)
+		}
+	}
+	*c = content(joined.String())
+	return nil
+}
+
+type tool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Parameters  map[string]any `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+type toolCall struct {
+	Index    *int   `json:"index,omitempty"` // Only in stream deltas
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatCompletion is a response, or a stream chunk when Object is "chat.completion.chunk"
+type chatCompletion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []choice `json:"choices"`
+	Usage   *usage   `json:"usage,omitempty"`
+}
+
+type choice struct {
+	Index        int              `json:"index"`
+	Message      *responseMessage `json:"message,omitempty"`
+	Delta        *delta           `json:"delta,omitempty"`
+	FinishReason *string          `json:"finish_reason"`
+}
+
+type responseMessage struct {
+	Role      string     `json:"role"`
+	Content   *string    `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+type delta struct {
+	Role      string     `json:"role,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type modelList struct {
+	Object string        `json:"object"`
+	Data   []modelObject `json:"data"`
+}
+
+type modelObject struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// errorResponse is the OpenAI error body
+type errorResponse struct {
+	Error errorObject `json:"error"`
+}
+
+type errorObject struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
+
+// convertRequest converts an OpenAI request to a simpleai chat request. System messages are
+// kept as messages, which every provider accepts.
+func convertRequest(body chatCompletionRequest) (simpleai.ChatRequest, error) {
+	if len(body.Messages) == 0 {
+		return simpleai.ChatRequest{}, errors.New("messages must not be empty")
+	}
+
+	request := simpleai.ChatRequest{Temperature: body.Temperature}
+	for _, msg := range body.Messages {
+		role := msg.Role
+		if role == "developer" {
+			role = "system"
+		}
+		message := simpleai.Message{Role: role, Content: string(msg.Content), ToolCallID: msg.ToolCallID}
+		for _, call := range msg.ToolCalls {
+			message.ToolCalls = append(message.ToolCalls, simpleai.ToolCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			})
+		}
+		request.Messages = append(request.Messages, message)
+	}
+
+	for _, t := range body.Tools {
+		if t.Type != "" && t.Type != "function" {
+			continue
+		}
+		request.Tools = append(request.Tools, simpleai.Tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			Parameters:  t.Function.Parameters,
+		})
+	}
+
+	choice, err := convertToolChoice(body.ToolChoice)
+	if err != nil {
+		return simpleai.ChatRequest{}, err
+	}
+	request.ToolChoice = choice
+	return request, nil
+}
+
+// convertToolChoice accepts the keyword choices and {"type":"function","function":{"name":...}}
+func convertToolChoice(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", nil
+	}
+	var keyword string
+	if json.Unmarshal(raw, &keyword) == nil {
+		return keyword, nil
+	}
+
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Function.Name == "" {
+		return "", errors.New("tool_choice must be a string or name a function")
+	}
+	return named.Function.Name, nil
+}
+
+// convertToolCalls converts tool calls for a response, with indexes for stream deltas
+func convertToolCalls(calls []simpleai.ToolCall, indexed bool) []toolCall {
+	converted := make([]toolCall, len(calls))
+	for i, call := range calls {
+		converted[i].ID = call.ID
+		converted[i].Type = "function"
+		converted[i].Function.Name = call.Name
+		converted[i].Function.Arguments = call.Arguments
+		if indexed {
+			index := i
+			converted[i].Index = &index
+		}
+	}
+	return converted
+}
+
+// convertUsage converts token usage, if reported
+func convertUsage(u *simpleai.Usage) *usage {
+	if u == nil {
+		return nil
+	}
+	return &usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+// finishReason reports why a response ended
+func finishReason(response simpleai.ChatResponse) *string {
+	reason := "stop"
+	if len(response.ToolCalls) > 0 {
+		reason = "tool_calls"
+	}
+	return &reason
+}
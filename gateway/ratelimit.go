@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter enforces each provider's RateLimit (requests per minute) with a token bucket
+// that holds a minute's worth of requests
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	perMinute int
+	tokens    float64
+	updated   time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// allow takes a token for provider, or reports how long until one is available. A limit of
+// zero or less means unlimited.
+func (l *rateLimiter) allow(provider string, perMinute int) (bool, time.Duration) {
+	if perMinute <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[provider]
+	if !ok || b.perMinute != perMinute {
+		// New providers and changed limits start with a full bucket
+		b = &bucket{perMinute: perMinute, tokens: float64(perMinute), updated: now}
+		l.buckets[provider] = b
+	}
+
+	rate := float64(perMinute) / float64(time.Minute)
+	b.tokens = min(float64(perMinute), b.tokens+float64(now.Sub(b.updated))*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate)
+	}
+	b.tokens--
+	return true, 0
+}
@@ -0,0 +1,311 @@
+// Package jsonextract pulls JSON values out of free-form LLM responses.
+//
+// LLMs often wrap JSON in markdown code blocks, surround it with explanatory text or
+// emit small formatting mistakes. An Extractor runs an ordered pipeline of strategies,
+// each proposing candidate JSON texts, and returns the first candidate that is valid JSON
+// together with the name of the strategy that produced it. ExtractFor additionally takes the
+// structured output target into account, so arrays, objects and scalars are all extracted
+// correctly.
+package jsonextract
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Names of the built-in strategies
+const (
+	StrategyDirect            = "direct"
+	StrategyRepair            = "repair"
+	StrategyMarkdown          = "markdown"
+	StrategyBraceCounting     = "brace_counting"
+	StrategyAggressiveCleanup = "aggressive_cleanup"
+	StrategyScalar            = "scalar"
+)
+
+// Strategy proposes candidate JSON texts found in a response
+type Strategy interface {
+	// Name identifies the strategy in results and when disabling it
+	Name() string
+
+	// Candidates returns possible JSON texts in order of preference. Candidates do not
+	// need to be valid; the Extractor validates them.
+	Candidates(response string) []string
+}
+
+// TargetedStrategy is implemented by strategies that only apply to some kinds of target
+type TargetedStrategy interface {
+	Strategy
+
+	// AppliesTo reports whether the strategy should run for a target of the given kind
+	AppliesTo(kind Kind) bool
+}
+
+// NestingStrategy is implemented by strategies that also find values nested inside their
+// candidates, such as an object inside an array. Nested candidates are only used when no
+// whole candidate fits, so a field of the intended value is never mistaken for the value.
+type NestingStrategy interface {
+	Strategy
+
+	// NestedCandidates returns values nested inside the strategy's candidates, outer ones first
+	NestedCandidates(response string) []string
+}
+
+// strategyFunc adapts a function to the Strategy interface
+type strategyFunc struct {
+	name string
+	fn   func(response string) []string
+}
+
+func (s strategyFunc) Name() string {
+	return s.name
+}
+
+func (s strategyFunc) Candidates(response string) []string {
+	return s.fn(response)
+}
+
+// NewStrategy creates a custom strategy from a candidate function
+func NewStrategy(name string, fn func(response string) []string) Strategy {
+	return strategyFunc{name: name, fn: fn}
+}
+
+// Result describes a successful extraction
+type Result struct {
+	JSON     string `json:"json"`     // The extracted JSON text
+	Strategy string `json:"strategy"` // Name of the strategy that found it
+	Repaired bool   `json:"repaired"` // Whether the JSON was produced by the lenient repair parser
+}
+
+// Extractor runs an ordered pipeline of strategies
+type Extractor struct {
+	strategies []Strategy
+}
+
+// New creates an extractor that runs the given strategies in order
+func New(strategies ...Strategy) *Extractor {
+	return &Extractor{strategies: append([]Strategy(nil), strategies...)}
+}
+
+// Default creates an extractor with the built-in strategies in their default order
+func Default() *Extractor {
+	return New(DefaultStrategies()...)
+}
+
+// DefaultStrategies returns the built-in strategies in their default order
+func DefaultStrategies() []Strategy {
+	return []Strategy{
+		Direct(),
+		Repair(),
+		Markdown(),
+		BraceCounting(),
+		AggressiveCleanup(),
+		Scalars(),
+	}
+}
+
+// Strategies returns the extractor's strategies in order
+func (e *Extractor) Strategies() []Strategy {
+	return append([]Strategy(nil), e.strategies...)
+}
+
+// Without returns a copy of the extractor with the named strategies removed
+func (e *Extractor) Without(names ...string) *Extractor {
+	disabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		disabled[name] = true
+	}
+
+	var strategies []Strategy
+	for _, strategy := range e.strategies {
+		if !disabled[strategy.Name()] {
+			strategies = append(strategies, strategy)
+		}
+	}
+	return &Extractor{strategies: strategies}
+}
+
+// With returns a copy of the extractor with strategies appended to the pipeline
+func (e *Extractor) With(strategies ...Strategy) *Extractor {
+	return New(append(e.Strategies(), strategies...)...)
+}
+
+// WithBefore returns a copy of the extractor with strategy inserted before the named
+// strategy, or appended if no strategy has that name
+func (e *Extractor) WithBefore(name string, strategy Strategy) *Extractor {
+	strategies := make([]Strategy, 0, len(e.strategies)+1)
+	inserted := false
+	for _, existing := range e.strategies {
+		if !inserted && existing.Name() == name {
+			strategies = append(strategies, strategy)
+			inserted = true
+		}
+		strategies = append(strategies, existing)
+	}
+	if !inserted {
+		strategies = append(strategies, strategy)
+	}
+	return &Extractor{strategies: strategies}
+}
+
+// Extract returns the first valid JSON candidate produced by the pipeline
+func (e *Extractor) Extract(response string) (Result, bool) {
+	var found Result
+	ok := false
+	e.eachCandidate(response, KindAny, func(candidate Result, nested bool) bool {
+		found, ok = candidate, true
+		return false
+	})
+	return found, ok
+}
+
+// ExtractFor returns the candidate best suited to target, typically the pointer that will
+// be passed to json.Unmarshal. Only candidates of the JSON kind the target expects are
+// considered (an array for *[]Item, a number for *int, ...). Among those, the first one that
+// decodes into the target's type without unknown fields wins, then the first one that
+// decodes at all. Nested candidates are only considered when no whole candidate decodes.
+// target itself is not modified.
+func (e *Extractor) ExtractFor(response string, target any) (Result, bool) {
+	whole, nested := e.candidates(response, KindOf(target))
+	if len(whole)+len(nested) == 0 {
+		return Result{}, false
+	}
+
+	targetType := reflect.TypeOf(target)
+	if targetType == nil || targetType.Kind() != reflect.Pointer {
+		return append(whole, nested...)[0], true
+	}
+
+	for _, candidates := range [][]Result{whole, nested} {
+		for _, strict := range []bool{true, false} {
+			for _, candidate := range candidates {
+				if decodes(candidate.JSON, targetType.Elem(), strict) {
+					return candidate, true
+				}
+			}
+		}
+	}
+	return Result{}, false
+}
+
+// candidates returns the distinct valid candidates of the given kind in pipeline order,
+// split into whole and nested candidates
+func (e *Extractor) candidates(response string, kind Kind) (whole, nested []Result) {
+	e.eachCandidate(response, kind, func(candidate Result, isNested bool) bool {
+		if isNested {
+			nested = append(nested, candidate)
+		} else {
+			whole = append(whole, candidate)
+		}
+		return true
+	})
+	return whole, nested
+}
+
+// eachCandidate calls fn with each distinct valid candidate of the given kind until fn
+// returns false, reporting whether it is a nested candidate. Whole candidates come first:
+// those that are already valid JSON, in pipeline order, then repaired ones, first from
+// repairing strategies, then invalid candidates of the other strategies passed through
+// RepairJSON. Nested candidates follow in the same order, so a repaired value is preferred
+// over a valid fragment of it, while valid JSON is preferred over repaired JSON at each
+// level. Without a repairing strategy in the pipeline, nothing is repaired.
+func (e *Extractor) eachCandidate(response string, kind Kind, fn func(candidate Result, nested bool) bool) {
+	seen := make(map[string]bool)
+	emit := func(candidate, strategy string, repaired, nested bool) bool {
+		if seen[candidate] || !kind.accepts(kindOfJSON(candidate)) || !isValidJSON(candidate) {
+			return true
+		}
+		seen[candidate] = true
+		return fn(Result{JSON: candidate, Strategy: strategy, Repaired: repaired}, nested)
+	}
+
+	type proposal struct {
+		strategy string
+		text     string
+	}
+	var valid, invalid, invalidNested []proposal
+	var repairing []Strategy
+
+	for _, strategy := range e.strategies {
+		if targeted, ok := strategy.(TargetedStrategy); ok && !targeted.AppliesTo(kind) {
+			continue
+		}
+		if r, ok := strategy.(RepairingStrategy); ok && r.Repairs() {
+			repairing = append(repairing, strategy)
+			continue
+		}
+
+		for _, candidate := range strategy.Candidates(response) {
+			if !isValidJSON(candidate) {
+				invalid = append(invalid, proposal{strategy: strategy.Name(), text: candidate})
+				continue
+			}
+			if !emit(candidate, strategy.Name(), false, false) {
+				return
+			}
+		}
+		if nesting, ok := strategy.(NestingStrategy); ok {
+			for _, candidate := range nesting.NestedCandidates(response) {
+				if isValidJSON(candidate) {
+					valid = append(valid, proposal{strategy: strategy.Name(), text: candidate})
+				} else {
+					invalidNested = append(invalidNested, proposal{strategy: strategy.Name(), text: candidate})
+				}
+			}
+		}
+	}
+
+	repair := func(candidates []proposal, nested bool) bool {
+		for _, candidate := range candidates {
+			if repaired, err := RepairJSON(candidate.text); err == nil {
+				if !emit(repaired, candidate.strategy, true, nested) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	if len(repairing) > 0 {
+		for _, strategy := range repairing {
+			for _, candidate := range strategy.Candidates(response) {
+				if !emit(candidate, strategy.Name(), true, false) {
+					return
+				}
+			}
+		}
+		if !repair(invalid, false) {
+			return
+		}
+	}
+
+	for _, candidate := range valid {
+		if !emit(candidate.text, candidate.strategy, false, true) {
+			return
+		}
+	}
+	if len(repairing) > 0 {
+		repair(invalidNested, true)
+	}
+}
+
+// decodes reports whether text unmarshals into a value of type t
+func decodes(text string, t reflect.Type, strict bool) bool {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(reflect.New(t).Interface()) == nil
+}
+
+// Extract returns the JSON found in response by the default pipeline, or an empty string
+func Extract(response string) string {
+	result, _ := Default().Extract(response)
+	return result.JSON
+}
+
+// isValidJSON checks if a string is valid JSON using Go's standard library
+func isValidJSON(s string) bool {
+	return s != "" && json.Valid([]byte(s))
+}
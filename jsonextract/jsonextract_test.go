@@ -0,0 +1,227 @@
+package jsonextract
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		json     string
+		strategy string
+	}{
+		{
+			name:     "plain JSON",
+			response: `  {"answer": 42}  `,
+			json:     `{"answer": 42}`,
+			strategy: StrategyDirect,
+		},
+		{
+			name:     "unescaped newline",
+			response: "{\"text\": \"line one\nline two\"}",
+			json:     `{"text":"line one\nline two"}`,
+			strategy: StrategyRepair,
+		},
+		{
+			name:     "markdown fence",
+			response: "Here you go:\n```json\n{\"answer\": 42}\n```\nLet me know!",
+			json:     `{"answer": 42}`,
+			strategy: StrategyMarkdown,
+		},
+		{
+			name:     "generic fence",
+			response: "Result:\n```\n{\"answer\": 42}\n```",
+			json:     `{"answer": 42}`,
+			strategy: StrategyMarkdown,
+		},
+		{
+			name:     "surrounding prose",
+			response: `The result is {"answer": {"value": "}"}} as requested.`,
+			json:     `{"answer": {"value": "}"}}`,
+			strategy: StrategyBraceCounting,
+		},
+		{
+			name:     "skips invalid spans",
+			response: `Use {placeholders} like {"answer": 42}.`,
+			json:     `{"answer": 42}`,
+			strategy: StrategyBraceCounting,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := Default().Extract(tt.response)
+			if !ok {
+				t.Fatalf("Expected JSON to be extracted from %q", tt.response)
+			}
+			if result.JSON != tt.json {
+				t.Errorf("Expected JSON %q, got %q", tt.json, result.JSON)
+			}
+			if result.Strategy != tt.strategy {
+				t.Errorf("Expected strategy %q, got %q", tt.strategy, result.Strategy)
+			}
+		})
+	}
+}
+
+func TestExtractNoJSON(t *testing.T) {
+	if _, ok := Default().Extract("I cannot help with that."); ok {
+		t.Error("Expected no JSON to be found")
+	}
+	if got := Extract("nothing here"); got != "" {
+		t.Errorf("Expected empty string, got %q", got)
+	}
+}
+
+func TestExtractorCustomization(t *testing.T) {
+	response := "```json\n{\"source\": \"fence\"}\n```"
+
+	// Disabling the markdown strategy falls through to brace counting
+	result, ok := Default().Without(StrategyMarkdown).Extract(response)
+	if !ok || result.Strategy != StrategyBraceCounting {
+		t.Errorf("Expected brace counting after disabling markdown, got %+v", result)
+	}
+
+	// Custom strategies can run ahead of the built-ins
+	custom := NewStrategy("always", func(response string) []string {
+		return []string{`{"source": "custom"}`}
+	})
+	result, ok = Default().WithBefore(StrategyDirect, custom).Extract(response)
+	if !ok || result.Strategy != "always" || !strings.Contains(result.JSON, "custom") {
+		t.Errorf("Expected custom strategy to win, got %+v", result)
+	}
+
+	// Strategies run in the order given
+	extractor := New(BraceCounting(), Markdown())
+	result, _ = extractor.Extract(response)
+	if result.Strategy != StrategyBraceCounting {
+		t.Errorf("Expected brace counting to run first, got %q", result.Strategy)
+	}
+	if len(extractor.Strategies()) != 2 {
+		t.Errorf("Expected 2 strategies, got %d", len(extractor.Strategies()))
+	}
+}
+
+type item struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+func TestExtractForArrays(t *testing.T) {
+	response := "Here are the items:\n[\n  {\"name\": \"apple\", \"price\": 1},\n  {\"name\": \"pear\", \"price\": 2}\n]\nEnjoy!"
+
+	var items []item
+	result, ok := Default().ExtractFor(response, &items)
+	if !ok {
+		t.Fatal("Expected array to be extracted")
+	}
+	if result.Strategy != StrategyBraceCounting || !strings.HasPrefix(result.JSON, "[") {
+		t.Errorf("Expected the whole array from brace counting, got %+v", result)
+	}
+
+	// An object target picks the first inner object instead
+	var single item
+	result, ok = Default().ExtractFor(response, &single)
+	if !ok || !strings.Contains(result.JSON, "apple") || !strings.HasPrefix(result.JSON, "{") {
+		t.Errorf("Expected first object for object target, got %+v", result)
+	}
+}
+
+func TestExtractForArrayInMarkdown(t *testing.T) {
+	response := "```json\n[{\"name\": \"apple\", \"price\": 1}]\n```"
+
+	var items []item
+	result, ok := Default().ExtractFor(response, &items)
+	if !ok || result.Strategy != StrategyMarkdown {
+		t.Errorf("Expected array from markdown fence, got %+v", result)
+	}
+}
+
+func TestExtractForPrefersMatchingCandidate(t *testing.T) {
+	type answer struct {
+		Answer int `json:"answer"`
+	}
+	response := `Thinking: {"step": 1, "note": "add"} Final: {"answer": 42}`
+
+	var target answer
+	result, ok := Default().ExtractFor(response, &target)
+	if !ok || result.JSON != `{"answer": 42}` {
+		t.Errorf("Expected the candidate matching the target's fields, got %+v", result)
+	}
+}
+
+func TestExtractForPrefersWholeValue(t *testing.T) {
+	type person struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	// The inner {} and [] decode strictly, but fields of the value are not the value
+	for _, response := range []string{
+		`{"name":"x","age":3,"tags":{}}`,
+		`Here you go: {"name":"x","age":3,"pets":[{"name":"rex"}]} Done.`,
+	} {
+		var target person
+		result, ok := Default().ExtractFor(response, &target)
+		if !ok || !strings.Contains(result.JSON, `"age":3`) {
+			t.Errorf("Expected the whole object from %q, got %+v", response, result)
+		}
+	}
+}
+
+func TestExtractForScalars(t *testing.T) {
+	var number int
+	if result, ok := Default().ExtractFor("The answer is 42.", &number); !ok || result.JSON != "42" {
+		t.Errorf("Expected number 42, got %+v", result)
+	}
+
+	var flag bool
+	if result, ok := Default().ExtractFor("Yes, that is True.", &flag); !ok || result.JSON != "true" {
+		t.Errorf("Expected boolean true, got %+v", result)
+	}
+
+	var text string
+	if result, ok := Default().ExtractFor(`"Paris"`, &text); !ok || result.JSON != `"Paris"` {
+		t.Errorf("Expected quoted string, got %+v", result)
+	}
+	if result, ok := Default().ExtractFor("Paris", &text); !ok || result.JSON != `"Paris"` {
+		t.Errorf("Expected bare text to be treated as a string, got %+v", result)
+	}
+	if result, ok := Default().ExtractFor(`Sure! "hello"`, &text); !ok || result.JSON != `"hello"` {
+		t.Errorf("Expected the quoted string in the text, got %+v", result)
+	}
+
+	// Untyped extraction does not pull numbers out of prose
+	if _, ok := Default().Extract("I have 2 cats."); ok {
+		t.Error("Expected no JSON from prose without a target")
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	var items []item
+	var obj item
+	var m map[string]any
+	var n float64
+	var raw []byte
+	var anything any
+
+	tests := []struct {
+		target any
+		kind   Kind
+	}{
+		{&items, KindArray},
+		{&obj, KindObject},
+		{&m, KindObject},
+		{&n, KindNumber},
+		{&raw, KindString},
+		{&anything, KindAny},
+		{nil, KindAny},
+	}
+	for _, tt := range tests {
+		if kind := KindOf(tt.target); kind != tt.kind {
+			t.Errorf("KindOf(%T): expected %s, got %s", tt.target, tt.kind, kind)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package jsonextract
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Kind is the kind of JSON value a target expects
+type Kind int
+
+const (
+	// KindAny accepts any JSON value
+	KindAny Kind = iota
+	// KindObject expects a JSON object
+	KindObject
+	// KindArray expects a JSON array
+	KindArray
+	// KindString expects a JSON string
+	KindString
+	// KindNumber expects a JSON number
+	KindNumber
+	// KindBool expects a JSON boolean
+	KindBool
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindObject:
+		return "object"
+	case KindArray:
+		return "array"
+	case KindString:
+		return "string"
+	case KindNumber:
+		return "number"
+	case KindBool:
+		return "boolean"
+	default:
+		return "any"
+	}
+}
+
+// IsScalar reports whether the kind is a string, number or boolean
+func (k Kind) IsScalar() bool {
+	return k == KindString || k == KindNumber || k == KindBool
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// KindOf returns the kind of JSON value target (usually a pointer) unmarshals from
+func KindOf(target any) Kind {
+	if target == nil {
+		return KindAny
+	}
+	return kindOfType(reflect.TypeOf(target))
+}
+
+func kindOfType(t reflect.Type) Kind {
+	for t.Kind() == reflect.Pointer {
+		// Custom unmarshalers decide for themselves what they accept
+		if t.Implements(jsonUnmarshalerType) {
+			return KindAny
+		}
+		t = t.Elem()
+	}
+
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return KindAny
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return KindString
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return KindObject
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string
+			return KindString
+		}
+		return KindArray
+	case reflect.Array:
+		return KindArray
+	case reflect.String:
+		return KindString
+	case reflect.Bool:
+		return KindBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return KindNumber
+	default:
+		return KindAny
+	}
+}
+
+// kindOfJSON returns the kind of a JSON text from its first character
+func kindOfJSON(text string) Kind {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return KindAny
+	}
+
+	switch c := text[0]; {
+	case c == '{':
+		return KindObject
+	case c == '[':
+		return KindArray
+	case c == '"':
+		return KindString
+	case c == 't' || c == 'f':
+		return KindBool
+	case c == '-' || (c >= '0' && c <= '9'):
+		return KindNumber
+	default:
+		return KindAny
+	}
+}
+
+// accepts reports whether a JSON value of kind found satisfies a target of kind k
+func (k Kind) accepts(found Kind) bool {
+	return k == KindAny || k == found
+}
@@ -0,0 +1,150 @@
+package jsonextract
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrNoJSON is returned when a complete response contains no usable JSON
+var ErrNoJSON = errors.New("no valid JSON found in response")
+
+// PartialJSON returns a best-effort JSON text for a response that is still streaming in.
+// Like the extraction pipeline, it skips markdown fences and any prose before the value,
+// then closes unterminated strings, arrays and objects with the repair parser. Object
+// members whose value has not arrived yet are left out. kind selects where the value
+// starts, so an array target ignores a '{' that appears before the first '['.
+func PartialJSON(buffer string, kind Kind) (string, bool) {
+	text, ok := partialFenceContent(buffer)
+	if !ok {
+		return "", false
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", false
+	}
+
+	if kind.IsScalar() {
+		if kind == KindString && text[0] != '"' && text[0] != '\'' {
+			encoded, _ := json.Marshal(text)
+			return string(encoded), true
+		}
+	} else {
+		start := partialValueStart(text, kind)
+		if start == -1 {
+			return "", false
+		}
+		text = text[start:]
+	}
+
+	repaired, err := RepairJSON(text)
+	if err != nil || !kind.accepts(kindOfJSON(repaired)) {
+		return "", false
+	}
+	return repaired, true
+}
+
+// DecodePartial decodes the best-effort JSON of a streaming response into target and
+// reports whether anything was decoded. target is typically a freshly allocated pointer,
+// since a failed decode may leave it partially filled.
+func DecodePartial(buffer string, target any) bool {
+	text, ok := PartialJSON(buffer, KindOf(target))
+	if !ok {
+		return false
+	}
+	return json.Unmarshal([]byte(text), target) == nil
+}
+
+// partialFenceContent returns the text inside the first markdown fence, which may still
+// be open, or the whole buffer when there is no fence. It reports false while the fence's
+// opening line is incomplete.
+func partialFenceContent(buffer string) (string, bool) {
+	start := strings.Index(buffer, "```")
+	if start == -1 {
+		// A fence may be starting at the very end of the buffer
+		return strings.TrimRight(buffer, "`"), true
+	}
+
+	// Skip the language tag, unless the value starts on the fence line itself
+	rest := buffer[start+3:]
+	newline := strings.IndexByte(rest, '\n')
+	switch {
+	case newline != -1 && !strings.ContainsAny(rest[:newline], "{[\""):
+		rest = rest[newline+1:]
+	case newline == -1 && !strings.ContainsAny(rest, "{[\""):
+		return "", false
+	}
+
+	if end := strings.Index(rest, "```"); end != -1 {
+		rest = rest[:end]
+	}
+	return strings.TrimRight(rest, "`"), true
+}
+
+// partialValueStart returns the offset of the first character that can start a value of
+// the given kind, or -1 if there is none yet
+func partialValueStart(text string, kind Kind) int {
+	switch kind {
+	case KindObject:
+		return strings.IndexByte(text, '{')
+	case KindArray:
+		return strings.IndexByte(text, '[')
+	default:
+		return strings.IndexAny(text, "{[")
+	}
+}
+
+// PartialParser decodes a structured response of type T while it streams in. Each Write
+// appends a chunk and yields a best-effort value; Final decodes the complete response with
+// the regular extraction pipeline.
+type PartialParser[T any] struct {
+	extractor *Extractor
+	buffer    strings.Builder
+	last      string
+	value     T
+}
+
+// NewPartialParser creates a parser that uses extractor for the final decode, or the
+// default pipeline if extractor is nil
+func NewPartialParser[T any](extractor *Extractor) *PartialParser[T] {
+	if extractor == nil {
+		extractor = Default()
+	}
+	return &PartialParser[T]{extractor: extractor}
+}
+
+// Write appends chunk to the buffer and returns the best-effort value decoded so far.
+// changed is false when the value is the same as after the previous Write, including
+// when nothing could be decoded yet.
+func (p *PartialParser[T]) Write(chunk string) (value T, changed bool) {
+	p.buffer.WriteString(chunk)
+
+	text, ok := PartialJSON(p.buffer.String(), KindOf(&value))
+	if !ok || text == p.last {
+		return p.value, false
+	}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return p.value, false
+	}
+	p.last, p.value = text, value
+	return value, true
+}
+
+// Buffer returns the text received so far
+func (p *PartialParser[T]) Buffer() string {
+	return p.buffer.String()
+}
+
+// Final decodes the complete response with the extractor's pipeline, exactly as for a
+// response that was not streamed
+func (p *PartialParser[T]) Final() (T, Result, error) {
+	var value T
+	result, ok := p.extractor.ExtractFor(p.buffer.String(), &value)
+	if !ok {
+		return value, Result{}, ErrNoJSON
+	}
+	if err := json.Unmarshal([]byte(result.JSON), &value); err != nil {
+		return value, result, err
+	}
+	return value, result, nil
+}
@@ -0,0 +1,80 @@
+package jsonextract
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPartialJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		buffer string
+		kind   Kind
+		json   string
+		ok     bool
+	}{
+		{"empty", "", KindObject, "", false},
+		{"prose only", "Sure, here", KindObject, "", false},
+		{"open object", `{"name": "app`, KindObject, `{"name":"app"}`, true},
+		{"pending key", `{"name": "apple", "pri`, KindObject, `{"name":"apple"}`, true},
+		{"pending value", `{"name": "apple", "price":`, KindObject, `{"name":"apple"}`, true},
+		{"nested array", `{"tags": ["red", "gre`, KindObject, `{"tags":["red","gre"]}`, true},
+		{"prose before value", `Here it is: {"a": 1`, KindObject, `{"a":1}`, true},
+		{"opening fence line", "```js", KindObject, "", false},
+		{"inside fence", "```json\n{\"a\": tr", KindObject, `{"a":true}`, true},
+		{"closing fence", "```json\n{\"a\": 1}\n``", KindObject, `{"a":1}`, true},
+		{"array target skips objects", `Use {x} like [1, 2`, KindArray, `[1,2]`, true},
+		{"string target", `Par`, KindString, `"Par"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PartialJSON(tt.buffer, tt.kind)
+			if ok != tt.ok || got != tt.json {
+				t.Errorf("PartialJSON(%q) = %q, %v; expected %q, %v", tt.buffer, got, ok, tt.json, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPartialParser(t *testing.T) {
+	type recipe struct {
+		Title string   `json:"title"`
+		Steps []string `json:"steps"`
+	}
+	response := "```json\n{\"title\": \"Tea\", \"steps\": [\"boil water\", \"steep\"]}\n```"
+
+	parser := NewPartialParser[recipe](nil)
+	var updates []recipe
+	for _, chunk := range strings.SplitAfter(response, " ") {
+		if value, changed := parser.Write(chunk); changed {
+			updates = append(updates, value)
+		}
+	}
+
+	if len(updates) < 3 {
+		t.Fatalf("Expected several partial updates, got %d: %+v", len(updates), updates)
+	}
+	if first := updates[0]; first.Title != "" && len(first.Steps) != 0 {
+		t.Errorf("Expected the first update to be incomplete, got %+v", first)
+	}
+
+	final, result, err := parser.Final()
+	if err != nil {
+		t.Fatalf("Expected final decode to succeed, got %v", err)
+	}
+	if final.Title != "Tea" || len(final.Steps) != 2 || result.Strategy != StrategyMarkdown {
+		t.Errorf("Unexpected final value %+v from %+v", final, result)
+	}
+	if parser.Buffer() != response {
+		t.Error("Expected buffer to hold the whole response")
+	}
+}
+
+func TestPartialParserFinalWithoutJSON(t *testing.T) {
+	parser := NewPartialParser[map[string]any](nil)
+	parser.Write("I cannot help with that.")
+	if _, _, err := parser.Final(); err != ErrNoJSON {
+		t.Errorf("Expected ErrNoJSON, got %v", err)
+	}
+}
@@ -0,0 +1,441 @@
+package jsonextract
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// maxRepairDepth bounds nesting so hostile input cannot exhaust the stack
+const maxRepairDepth = 512
+
+// ErrUnrepairable is returned when no JSON value can be recovered from the input
+var ErrUnrepairable = errors.New("no repairable JSON value found")
+
+// RepairJSON leniently parses a JSON5-style value and re-emits it as strict JSON. It accepts:
+//   - trailing and missing commas
+//   - single-quoted strings and unquoted object keys
+//   - // line, /* block */ and # comments
+//   - Python literals (True, False, None) and NaN/Infinity (emitted as null)
+//   - raw newlines, tabs and invalid escapes inside strings
+//   - output truncated mid-value, closing unterminated strings, arrays and objects and
+//     dropping object members whose value never arrived
+//
+// Text after the first complete object or array is ignored. Other values, such as a
+// single-quoted string or a Python literal, must make up the whole text, and bare words
+// are not treated as JSON at the top level.
+func RepairJSON(text string) (string, error) {
+	r := &repairer{src: text}
+	r.skipSpace()
+	if r.eof() {
+		return "", ErrUnrepairable
+	}
+
+	container := r.peek() == '{' || r.peek() == '['
+	if !container && isIdentStart(r.peek()) {
+		word := r.ident()
+		r.literal(word)
+		if kindOfJSON(string(r.out)) == KindString {
+			return "", ErrUnrepairable
+		}
+	} else if !r.value(0) {
+		return "", ErrUnrepairable
+	}
+
+	if !container {
+		r.skipSpace()
+		if !r.eof() {
+			return "", ErrUnrepairable
+		}
+	}
+
+	repaired := string(r.out)
+	if !json.Valid(r.out) {
+		return "", ErrUnrepairable
+	}
+	return repaired, nil
+}
+
+// Repair leniently parses the trimmed response, and the text from its first '{' and '['
+// when it starts with prose. Results from this strategy are flagged as repaired.
+func Repair() Strategy {
+	return repairStrategy{}
+}
+
+// RepairingStrategy is implemented by strategies whose candidates have been repaired,
+// so results from them are flagged as Repaired
+type RepairingStrategy interface {
+	Strategy
+
+	// Repairs reports whether the strategy's candidates are repaired text
+	Repairs() bool
+}
+
+type repairStrategy struct{}
+
+func (repairStrategy) Name() string {
+	return StrategyRepair
+}
+
+func (repairStrategy) Repairs() bool {
+	return true
+}
+
+func (repairStrategy) Candidates(response string) []string {
+	trimmed := strings.TrimSpace(response)
+	starts := []string{trimmed}
+	if i := strings.IndexByte(trimmed, '{'); i > 0 {
+		starts = append(starts, trimmed[i:])
+	}
+	if i := strings.IndexByte(trimmed, '['); i > 0 {
+		starts = append(starts, trimmed[i:])
+	}
+
+	var candidates []string
+	for _, start := range starts {
+		if repaired, err := RepairJSON(start); err == nil {
+			candidates = append(candidates, repaired)
+		}
+	}
+	return candidates
+}
+
+// repairer is a recursive descent parser that writes strict JSON as it reads lenient input
+type repairer struct {
+	src string
+	pos int
+	out []byte
+}
+
+func (r *repairer) eof() bool {
+	return r.pos >= len(r.src)
+}
+
+func (r *repairer) peek() byte {
+	return r.src[r.pos]
+}
+
+// skipSpace skips whitespace and comments
+func (r *repairer) skipSpace() {
+	for !r.eof() {
+		switch c := r.peek(); {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			r.pos++
+		case c == '#' || strings.HasPrefix(r.src[r.pos:], "//"):
+			end := strings.IndexByte(r.src[r.pos:], '\n')
+			if end == -1 {
+				r.pos = len(r.src)
+			} else {
+				r.pos += end + 1
+			}
+		case strings.HasPrefix(r.src[r.pos:], "/*"):
+			end := strings.Index(r.src[r.pos+2:], "*/")
+			if end == -1 {
+				r.pos = len(r.src)
+			} else {
+				r.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+// value parses one value, returning false if nothing usable was found
+func (r *repairer) value(depth int) bool {
+	if r.eof() || depth > maxRepairDepth {
+		return false
+	}
+
+	switch c := r.peek(); {
+	case c == '{':
+		r.object(depth + 1)
+		return true
+	case c == '[':
+		r.array(depth + 1)
+		return true
+	case c == '"' || c == '\'':
+		return r.str(c)
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return r.number()
+	case isIdentStart(c):
+		r.literal(r.ident())
+		return true
+	default:
+		return false
+	}
+}
+
+// object parses an object, tolerating missing commas and dropping incomplete members
+func (r *repairer) object(depth int) {
+	r.pos++ // '{'
+	r.out = append(r.out, '{')
+	members := 0
+
+	for {
+		r.skipSpace()
+		if r.eof() {
+			break
+		}
+
+		c := r.peek()
+		if c == '}' || c == ']' {
+			r.pos++
+			break
+		}
+		if c == ',' {
+			r.pos++
+			continue
+		}
+
+		start, mark := r.pos, len(r.out)
+		if members > 0 {
+			r.out = append(r.out, ',')
+		}
+		if r.member(depth) {
+			members++
+		} else {
+			r.out = r.out[:mark]
+			if r.pos == start {
+				// Skip a character we cannot make sense of
+				r.pos++
+			}
+		}
+	}
+
+	r.out = append(r.out, '}')
+}
+
+// member parses a key/value pair, returning false if it is incomplete
+func (r *repairer) member(depth int) bool {
+	switch c := r.peek(); {
+	case c == '"' || c == '\'':
+		if !r.str(c) {
+			return false
+		}
+	case isIdentStart(c) || (c >= '0' && c <= '9'):
+		r.writeString(r.ident())
+	default:
+		return false
+	}
+
+	r.skipSpace()
+	if r.eof() {
+		return false
+	}
+	if c := r.peek(); c == ':' || c == '=' {
+		r.pos++
+		r.skipSpace()
+	}
+	r.out = append(r.out, ':')
+
+	if r.eof() {
+		return false
+	}
+	if c := r.peek(); c == ',' || c == '}' {
+		return false
+	}
+	return r.value(depth)
+}
+
+// array parses an array, tolerating missing and trailing commas
+func (r *repairer) array(depth int) {
+	r.pos++ // '['
+	r.out = append(r.out, '[')
+	elements := 0
+
+	for {
+		r.skipSpace()
+		if r.eof() {
+			break
+		}
+
+		c := r.peek()
+		if c == ']' || c == '}' {
+			r.pos++
+			break
+		}
+		if c == ',' {
+			r.pos++
+			continue
+		}
+
+		start, mark := r.pos, len(r.out)
+		if elements > 0 {
+			r.out = append(r.out, ',')
+		}
+		if r.value(depth) {
+			elements++
+		} else {
+			r.out = r.out[:mark]
+			if r.pos == start {
+				r.pos++
+			}
+		}
+	}
+
+	r.out = append(r.out, ']')
+}
+
+// str parses a single- or double-quoted string, closing it if the input ends first
+func (r *repairer) str(quote byte) bool {
+	r.pos++ // opening quote
+	r.out = append(r.out, '"')
+
+	for !r.eof() {
+		c := r.peek()
+		switch {
+		case c == quote:
+			r.pos++
+			r.out = append(r.out, '"')
+			return true
+		case c == '\\':
+			if r.pos+1 >= len(r.src) {
+				// Truncated in the middle of an escape
+				r.pos++
+				continue
+			}
+			next := r.src[r.pos+1]
+			switch {
+			case next == 'u' && isHex4(r.src[r.pos+2:]):
+				r.out = append(r.out, r.src[r.pos:r.pos+6]...)
+				r.pos += 6
+				continue
+			case next == 'u' && len(r.src)-r.pos < 6:
+				// Truncated unicode escape
+				r.pos = len(r.src)
+				continue
+			case strings.IndexByte(`"\/bfnrt`, next) != -1:
+				r.out = append(r.out, '\\', next)
+			case next == '\'':
+				r.out = append(r.out, '\'')
+			default:
+				// Invalid escape; keep the backslash literally
+				r.out = append(r.out, '\\', '\\', next)
+			}
+			r.pos += 2
+			continue
+		case c == '"':
+			r.out = append(r.out, '\\', '"')
+		case c == '\n':
+			r.out = append(r.out, '\\', 'n')
+		case c == '\r':
+			r.out = append(r.out, '\\', 'r')
+		case c == '\t':
+			r.out = append(r.out, '\\', 't')
+		case c < 0x20:
+			r.out = append(r.out, []byte(`\u00`)...)
+			r.out = append(r.out, "0123456789abcdef"[c>>4], "0123456789abcdef"[c&0xf])
+		default:
+			r.out = append(r.out, c)
+		}
+		r.pos++
+	}
+
+	// Unterminated string
+	r.out = append(r.out, '"')
+	return true
+}
+
+// numberPattern matches a strict JSON number
+var numberPattern = regexp.MustCompile(`^-?(?:0|[1-9]\d*)(?:\.\d+)?(?:[eE][+-]?\d+)?$`)
+
+// number parses a number, normalizing leading '+', leading or trailing '.', leading zeros
+// and exponents cut off by truncation
+func (r *repairer) number() bool {
+	start := r.pos
+	for !r.eof() && strings.IndexByte("+-.0123456789eE", r.peek()) != -1 {
+		r.pos++
+	}
+
+	raw := r.src[start:r.pos]
+	if (raw == "-" || raw == "+") && !r.eof() && isIdentStart(r.peek()) {
+		// -Infinity and friends
+		r.ident()
+		r.out = append(r.out, "null"...)
+		return true
+	}
+
+	negative := strings.HasPrefix(raw, "-")
+	digits := strings.TrimLeft(raw, "+-")
+	digits = strings.TrimRight(digits, "eE+-")
+	digits = strings.TrimSuffix(digits, ".")
+	if strings.HasPrefix(digits, ".") {
+		digits = "0" + digits
+	}
+	if trimmed := strings.TrimLeft(digits, "0"); trimmed != digits {
+		if trimmed == "" || trimmed[0] == '.' || trimmed[0] == 'e' || trimmed[0] == 'E' {
+			trimmed = "0" + trimmed
+		}
+		digits = trimmed
+	}
+	if negative {
+		digits = "-" + digits
+	}
+
+	if !numberPattern.MatchString(digits) {
+		return false
+	}
+	r.out = append(r.out, digits...)
+	return true
+}
+
+// ident reads an unquoted identifier
+func (r *repairer) ident() string {
+	start := r.pos
+	for !r.eof() {
+		c := r.peek()
+		if !isIdentStart(c) && !(c >= '0' && c <= '9') && c != '-' && c != '.' {
+			break
+		}
+		r.pos++
+	}
+	return r.src[start:r.pos]
+}
+
+// literal writes the JSON equivalent of an unquoted word
+func (r *repairer) literal(word string) {
+	switch word {
+	case "true", "True", "TRUE":
+		r.out = append(r.out, "true"...)
+	case "false", "False", "FALSE":
+		r.out = append(r.out, "false"...)
+	case "null", "None", "NULL", "nil", "undefined", "NaN", "Infinity":
+		r.out = append(r.out, "null"...)
+	default:
+		// A literal cut off by truncation ("tr", "fals", "nu")
+		if r.eof() {
+			for _, full := range []string{"true", "false", "null"} {
+				if strings.HasPrefix(full, word) {
+					r.out = append(r.out, full...)
+					return
+				}
+			}
+		}
+		// Anything else is treated as an unquoted string
+		r.writeString(word)
+	}
+}
+
+// writeString writes s as a JSON string
+func (r *repairer) writeString(s string) {
+	encoded, _ := json.Marshal(s)
+	r.out = append(r.out, encoded...)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isHex4(s string) bool {
+	if len(s) < 4 {
+		return false
+	}
+	for i := 0; i < 4; i++ {
+		if strings.IndexByte("0123456789abcdefABCDEF", s[i]) == -1 {
+			return false
+		}
+	}
+	return true
+}
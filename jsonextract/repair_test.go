@@ -0,0 +1,100 @@
+package jsonextract
+
+import (
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"trailing commas", `{"a": [1, 2, ], "b": 3,}`, `{"a":[1,2],"b":3}`},
+		{"single quotes", `{'name': 'O\'Brien', 'quote': 'say "hi"'}`, `{"name":"O'Brien","quote":"say \"hi\""}`},
+		{"unquoted keys", `{name: "x", first_name: "y", $id: 1}`, `{"name":"x","first_name":"y","$id":1}`},
+		{"comments", "{\n  // the name\n  \"name\": \"x\", /* inline */ \"n\": 1 # trailing\n}", `{"name":"x","n":1}`},
+		{"python literals", `{"a": True, "b": False, "c": None}`, `{"a":true,"b":false,"c":null}`},
+		{"non-finite numbers", `[NaN, Infinity, -Infinity]`, `[null,null,null]`},
+		{"number forms", `[+1, .5, 2., 007, 1e]`, `[1,0.5,2,7,1]`},
+		{"missing commas", `{"a": 1 "b": 2}`, `{"a":1,"b":2}`},
+		{"raw control characters", "{\"text\": \"line one\nline\ttwo\"}", `{"text":"line one\nline\ttwo"}`},
+		{"invalid escape", `{"path": "C:\windows"}`, `{"path":"C:\\windows"}`},
+		{"truncated string", `{"title": "Hello wor`, `{"title":"Hello wor"}`},
+		{"truncated array", `{"items": [1, 2, {"id": 3`, `{"items":[1,2,{"id":3}]}`},
+		{"truncated after key", `{"a": 1, "b":`, `{"a":1}`},
+		{"truncated key", `{"a": 1, "bc`, `{"a":1}`},
+		{"truncated literal", `{"done": tr`, `{"done":true}`},
+		{"truncated number", `{"n": -`, `{}`},
+		{"trailing prose", `{"a": 1} and that's it`, `{"a":1}`},
+		{"scalar literal", `True`, `true`},
+		{"single-quoted string", `'hello'`, `"hello"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RepairJSON(tt.input)
+			if err != nil {
+				t.Fatalf("Expected %q to be repaired, got error %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRepairJSONRejectsProse(t *testing.T) {
+	for _, input := range []string{"", "   ", "Hello there", "'unterminated' and more", "}"} {
+		if got, err := RepairJSON(input); err == nil {
+			t.Errorf("Expected %q to be unrepairable, got %s", input, got)
+		}
+	}
+}
+
+func TestExtractFlagsRepairedResults(t *testing.T) {
+	// Valid JSON elsewhere in the response wins over repairing earlier text
+	result, ok := Default().Extract("Draft: {a: 1,}\nFinal: {\"a\": 2}")
+	if !ok || result.JSON != `{"a": 2}` || result.Repaired {
+		t.Errorf("Expected valid JSON to be preferred, got %+v", result)
+	}
+
+	// Truncated output from a max-tokens cutoff
+	result, ok = Default().Extract("```json\n{\"items\": [\"a\", \"b\"")
+	if !ok || result.JSON != `{"items":["a","b"]}` || !result.Repaired || result.Strategy != StrategyRepair {
+		t.Errorf("Expected truncated JSON to be repaired, got %+v", result)
+	}
+
+	// Repair applies to candidates found by other strategies too
+	var target map[string]int
+	result, ok = Default().ExtractFor("Note [1]: see below\n```json\n{'a': 1,}\n```", &target)
+	if !ok || result.JSON != `{"a":1}` || !result.Repaired {
+		t.Errorf("Expected fenced JSON5 to be repaired, got %+v", result)
+	}
+
+	// Disabling the repair strategy disables repair entirely
+	if _, ok := Default().Without(StrategyRepair).Extract(`{a: 1}`); ok {
+		t.Error("Expected no result without the repair strategy")
+	}
+}
+
+func TestRepairPreferredOverNestedFragments(t *testing.T) {
+	// The inner {} and [] are valid, but the repaired whole value is what was meant
+	result, ok := Default().Extract(`{name: 'x', tags: {},}`)
+	if !ok || result.JSON != `{"name":"x","tags":{}}` || !result.Repaired {
+		t.Errorf("Expected the repaired object, got %+v", result)
+	}
+
+	type person struct {
+		Name string   `json:"name"`
+		Pets []string `json:"pets"`
+		Home struct {
+			City string `json:"city"`
+		} `json:"home"`
+	}
+	var target person
+	result, ok = Default().ExtractFor("Sure:\n{name: 'x', pets: ['rex',], home: {\"city\": \"Oslo\"}} // done", &target)
+	if !ok || result.JSON != `{"name":"x","pets":["rex"],"home":{"city":"Oslo"}}` || !result.Repaired {
+		t.Errorf("Expected the repaired object instead of its nested object, got %+v", result)
+	}
+}
@@ -0,0 +1,257 @@
+package jsonextract
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Direct tries the response as-is after trimming whitespace
+func Direct() Strategy {
+	return NewStrategy(StrategyDirect, func(response string) []string {
+		return []string{strings.TrimSpace(response)}
+	})
+}
+
+// Markdown returns the contents of markdown code blocks, ```json blocks first
+func Markdown() Strategy {
+	return NewStrategy(StrategyMarkdown, func(response string) []string {
+		var tagged, untagged []string
+		for _, block := range codeBlocks(response) {
+			if block.language == "json" {
+				tagged = append(tagged, block.content)
+			} else {
+				untagged = append(untagged, block.content)
+			}
+		}
+		return append(tagged, untagged...)
+	})
+}
+
+// BraceCounting finds balanced {...} and [...] spans while ignoring brackets inside strings.
+// Its candidates are the outermost spans; the spans nested inside them are its nested
+// candidates.
+func BraceCounting() Strategy {
+	return nestingStrategy{
+		strategyFunc: strategyFunc{name: StrategyBraceCounting, fn: topLevelSpans},
+		nested: func(response string) []string {
+			return nestedValues(topLevelSpans(response), maxNestedSpanDepth)
+		},
+	}
+}
+
+// AggressiveCleanup strips common prefixes and suffixes LLMs add, then retries brace counting
+func AggressiveCleanup() Strategy {
+	return nestingStrategy{
+		strategyFunc: strategyFunc{name: StrategyAggressiveCleanup, fn: func(response string) []string {
+			cleaned := aggressiveCleanup(response)
+			return append([]string{cleaned}, topLevelSpans(cleaned)...)
+		}},
+		nested: func(response string) []string {
+			return nestedValues(topLevelSpans(aggressiveCleanup(response)), maxNestedSpanDepth)
+		},
+	}
+}
+
+// nestingStrategy adds nested candidates to a strategyFunc
+type nestingStrategy struct {
+	strategyFunc
+	nested func(response string) []string
+}
+
+func (s nestingStrategy) NestedCandidates(response string) []string {
+	return s.nested(response)
+}
+
+// codeBlock is a fenced markdown code block
+type codeBlock struct {
+	language string
+	content  string
+}
+
+// codeBlocks returns the fenced code blocks in a response, in order
+func codeBlocks(response string) []codeBlock {
+	var blocks []codeBlock
+	rest := response
+
+	for {
+		start := strings.Index(rest, "```")
+		if start == -1 {
+			return blocks
+		}
+		rest = rest[start+3:]
+
+		// The language tag runs to the end of the opening fence line
+		language := ""
+		if newline := strings.IndexByte(rest, '\n'); newline != -1 {
+			tag := strings.TrimSpace(rest[:newline])
+			if !strings.ContainsAny(tag, "{[\"") {
+				language = strings.ToLower(tag)
+				rest = rest[newline+1:]
+			}
+		}
+
+		end := strings.Index(rest, "```")
+		if end == -1 {
+			return blocks
+		}
+		blocks = append(blocks, codeBlock{language: language, content: strings.TrimSpace(rest[:end])})
+		rest = rest[end+3:]
+	}
+}
+
+// maxNestedSpanDepth limits how deep balancedValues looks for nested values, keeping
+// pathological inputs like thousands of nested brackets cheap
+const maxNestedSpanDepth = 8
+
+// balancedValues finds spans delimited by matching braces or brackets, ignoring delimiters
+// inside strings. Top-level spans are returned first, followed by the spans nested in them
+// down to the given depth.
+func balancedValues(response string, depth int) []string {
+	spans := topLevelSpans(response)
+	if depth <= 1 {
+		return spans
+	}
+
+	return append(spans, nestedValues(spans, depth)...)
+}
+
+// nestedValues finds the spans nested inside spans, down to depth-1 levels below them.
+// Nested values let callers recover an object from an invalid outer span
+// ("see [the {...} below]") or pick an inner value that fits their target.
+func nestedValues(spans []string, depth int) []string {
+	if depth <= 1 {
+		return nil
+	}
+	var nested []string
+	for _, span := range spans {
+		nested = append(nested, balancedValues(span[1:len(span)-1], depth-1)...)
+	}
+	return nested
+}
+
+// topLevelSpans finds the outermost balanced {...} and [...] spans in order
+func topLevelSpans(response string) []string {
+	var spans []string
+	var stack []rune
+	start := -1
+	inString := false
+	escaped := false
+
+	for i, char := range response {
+		// Handle string escaping to avoid counting brackets inside strings
+		if char == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+
+		if char == '"' && !escaped && start != -1 {
+			inString = !inString
+		}
+
+		escaped = false
+
+		// Only count brackets outside of strings
+		if inString {
+			continue
+		}
+
+		switch char {
+		case '{', '[':
+			if start == -1 {
+				start = i
+			}
+			stack = append(stack, char)
+		case '}', ']':
+			if start == -1 {
+				continue
+			}
+			if open := stack[len(stack)-1]; (open == '{') != (char == '}') {
+				// Mismatched closer; reset for next potential JSON value
+				stack = stack[:0]
+				start = -1
+				continue
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				spans = append(spans, response[start:i+1])
+				start = -1
+			}
+		}
+	}
+
+	return spans
+}
+
+// aggressiveCleanup removes common prefixes and suffixes that LLMs sometimes add
+func aggressiveCleanup(response string) string {
+	prefixes := []string{"```json", "```", "Here's the JSON:", "JSON:"}
+	suffixes := []string{"```"}
+
+	cleaned := strings.TrimSpace(response)
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(cleaned, prefix) {
+			cleaned = strings.TrimSpace(cleaned[len(prefix):])
+			break
+		}
+	}
+
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(cleaned, suffix) {
+			cleaned = strings.TrimSpace(cleaned[:len(cleaned)-len(suffix)])
+			break
+		}
+	}
+
+	return cleaned
+}
+
+// Scalars proposes string, number and boolean values for scalar targets: numbers,
+// true/false words and quoted strings found in the text, and the whole trimmed response
+// as a string when it quotes none
+func Scalars() Strategy {
+	return scalarStrategy{}
+}
+
+// scalarStrategy only runs for scalar targets, since prose is full of numbers
+type scalarStrategy struct{}
+
+func (scalarStrategy) Name() string {
+	return StrategyScalar
+}
+
+func (scalarStrategy) AppliesTo(kind Kind) bool {
+	return kind.IsScalar()
+}
+
+func (scalarStrategy) Candidates(response string) []string {
+	var candidates []string
+	for _, token := range scalarPattern.FindAllString(response, -1) {
+		candidates = append(candidates, strings.ToLower(token))
+	}
+
+	quoted := false
+	for _, token := range quotedPattern.FindAllString(response, -1) {
+		if json.Valid([]byte(token)) {
+			candidates = append(candidates, token)
+			quoted = true
+		}
+	}
+	if quoted {
+		return candidates
+	}
+
+	if trimmed := strings.TrimSpace(response); trimmed != "" {
+		if encoded, err := json.Marshal(trimmed); err == nil {
+			candidates = append(candidates, string(encoded))
+		}
+	}
+	return candidates
+}
+
+// scalarPattern matches JSON numbers and boolean words in free text
+var scalarPattern = regexp.MustCompile(`(?i)-?\b\d+(?:\.\d+)?(?:[eE][+-]?\d+)?\b|\b(?:true|false)\b`)
+
+// quotedPattern matches double-quoted strings, with escapes, in free text
+var quotedPattern = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
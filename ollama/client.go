@@ -2,12 +2,11 @@ package ollama
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"simpleai"
-	"strings"
 	"time"
 
 	"github.com/ollama/ollama/api"
@@ -16,16 +15,69 @@ import (
 type Client struct {
 	ollamaClient *api.Client
 	model        simpleai.Model
+	timeout      time.Duration
 }
 
+// defaultTimeout bounds each chat attempt when no timeout is set with WithTimeout
+const defaultTimeout = 60 * time.Second
+
 func NewClient(model simpleai.Model) *Client {
+	return NewClientWithHost(&url.URL{Scheme: "http", Host: "localhost:11434"}, model)
+}
+
+// NewClientWithHost creates a client for the Ollama server at host
+func NewClientWithHost(host *url.URL, model simpleai.Model) *Client {
 	return &Client{
-		ollamaClient: api.NewClient(&url.URL{Scheme: "http", Host: "localhost:11434"}, http.DefaultClient),
+		ollamaClient: api.NewClient(host, &http.Client{Transport: &retryHintTransport{}}),
 		model:        model,
+		timeout:      defaultTimeout,
 	}
 }
 
+// WithTimeout sets how long each chat attempt may take and returns the client
+func (c *Client) WithTimeout(timeout time.Duration) *Client {
+	c.timeout = timeout
+	return c
+}
+
+// retryHintKey is the context key under which a request's retryHint is stored
+type retryHintKey struct{}
+
+// retryHint receives the Retry-After delay from a throttled response
+type retryHint struct {
+	after time.Duration
+}
+
+// retryHintTransport records Retry-After headers from 429 and 503 responses into the
+// request's retryHint, since the Ollama API client does not expose response headers
+type retryHintTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryHintTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if hint, ok := req.Context().Value(retryHintKey{}).(*retryHint); ok {
+			hint.after = simpleai.ParseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+	}
+	return resp, nil
+}
+
+// PrependSystemPrompt adds the system prompt as the first message, if there is one
 func PrependSystemPrompt(messages []simpleai.Message, systemPrompt simpleai.SystemPrompt) []simpleai.Message {
+	if systemPrompt.Content == "" {
+		return messages
+	}
 	return append([]simpleai.Message{{Role: "system", Content: systemPrompt.Content}}, messages...)
 }
 
@@ -44,336 +96,203 @@ func (c *Client) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, erro
 	return c.ChatWithRetry(request, simpleai.DefaultRetryConfig())
 }
 
+// ChatContext sends a chat request, giving up when ctx is done
+func (c *Client) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return c.ChatContextWithRetry(ctx, request, simpleai.DefaultRetryConfig())
+}
+
 // ChatWithRetry executes a chat request with retry logic
 func (c *Client) ChatWithRetry(request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
-	var lastErr error
-	var finalResponse string
-	var structuredData any
-	var targetType interface{} = request.T
+	return c.ChatContextWithRetry(context.Background(), request, retryConfig)
+}
 
-	for attempt := 0; attempt <= retryConfig.MaxRetries; attempt++ {
-		// Calculate delay for retry (exponential backoff)
-		if attempt > 0 {
-			delay := time.Duration(float64(retryConfig.BaseDelay) * pow(retryConfig.BackoffFactor, attempt-1))
-			if delay > retryConfig.MaxDelay {
-				delay = retryConfig.MaxDelay
-			}
-			time.Sleep(delay)
-		}
+// ChatContextWithRetry executes a cancellable chat request with retry logic
+func (c *Client) ChatContextWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+	var targetType interface{} = request.T
 
-		// Reset response for each attempt
-		finalResponse = ""
-		structuredData = nil
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		finalResponse := ""
+		var usage *simpleai.Usage
 
 		handler := func(resp api.ChatResponse) error {
 			finalResponse += resp.Message.Content
+			if resp.Done {
+				usage = usageFromMetrics(resp.Metrics)
+			}
 			return nil
 		}
 
-		stream := false
-		thinkValue := &api.ThinkValue{
-			Value: false,
-		}
-
-		// Use configurable timeout (default 60 seconds, but could be made configurable)
-		timeout := 60 * time.Second
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		ctx, cancel := context.WithTimeout(ctx, c.timeout)
+		defer cancel() // Always cancel context
 
-		err := c.ollamaClient.Chat(ctx, &api.ChatRequest{
-			Model:    c.model.Name,
-			Messages: ConvertMessages(PrependSystemPrompt(request.Messages, request.SystemPrompt)),
-			Stream:   &stream,
-			Think:    thinkValue,
-		}, handler)
+		// Capture any Retry-After hint the server sends with a 429 or 503
+		hint := &retryHint{}
+		ctx = context.WithValue(ctx, retryHintKey{}, hint)
 
-		cancel() // Always cancel context
+		err := c.ollamaClient.Chat(ctx, c.apiRequest(request, false), handler)
 
 		if err != nil {
-			lastErr = c.classifyError(err, attempt, "chat")
-			if attempt < retryConfig.MaxRetries && simpleai.IsRetryable(lastErr) {
-				continue // Retry
-			}
-			break // No more retries or non-retryable error
+			return c.classifyError(err, attempt, "chat").WithRetryAfter(hint.after)
 		}
 
-		// Handle structured data parsing after complete response
-		if targetType != nil {
-			// Try to extract JSON from the response
-			jsonStr := extractJSON(finalResponse)
-			if jsonStr == "" {
-				// Enhanced error reporting for JSON extraction failures
-				responsePreview := finalResponse
-				if len(responsePreview) > 200 {
-					responsePreview = responsePreview[:200] + "..."
-				}
-				lastErr = simpleai.NewLLMError(simpleai.ErrJSONParseFailed,
-					"no valid JSON found in response", "chat", true, attempt,
-					fmt.Errorf("extractJSON failed - response preview: %s", responsePreview))
-				if attempt < retryConfig.MaxRetries {
-					continue // Retry for JSON parsing issues
-				}
-				break
-			}
+		if finalResponse == "" {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from Ollama",
+				"chat", true, attempt, nil).WithProvider("ollama", c.model.Name)
+		}
 
-			if err := json.Unmarshal([]byte(jsonStr), targetType); err != nil {
-				// Enhanced error reporting for JSON unmarshaling failures
-				jsonPreview := jsonStr
-				if len(jsonPreview) > 200 {
-					jsonPreview = jsonPreview[:200] + "..."
-				}
-				lastErr = simpleai.NewLLMError(simpleai.ErrJSONParseFailed,
-					"failed to parse JSON response", "chat", true, attempt,
-					fmt.Errorf("unmarshal error: %v - extracted JSON preview: %s", err, jsonPreview))
-				if attempt < retryConfig.MaxRetries {
-					continue // Retry for JSON parsing issues
-				}
-				break
-			}
-			structuredData = targetType
+		// Handle structured data parsing after complete response
+		structuredData, err := simpleai.ParseStructuredOutput(finalResponse, targetType, request.Extractor, attempt)
+		if err != nil {
+			return err
 		}
 
-		// Success - return the response
-		return simpleai.ChatResponse{Message: finalResponse, Data: structuredData}, nil
+		response = simpleai.ChatResponse{Message: finalResponse, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		// All retries exhausted or non-retryable error
+		return simpleai.ChatResponse{}, err
 	}
 
-	// All retries exhausted
-	return simpleai.ChatResponse{}, lastErr
+	return response, nil
 }
 
-// classifyError classifies errors and determines if they are retryable
-func (c *Client) classifyError(err error, attempt int, operation string) *simpleai.LLMError {
-	errStr := err.Error()
-
-	// Check for specific error types
-	if strings.Contains(errStr, "connection refused") ||
-		strings.Contains(errStr, "no such host") ||
-		strings.Contains(errStr, "network is unreachable") {
-		return simpleai.NewLLMError(simpleai.ErrConnectionFailed,
-			"connection to Ollama service failed", operation, true, attempt, err)
-	}
-
-	if strings.Contains(errStr, "timeout") ||
-		strings.Contains(errStr, "context deadline exceeded") {
-		return simpleai.NewLLMError(simpleai.ErrTimeout,
-			"request timed out", operation, true, attempt, err)
-	}
-
-	if strings.Contains(errStr, "model not found") ||
-		strings.Contains(errStr, "model is not available") {
-		return simpleai.NewLLMError(simpleai.ErrModelNotAvailable,
-			"requested model not available", operation, false, attempt, err)
-	}
-
-	// Default to retryable operation failure
-	return simpleai.NewLLMError(simpleai.ErrOperationFailed,
-		"operation failed", operation, true, attempt, err)
+// ChatStream streams a chat request, calling handler with each chunk of the response
+func (c *Client) ChatStream(ctx context.Context, request simpleai.ChatRequest, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	return c.ChatStreamWithRetry(ctx, request, simpleai.DefaultRetryConfig(), handler)
 }
 
-// pow calculates power for exponential backoff
-func pow(base float64, exp int) float64 {
-	result := 1.0
-	for i := 0; i < exp; i++ {
-		result *= base
-	}
-	return result
-}
+// ChatStreamWithRetry streams a chat request with retry logic. Attempts are only retried
+// until the first chunk reaches handler, so a response is never delivered twice.
+func (c *Client) ChatStreamWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
 
-// extractJSON attempts to extract valid JSON from a string that may contain extra text
-func extractJSON(response string) string {
-	// Strategy 1: Try the response as-is after basic cleanup
-	cleaned := strings.TrimSpace(response)
-	if isValidJSON(cleaned) {
-		return cleaned
-	}
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		text := ""
+		started := false
+		var usage *simpleai.Usage
 
-	// Strategy 2: Try repairing common JSON issues (e.g., unescaped newlines)
-	if repaired := repairCommonJSONIssues(cleaned); isValidJSON(repaired) {
-		return repaired
-	}
+		ctx, cancel := context.WithTimeout(ctx, c.timeout)
+		defer cancel()
 
-	// Strategy 3: Check for markdown code blocks (```json ... ```)
-	if strings.Contains(response, "```json") {
-		if candidate := extractFromMarkdown(response, "```json"); candidate != "" {
-			return candidate
-		}
-	}
+		hint := &retryHint{}
+		ctx = context.WithValue(ctx, retryHintKey{}, hint)
 
-	// Strategy 4: Check for generic code blocks (``` ... ```)
-	if strings.Contains(response, "```") {
-		if candidate := extractFromMarkdown(response, "```"); candidate != "" {
-			return candidate
+		var handlerErr error
+		err := c.ollamaClient.Chat(ctx, c.apiRequest(request, true), func(resp api.ChatResponse) error {
+			if resp.Done {
+				usage = usageFromMetrics(resp.Metrics)
+			}
+			// Nothing is delivered until there is text, so empty responses can be retried
+			if text == "" && resp.Message.Content == "" {
+				return nil
+			}
+			text += resp.Message.Content
+			started = true
+			handlerErr = handler(simpleai.StreamChunk{Delta: resp.Message.Content, Text: text, Done: resp.Done})
+			return handlerErr
+		})
+
+		if handlerErr != nil {
+			// The caller stopped the stream; keep its error as the cause
+			return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+				"stream handler failed", "chat_stream", false, attempt, handlerErr)
 		}
-	}
-
-	// Strategy 5: Use brace counting for complex responses
-	if candidate := extractWithBraceCounting(response); candidate != "" {
-		return candidate
-	}
-
-	// Strategy 6: Try to find JSON-like patterns with more aggressive cleanup
-	if candidate := extractWithAggressiveCleanup(response); candidate != "" {
-		return candidate
-	}
-
-	return ""
-}
-
-// repairCommonJSONIssues fixes common JSON formatting issues from LLM responses
-func repairCommonJSONIssues(jsonStr string) string {
-	// Fix unescaped newlines in JSON strings
-	// This is the most common issue where LLMs put literal newlines in JSON strings
-	repaired := fixUnescapedNewlines(jsonStr)
-
-	// Fix unescaped quotes (though this is trickier and less common)
-	// We'll be conservative here to avoid breaking valid JSON
-
-	return repaired
-}
-
-// fixUnescapedNewlines fixes literal newlines in JSON string values
-func fixUnescapedNewlines(jsonStr string) string {
-	var result strings.Builder
-	inString := false
-	escaped := false
-
-	for _, char := range jsonStr {
-		switch {
-		case char == '\\' && !escaped:
-			escaped = true
-			result.WriteRune(char)
-		case char == '"' && !escaped:
-			inString = !inString
-			result.WriteRune(char)
-		case char == '\n' && inString && !escaped:
-			// Replace unescaped newline in string with escaped version
-			result.WriteString("\\n")
-		case char == '\r' && inString && !escaped:
-			// Replace unescaped carriage return in string with escaped version
-			result.WriteString("\\r")
-		case char == '\t' && inString && !escaped:
-			// Replace unescaped tab in string with escaped version
-			result.WriteString("\\t")
-		default:
-			result.WriteRune(char)
+		if err != nil {
+			classified := c.classifyError(err, attempt, "chat_stream").WithRetryAfter(hint.after)
+			if started {
+				return simpleai.StopRetrying(classified)
+			}
+			return classified
 		}
-
-		if escaped && char != '\\' {
-			escaped = false
+		if text == "" {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from Ollama",
+				"chat_stream", true, attempt, nil).WithProvider("ollama", c.model.Name)
 		}
-	}
 
-	return result.String()
-}
-
-// isValidJSON checks if a string is valid JSON using Go's standard library
-func isValidJSON(s string) bool {
-	var temp interface{}
-	return json.Unmarshal([]byte(s), &temp) == nil
-}
+		structuredData, err := simpleai.ParseStructuredOutput(text, request.T, request.Extractor, attempt)
+		if err != nil {
+			// The response has already been streamed, so asking again would repeat it
+			return simpleai.StopRetrying(err)
+		}
 
-// extractFromMarkdown extracts JSON from markdown code blocks
-func extractFromMarkdown(response, marker string) string {
-	start := strings.Index(response, marker)
-	if start == -1 {
-		return ""
+		response = simpleai.ChatResponse{Message: text, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
 	}
 
-	// Move past the marker
-	content := response[start+len(marker):]
+	return response, nil
+}
 
-	// Find the closing ```
-	end := strings.Index(content, "```")
-	if end == -1 {
-		return ""
+// ListModels returns the models installed on the Ollama server, classifying errors as Chat
+// does
+func (c *Client) ListModels(ctx context.Context) ([]simpleai.Model, error) {
+	response, err := c.ollamaClient.List(ctx)
+	if err != nil {
+		return nil, c.classifyError(err, 0, "list_models")
 	}
 
-	candidate := strings.TrimSpace(content[:end])
-	if isValidJSON(candidate) {
-		return candidate
+	models := make([]simpleai.Model, len(response.Models))
+	for i, model := range response.Models {
+		models[i] = simpleai.Model{Name: model.Name}
 	}
-	return ""
+	return models, nil
 }
 
-// extractWithBraceCounting uses brace counting to find JSON boundaries
-func extractWithBraceCounting(response string) string {
-	return extractJSONBoundaries(response, '{', '}')
-}
-
-// extractJSONBoundaries finds JSON object/array boundaries using character counting
-func extractJSONBoundaries(response string, openChar, closeChar rune) string {
-	start := -1
-	count := 0
-	inString := false
-	escaped := false
-
-	for i, char := range response {
-		// Handle string escaping to avoid counting braces inside strings
-		if char == '\\' && !escaped {
-			escaped = true
-			continue
-		}
-
-		if char == '"' && !escaped {
-			inString = !inString
-		}
-
-		escaped = false
-
-		// Only count braces outside of strings
-		if !inString {
-			if char == openChar {
-				if start == -1 {
-					start = i
-				}
-				count++
-			} else if char == closeChar {
-				count--
-				if count == 0 && start != -1 {
-					candidate := response[start : i+1]
-					if isValidJSON(candidate) {
-						return candidate
-					}
-					// Reset for next potential JSON object
-					start = -1
-					count = 0
-				}
-			}
-		}
+// usageFromMetrics converts the token counts Ollama reports with its final chunk
+func usageFromMetrics(metrics api.Metrics) *simpleai.Usage {
+	if metrics.PromptEvalCount == 0 && metrics.EvalCount == 0 {
+		return nil
+	}
+	return &simpleai.Usage{
+		PromptTokens:     metrics.PromptEvalCount,
+		CompletionTokens: metrics.EvalCount,
+		TotalTokens:      metrics.PromptEvalCount + metrics.EvalCount,
 	}
-
-	return ""
 }
 
-// extractWithAggressiveCleanup tries various cleanup strategies for malformed responses
-func extractWithAggressiveCleanup(response string) string {
-	// Remove common prefixes that LLMs sometimes add
-	prefixes := []string{"```json", "```", "Here's the JSON:", "JSON:", "{", "["}
-	suffixes := []string{"```", "}", "]"}
-
-	cleaned := response
-
-	// Remove prefixes
-	for _, prefix := range prefixes {
-		if strings.HasPrefix(cleaned, prefix) {
-			cleaned = strings.TrimSpace(cleaned[len(prefix):])
-			break
-		}
+// apiRequest converts a chat request to the Ollama API format
+func (c *Client) apiRequest(request simpleai.ChatRequest, stream bool) *api.ChatRequest {
+	apiRequest := &api.ChatRequest{
+		Model:    c.model.Name,
+		Messages: ConvertMessages(PrependSystemPrompt(request.Messages, request.SystemPrompt)),
+		Stream:   &stream,
+		Think: &api.ThinkValue{
+			Value: false,
+		},
+	}
+	if request.Temperature != nil {
+		apiRequest.Options = map[string]any{"temperature": *request.Temperature}
 	}
+	return apiRequest
+}
 
-	// Remove suffixes
-	for _, suffix := range suffixes {
-		if strings.HasSuffix(cleaned, suffix) && suffix != "}" && suffix != "]" {
-			cleaned = strings.TrimSpace(cleaned[:len(cleaned)-len(suffix)])
-			break
+// classifyError classifies errors and determines if they are retryable
+func (c *Client) classifyError(err error, attempt int, operation string) *simpleai.LLMError {
+	// Errors reported by the Ollama server carry the HTTP status code
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		message := statusErr.ErrorMessage
+		if message == "" {
+			message = statusErr.Status
 		}
+		return simpleai.NewStatusError(statusErr.StatusCode,
+			fmt.Sprintf("Ollama API error: %s", message), operation, attempt, err).
+			WithProvider("ollama", c.model.Name)
 	}
 
-	// Try to find JSON boundaries again after cleanup
-	if isValidJSON(cleaned) {
-		return cleaned
+	// Errors from reaching the server at all
+	if llmErr, ok := simpleai.NewTransportError(err, "Ollama service", operation, attempt); ok {
+		return llmErr.WithProvider("ollama", c.model.Name)
 	}
 
-	// Try brace counting on cleaned content
-	return extractJSONBoundaries(cleaned, '{', '}')
+	// Default to retryable operation failure
+	return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+		"operation failed", operation, true, attempt, err).
+		WithProvider("ollama", c.model.Name)
 }
-
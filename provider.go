@@ -1,5 +1,10 @@
 package simpleai
 
+import (
+	"context"
+	"reflect"
+)
+
 // Provider defines the interface that all LLM providers must implement
 type Provider interface {
 	// Chat sends a chat request and returns a response
@@ -18,24 +23,64 @@ type Provider interface {
 	SupportedFeatures() ProviderFeatures
 }
 
+// ContextProvider is implemented by providers whose chat calls can be cancelled
+type ContextProvider interface {
+	Provider
+
+	// ChatContext sends a chat request, giving up when ctx is done
+	ChatContext(ctx context.Context, request ChatRequest) (ChatResponse, error)
+}
+
+// ChatContext sends a chat request through provider, passing ctx along when the provider
+// supports it. Other providers are only checked for cancellation before the call.
+func ChatContext(ctx context.Context, provider Provider, request ChatRequest) (ChatResponse, error) {
+	if p, ok := provider.(ContextProvider); ok {
+		return p.ChatContext(ctx, request)
+	}
+	if err := ctx.Err(); err != nil {
+		errType, retryable, _ := ClassifyTransportError(err)
+		return ChatResponse{}, NewLLMError(errType, "request cancelled", "chat", retryable, 0, err)
+	}
+	return provider.Chat(request)
+}
+
 // ProviderConstructor is a function that creates a new provider instance
 type ProviderConstructor func(config map[string]interface{}) (Provider, error)
 
 // ProviderRegistry holds information about registered providers
 type ProviderRegistry struct {
 	providers map[string]ProviderConstructor
+	configs   map[string]reflect.Type // Config struct types of providers that declare one
 }
 
 // NewProviderRegistry creates a new provider registry
 func NewProviderRegistry() *ProviderRegistry {
 	return &ProviderRegistry{
 		providers: make(map[string]ProviderConstructor),
+		configs:   make(map[string]reflect.Type),
 	}
 }
 
 // Register adds a new provider constructor to the registry
 func (r *ProviderRegistry) Register(name string, constructor ProviderConstructor) {
 	r.providers[name] = constructor
+	delete(r.configs, name)
+}
+
+// RegisterWithConfig adds a provider constructor along with the config struct its settings
+// decode into, such as openai.Config{}
+func (r *ProviderRegistry) RegisterWithConfig(name string, constructor ProviderConstructor, config interface{}) {
+	r.providers[name] = constructor
+	t := reflect.TypeOf(config)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	r.configs[name] = t
+}
+
+// ConfigType returns the config struct type a provider was registered with, or nil
+func (r *ProviderRegistry) ConfigType(name string) reflect.Type {
+	return r.configs[name]
 }
 
 // Create creates a new provider instance using the registered constructor
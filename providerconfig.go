@@ -0,0 +1,264 @@
+package simpleai
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"simpleai/schema"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProviderSettings are the settings every provider receives from ProviderConfig. Provider
+// config structs embed it and add their own settings, which come from ExtraSettings.
+type ProviderSettings struct {
+	Host          string `json:"host,omitempty" description:"Base URL of the provider's API"`
+	APIKey        string `json:"api_key,omitempty" description:"API key, or a secret reference such as env:NAME or file:PATH"`
+	DefaultModel  string `json:"default_model,omitempty" description:"Model used when a request names none"`
+	Timeout       int    `json:"timeout,omitempty" description:"Request timeout in seconds"`
+	RetryAttempts *int   `json:"retry_attempts,omitempty" description:"Maximum retry attempts"`
+	RateLimit     int    `json:"rate_limit,omitempty" description:"Requests per minute, enforced by callers such as the gateway"`
+}
+
+// remainTag marks a map[string]interface{} field that collects settings the struct does not
+// declare, for providers that pass them on, such as plugins
+const remainTag = "remain"
+
+// DecodeProviderConfig decodes a provider's config map into target, a pointer to a struct
+// whose fields are named by their json tags. Values are coerced to the field types:
+// numbers and booleans may be given as strings, since ExtraSettings only holds strings,
+// whole floats decoded from JSON are accepted as integers, and a string is split on spaces
+// where a list is expected. Unknown keys and values that cannot be coerced return an
+// ErrInvalidConfig LLMError wrapping a *ValidationError that names the setting.
+func DecodeProviderConfig(config map[string]interface{}, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return NewLLMError(ErrInvalidConfig,
+			fmt.Sprintf("config target must be a pointer to a struct, got %T", target),
+			"provider_creation", false, 0, nil)
+	}
+	fields, remain := settingFields(v.Elem())
+
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := config[key]
+		field, ok := fields[key]
+		if !ok {
+			if remain.IsValid() {
+				if remain.IsNil() {
+					remain.Set(reflect.MakeMap(remain.Type()))
+				}
+				remain.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(&value).Elem())
+				continue
+			}
+			return invalidSetting(key, value, "unknown setting")
+		}
+		if value == nil {
+			continue
+		}
+		if problem := coerce(value, field); problem != "" {
+			return invalidSetting(key, value, problem)
+		}
+	}
+	return nil
+}
+
+// invalidSetting returns the error for a provider setting
+func invalidSetting(key string, value interface{}, problem string) error {
+	return NewLLMError(ErrInvalidConfig, fmt.Sprintf("invalid setting %s: %s", key, problem),
+		"provider_creation", false, 0, NewValidationError(key, value, problem))
+}
+
+// settingFields maps the json names of a struct's fields, including those of embedded
+// structs, to the fields. It also returns the field tagged to collect unknown settings.
+func settingFields(v reflect.Value) (map[string]reflect.Value, reflect.Value) {
+	fields := make(map[string]reflect.Value)
+	var remain reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded, embeddedRemain := settingFields(v.Field(i))
+			for key, value := range embedded {
+				fields[key] = value
+			}
+			if embeddedRemain.IsValid() {
+				remain = embeddedRemain
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if field.Tag.Get("config") == remainTag {
+			remain = v.Field(i)
+			continue
+		}
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		fields[name] = v.Field(i)
+	}
+	return fields, remain
+}
+
+// coerce stores value in field, converting it to the field's type. It returns what was
+// expected when the value does not fit.
+func coerce(value interface{}, field reflect.Value) string {
+	given := reflect.ValueOf(value)
+	if given.Type().AssignableTo(field.Type()) {
+		field.Set(given)
+		return ""
+	}
+
+	switch field.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(field.Type().Elem())
+		if problem := coerce(value, elem.Elem()); problem != "" {
+			return problem
+		}
+		field.Set(elem)
+		return ""
+
+	case reflect.String:
+		switch v := value.(type) {
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+			field.SetString(fmt.Sprint(v))
+			return ""
+		}
+		return "expected a string"
+
+	case reflect.Bool:
+		if s, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				field.SetBool(b)
+				return ""
+			}
+		}
+		return "expected true or false"
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := wholeNumber(value); ok && !field.OverflowInt(n) {
+			field.SetInt(n)
+			return ""
+		}
+		return "expected a whole number"
+
+	case reflect.Float32, reflect.Float64:
+		if f, ok := number(value); ok {
+			field.SetFloat(f)
+			return ""
+		}
+		return "expected a number"
+
+	case reflect.Slice:
+		var items []interface{}
+		switch v := value.(type) {
+		case string:
+			for _, item := range strings.Fields(v) {
+				items = append(items, item)
+			}
+		default:
+			if given.Kind() != reflect.Slice && given.Kind() != reflect.Array {
+				return "expected a list"
+			}
+			for i := 0; i < given.Len(); i++ {
+				items = append(items, given.Index(i).Interface())
+			}
+		}
+		list := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if item == nil {
+				continue
+			}
+			if problem := coerce(item, list.Index(i)); problem != "" {
+				return fmt.Sprintf("item %d: %s", i, problem)
+			}
+		}
+		field.Set(list)
+		return ""
+
+	case reflect.Map:
+		if given.Kind() != reflect.Map || field.Type().Key().Kind() != reflect.String {
+			return "expected a table"
+		}
+		table := reflect.MakeMapWithSize(field.Type(), given.Len())
+		iter := given.MapRange()
+		for iter.Next() {
+			item := reflect.New(field.Type().Elem()).Elem()
+			if entry := iter.Value().Interface(); entry != nil {
+				if problem := coerce(entry, item); problem != "" {
+					return fmt.Sprintf("%v: %s", iter.Key(), problem)
+				}
+			}
+			table.SetMapIndex(reflect.ValueOf(fmt.Sprint(iter.Key().Interface())).Convert(field.Type().Key()), item)
+		}
+		field.Set(table)
+		return ""
+
+	case reflect.Interface:
+		return fmt.Sprintf("expected a %s", field.Type())
+	}
+	return fmt.Sprintf("unsupported setting type %s", field.Type())
+}
+
+// wholeNumber converts integers, whole floats and integer strings
+func wholeNumber(value interface{}) (int64, bool) {
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() <= math.MaxInt64 {
+			return int64(v.Uint()), true
+		}
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			return int64(f), true
+		}
+	case reflect.String:
+		if n, err := strconv.ParseInt(strings.TrimSpace(v.String()), 10, 64); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// number converts integers, floats and numeric strings
+func number(value interface{}) (float64, bool) {
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v.String()), 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// providerConfigSchema returns the JSON Schema of a provider config struct
+func providerConfigSchema(config interface{}) schema.Schema {
+	s := schema.For(config)
+	t := reflect.TypeOf(config)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if _, remain := settingFields(reflect.New(t).Elem()); remain.IsValid() {
+		s["additionalProperties"] = true
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+package simpleai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testProviderConfig is a provider config struct with one setting of each kind
+type testProviderConfig struct {
+	ProviderSettings
+	MaxTokens   int      `json:"max_tokens,omitempty" description:"Maximum tokens per reply"`
+	Temperature float64  `json:"temperature,omitempty"`
+	Stream      bool     `json:"stream,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+func TestDecodeProviderConfig(t *testing.T) {
+	var settings testProviderConfig
+	err := DecodeProviderConfig(map[string]interface{}{
+		"host":           "http://localhost:8080",
+		"timeout":        float64(30), // As decoded from JSON
+		"retry_attempts": 0,
+		"max_tokens":     "512", // From ExtraSettings
+		"temperature":    "0.2",
+		"stream":         "true",
+		"stop":           "END STOP",
+		"api_key":        nil,
+	}, &settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Host != "http://localhost:8080" || settings.Timeout != 30 || settings.MaxTokens != 512 ||
+		settings.Temperature != 0.2 || !settings.Stream || len(settings.Stop) != 2 {
+		t.Errorf("Unexpected settings %+v", settings)
+	}
+	if settings.RetryAttempts == nil || *settings.RetryAttempts != 0 {
+		t.Error("Expected retry_attempts to be set to 0")
+	}
+
+	for _, tc := range []struct {
+		config  map[string]interface{}
+		field   string
+		problem string
+	}{
+		{map[string]interface{}{"max_tokenz": "512"}, "max_tokenz", "unknown setting"},
+		{map[string]interface{}{"timeout": "soon"}, "timeout", "expected a whole number"},
+		{map[string]interface{}{"timeout": 1.5}, "timeout", "expected a whole number"},
+		{map[string]interface{}{"stream": "maybe"}, "stream", "expected true or false"},
+		{map[string]interface{}{"host": []string{"a"}}, "host", "expected a string"},
+	} {
+		err := DecodeProviderConfig(tc.config, &testProviderConfig{})
+		var validationErr *ValidationError
+		if !errors.Is(err, ErrInvalidConfig) || !errors.As(err, &validationErr) ||
+			validationErr.Field != tc.field || validationErr.Message != tc.problem {
+			t.Errorf("%v: expected %s to be %q, got %v", tc.config, tc.field, tc.problem, err)
+		}
+	}
+}
+
+func TestValidateConfigChecksProviderSettings(t *testing.T) {
+	factory := NewLLMFactory()
+	factory.RegisterProviderWithConfig("typed", func(config map[string]interface{}) (Provider, error) {
+		return &stubProvider{name: "typed"}, nil
+	}, testProviderConfig{})
+	factory.RegisterProvider("untyped", func(config map[string]interface{}) (Provider, error) {
+		return &stubProvider{name: "untyped"}, nil
+	})
+
+	config := FactoryConfig{
+		DefaultProvider: "typed",
+		Providers: map[string]ProviderConfig{
+			"typed":   {DefaultModel: "m", Timeout: 30, ExtraSettings: map[string]string{"max_tokens": "512"}},
+			"untyped": {DefaultModel: "m", Timeout: 30, ExtraSettings: map[string]string{"anything": "goes"}},
+		},
+	}
+	if err := factory.ValidateConfig(config); err != nil {
+		t.Fatalf("Expected the config to be valid, got %v", err)
+	}
+
+	config.Providers["typed"] = ProviderConfig{DefaultModel: "m", Timeout: 30, ExtraSettings: map[string]string{"max_token": "512"}}
+	err := factory.ValidateConfig(config)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "providers.typed.extra_settings.max_token" {
+		t.Errorf("Expected the misspelled setting to be reported, got %v", err)
+	}
+	if _, err := factory.ReloadConfig(config); err == nil {
+		t.Error("Expected ReloadConfig to reject the misspelled setting")
+	}
+
+	s, err := factory.ConfigSchema("typed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties := s["properties"].(map[string]any)
+	if property, ok := properties["max_tokens"].(map[string]any); !ok || property["type"] != "integer" ||
+		!strings.Contains(property["description"].(string), "Maximum tokens") {
+		t.Errorf("Unexpected max_tokens schema %v", properties["max_tokens"])
+	}
+	if _, ok := properties["timeout"]; !ok {
+		t.Error("Expected the common settings in the schema")
+	}
+	if _, err := factory.ConfigSchema("untyped"); err == nil {
+		t.Error("Expected no schema for a provider without a config struct")
+	}
+}
@@ -0,0 +1,31 @@
+// Package all registers the built-in providers, for programs that let the configuration
+// choose among them.
+//
+//	factory := simpleai.NewLLMFactory()
+//	all.Register(factory)
+package all
+
+import (
+	"simpleai"
+	"simpleai/providers/anthropic"
+	"simpleai/providers/azure"
+	"simpleai/providers/bedrock"
+	"simpleai/providers/exec"
+	"simpleai/providers/google"
+	"simpleai/providers/llamacpp"
+	"simpleai/providers/ollama"
+	"simpleai/providers/openai"
+)
+
+// Register registers every built-in provider with factory, along with its config struct
+func Register(factory *simpleai.LLMFactory) {
+	factory.RegisterProviderWithConfig("ollama", ollama.NewProvider, ollama.Config{})
+	factory.RegisterProviderWithConfig("ollama-pool", ollama.NewPool, ollama.PoolConfig{})
+	factory.RegisterProviderWithConfig("openai", openai.NewProvider, openai.Config{})
+	factory.RegisterProviderWithConfig("anthropic", anthropic.NewProvider, anthropic.Config{})
+	factory.RegisterProviderWithConfig("azure", azure.NewProvider, azure.Config{})
+	factory.RegisterProviderWithConfig("google", google.NewProvider, google.Config{})
+	factory.RegisterProviderWithConfig("llamacpp", llamacpp.NewProvider, llamacpp.Config{})
+	factory.RegisterProviderWithConfig("bedrock", bedrock.NewProvider, bedrock.Config{})
+	factory.RegisterProviderWithConfig("exec", exec.NewProvider, exec.Config{})
+}
@@ -0,0 +1,216 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"simpleai"
+	"strings"
+)
+
+// messageRequest is the body of POST /v1/messages
+type messageRequest struct {
+	Model      string      `json:"model"`
+	MaxTokens  int         `json:"max_tokens"`
+	System     string      `json:"system,omitempty"`
+	Messages   []message   `json:"messages"`
+	Stream     bool        `json:"stream,omitempty"`
+	Tools      []tool      `json:"tools,omitempty"`
+	ToolChoice *toolChoice `json:"tool_choice,omitempty"`
+
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// contentBlock is a text, tool_use or tool_result block
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type toolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// messageResponse is a response, or the message in a stream's message_start event
+type messageResponse struct {
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      *usage         `json:"usage"`
+}
+
+func (m messageResponse) text() string {
+	var text strings.Builder
+	for _, block := range m.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String()
+}
+
+func (m messageResponse) toolCalls() []simpleai.ToolCall {
+	var calls []simpleai.ToolCall
+	for _, block := range m.Content {
+		if block.Type == "tool_use" {
+			calls = append(calls, simpleai.ToolCall{ID: block.ID, Name: block.Name, Arguments: string(block.Input)})
+		}
+	}
+	return calls
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u *usage) convert() *simpleai.Usage {
+	if u == nil {
+		return nil
+	}
+	return &simpleai.Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+type errorBody struct {
+	Error *errorDetails `json:"error"`
+}
+
+type errorDetails struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type modelList struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// buildRequest converts a chat request to the API's request body
+func (p *Provider) buildRequest(request simpleai.ChatRequest, stream bool) messageRequest {
+	system, messages := convertMessages(request)
+	return messageRequest{
+		Model:      p.defaultModel,
+		MaxTokens:  p.maxTokens,
+		System:     system,
+		Messages:   messages,
+		Stream:     stream,
+		Tools:      convertTools(request.Tools),
+		ToolChoice: convertToolChoice(request.ToolChoice),
+
+		Temperature: request.Temperature,
+	}
+}
+
+// conversationStart is the text of the user turn put before a conversation the assistant
+// opened
+const conversationStart = "(start of conversation)"
+
+// convertMessages splits a chat request into the system field and alternating turns. The
+// system prompt and system messages are joined into the system field, tool results are sent
+// as user turns, and consecutive turns with the same role are merged. The API requires the
+// first turn to be the user's, so a conversation opened by the assistant gets a placeholder
+// user turn before it.
+func convertMessages(request simpleai.ChatRequest) (string, []message) {
+	var instructions []string
+	if request.SystemPrompt.Content != "" {
+		instructions = append(instructions, request.SystemPrompt.Content)
+	}
+
+	var messages []message
+	for _, msg := range request.Messages {
+		var role string
+		var blocks []contentBlock
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				instructions = append(instructions, msg.Content)
+			}
+			continue
+		case "tool":
+			role = "user"
+			blocks = []contentBlock{{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}}
+		case "assistant":
+			role = "assistant"
+			if msg.Content != "" {
+				blocks = append(blocks, contentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, contentBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: input})
+			}
+		default:
+			role = "user"
+			if msg.Content != "" {
+				blocks = []contentBlock{{Type: "text", Text: msg.Content}}
+			}
+		}
+
+		// The API rejects empty turns and empty text blocks
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, blocks...)
+			continue
+		}
+		messages = append(messages, message{Role: role, Content: blocks})
+	}
+	if len(messages) > 0 && messages[0].Role == "assistant" {
+		start := message{Role: "user", Content: []contentBlock{{Type: "text", Text: conversationStart}}}
+		messages = append([]message{start}, messages...)
+	}
+	return strings.Join(instructions, "\n\n"), messages
+}
+
+// convertTools converts tools, giving tools without parameters an empty object schema
+func convertTools(tools []simpleai.Tool) []tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	converted := make([]tool, len(tools))
+	for i, t := range tools {
+		inputSchema := t.Parameters
+		if inputSchema == nil {
+			inputSchema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		converted[i] = tool{Name: t.Name, Description: t.Description, InputSchema: inputSchema}
+	}
+	return converted
+}
+
+// convertToolChoice maps the keyword choices to their Anthropic equivalents and anything
+// else to a choice of the named tool
+func convertToolChoice(choice string) *toolChoice {
+	switch choice {
+	case "":
+		return nil
+	case "auto", "none":
+		return &toolChoice{Type: choice}
+	case "required":
+		return &toolChoice{Type: "any"}
+	default:
+		return &toolChoice{Type: "tool", Name: choice}
+	}
+}
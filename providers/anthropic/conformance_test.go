@@ -0,0 +1,99 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"simpleai"
+	"simpleai/providertest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// anthropicProtocol speaks the Messages API protocol for the conformance suite
+type anthropicProtocol struct{}
+
+func (anthropicProtocol) Decode(r *http.Request) (providertest.Received, error) {
+	if r.URL.Path != "/v1/messages" {
+		return providertest.Received{}, errors.New("unknown endpoint " + r.URL.Path)
+	}
+	if r.Header.Get("X-Api-Key") == "" || r.Header.Get("Anthropic-Version") == "" {
+		return providertest.Received{}, errors.New("missing API headers")
+	}
+
+	var request messageRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return providertest.Received{}, err
+	}
+
+	received := providertest.Received{System: request.System, Model: request.Model, Stream: request.Stream}
+	for _, message := range request.Messages {
+		received.Messages = append(received.Messages, simpleai.Message{
+			Role:    message.Role,
+			Content: messageResponse{Content: message.Content}.text(),
+		})
+	}
+	return received, nil
+}
+
+func (anthropicProtocol) WriteText(w http.ResponseWriter, received providertest.Received, chunks []string) {
+	if !received.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		text := ""
+		for _, chunk := range chunks {
+			text += chunk
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"type":        "message",
+			"role":        "assistant",
+			"content":     []map[string]any{{"type": "text", "text": text}},
+			"stop_reason": "end_turn",
+			"usage":       map[string]int{"input_tokens": 5, "output_tokens": len(chunks)},
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	write := func(event string, value map[string]any) {
+		value["type"] = event
+		data, _ := json.Marshal(value)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		w.(http.Flusher).Flush()
+	}
+	write("message_start", map[string]any{"message": map[string]any{"content": []any{}, "usage": map[string]int{"input_tokens": 5, "output_tokens": 1}}})
+	write("content_block_start", map[string]any{"index": 0, "content_block": map[string]any{"type": "text", "text": ""}})
+	write("ping", map[string]any{})
+	for _, chunk := range chunks {
+		write("content_block_delta", map[string]any{"index": 0, "delta": map[string]any{"type": "text_delta", "text": chunk}})
+	}
+	write("content_block_stop", map[string]any{"index": 0})
+	write("message_delta", map[string]any{"delta": map[string]any{"stop_reason": "end_turn"}, "usage": map[string]int{"output_tokens": len(chunks)}})
+	write("message_stop", map[string]any{})
+}
+
+func (anthropicProtocol) WriteError(w http.ResponseWriter, received providertest.Received, status int, message string, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"type":  "error",
+		"error": map[string]any{"type": "api_error", "message": message},
+	})
+}
+
+func TestConformance(t *testing.T) {
+	providertest.Run(t, providertest.Harness{
+		Protocol: anthropicProtocol{},
+		NewProvider: func(t *testing.T, baseURL string) simpleai.Provider {
+			provider, err := NewProvider(map[string]interface{}{"api_key": "test-key", "host": baseURL})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			return provider
+		},
+	})
+}
@@ -0,0 +1,261 @@
+// Package anthropic implements simpleai.Provider against the Anthropic Messages API.
+//
+// The system prompt and any system messages are sent as the top-level system field.
+// Consecutive turns with the same role are merged, since the API requires user and
+// assistant turns to alternate. Structured output relies on prompting and JSON extraction.
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"simpleai"
+	"strings"
+	"time"
+)
+
+// apiVersion is the Messages API version the provider speaks
+const apiVersion = "2023-06-01"
+
+// statusOverloaded is the non-standard status Anthropic returns when its servers are
+// overloaded
+const statusOverloaded = 529
+
+// Provider implements the simpleai.Provider interface for Anthropic
+type Provider struct {
+	api          *simpleai.APIClient
+	apiKey       string
+	defaultModel string
+	maxTokens    int
+	timeout      int
+	retryConfig  *simpleai.RetryConfig
+}
+
+// Config holds the settings of an Anthropic provider
+type Config struct {
+	simpleai.ProviderSettings
+	MaxTokens int `json:"max_tokens,omitempty" description:"Maximum tokens per reply, required by the API; defaults to 4096"`
+}
+
+// NewProvider creates a new Anthropic provider instance
+func NewProvider(config map[string]interface{}) (simpleai.Provider, error) {
+	var settings Config
+	if err := simpleai.DecodeProviderConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	// Extract API key (required)
+	apiKey := settings.APIKey
+	if apiKey == "" {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			"API key is required for Anthropic provider",
+			"provider_creation", false, 0, nil)
+	}
+
+	host := "https://api.anthropic.com"
+	if settings.Host != "" {
+		host = settings.Host
+	}
+
+	defaultModel := "claude-sonnet-4-5"
+	if settings.DefaultModel != "" {
+		defaultModel = settings.DefaultModel
+	}
+
+	// max_tokens is required by the API
+	maxTokens := 4096
+	if settings.MaxTokens < 0 {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			fmt.Sprintf("invalid max_tokens %d", settings.MaxTokens),
+			"provider_creation", false, 0, nil)
+	}
+	if settings.MaxTokens > 0 {
+		maxTokens = settings.MaxTokens
+	}
+
+	timeout := 60
+	if settings.Timeout > 0 {
+		timeout = settings.Timeout
+	}
+
+	retryAttempts := 3
+	if r := settings.RetryAttempts; r != nil && *r >= 0 {
+		retryAttempts = *r
+	}
+
+	// Create retry configuration
+	retryConfig := simpleai.DefaultRetryConfig()
+	retryConfig.MaxRetries = retryAttempts
+
+	provider := &Provider{
+		apiKey:       apiKey,
+		defaultModel: defaultModel,
+		maxTokens:    maxTokens,
+		timeout:      timeout,
+		retryConfig:  retryConfig,
+	}
+	provider.api = &simpleai.APIClient{
+		BaseURL:        strings.TrimSuffix(host, "/"),
+		Authorize:      provider.authorize,
+		ClassifyStatus: provider.classifyStatusError,
+		Service:        "Anthropic API",
+		Provider:       "anthropic",
+		Model:          defaultModel,
+	}
+	return provider, nil
+}
+
+// Chat sends a chat request and returns a response
+func (p *Provider) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatWithRetry(request, p.retryConfig)
+}
+
+// ChatContext sends a chat request, giving up when ctx is done
+func (p *Provider) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(ctx, request, p.retryConfig)
+}
+
+// ChatWithRetry executes a chat request with retry logic
+func (p *Provider) ChatWithRetry(request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(context.Background(), request, retryConfig)
+}
+
+// ChatContextWithRetry executes a cancellable chat request with retry logic. Responses that
+// use tools are returned without decoding structured output.
+func (p *Provider) ChatContextWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		var message messageResponse
+		if err := p.api.Do(ctx, http.MethodPost, "/v1/messages", p.buildRequest(request, false), &message, attempt, "chat"); err != nil {
+			return err
+		}
+
+		text := message.text()
+		toolCalls := message.toolCalls()
+		usage := message.Usage.convert()
+
+		if len(toolCalls) > 0 {
+			response = simpleai.ChatResponse{Message: text, ToolCalls: toolCalls, Usage: usage}
+			return nil
+		}
+		if text == "" {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from Anthropic API",
+				"chat", true, attempt, nil).WithProvider("anthropic", p.defaultModel)
+		}
+
+		structuredData, err := simpleai.ParseStructuredOutput(text, request.T, request.Extractor, attempt)
+		if err != nil {
+			return err
+		}
+
+		response = simpleai.ChatResponse{Message: text, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	return response, nil
+}
+
+// ListModels returns the models available to the API key
+func (p *Provider) ListModels() ([]simpleai.Model, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.timeout)*time.Second)
+	defer cancel()
+
+	var list modelList
+	if err := p.api.Do(ctx, http.MethodGet, "/v1/models", nil, &list, 0, "list_models"); err != nil {
+		return nil, err
+	}
+
+	models := make([]simpleai.Model, len(list.Data))
+	for i, model := range list.Data {
+		models[i] = simpleai.Model{Name: model.ID}
+	}
+	return models, nil
+}
+
+// Name returns the provider's name
+func (p *Provider) Name() string {
+	return "anthropic"
+}
+
+// IsAvailable checks if the provider is currently available/reachable
+func (p *Provider) IsAvailable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Listing models is free and needs the same credentials as chat
+	return p.api.Do(ctx, http.MethodGet, "/v1/models", nil, nil, 0, "health_check") == nil
+}
+
+// SupportedFeatures returns the capabilities supported by this provider
+func (p *Provider) SupportedFeatures() simpleai.ProviderFeatures {
+	return simpleai.ProviderFeatures{
+		StructuredOutput: true,   // Structured JSON output via prompting
+		Streaming:        true,   // Server-sent events
+		Vision:           false,  // Image inputs are not sent
+		MaxTokens:        200000, // Claude context window
+		SupportedRoles:   []string{"system", "user", "assistant", "tool"},
+		FunctionCalling:  true, // Tool use
+		Temperature:      true,
+		TopP:             true,
+	}
+}
+
+// GetDefaultModel returns the default model for this provider
+func (p *Provider) GetDefaultModel() string {
+	return p.defaultModel
+}
+
+// GetRetryConfig returns the retry configuration for this provider
+func (p *Provider) GetRetryConfig() *simpleai.RetryConfig {
+	return p.retryConfig
+}
+
+// UpdateRetryConfig allows updating the retry configuration
+func (p *Provider) UpdateRetryConfig(config *simpleai.RetryConfig) {
+	if config != nil {
+		p.retryConfig = config
+	}
+}
+
+// authorize adds the API key and version headers to a request
+func (p *Provider) authorize(req *http.Request) error {
+	req.Header.Set("X-Api-Key", p.apiKey)
+	req.Header.Set("Anthropic-Version", apiVersion)
+	return nil
+}
+
+// classifyStatusError classifies an error response by its status code, keeping the API's
+// message and retry hint. Overloaded responses are retryable rate limits.
+func (p *Provider) classifyStatusError(resp *http.Response, attempt int, operation string) *simpleai.LLMError {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	message := http.StatusText(resp.StatusCode)
+	var body errorBody
+	if json.Unmarshal(data, &body) == nil && body.Error != nil && body.Error.Message != "" {
+		message = body.Error.Message
+	} else if text := strings.TrimSpace(string(data)); text != "" {
+		message = text
+	}
+	message = fmt.Sprintf("Anthropic API error: %s", message)
+	retryAfter := simpleai.ParseRetryAfter(resp.Header.Get("Retry-After"))
+
+	if resp.StatusCode == statusOverloaded || (body.Error != nil && body.Error.Type == "overloaded_error") {
+		return simpleai.NewLLMError(simpleai.ErrRateLimitExceeded, message, operation, true, attempt, nil).
+			WithStatusCode(resp.StatusCode).
+			WithProvider("anthropic", p.defaultModel).
+			WithRetryAfter(retryAfter)
+	}
+	return simpleai.NewStatusError(resp.StatusCode, message, operation, attempt, nil).
+		WithProvider("anthropic", p.defaultModel).
+		WithRetryAfter(retryAfter)
+}
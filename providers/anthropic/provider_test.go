@@ -0,0 +1,235 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"simpleai"
+	"simpleai/providertest"
+	"testing"
+)
+
+// newTestProvider creates a provider for a server answering with handler
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+	config := map[string]interface{}{"api_key": "test-key", "retry_attempts": 0}
+	return providertest.NewProvider(t, NewProvider, config, handler).(*Provider)
+}
+
+func TestNewProviderMissingAPIKey(t *testing.T) {
+	_, err := NewProvider(map[string]interface{}{"default_model": "claude-sonnet-4-5"})
+	if !errors.Is(err, simpleai.ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestProviderConfig(t *testing.T) {
+	var body messageRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"Hi"}]}`)
+	}))
+	defer server.Close()
+
+	factory := simpleai.NewLLMFactory()
+	factory.RegisterProvider("anthropic", NewProvider)
+	if err := factory.LoadConfig(simpleai.FactoryConfig{
+		DefaultProvider: "anthropic",
+		Providers: map[string]simpleai.ProviderConfig{
+			"anthropic": {
+				Host:          server.URL,
+				APIKey:        "test-key",
+				DefaultModel:  "claude-haiku-4-5",
+				ExtraSettings: map[string]string{"max_tokens": "512"},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	provider, err := factory.CreateProviderFromConfig("anthropic")
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if _, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hello"}}}); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if body.Model != "claude-haiku-4-5" || body.MaxTokens != 512 {
+		t.Errorf("Expected the configured model and max_tokens, got %q and %d", body.Model, body.MaxTokens)
+	}
+}
+
+func TestConvertMessagesAlternates(t *testing.T) {
+	system, messages := convertMessages(simpleai.ChatRequest{
+		SystemPrompt: simpleai.SystemPrompt{Content: "Be brief."},
+		Messages: []simpleai.Message{
+			{Role: "user", Content: "One"},
+			{Role: "user", Content: "Two"},
+			{Role: "system", Content: "Use metric units."},
+			{Role: "assistant", Content: "Checking.", ToolCalls: []simpleai.ToolCall{{ID: "toolu_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`}}},
+			{Role: "tool", Content: "4C", ToolCallID: "toolu_1"},
+			{Role: "user", Content: "Thanks"},
+		},
+	})
+
+	if system != "Be brief.\n\nUse metric units." {
+		t.Errorf("Expected system prompt and messages in the system field, got %q", system)
+	}
+	roles := []string{}
+	for _, message := range messages {
+		roles = append(roles, message.Role)
+	}
+	if fmt.Sprint(roles) != "[user assistant user]" {
+		t.Fatalf("Expected alternating turns, got %v", roles)
+	}
+	if len(messages[0].Content) != 2 {
+		t.Errorf("Expected consecutive user turns to be merged, got %+v", messages[0].Content)
+	}
+	if block := messages[1].Content[1]; block.Type != "tool_use" || string(block.Input) != `{"city":"Oslo"}` {
+		t.Errorf("Expected a tool_use block, got %+v", block)
+	}
+	if block := messages[2].Content[0]; block.Type != "tool_result" || block.ToolUseID != "toolu_1" {
+		t.Errorf("Expected the tool result first in the next user turn, got %+v", block)
+	}
+}
+
+func TestConvertMessagesStartsWithUser(t *testing.T) {
+	_, messages := convertMessages(simpleai.ChatRequest{
+		Messages: []simpleai.Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "assistant", Content: "Hi! How can I help?"},
+			{Role: "user", Content: "Weather in Oslo?"},
+		},
+	})
+
+	roles := []string{}
+	for _, message := range messages {
+		roles = append(roles, message.Role)
+	}
+	if fmt.Sprint(roles) != "[user assistant user]" {
+		t.Fatalf("Expected a user turn before the assistant's greeting, got %v", roles)
+	}
+	if messages[0].Content[0].Text != conversationStart || messages[1].Content[0].Text != "Hi! How can I help?" {
+		t.Errorf("Expected the greeting to be kept after a placeholder, got %+v", messages)
+	}
+}
+
+func TestToolUse(t *testing.T) {
+	var body messageRequest
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"Let me check."},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Oslo"}}],"stop_reason":"tool_use","usage":{"input_tokens":20,"output_tokens":10}}`)
+	})
+
+	response, err := provider.Chat(simpleai.ChatRequest{
+		Messages:   []simpleai.Message{{Role: "user", Content: "Weather in Oslo?"}},
+		Tools:      []simpleai.Tool{{Name: "get_weather", Description: "Current weather"}},
+		ToolChoice: "required",
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	want := simpleai.ToolCall{ID: "toolu_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`}
+	if len(response.ToolCalls) != 1 || response.ToolCalls[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, response.ToolCalls)
+	}
+	if response.Message != "Let me check." || response.Usage.TotalTokens != 30 {
+		t.Errorf("Unexpected message or usage: %q %+v", response.Message, response.Usage)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("Expected a tool with an object input schema, got %+v", body.Tools)
+	}
+	if body.ToolChoice == nil || body.ToolChoice.Type != "any" {
+		t.Errorf("Expected tool_choice any, got %+v", body.ToolChoice)
+	}
+}
+
+func TestOverloaded(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusOverloaded)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	})
+
+	_, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hi"}}})
+	var llmErr *simpleai.LLMError
+	if !errors.As(err, &llmErr) {
+		t.Fatalf("Expected an LLMError, got %v", err)
+	}
+	if !errors.Is(err, simpleai.ErrRateLimitExceeded) || !llmErr.Retryable || llmErr.StatusCode != statusOverloaded {
+		t.Errorf("Expected a retryable rate limit error with status 529, got %+v", llmErr)
+	}
+}
+
+func TestStreamToolUse(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Oslo\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":15}}`,
+			`{"type":"message_stop"}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	})
+
+	var deltas []string
+	response, err := provider.ChatStream(context.Background(), simpleai.ChatRequest{
+		Messages: []simpleai.Message{{Role: "user", Content: "Weather in Oslo?"}},
+		Tools:    []simpleai.Tool{{Name: "get_weather"}},
+	}, func(chunk simpleai.StreamChunk) error {
+		if chunk.Delta != "" {
+			deltas = append(deltas, chunk.Delta)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+
+	want := simpleai.ToolCall{ID: "toolu_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`}
+	if len(response.ToolCalls) != 1 || response.ToolCalls[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, response.ToolCalls)
+	}
+	if len(deltas) != 1 || deltas[0] != "Checking." {
+		t.Errorf("Expected only text deltas to reach the handler, got %q", deltas)
+	}
+	if response.Usage == nil || response.Usage.PromptTokens != 12 || response.Usage.CompletionTokens != 15 {
+		t.Errorf("Unexpected usage: %+v", response.Usage)
+	}
+}
+
+func TestStreamEndedEarly(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"The answer is"}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	})
+
+	var done bool
+	_, err := provider.ChatStream(context.Background(), simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hello"}}},
+		func(chunk simpleai.StreamChunk) error {
+			done = done || chunk.Done
+			return nil
+		})
+	if !errors.Is(err, simpleai.ErrConnectionFailed) || simpleai.IsRetryable(err) {
+		t.Errorf("Expected a non-retryable ErrConnectionFailed after streamed text, got %v", err)
+	}
+	if done {
+		t.Error("Expected no chunk marked Done for a cut-off stream")
+	}
+}
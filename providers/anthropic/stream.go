@@ -0,0 +1,188 @@
+package anthropic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"simpleai"
+	"strings"
+	"time"
+)
+
+// streamEvent is the data of a server-sent event. Which fields are set depends on Type.
+type streamEvent struct {
+	Type         string           `json:"type"`
+	Index        int              `json:"index"`
+	Message      *messageResponse `json:"message"`       // message_start
+	ContentBlock *contentBlock    `json:"content_block"` // content_block_start
+	Delta        *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`         // text_delta
+		PartialJSON string `json:"partial_json"` // input_json_delta
+	} `json:"delta"` // content_block_delta and message_delta
+	Usage *usage        `json:"usage"` // message_delta
+	Error *errorDetails `json:"error"` // error
+}
+
+// ChatStream streams a chat request, calling handler with each chunk of the response
+func (p *Provider) ChatStream(ctx context.Context, request simpleai.ChatRequest, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	return p.ChatStreamWithRetry(ctx, request, p.retryConfig, handler)
+}
+
+// ChatStreamWithRetry streams a chat request with retry logic. Attempts are only retried
+// until the first chunk reaches handler, so a response is never delivered twice. Tool use
+// blocks are assembled from their deltas and returned in the response.
+func (p *Provider) ChatStreamWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		text := ""
+		started := false
+		usage := &usage{}
+		blocks := map[int]*contentBlock{}
+		var order []int
+		finished := false
+
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		resp, err := p.api.Send(ctx, http.MethodPost, "/v1/messages", p.buildRequest(request, true), attempt, "chat_stream")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		fail := func(err error) error {
+			if started {
+				return simpleai.StopRetrying(err)
+			}
+			return err
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+	events:
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				// Event names are repeated in the data, so only data lines matter
+				continue
+			}
+
+			var event streamEvent
+			if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+				return fail(simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+					"invalid stream event from Anthropic API", "chat_stream", true, attempt, err).
+					WithProvider("anthropic", p.defaultModel))
+			}
+
+			switch event.Type {
+			case "message_start":
+				if event.Message != nil && event.Message.Usage != nil {
+					usage.InputTokens = event.Message.Usage.InputTokens
+				}
+			case "content_block_start":
+				if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+					block := *event.ContentBlock
+					block.Input = nil
+					blocks[event.Index] = &block
+					order = append(order, event.Index)
+				}
+			case "content_block_delta":
+				if event.Delta == nil {
+					continue
+				}
+				if block, ok := blocks[event.Index]; ok {
+					block.Input = append(block.Input, event.Delta.PartialJSON...)
+					continue
+				}
+				if event.Delta.Text == "" {
+					continue
+				}
+				text += event.Delta.Text
+				started = true
+				if err := handler(simpleai.StreamChunk{Delta: event.Delta.Text, Text: text}); err != nil {
+					// The caller stopped the stream; keep its error as the cause
+					return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+						"stream handler failed", "chat_stream", false, attempt, err)
+				}
+			case "message_delta":
+				// Output tokens are cumulative
+				if event.Usage != nil {
+					usage.OutputTokens = event.Usage.OutputTokens
+				}
+			case "message_stop":
+				finished = true
+				break events
+			case "error":
+				message := "stream failed"
+				errType := ""
+				if event.Error != nil {
+					message, errType = event.Error.Message, event.Error.Type
+				}
+				if errType == "overloaded_error" {
+					return fail(simpleai.NewLLMError(simpleai.ErrRateLimitExceeded,
+						fmt.Sprintf("Anthropic API error: %s", message), "chat_stream", true, attempt, nil).
+						WithProvider("anthropic", p.defaultModel))
+				}
+				return fail(simpleai.NewLLMError(simpleai.ErrOperationFailed,
+					fmt.Sprintf("Anthropic API error: %s", message), "chat_stream", true, attempt, nil).
+					WithProvider("anthropic", p.defaultModel))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			}
+			return fail(p.api.TransportError(err, attempt, "chat_stream"))
+		}
+		if !finished {
+			// The connection was closed before the server finished the message
+			return fail(simpleai.NewLLMError(simpleai.ErrConnectionFailed,
+				"stream from Anthropic API ended before message_stop", "chat_stream", true, attempt, nil).
+				WithProvider("anthropic", p.defaultModel))
+		}
+
+		var toolCalls []simpleai.ToolCall
+		for _, index := range order {
+			block := blocks[index]
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			toolCalls = append(toolCalls, simpleai.ToolCall{ID: block.ID, Name: block.Name, Arguments: arguments})
+		}
+
+		if text == "" && len(toolCalls) == 0 {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from Anthropic API",
+				"chat_stream", true, attempt, nil).WithProvider("anthropic", p.defaultModel)
+		}
+
+		if err := handler(simpleai.StreamChunk{Text: text, Done: true}); err != nil {
+			return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+				"stream handler failed", "chat_stream", false, attempt, err)
+		}
+
+		if len(toolCalls) > 0 {
+			response = simpleai.ChatResponse{Message: text, ToolCalls: toolCalls, Usage: usage.convert()}
+			return nil
+		}
+
+		structuredData, err := simpleai.ParseStructuredOutput(text, request.T, request.Extractor, attempt)
+		if err != nil {
+			// The response has already been streamed, so asking again would repeat it
+			return simpleai.StopRetrying(err)
+		}
+
+		response = simpleai.ChatResponse{Message: text, Data: structuredData, Usage: usage.convert()}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	return response, nil
+}
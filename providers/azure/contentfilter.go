@@ -0,0 +1,86 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"simpleai"
+	"sort"
+	"strings"
+)
+
+// ContentFilterError describes a prompt rejected by Azure's content filter. It is the cause
+// of an LLMError of type simpleai.ErrContentFiltered, so either can be checked:
+//
+//	var filterErr *azure.ContentFilterError
+//	if errors.As(err, &filterErr) {
+//		log.Printf("blocked for %v", filterErr.Categories())
+//	}
+type ContentFilterError struct {
+	Code    string                  // Error code, e.g. "content_filter"
+	Message string                  // Message from Azure
+	Results map[string]FilterResult // Filter results by category, e.g. "hate" or "jailbreak"
+}
+
+// FilterResult is one content filter category's verdict
+type FilterResult struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity,omitempty"` // "safe", "low", "medium" or "high"
+	Detected bool   `json:"detected,omitempty"` // For detection filters such as jailbreak
+}
+
+func (e *ContentFilterError) Error() string {
+	if categories := e.Categories(); len(categories) > 0 {
+		return fmt.Sprintf("content filtered (%s): %s", strings.Join(categories, ", "), e.Message)
+	}
+	return "content filtered: " + e.Message
+}
+
+// Is makes errors.Is(err, simpleai.ErrContentFiltered) hold for the error itself too
+func (e *ContentFilterError) Is(target error) bool {
+	return target == simpleai.ErrContentFiltered
+}
+
+// Categories returns the names of the categories that blocked the content, sorted
+func (e *ContentFilterError) Categories() []string {
+	var categories []string
+	for name, result := range e.Results {
+		if result.Filtered {
+			categories = append(categories, name)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// azureError is the body of an Azure OpenAI error response
+type azureError struct {
+	Error *struct {
+		Code       string `json:"code"`
+		Message    string `json:"message"`
+		InnerError *struct {
+			Code                string                  `json:"code"`
+			ContentFilterResult map[string]FilterResult `json:"content_filter_result"`
+		} `json:"innererror"`
+	} `json:"error"`
+}
+
+// classifyError turns content filter rejections into ErrContentFiltered errors, leaving
+// other errors to the default classification
+func classifyError(status int, body []byte, operation string, attempt int) *simpleai.LLMError {
+	var parsed azureError
+	if json.Unmarshal(body, &parsed) != nil || parsed.Error == nil {
+		return nil
+	}
+	inner := parsed.Error.InnerError
+	if parsed.Error.Code != "content_filter" && (inner == nil || inner.Code != "ResponsibleAIPolicyViolation") {
+		return nil
+	}
+
+	filterErr := &ContentFilterError{Code: parsed.Error.Code, Message: parsed.Error.Message}
+	if inner != nil {
+		filterErr.Results = inner.ContentFilterResult
+	}
+	return simpleai.NewLLMError(simpleai.ErrContentFiltered,
+		"prompt blocked by Azure content filter", operation, false, attempt, filterErr).
+		WithStatusCode(status)
+}
@@ -0,0 +1,156 @@
+// Package azure implements simpleai.Provider for Azure OpenAI.
+//
+// Azure OpenAI serves the Chat Completions API per deployment, so requests go to
+// {host}/openai/deployments/{deployment}/chat/completions with an api-version query
+// parameter. Requests are authenticated with an api-key header or, in "entra" auth mode,
+// with a Microsoft Entra ID bearer token. Everything else behaves like the openai provider.
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"simpleai"
+	"simpleai/providers/openai"
+	"strings"
+)
+
+// DefaultAPIVersion is the api-version used when none is configured
+const DefaultAPIVersion = "2024-10-21"
+
+// Auth modes accepted by the "auth_mode" config key
+const (
+	AuthAPIKey = "api_key" // api-key header (default)
+	AuthEntra  = "entra"   // Microsoft Entra ID bearer token
+)
+
+// TokenSource returns a Microsoft Entra ID access token for the Cognitive Services scope.
+// It is called for every request, so it should cache tokens until they expire.
+type TokenSource func(ctx context.Context) (string, error)
+
+// Provider implements the simpleai.Provider interface for Azure OpenAI
+type Provider struct {
+	*openai.Provider
+	deployment string
+}
+
+// Config holds the settings of an Azure OpenAI provider
+type Config struct {
+	openai.Config
+	Deployment  string      `json:"deployment,omitempty" description:"Deployment to send requests to; defaults to default_model"`
+	APIVersion  string      `json:"api_version,omitempty" description:"api-version query parameter"`
+	AuthMode    string      `json:"auth_mode,omitempty" description:"api_key (default) or entra"`
+	TokenSource TokenSource `json:"token_source,omitempty" description:"Entra ID token source, set from code; without one api_key is sent as a static token"`
+}
+
+// NewProvider creates a new Azure OpenAI provider instance. Besides the common keys, config
+// accepts "deployment" (defaults to default_model), "api_version", "auth_mode" and, for
+// Entra ID, a "token_source" TokenSource; without one, api_key is sent as a static token.
+func NewProvider(config map[string]interface{}) (simpleai.Provider, error) {
+	var settings Config
+	if err := simpleai.DecodeProviderConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	host := settings.Host
+	if host == "" {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			"host is required for Azure OpenAI provider, e.g. https://my-resource.openai.azure.com",
+			"provider_creation", false, 0, nil)
+	}
+
+	// The deployment names the model in the URL; DefaultModel is used if it is not set
+	deployment := settings.Deployment
+	if deployment == "" {
+		deployment = settings.DefaultModel
+	}
+	if deployment == "" {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			"deployment or default_model is required for Azure OpenAI provider",
+			"provider_creation", false, 0, nil)
+	}
+
+	apiVersion := DefaultAPIVersion
+	if settings.APIVersion != "" {
+		apiVersion = settings.APIVersion
+	}
+
+	authorize, err := authorizer(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// The openai provider reports the deployment as its model
+	openaiSettings := settings.Config
+	openaiSettings.DefaultModel = deployment
+
+	base := strings.TrimSuffix(host, "/") + "/openai"
+	query := "?api-version=" + url.QueryEscape(apiVersion)
+	provider, err := openai.NewProviderWithConfig(openaiSettings, openai.Options{
+		Name: "azure",
+		URL: func(path string) string {
+			if path == "/chat/completions" {
+				return base + "/deployments/" + url.PathEscape(deployment) + path + query
+			}
+			return base + path + query
+		},
+		Authorize:     authorize,
+		ClassifyError: classifyError,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{Provider: provider, deployment: deployment}, nil
+}
+
+// authorizer returns the function that adds credentials for the configured auth mode
+func authorizer(settings Config) (func(req *http.Request) error, error) {
+	apiKey := settings.APIKey
+
+	switch settings.AuthMode {
+	case "", AuthAPIKey:
+		if apiKey == "" {
+			return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+				"API key is required for Azure OpenAI provider",
+				"provider_creation", false, 0, nil)
+		}
+		return func(req *http.Request) error {
+			req.Header.Set("Api-Key", apiKey)
+			return nil
+		}, nil
+	case AuthEntra:
+		tokenSource := settings.TokenSource
+		if tokenSource == nil && apiKey == "" {
+			return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+				"a token_source or api_key token is required for Entra ID auth",
+				"provider_creation", false, 0, nil)
+		}
+		return func(req *http.Request) error {
+			token := apiKey
+			if tokenSource != nil {
+				var err error
+				if token, err = tokenSource(req.Context()); err != nil {
+					return fmt.Errorf("cannot get Entra ID token: %w", err)
+				}
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		}, nil
+	default:
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			fmt.Sprintf("unknown auth_mode %q", settings.AuthMode),
+			"provider_creation", false, 0, nil)
+	}
+}
+
+// ListModels returns the configured deployment, which is the only model requests can use
+func (p *Provider) ListModels() ([]simpleai.Model, error) {
+	return []simpleai.Model{{Name: p.deployment}}, nil
+}
+
+// Deployment returns the deployment requests are sent to
+func (p *Provider) Deployment() string {
+	return p.deployment
+}
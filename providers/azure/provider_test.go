@@ -0,0 +1,178 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"simpleai"
+	"testing"
+)
+
+// newTestServer starts a server answering with handler and returns its URL
+func newTestServer(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+const okCompletion = `{"choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`
+
+func TestDeploymentRoutingWithAPIKey(t *testing.T) {
+	var request *http.Request
+	host := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		fmt.Fprint(w, okCompletion)
+	})
+
+	provider, err := NewProvider(map[string]interface{}{
+		"host":          host + "/",
+		"api_key":       "secret",
+		"default_model": "gpt-4o-prod",
+		"api_version":   "2024-08-01-preview",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	response, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hello"}}})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if response.Message != "Hi" {
+		t.Errorf("Unexpected response %+v", response)
+	}
+
+	if request.URL.Path != "/openai/deployments/gpt-4o-prod/chat/completions" {
+		t.Errorf("Unexpected path %s", request.URL.Path)
+	}
+	if got := request.URL.Query().Get("api-version"); got != "2024-08-01-preview" {
+		t.Errorf("Expected api-version 2024-08-01-preview, got %q", got)
+	}
+	if request.Header.Get("api-key") != "secret" || request.Header.Get("Authorization") != "" {
+		t.Errorf("Expected only the api-key header, got %v", request.Header)
+	}
+	if provider.Name() != "azure" {
+		t.Errorf("Expected name azure, got %s", provider.Name())
+	}
+}
+
+func TestEntraAuth(t *testing.T) {
+	var authorization string
+	host := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		fmt.Fprint(w, okCompletion)
+	})
+
+	calls := 0
+	provider, err := NewProvider(map[string]interface{}{
+		"host":       host,
+		"deployment": "chat",
+		"auth_mode":  AuthEntra,
+		"token_source": TokenSource(func(ctx context.Context) (string, error) {
+			calls++
+			return fmt.Sprintf("token-%d", calls), nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	if _, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hello"}}}); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if authorization != "Bearer token-1" {
+		t.Errorf("Expected a bearer token from the token source, got %q", authorization)
+	}
+}
+
+func TestContentFilterError(t *testing.T) {
+	host := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"code":"content_filter","message":"The response was filtered","status":400,
+			"innererror":{"code":"ResponsibleAIPolicyViolation","content_filter_result":{
+				"hate":{"filtered":false,"severity":"safe"},
+				"violence":{"filtered":true,"severity":"high"},
+				"jailbreak":{"filtered":true,"detected":true}}}}}`)
+	})
+
+	provider, err := NewProvider(map[string]interface{}{"host": host, "api_key": "secret", "deployment": "chat"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	_, err = provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hello"}}})
+	if !errors.Is(err, simpleai.ErrContentFiltered) {
+		t.Fatalf("Expected ErrContentFiltered, got %v", err)
+	}
+
+	var llmErr *simpleai.LLMError
+	if !errors.As(err, &llmErr) || llmErr.Retryable || llmErr.StatusCode != http.StatusBadRequest || llmErr.Provider != "azure" {
+		t.Errorf("Expected a non-retryable 400 error from azure, got %+v", llmErr)
+	}
+
+	var filterErr *ContentFilterError
+	if !errors.As(err, &filterErr) {
+		t.Fatalf("Expected a ContentFilterError, got %v", err)
+	}
+	if categories := filterErr.Categories(); len(categories) != 2 || categories[0] != "jailbreak" || categories[1] != "violence" {
+		t.Errorf("Expected jailbreak and violence, got %v", categories)
+	}
+	if filterErr.Results["violence"].Severity != "high" || !filterErr.Results["jailbreak"].Detected {
+		t.Errorf("Unexpected filter results %+v", filterErr.Results)
+	}
+}
+
+func TestFactoryConfig(t *testing.T) {
+	var path, apiVersion string
+	host := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		path, apiVersion = r.URL.Path, r.URL.Query().Get("api-version")
+		fmt.Fprint(w, okCompletion)
+	})
+
+	factory := simpleai.NewLLMFactory()
+	factory.RegisterProvider("azure", NewProvider)
+	err := factory.LoadConfig(simpleai.FactoryConfig{DefaultProvider: "azure", Providers: map[string]simpleai.ProviderConfig{
+		"azure": {
+			Host:          host,
+			APIKey:        "secret",
+			DefaultModel:  "gpt-4o",
+			ExtraSettings: map[string]string{"deployment": "gpt-4o-eu", "api_version": "2024-06-01"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	provider, err := factory.CreateProviderFromConfig("azure")
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if _, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hello"}}}); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if path != "/openai/deployments/gpt-4o-eu/chat/completions" || apiVersion != "2024-06-01" {
+		t.Errorf("Unexpected request to %s with api-version %s", path, apiVersion)
+	}
+
+	models, err := provider.ListModels()
+	if err != nil || len(models) != 1 || models[0].Name != "gpt-4o-eu" {
+		t.Errorf("Expected the deployment as the only model, got %v, %v", models, err)
+	}
+}
+
+func TestNewProviderInvalidConfig(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"api_key": "secret", "deployment": "chat"},
+		{"host": "https://example.openai.azure.com", "api_key": "secret"},
+		{"host": "https://example.openai.azure.com", "deployment": "chat"},
+		{"host": "https://example.openai.azure.com", "deployment": "chat", "auth_mode": AuthEntra},
+		{"host": "https://example.openai.azure.com", "deployment": "chat", "api_key": "secret", "auth_mode": "sas"},
+	} {
+		if _, err := NewProvider(config); !errors.Is(err, simpleai.ErrInvalidConfig) {
+			t.Errorf("Expected %v to be rejected, got %v", config, err)
+		}
+	}
+}
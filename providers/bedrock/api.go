@@ -0,0 +1,198 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"simpleai"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// buildInput converts a chat request to a Converse request
+func (p *Provider) buildInput(request simpleai.ChatRequest) *bedrockruntime.ConverseInput {
+	system, messages := convertMessages(request)
+	input := &bedrockruntime.ConverseInput{
+		ModelId:    aws.String(p.defaultModel),
+		System:     system,
+		Messages:   messages,
+		ToolConfig: convertTools(request.Tools, request.ToolChoice),
+	}
+	if p.maxTokens > 0 || request.Temperature != nil {
+		input.InferenceConfig = &types.InferenceConfiguration{}
+		if p.maxTokens > 0 {
+			input.InferenceConfig.MaxTokens = aws.Int32(int32(p.maxTokens))
+		}
+		if request.Temperature != nil {
+			input.InferenceConfig.Temperature = aws.Float32(float32(*request.Temperature))
+		}
+	}
+	return input
+}
+
+// buildStreamInput converts a chat request to a ConverseStream request
+func (p *Provider) buildStreamInput(request simpleai.ChatRequest) *bedrockruntime.ConverseStreamInput {
+	input := p.buildInput(request)
+	return &bedrockruntime.ConverseStreamInput{
+		ModelId:         input.ModelId,
+		System:          input.System,
+		Messages:        input.Messages,
+		ToolConfig:      input.ToolConfig,
+		InferenceConfig: input.InferenceConfig,
+	}
+}
+
+// conversationStart is the text of the user turn put before a conversation the assistant
+// opened
+const conversationStart = "(start of conversation)"
+
+// convertMessages converts the system prompt and messages to Converse system and content
+// blocks. Tool results are sent in user turns, and consecutive turns with the same role are
+// merged, since the API requires user and assistant turns to alternate. The API also requires
+// the first turn to be the user's, so a conversation opened by the assistant gets a
+// placeholder user turn before it.
+func convertMessages(request simpleai.ChatRequest) ([]types.SystemContentBlock, []types.Message) {
+	var system []types.SystemContentBlock
+	if request.SystemPrompt.Content != "" {
+		system = append(system, &types.SystemContentBlockMemberText{Value: request.SystemPrompt.Content})
+	}
+
+	var messages []types.Message
+	for _, msg := range request.Messages {
+		var role types.ConversationRole
+		var blocks []types.ContentBlock
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				system = append(system, &types.SystemContentBlockMemberText{Value: msg.Content})
+			}
+			continue
+		case "tool":
+			role = types.ConversationRoleUser
+			blocks = []types.ContentBlock{&types.ContentBlockMemberToolResult{Value: types.ToolResultBlock{
+				ToolUseId: aws.String(msg.ToolCallID),
+				Content:   []types.ToolResultContentBlock{&types.ToolResultContentBlockMemberText{Value: msg.Content}},
+			}}}
+		case "assistant":
+			role = types.ConversationRoleAssistant
+			if msg.Content != "" {
+				blocks = append(blocks, &types.ContentBlockMemberText{Value: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				var input any
+				if json.Unmarshal([]byte(call.Arguments), &input) != nil || input == nil {
+					input = map[string]any{}
+				}
+				blocks = append(blocks, &types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{
+					ToolUseId: aws.String(call.ID),
+					Name:      aws.String(call.Name),
+					Input:     document.NewLazyDocument(input),
+				}})
+			}
+		default:
+			role = types.ConversationRoleUser
+			if msg.Content != "" {
+				blocks = []types.ContentBlock{&types.ContentBlockMemberText{Value: msg.Content}}
+			}
+		}
+
+		// The API rejects empty turns and empty text blocks
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, blocks...)
+			continue
+		}
+		messages = append(messages, types.Message{Role: role, Content: blocks})
+	}
+	if len(messages) > 0 && messages[0].Role == types.ConversationRoleAssistant {
+		start := types.Message{
+			Role:    types.ConversationRoleUser,
+			Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: conversationStart}},
+		}
+		messages = append([]types.Message{start}, messages...)
+	}
+	return system, messages
+}
+
+// convertTools converts tools and the tool choice, giving tools without parameters an empty
+// object schema. Converse has no choice that disables tools, so "none" leaves them out.
+func convertTools(tools []simpleai.Tool, choice string) *types.ToolConfiguration {
+	if len(tools) == 0 || choice == "none" {
+		return nil
+	}
+
+	config := &types.ToolConfiguration{}
+	for _, t := range tools {
+		inputSchema := t.Parameters
+		if inputSchema == nil {
+			inputSchema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		spec := types.ToolSpecification{
+			Name:        aws.String(t.Name),
+			InputSchema: &types.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(inputSchema)},
+		}
+		if t.Description != "" {
+			spec.Description = aws.String(t.Description)
+		}
+		config.Tools = append(config.Tools, &types.ToolMemberToolSpec{Value: spec})
+	}
+
+	switch choice {
+	case "", "auto":
+	case "required":
+		config.ToolChoice = &types.ToolChoiceMemberAny{}
+	default:
+		config.ToolChoice = &types.ToolChoiceMemberTool{Value: types.SpecificToolChoice{Name: aws.String(choice)}}
+	}
+	return config
+}
+
+// messageText joins the text blocks of a message
+func messageText(message types.Message) string {
+	var text strings.Builder
+	for _, block := range message.Content {
+		if b, ok := block.(*types.ContentBlockMemberText); ok {
+			text.WriteString(b.Value)
+		}
+	}
+	return text.String()
+}
+
+// messageToolCalls returns the tool calls of a message with their input as JSON
+func messageToolCalls(message types.Message) []simpleai.ToolCall {
+	var calls []simpleai.ToolCall
+	for _, block := range message.Content {
+		b, ok := block.(*types.ContentBlockMemberToolUse)
+		if !ok {
+			continue
+		}
+		arguments := "{}"
+		if b.Value.Input != nil {
+			if data, err := b.Value.Input.MarshalSmithyDocument(); err == nil {
+				arguments = string(data)
+			}
+		}
+		calls = append(calls, simpleai.ToolCall{
+			ID:        aws.ToString(b.Value.ToolUseId),
+			Name:      aws.ToString(b.Value.Name),
+			Arguments: arguments,
+		})
+	}
+	return calls
+}
+
+// convertUsage converts Bedrock token usage
+func convertUsage(usage *types.TokenUsage) *simpleai.Usage {
+	if usage == nil {
+		return nil
+	}
+	return &simpleai.Usage{
+		PromptTokens:     int(aws.ToInt32(usage.InputTokens)),
+		CompletionTokens: int(aws.ToInt32(usage.OutputTokens)),
+		TotalTokens:      int(aws.ToInt32(usage.TotalTokens)),
+	}
+}
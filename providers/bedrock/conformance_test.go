@@ -0,0 +1,137 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"simpleai"
+	"simpleai/providertest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+)
+
+// converseProtocol speaks the Converse REST protocol for the conformance suite
+type converseProtocol struct{}
+
+type converseBlock struct {
+	Text string `json:"text,omitempty"`
+}
+
+type converseRequest struct {
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content []converseBlock `json:"content"`
+	} `json:"messages"`
+	System []converseBlock `json:"system"`
+}
+
+func (converseProtocol) Decode(r *http.Request) (providertest.Received, error) {
+	rest, ok := strings.CutPrefix(r.URL.Path, "/model/")
+	if !ok {
+		return providertest.Received{}, errors.New("unknown endpoint " + r.URL.Path)
+	}
+	model, operation, _ := strings.Cut(rest, "/")
+	if operation != "converse" && operation != "converse-stream" {
+		return providertest.Received{}, errors.New("unknown operation " + operation)
+	}
+
+	var request converseRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return providertest.Received{}, err
+	}
+
+	received := providertest.Received{Model: model, Stream: operation == "converse-stream"}
+	var system []string
+	for _, block := range request.System {
+		system = append(system, block.Text)
+	}
+	received.System = strings.Join(system, "\n\n")
+	for _, message := range request.Messages {
+		var text strings.Builder
+		for _, block := range message.Content {
+			text.WriteString(block.Text)
+		}
+		received.Messages = append(received.Messages, simpleai.Message{Role: message.Role, Content: text.String()})
+	}
+	return received, nil
+}
+
+func (converseProtocol) WriteText(w http.ResponseWriter, received providertest.Received, chunks []string) {
+	usage := map[string]int{"inputTokens": 5, "outputTokens": len(chunks), "totalTokens": 5 + len(chunks)}
+
+	if !received.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"output": map[string]any{"message": map[string]any{
+				"role":    "assistant",
+				"content": []converseBlock{{Text: strings.Join(chunks, "")}},
+			}},
+			"stopReason": "end_turn",
+			"usage":      usage,
+			"metrics":    map[string]int{"latencyMs": 1},
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+	writeEvent(w, "messageStart", map[string]any{"role": "assistant"})
+	for _, chunk := range chunks {
+		writeEvent(w, "contentBlockDelta", map[string]any{"contentBlockIndex": 0, "delta": map[string]any{"text": chunk}})
+	}
+	writeEvent(w, "contentBlockStop", map[string]any{"contentBlockIndex": 0})
+	writeEvent(w, "messageStop", map[string]any{"stopReason": "end_turn"})
+	writeEvent(w, "metadata", map[string]any{"usage": usage, "metrics": map[string]int{"latencyMs": 1}})
+}
+
+func (converseProtocol) WriteError(w http.ResponseWriter, received providertest.Received, status int, message string, retryAfter time.Duration) {
+	code := "InternalServerException"
+	switch status {
+	case http.StatusUnauthorized:
+		code = "UnrecognizedClientException"
+	case http.StatusForbidden:
+		code = "AccessDeniedException"
+	case http.StatusNotFound:
+		code = "ResourceNotFoundException"
+	case http.StatusTooManyRequests:
+		code = "ThrottlingException"
+	case http.StatusServiceUnavailable:
+		code = "ServiceUnavailableException"
+	}
+
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Amzn-ErrorType", code)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// writeEvent writes one event stream message
+func writeEvent(w http.ResponseWriter, eventType string, payload any) {
+	data, _ := json.Marshal(payload)
+	var headers eventstream.Headers
+	headers.Set(":message-type", eventstream.StringValue("event"))
+	headers.Set(":event-type", eventstream.StringValue(eventType))
+	headers.Set(":content-type", eventstream.StringValue("application/json"))
+	eventstream.NewEncoder().Encode(w, eventstream.Message{Headers: headers, Payload: data})
+	w.(http.Flusher).Flush()
+}
+
+func TestConformance(t *testing.T) {
+	setCredentials(t)
+	providertest.Run(t, providertest.Harness{
+		Protocol: converseProtocol{},
+		NewProvider: func(t *testing.T, baseURL string) simpleai.Provider {
+			provider, err := NewProvider(map[string]interface{}{"host": baseURL})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			return provider
+		},
+	})
+}
@@ -0,0 +1,300 @@
+// Package bedrock implements simpleai.Provider against the Amazon Bedrock Converse API.
+//
+// Requests are signed with SigV4 using the standard AWS credential chain: environment
+// variables, shared config and credentials files, SSO, and container or instance roles.
+// The system prompt and any system messages are sent as system content blocks, and
+// structured output relies on prompting and JSON extraction.
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"simpleai"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+)
+
+// Provider implements the simpleai.Provider interface for Amazon Bedrock
+type Provider struct {
+	client       *bedrockruntime.Client
+	region       string
+	defaultModel string
+	maxTokens    int
+	timeout      int
+	retryConfig  *simpleai.RetryConfig
+}
+
+// Config holds the settings of a Bedrock provider
+type Config struct {
+	simpleai.ProviderSettings
+	Region    string `json:"region,omitempty" description:"AWS region; defaults to the AWS configuration"`
+	Profile   string `json:"profile,omitempty" description:"Shared AWS config profile to load credentials from"`
+	MaxTokens int    `json:"max_tokens,omitempty" description:"Maximum tokens per reply; the model's default applies without it"`
+}
+
+// NewProvider creates a new Bedrock provider instance. Besides the common keys, config
+// accepts "region" and "profile" to override the AWS defaults and "max_tokens". "host"
+// overrides the Bedrock runtime endpoint, e.g. for a VPC endpoint.
+func NewProvider(config map[string]interface{}) (simpleai.Provider, error) {
+	var settings Config
+	if err := simpleai.DecodeProviderConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	defaultModel := "amazon.nova-lite-v1:0"
+	if settings.DefaultModel != "" {
+		defaultModel = settings.DefaultModel
+	}
+
+	// max_tokens is optional; the model's default applies without it
+	if settings.MaxTokens < 0 {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			fmt.Sprintf("invalid max_tokens %d", settings.MaxTokens),
+			"provider_creation", false, 0, nil)
+	}
+	maxTokens := settings.MaxTokens
+
+	timeout := 60
+	if settings.Timeout > 0 {
+		timeout = settings.Timeout
+	}
+
+	retryAttempts := 3
+	if r := settings.RetryAttempts; r != nil && *r >= 0 {
+		retryAttempts = *r
+	}
+
+	// Create retry configuration
+	retryConfig := simpleai.DefaultRetryConfig()
+	retryConfig.MaxRetries = retryAttempts
+
+	// Load credentials and region from the standard sources. Retries are left to simpleai,
+	// so the SDK must not retry on its own.
+	options := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRetryer(func() aws.Retryer { return aws.NopRetryer{} }),
+	}
+	if settings.Region != "" {
+		options = append(options, awsconfig.WithRegion(settings.Region))
+	}
+	if settings.Profile != "" {
+		options = append(options, awsconfig.WithSharedConfigProfile(settings.Profile))
+	}
+	awsConfig, err := awsconfig.LoadDefaultConfig(context.Background(), options...)
+	if err != nil {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			"failed to load AWS configuration",
+			"provider_creation", false, 0, err)
+	}
+	if awsConfig.Region == "" {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			"region is required for Bedrock provider; set it in the config or AWS_REGION",
+			"provider_creation", false, 0, nil)
+	}
+
+	client := bedrockruntime.NewFromConfig(awsConfig, func(o *bedrockruntime.Options) {
+		if settings.Host != "" {
+			o.BaseEndpoint = aws.String(settings.Host)
+		}
+	})
+
+	return &Provider{
+		client:       client,
+		region:       awsConfig.Region,
+		defaultModel: defaultModel,
+		maxTokens:    maxTokens,
+		timeout:      timeout,
+		retryConfig:  retryConfig,
+	}, nil
+}
+
+// Chat sends a chat request and returns a response
+func (p *Provider) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatWithRetry(request, p.retryConfig)
+}
+
+// ChatContext sends a chat request, giving up when ctx is done
+func (p *Provider) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(ctx, request, p.retryConfig)
+}
+
+// ChatWithRetry executes a chat request with retry logic
+func (p *Provider) ChatWithRetry(request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(context.Background(), request, retryConfig)
+}
+
+// ChatContextWithRetry executes a cancellable chat request with retry logic. Responses that
+// use tools are returned without decoding structured output.
+func (p *Provider) ChatContextWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		output, err := p.client.Converse(ctx, p.buildInput(request))
+		if err != nil {
+			return p.classifyError(err, attempt, "chat")
+		}
+		if filtered(output.StopReason) {
+			return p.filteredError(attempt, "chat")
+		}
+
+		var message types.Message
+		if m, ok := output.Output.(*types.ConverseOutputMemberMessage); ok {
+			message = m.Value
+		}
+		text := messageText(message)
+		usage := convertUsage(output.Usage)
+
+		if toolCalls := messageToolCalls(message); len(toolCalls) > 0 {
+			response = simpleai.ChatResponse{Message: text, ToolCalls: toolCalls, Usage: usage}
+			return nil
+		}
+		if text == "" {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from Bedrock API",
+				"chat", true, attempt, nil).WithProvider("bedrock", p.defaultModel)
+		}
+
+		structuredData, err := simpleai.ParseStructuredOutput(text, request.T, request.Extractor, attempt)
+		if err != nil {
+			return err
+		}
+
+		response = simpleai.ChatResponse{Message: text, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	return response, nil
+}
+
+// ListModels returns the configured model. Listing foundation models needs the Bedrock
+// control plane API and permissions the runtime does not.
+func (p *Provider) ListModels() ([]simpleai.Model, error) {
+	return []simpleai.Model{{Name: p.defaultModel}}, nil
+}
+
+// Name returns the provider's name
+func (p *Provider) Name() string {
+	return "bedrock"
+}
+
+// IsAvailable checks if the provider is currently available/reachable. It lists async
+// invocations, a signed runtime request that is not billed, rather than sending a chat. A
+// caller without the bedrock:ListAsyncInvokes permission is still reported available, since
+// the request was authenticated before it was denied.
+func (p *Provider) IsAvailable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := p.client.ListAsyncInvokes(ctx, &bedrockruntime.ListAsyncInvokesInput{MaxResults: aws.Int32(1)})
+	var denied *types.AccessDeniedException
+	return err == nil || errors.As(err, &denied)
+}
+
+// SupportedFeatures returns the capabilities supported by this provider
+func (p *Provider) SupportedFeatures() simpleai.ProviderFeatures {
+	return simpleai.ProviderFeatures{
+		StructuredOutput: true,   // Structured JSON output via prompting
+		Streaming:        true,   // ConverseStream
+		Vision:           false,  // Image inputs are not sent
+		MaxTokens:        200000, // Largest context window among Bedrock chat models
+		SupportedRoles:   []string{"system", "user", "assistant", "tool"},
+		FunctionCalling:  true, // Tool use through the Converse API
+		Temperature:      true,
+		TopP:             true,
+	}
+}
+
+// GetDefaultModel returns the default model for this provider
+func (p *Provider) GetDefaultModel() string {
+	return p.defaultModel
+}
+
+// Region returns the AWS region requests are sent to
+func (p *Provider) Region() string {
+	return p.region
+}
+
+// GetRetryConfig returns the retry configuration for this provider
+func (p *Provider) GetRetryConfig() *simpleai.RetryConfig {
+	return p.retryConfig
+}
+
+// UpdateRetryConfig allows updating the retry configuration
+func (p *Provider) UpdateRetryConfig(config *simpleai.RetryConfig) {
+	if config != nil {
+		p.retryConfig = config
+	}
+}
+
+// filtered reports whether a stop reason means the response was blocked
+func filtered(reason types.StopReason) bool {
+	return reason == types.StopReasonContentFiltered || reason == types.StopReasonGuardrailIntervened
+}
+
+// filteredError reports a response stopped by a content filter or guardrail
+func (p *Provider) filteredError(attempt int, operation string) *simpleai.LLMError {
+	return simpleai.NewLLMError(simpleai.ErrContentFiltered,
+		"response blocked by Bedrock content filter or guardrail", operation, false, attempt, nil).
+		WithProvider("bedrock", p.defaultModel)
+}
+
+// classifyError classifies errors and determines if they are retryable
+func (p *Provider) classifyError(err error, attempt int, operation string) *simpleai.LLMError {
+	// Errors from reaching the API at all, including cancellation
+	if llmErr, ok := simpleai.NewTransportError(err, "Bedrock API", operation, attempt); ok {
+		return llmErr.WithProvider("bedrock", p.defaultModel)
+	}
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+			"operation failed", operation, true, attempt, err).
+			WithProvider("bedrock", p.defaultModel)
+	}
+
+	// Errors sent mid-stream have no HTTP response
+	status := 0
+	var retryAfter time.Duration
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.Response != nil {
+		status = respErr.HTTPStatusCode()
+		retryAfter = simpleai.ParseRetryAfter(respErr.Response.Header.Get("Retry-After"))
+	}
+
+	message := fmt.Sprintf("Bedrock API error: %s", apiErr.ErrorMessage())
+	var llmErr *simpleai.LLMError
+	switch apiErr.ErrorCode() {
+	case "ThrottlingException":
+		llmErr = simpleai.NewLLMError(simpleai.ErrRateLimitExceeded, message, operation, true, attempt, err)
+	case "ModelNotReadyException":
+		// The model is being loaded and will accept requests shortly
+		llmErr = simpleai.NewLLMError(simpleai.ErrModelNotAvailable, message, operation, true, attempt, err)
+	case "ModelTimeoutException":
+		llmErr = simpleai.NewLLMError(simpleai.ErrTimeout, message, operation, true, attempt, err)
+	case "ResourceNotFoundException":
+		llmErr = simpleai.NewLLMError(simpleai.ErrModelNotAvailable, message, operation, false, attempt, err)
+	default:
+		if status != 0 {
+			llmErr = simpleai.NewStatusError(status, message, operation, attempt, err)
+		} else {
+			retryable := apiErr.ErrorFault() == smithy.FaultServer
+			llmErr = simpleai.NewLLMError(simpleai.ErrOperationFailed, message, operation, retryable, attempt, err)
+		}
+	}
+	if status != 0 {
+		llmErr = llmErr.WithStatusCode(status)
+	}
+	return llmErr.WithProvider("bedrock", p.defaultModel).WithRetryAfter(retryAfter)
+}
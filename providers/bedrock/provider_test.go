@@ -0,0 +1,237 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"simpleai"
+	"simpleai/providertest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// setCredentials points the AWS credential chain at static test credentials only
+func setCredentials(t *testing.T) {
+	t.Helper()
+	missing := filepath.Join(t.TempDir(), "missing")
+	t.Setenv("AWS_CONFIG_FILE", missing)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", missing)
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "eu-central-1")
+}
+
+// newTestProvider creates a provider for a server answering with handler
+func newTestProvider(t *testing.T, config map[string]interface{}, handler http.HandlerFunc) *Provider {
+	t.Helper()
+	setCredentials(t)
+	return providertest.NewProvider(t, NewProvider, config, handler).(*Provider)
+}
+
+func TestConverseRequest(t *testing.T) {
+	var request *http.Request
+	var body map[string]any
+	provider := newTestProvider(t, map[string]interface{}{"default_model": "anthropic.claude-3-haiku", "max_tokens": "512"},
+		func(w http.ResponseWriter, r *http.Request) {
+			request = r
+			json.NewDecoder(r.Body).Decode(&body)
+			fmt.Fprint(w, `{"output":{"message":{"role":"assistant","content":[{"text":"Hi"}]}},"stopReason":"end_turn",
+				"usage":{"inputTokens":7,"outputTokens":2,"totalTokens":9},"metrics":{"latencyMs":1}}`)
+		})
+
+	response, err := provider.Chat(simpleai.ChatRequest{
+		SystemPrompt: simpleai.SystemPrompt{Content: "Be brief"},
+		Messages: []simpleai.Message{
+			{Role: "user", Content: "Hello"},
+			{Role: "user", Content: "Anyone there?"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if response.Message != "Hi" || response.Usage == nil || response.Usage.TotalTokens != 9 {
+		t.Errorf("Unexpected response %+v, usage %+v", response, response.Usage)
+	}
+
+	if request.URL.Path != "/model/anthropic.claude-3-haiku/converse" {
+		t.Errorf("Unexpected path %s", request.URL.Path)
+	}
+	authorization := request.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDTEST/") || !strings.Contains(authorization, "/eu-central-1/bedrock/aws4_request") {
+		t.Errorf("Expected a SigV4 signature for eu-central-1, got %q", authorization)
+	}
+
+	messages, _ := body["messages"].([]any)
+	if len(messages) != 1 || len(messages[0].(map[string]any)["content"].([]any)) != 2 {
+		t.Errorf("Expected consecutive user messages to be merged, got %v", body["messages"])
+	}
+	if system, _ := body["system"].([]any); len(system) != 1 {
+		t.Errorf("Expected one system block, got %v", body["system"])
+	}
+	if config, _ := body["inferenceConfig"].(map[string]any); config["maxTokens"] != float64(512) {
+		t.Errorf("Expected maxTokens 512, got %v", body["inferenceConfig"])
+	}
+}
+
+func TestToolUse(t *testing.T) {
+	var body map[string]any
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"output":{"message":{"role":"assistant","content":[
+			{"toolUse":{"toolUseId":"tu_1","name":"get_weather","input":{"city":"Oslo"}}}]}},"stopReason":"tool_use"}`)
+	})
+
+	response, err := provider.Chat(simpleai.ChatRequest{
+		Messages: []simpleai.Message{
+			{Role: "user", Content: "Weather in Bergen and Oslo?"},
+			{Role: "assistant", ToolCalls: []simpleai.ToolCall{{ID: "tu_0", Name: "get_weather", Arguments: `{"city":"Bergen"}`}}},
+			{Role: "tool", ToolCallID: "tu_0", Content: "rain"},
+		},
+		Tools:      []simpleai.Tool{{Name: "get_weather", Description: "Current weather"}},
+		ToolChoice: "required",
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if len(response.ToolCalls) != 1 || response.ToolCalls[0].ID != "tu_1" || response.ToolCalls[0].Arguments != `{"city":"Oslo"}` {
+		t.Errorf("Unexpected tool calls %+v", response.ToolCalls)
+	}
+
+	data, _ := json.Marshal(body)
+	for _, want := range []string{
+		`"toolUse":{"input":{"city":"Bergen"},"name":"get_weather","toolUseId":"tu_0"}`,
+		`"toolResult":{"content":[{"text":"rain"}],"toolUseId":"tu_0"}`,
+		`"toolChoice":{"any":{}}`,
+		`"inputSchema":{"json":{"properties":{},"type":"object"}}`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %s in request %s", want, data)
+		}
+	}
+}
+
+func TestConvertMessagesStartsWithUser(t *testing.T) {
+	_, messages := convertMessages(simpleai.ChatRequest{
+		Messages: []simpleai.Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "assistant", Content: "Hi! How can I help?"},
+			{Role: "user", Content: "Weather in Oslo?"},
+		},
+	})
+
+	roles := []types.ConversationRole{}
+	for _, message := range messages {
+		roles = append(roles, message.Role)
+	}
+	if fmt.Sprint(roles) != "[user assistant user]" {
+		t.Fatalf("Expected a user turn before the assistant's greeting, got %v", roles)
+	}
+	if messageText(messages[0]) != conversationStart || messageText(messages[1]) != "Hi! How can I help?" {
+		t.Errorf("Expected the greeting to be kept after a placeholder, got %+v", messages)
+	}
+}
+
+func TestStreamToolUse(t *testing.T) {
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		writeEvent(w, "contentBlockDelta", map[string]any{"contentBlockIndex": 0, "delta": map[string]any{"text": "Checking"}})
+		writeEvent(w, "contentBlockStart", map[string]any{"contentBlockIndex": 1, "start": map[string]any{"toolUse": map[string]any{"toolUseId": "tu_1", "name": "get_weather"}}})
+		writeEvent(w, "contentBlockDelta", map[string]any{"contentBlockIndex": 1, "delta": map[string]any{"toolUse": map[string]any{"input": `{"city":`}}})
+		writeEvent(w, "contentBlockDelta", map[string]any{"contentBlockIndex": 1, "delta": map[string]any{"toolUse": map[string]any{"input": `"Oslo"}`}}})
+		writeEvent(w, "messageStop", map[string]any{"stopReason": "tool_use"})
+		writeEvent(w, "metadata", map[string]any{"usage": map[string]int{"inputTokens": 3, "outputTokens": 4, "totalTokens": 7}, "metrics": map[string]int{"latencyMs": 1}})
+	})
+
+	response, err := provider.ChatStream(context.Background(), simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Weather?"}}},
+		func(simpleai.StreamChunk) error { return nil })
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+	if response.Message != "Checking" || len(response.ToolCalls) != 1 || response.ToolCalls[0].Arguments != `{"city":"Oslo"}` {
+		t.Errorf("Unexpected response %+v", response)
+	}
+	if response.Usage == nil || response.Usage.TotalTokens != 7 {
+		t.Errorf("Expected usage from the metadata event, got %+v", response.Usage)
+	}
+}
+
+func TestThrottlingIsRetried(t *testing.T) {
+	calls := 0
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("X-Amzn-ErrorType", "ThrottlingException")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"message":"Too many requests, please wait before trying again."}`)
+			return
+		}
+		fmt.Fprint(w, `{"output":{"message":{"role":"assistant","content":[{"text":"ok"}]}},"stopReason":"end_turn"}`)
+	})
+	provider.UpdateRetryConfig(&simpleai.RetryConfig{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffFactor: 1})
+
+	response, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hello"}}})
+	if err != nil || response.Message != "ok" || calls != 2 {
+		t.Errorf("Expected a retry after throttling, got %q, %v after %d calls", response.Message, err, calls)
+	}
+}
+
+func TestGuardrailIntervened(t *testing.T) {
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"output":{"message":{"role":"assistant","content":[{"text":"Sorry."}]}},"stopReason":"guardrail_intervened"}`)
+	})
+
+	_, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hello"}}})
+	if !errors.Is(err, simpleai.ErrContentFiltered) || simpleai.IsRetryable(err) {
+		t.Errorf("Expected a non-retryable ErrContentFiltered, got %v", err)
+	}
+}
+
+func TestIsAvailableDoesNotChat(t *testing.T) {
+	var paths []string
+	denied := false
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if denied {
+			w.Header().Set("X-Amzn-ErrorType", "AccessDeniedException")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"not authorized to perform bedrock:ListAsyncInvokes"}`)
+			return
+		}
+		fmt.Fprint(w, `{"asyncInvokeSummaries":[]}`)
+	})
+
+	if !provider.IsAvailable() {
+		t.Error("Expected the provider to be available")
+	}
+	denied = true
+	if !provider.IsAvailable() {
+		t.Error("Expected a denied but authenticated request to count as available")
+	}
+	for _, path := range paths {
+		if path != "GET /async-invoke" {
+			t.Errorf("Expected only async invoke listings, got %s", path)
+		}
+	}
+}
+
+func TestNewProviderRequiresRegion(t *testing.T) {
+	setCredentials(t)
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	if _, err := NewProvider(map[string]interface{}{}); !errors.Is(err, simpleai.ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig without a region, got %v", err)
+	}
+	provider, err := NewProvider(map[string]interface{}{"region": "us-west-2"})
+	if err != nil || provider.(*Provider).Region() != "us-west-2" {
+		t.Errorf("Expected the configured region, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package bedrock
+
+import (
+	"context"
+	"simpleai"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// ChatStream streams a chat request, calling handler with each chunk of the response
+func (p *Provider) ChatStream(ctx context.Context, request simpleai.ChatRequest, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	return p.ChatStreamWithRetry(ctx, request, p.retryConfig, handler)
+}
+
+// ChatStreamWithRetry streams a chat request with retry logic. Attempts are only retried
+// until the first chunk reaches handler, so a response is never delivered twice. Tool use
+// blocks are assembled from their deltas and returned in the response.
+func (p *Provider) ChatStreamWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		text := ""
+		started := false
+		var usage *simpleai.Usage
+		var stopReason types.StopReason
+		calls := map[int32]*simpleai.ToolCall{}
+		var order []int32
+
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		output, err := p.client.ConverseStream(ctx, p.buildStreamInput(request))
+		if err != nil {
+			return p.classifyError(err, attempt, "chat_stream")
+		}
+		stream := output.GetStream()
+		defer stream.Close()
+
+		fail := func(err error) error {
+			if started {
+				return simpleai.StopRetrying(err)
+			}
+			return err
+		}
+
+		for event := range stream.Events() {
+			switch e := event.(type) {
+			case *types.ConverseStreamOutputMemberContentBlockStart:
+				if start, ok := e.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
+					index := aws.ToInt32(e.Value.ContentBlockIndex)
+					calls[index] = &simpleai.ToolCall{
+						ID:   aws.ToString(start.Value.ToolUseId),
+						Name: aws.ToString(start.Value.Name),
+					}
+					order = append(order, index)
+				}
+			case *types.ConverseStreamOutputMemberContentBlockDelta:
+				switch delta := e.Value.Delta.(type) {
+				case *types.ContentBlockDeltaMemberToolUse:
+					if call, ok := calls[aws.ToInt32(e.Value.ContentBlockIndex)]; ok {
+						call.Arguments += aws.ToString(delta.Value.Input)
+					}
+				case *types.ContentBlockDeltaMemberText:
+					if delta.Value == "" {
+						continue
+					}
+					text += delta.Value
+					started = true
+					if err := handler(simpleai.StreamChunk{Delta: delta.Value, Text: text}); err != nil {
+						// The caller stopped the stream; keep its error as the cause
+						return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+							"stream handler failed", "chat_stream", false, attempt, err)
+					}
+				}
+			case *types.ConverseStreamOutputMemberMessageStop:
+				stopReason = e.Value.StopReason
+			case *types.ConverseStreamOutputMemberMetadata:
+				usage = convertUsage(e.Value.Usage)
+			}
+		}
+		if err := stream.Err(); err != nil {
+			return fail(p.classifyError(err, attempt, "chat_stream"))
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// The SDK closes the event channel without an error when the context ends
+			return fail(p.classifyError(ctxErr, attempt, "chat_stream"))
+		}
+		if filtered(stopReason) {
+			// Whatever was streamed so far is incomplete
+			return fail(p.filteredError(attempt, "chat_stream"))
+		}
+
+		var toolCalls []simpleai.ToolCall
+		for _, index := range order {
+			call := *calls[index]
+			if call.Arguments == "" {
+				call.Arguments = "{}"
+			}
+			toolCalls = append(toolCalls, call)
+		}
+
+		if text == "" && len(toolCalls) == 0 {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from Bedrock API",
+				"chat_stream", true, attempt, nil).WithProvider("bedrock", p.defaultModel)
+		}
+
+		if err := handler(simpleai.StreamChunk{Text: text, Done: true}); err != nil {
+			return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+				"stream handler failed", "chat_stream", false, attempt, err)
+		}
+
+		if len(toolCalls) > 0 {
+			response = simpleai.ChatResponse{Message: text, ToolCalls: toolCalls, Usage: usage}
+			return nil
+		}
+
+		structuredData, err := simpleai.ParseStructuredOutput(text, request.T, request.Extractor, attempt)
+		if err != nil {
+			// The response has already been streamed, so asking again would repeat it
+			return simpleai.StopRetrying(err)
+		}
+
+		response = simpleai.ChatResponse{Message: text, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	return response, nil
+}
@@ -0,0 +1,68 @@
+// Package exec implements simpleai.Provider by running a plugin process and talking to it
+// with JSON-RPC 2.0 over stdin and stdout, so providers can be written in any language.
+//
+// The provider is configured like any other, typically from FactoryConfig:
+//
+//	factory.RegisterProvider("my-llm", exec.NewProvider)
+//
+//	config.Providers["my-llm"] = simpleai.ProviderConfig{
+//		DefaultModel:  "my-model",
+//		Timeout:       30,
+//		ExtraSettings: map[string]string{"command": "/usr/local/bin/my-llm-plugin", "args": "--verbose"},
+//	}
+//
+// # Protocol
+//
+// Each message is a single line of JSON terminated by a newline. The provider writes
+// requests to the plugin's stdin and reads responses from its stdout; the plugin's stderr is
+// passed through for logging. Requests may be sent concurrently, so responses can arrive in
+// any order and are matched by id.
+//
+// The plugin is started on the first call, which is always initialize:
+//
+//	→ {"jsonrpc":"2.0","id":1,"method":"initialize","params":{"config":{"default_model":"my-model","api_key":"..."}}}
+//	← {"jsonrpc":"2.0","id":1,"result":{}}
+//
+// config holds the provider's configuration except command, args and dir, which only the
+// provider uses. The other methods are:
+//
+//   - chat: params are a ChatRequest ({"system_prompt", "messages", "tools", "tool_choice"})
+//     plus "model" and, when a structured output target is set, its JSON Schema as
+//     "schema". The result is a ChatResponse: {"message", "tool_calls", "usage"}. The
+//     provider decodes structured output from the message itself.
+//   - list_models: no params; the result is a list of models, [{"name": "my-model"}].
+//   - is_available: no params; the result is true or false.
+//   - features: no params; the result is a ProviderFeatures object. Streaming is not
+//     supported over the protocol and is always reported as false.
+//
+// Plugins that do not implement list_models, is_available or features answer with the
+// standard "method not found" error (-32601); the provider then falls back to the default
+// model, to the process running, and to default features.
+//
+// When a request times out or its context is cancelled, the provider sends a cancel
+// notification, which plugins may use to stop work:
+//
+//	→ {"jsonrpc":"2.0","method":"cancel","params":{"id":7}}
+//
+// # Errors
+//
+// Errors use the JSON-RPC error object. data classifies the error so it can be retried
+// like errors from built-in providers:
+//
+//	← {"jsonrpc":"2.0","id":7,"error":{"code":-32000,"message":"slow down",
+//	     "data":{"type":"rate_limit_exceeded","retryable":true,"retry_after":2.5,"status_code":429}}}
+//
+// type is one of connection_failed, timeout, invalid_response, json_parse_failed,
+// model_not_available, rate_limit_exceeded, invalid_config, operation_failed or
+// content_filtered. retry_after is in seconds. Without a type, status_code classifies the
+// error like an HTTP status, and errors without data are non-retryable operation failures.
+//
+// # Supervision
+//
+// If the plugin exits, calls in flight fail with a retryable ErrConnectionFailed and the
+// next call starts it again. After consecutive crashes the restart is delayed, doubling from
+// 100ms up to 10s, until a call succeeds. Each attempt is limited by the configured timeout;
+// a plugin that lets three calls in a row time out is considered hung and is killed, so the
+// next call starts a new one. While one call starts the plugin, other calls wait for it
+// only as long as their own contexts allow.
+package exec
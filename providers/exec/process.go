@@ -0,0 +1,207 @@
+package exec
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	osexec "os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JSON-RPC error codes with a meaning to the provider
+const codeMethodNotFound = -32601
+
+// errExited is reported for calls that were in flight when the plugin exited
+var errExited = errors.New("plugin process exited")
+
+// outputDelay bounds how long the plugin's output is waited for after it exits, since a
+// child it started may keep stdout open
+const outputDelay = time.Second
+
+// request is a JSON-RPC request, or a notification when ID is zero
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int64  `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response
+type response struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// rpcError is a JSON-RPC error object
+type rpcError struct {
+	Code    int        `json:"code"`
+	Message string     `json:"message"`
+	Data    *errorData `json:"data"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// errorData classifies a plugin error
+type errorData struct {
+	Type       string  `json:"type"`
+	Retryable  bool    `json:"retryable"`
+	RetryAfter float64 `json:"retry_after"` // Seconds
+	StatusCode int     `json:"status_code"`
+}
+
+// process is one run of the plugin. Responses are read by a goroutine and handed to the
+// call waiting for their id.
+type process struct {
+	cmd   *osexec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex // Serializes writes so lines do not interleave
+
+	mu      sync.Mutex
+	pending map[int64]chan response
+
+	done chan struct{} // Closed when the process has exited
+	err  error         // Why the process exited, set before done is closed
+
+	timeouts atomic.Int32 // Calls timed out in a row without a response in between
+}
+
+// startProcess starts the plugin and begins reading its responses
+func startProcess(cmd *osexec.Cmd) (*process, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	// Unlike StdoutPipe, a pipe of our own is closed by Wait after WaitDelay even if another
+	// process holds the plugin's stdout
+	stdout, output := io.Pipe()
+	cmd.Stdout = output
+	cmd.WaitDelay = outputDelay
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	proc := &process{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan response),
+		done:    make(chan struct{}),
+	}
+	reads := make(chan error, 1)
+	go func() { reads <- proc.read(stdout) }()
+	go proc.wait(output, reads)
+	return proc, nil
+}
+
+// read delivers responses until stdout is closed and returns why reading stopped, or nil
+// at the end of the output
+func (proc *process) read(stdout io.Reader) error {
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var resp response
+			if json.Unmarshal(line, &resp) == nil && resp.ID != 0 {
+				proc.deliver(resp)
+			}
+			// Anything else on stdout is not part of the protocol and is ignored
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// wait waits for the process to exit and its output to be read, then closes done
+func (proc *process) wait(output *io.PipeWriter, reads <-chan error) {
+	err := proc.cmd.Wait()
+	output.Close()
+	if readErr := <-reads; err == nil {
+		err = readErr
+	}
+	if err == nil {
+		err = errExited
+	}
+	proc.err = err
+	close(proc.done)
+}
+
+// deliver hands a response to the call waiting for it, if it is still waiting
+func (proc *process) deliver(resp response) {
+	proc.mu.Lock()
+	ch, ok := proc.pending[resp.ID]
+	delete(proc.pending, resp.ID)
+	proc.mu.Unlock()
+
+	if ok {
+		ch <- resp
+	}
+}
+
+// register returns the channel the response to id will be delivered on
+func (proc *process) register(id int64) chan response {
+	ch := make(chan response, 1)
+	proc.mu.Lock()
+	proc.pending[id] = ch
+	proc.mu.Unlock()
+	return ch
+}
+
+// unregister stops waiting for the response to id
+func (proc *process) unregister(id int64) {
+	proc.mu.Lock()
+	delete(proc.pending, id)
+	proc.mu.Unlock()
+}
+
+// send writes a message to the plugin's stdin
+func (proc *process) send(msg request) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	proc.writeMu.Lock()
+	defer proc.writeMu.Unlock()
+	_, err = proc.stdin.Write(data)
+	return err
+}
+
+// exited reports whether the process has exited
+func (proc *process) exited() bool {
+	select {
+	case <-proc.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill kills the process and waits for it to exit
+func (proc *process) kill() {
+	proc.cmd.Process.Kill()
+	<-proc.done
+}
+
+// stop closes stdin so the plugin can exit cleanly, killing it if it has not exited
+// within grace
+func (proc *process) stop(grace time.Duration) {
+	proc.stdin.Close()
+	select {
+	case <-proc.done:
+	case <-time.After(grace):
+		proc.cmd.Process.Kill()
+		<-proc.done
+	}
+}
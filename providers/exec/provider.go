@@ -0,0 +1,501 @@
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"simpleai"
+	"simpleai/schema"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Restart delays after consecutive crashes
+const (
+	minRestartDelay = 100 * time.Millisecond
+	maxRestartDelay = 10 * time.Second
+)
+
+// maxTimeouts is how many calls in a row may time out before the plugin is considered hung
+// and killed, so the next call starts a new process
+const maxTimeouts = 3
+
+// Provider implements the simpleai.Provider interface by delegating to a plugin process
+type Provider struct {
+	name         string
+	command      string
+	args         []string
+	dir          string
+	settings     map[string]any // Sent to the plugin with initialize
+	defaultModel string
+	timeout      int
+	retryConfig  *simpleai.RetryConfig
+
+	mu       sync.Mutex // Guards proc, starting and stops
+	proc     *process
+	starting chan struct{} // Closed when the start in progress ends; nil if none
+	stops    int           // Incremented by Close, so a start it overlapped is undone
+	crashes  atomic.Int32  // Consecutive crashes without a response in between
+	nextID   atomic.Int64
+
+	featuresMu sync.Mutex
+	features   *simpleai.ProviderFeatures
+}
+
+// Config holds the settings of an exec provider. Settings it does not declare are passed to
+// the plugin along with the common ones.
+type Config struct {
+	simpleai.ProviderSettings
+	Command string                 `json:"command,omitempty" description:"Plugin executable (required)"`
+	Args    []string               `json:"args,omitempty" description:"Plugin arguments, a list or a space-separated string"`
+	Dir     string                 `json:"dir,omitempty" description:"Working directory of the plugin"`
+	Name    string                 `json:"name,omitempty" description:"Provider name; defaults to exec"`
+	Plugin  map[string]interface{} `json:"-" config:"remain"` // Settings defined by the plugin
+}
+
+// NewProvider creates a provider for a plugin. config must set "command" to the plugin
+// executable and may set "args" (a list, or a space-separated string from ExtraSettings),
+// "dir" for its working directory and "name" for the provider name ("exec" by default).
+// The plugin is started on the first call.
+func NewProvider(config map[string]interface{}) (simpleai.Provider, error) {
+	var settings Config
+	if err := simpleai.DecodeProviderConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	command := settings.Command
+	if command == "" {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			"command is required for exec provider",
+			"provider_creation", false, 0, nil)
+	}
+	if _, err := osexec.LookPath(command); err != nil {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			fmt.Sprintf("plugin command %q not found", command),
+			"provider_creation", false, 0, err)
+	}
+
+	name := "exec"
+	if settings.Name != "" {
+		name = settings.Name
+	}
+
+	timeout := 60
+	if settings.Timeout > 0 {
+		timeout = settings.Timeout
+	}
+
+	retryAttempts := 3
+	if r := settings.RetryAttempts; r != nil && *r >= 0 {
+		retryAttempts = *r
+	}
+
+	// Create retry configuration
+	retryConfig := simpleai.DefaultRetryConfig()
+	retryConfig.MaxRetries = retryAttempts
+
+	// The plugin gets the rest of the configuration, leaving out values JSON cannot carry
+	pluginSettings := make(map[string]any, len(config))
+	for key, value := range config {
+		switch key {
+		case "command", "args", "dir":
+			continue
+		}
+		if _, err := json.Marshal(value); err == nil {
+			pluginSettings[key] = value
+		}
+	}
+
+	return &Provider{
+		name:         name,
+		command:      command,
+		args:         settings.Args,
+		dir:          settings.Dir,
+		settings:     pluginSettings,
+		defaultModel: settings.DefaultModel,
+		timeout:      timeout,
+		retryConfig:  retryConfig,
+	}, nil
+}
+
+// chatParams are the params of a chat call
+type chatParams struct {
+	simpleai.ChatRequest
+	Model  string        `json:"model,omitempty"`
+	Schema schema.Schema `json:"schema,omitempty"`
+}
+
+// Chat sends a chat request and returns a response
+func (p *Provider) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatWithRetry(request, p.retryConfig)
+}
+
+// ChatContext sends a chat request, giving up when ctx is done
+func (p *Provider) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(ctx, request, p.retryConfig)
+}
+
+// ChatWithRetry executes a chat request with retry logic
+func (p *Provider) ChatWithRetry(request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(context.Background(), request, retryConfig)
+}
+
+// ChatContextWithRetry executes a cancellable chat request with retry logic. Responses that
+// use tools are returned without decoding structured output.
+func (p *Provider) ChatContextWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+
+	params := chatParams{ChatRequest: request, Model: p.defaultModel}
+	if request.T != nil {
+		params.Schema = schema.For(request.T)
+	}
+
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		var result simpleai.ChatResponse
+		if err := p.call(ctx, "chat", params, &result, attempt, "chat"); err != nil {
+			return err
+		}
+
+		if len(result.ToolCalls) > 0 {
+			response = simpleai.ChatResponse{Message: result.Message, ToolCalls: result.ToolCalls, Usage: result.Usage}
+			return nil
+		}
+		if result.Message == "" {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from plugin",
+				"chat", true, attempt, nil).WithProvider(p.name, p.defaultModel)
+		}
+
+		structuredData, err := simpleai.ParseStructuredOutput(result.Message, request.T, request.Extractor, attempt)
+		if err != nil {
+			return err
+		}
+
+		response = simpleai.ChatResponse{Message: result.Message, Data: structuredData, Usage: result.Usage}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	return response, nil
+}
+
+// ListModels returns the models the plugin offers, or the default model if it does not
+// implement list_models
+func (p *Provider) ListModels() ([]simpleai.Model, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.timeout)*time.Second)
+	defer cancel()
+
+	var models []simpleai.Model
+	err := p.call(ctx, "list_models", nil, &models, 0, "list_models")
+	if methodNotFound(err) {
+		if p.defaultModel == "" {
+			return nil, nil
+		}
+		return []simpleai.Model{{Name: p.defaultModel}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// Name returns the provider's name
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// IsAvailable checks if the plugin is running and reports itself available
+func (p *Provider) IsAvailable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var available bool
+	err := p.call(ctx, "is_available", nil, &available, 0, "health_check")
+	if methodNotFound(err) {
+		// The plugin started and answered, which is all that can be checked
+		return true
+	}
+	return err == nil && available
+}
+
+// SupportedFeatures returns the capabilities the plugin reports, asking it once
+func (p *Provider) SupportedFeatures() simpleai.ProviderFeatures {
+	p.featuresMu.Lock()
+	defer p.featuresMu.Unlock()
+
+	if p.features != nil {
+		return *p.features
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var features simpleai.ProviderFeatures
+	err := p.call(ctx, "features", nil, &features, 0, "features")
+	switch {
+	case methodNotFound(err):
+		features = defaultFeatures()
+	case err != nil:
+		// Ask again next time, the plugin may not be running yet
+		return defaultFeatures()
+	}
+	features.Streaming = false // Not part of the protocol
+	p.features = &features
+	return features
+}
+
+// defaultFeatures are reported for plugins that do not describe themselves
+func defaultFeatures() simpleai.ProviderFeatures {
+	return simpleai.ProviderFeatures{
+		StructuredOutput: true, // Structured JSON output via extraction
+		SupportedRoles:   []string{"system", "user", "assistant"},
+	}
+}
+
+// GetDefaultModel returns the default model for this provider
+func (p *Provider) GetDefaultModel() string {
+	return p.defaultModel
+}
+
+// GetRetryConfig returns the retry configuration for this provider
+func (p *Provider) GetRetryConfig() *simpleai.RetryConfig {
+	return p.retryConfig
+}
+
+// UpdateRetryConfig allows updating the retry configuration
+func (p *Provider) UpdateRetryConfig(config *simpleai.RetryConfig) {
+	if config != nil {
+		p.retryConfig = config
+	}
+}
+
+// Close stops the plugin process. A later call starts it again.
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	proc := p.proc
+	p.proc = nil
+	p.stops++
+	p.mu.Unlock()
+
+	if proc != nil {
+		proc.stop(5 * time.Second)
+	}
+	return nil
+}
+
+// call makes a JSON-RPC call to the plugin, starting it if needed, and decodes the result
+// into result, if result is non-nil
+func (p *Provider) call(ctx context.Context, method string, params, result any, attempt int, operation string) error {
+	proc, err := p.running(ctx, attempt, operation)
+	if err != nil {
+		return err
+	}
+	return p.roundTrip(ctx, proc, method, params, result, attempt, operation)
+}
+
+// running returns the plugin process, starting and initializing it if it is not running.
+// One caller starts the plugin while the others wait for it, each giving up when its own
+// context is done.
+func (p *Provider) running(ctx context.Context, attempt int, operation string) (*process, error) {
+	for {
+		p.mu.Lock()
+		if p.proc != nil {
+			if !p.proc.exited() {
+				proc := p.proc
+				p.mu.Unlock()
+				return proc, nil
+			}
+			// The plugin exited since the last call
+			p.crashes.Add(1)
+			p.proc = nil
+		}
+
+		if starting := p.starting; starting != nil {
+			p.mu.Unlock()
+			select {
+			case <-starting:
+				// Use the plugin it started, or try again if starting failed
+				continue
+			case <-ctx.Done():
+				return nil, p.contextError(ctx.Err(), attempt, operation)
+			}
+		}
+
+		starting := make(chan struct{})
+		p.starting = starting
+		stops := p.stops
+		p.mu.Unlock()
+
+		proc, err := p.start(ctx, attempt, operation)
+
+		p.mu.Lock()
+		p.starting = nil
+		closed := p.stops != stops
+		if err == nil && !closed {
+			p.proc = proc
+		}
+		p.mu.Unlock()
+		close(starting)
+
+		if err == nil && closed {
+			proc.stop(time.Second)
+			return nil, simpleai.NewLLMError(simpleai.ErrConnectionFailed,
+				"plugin process was stopped while starting", operation, true, attempt, nil).
+				WithProvider(p.name, p.defaultModel)
+		}
+		return proc, err
+	}
+}
+
+// start starts and initializes a plugin process. Restarts after crashes are delayed so a
+// plugin that keeps crashing is not spun.
+func (p *Provider) start(ctx context.Context, attempt int, operation string) (*process, error) {
+	if crashes := p.crashes.Load(); crashes > 0 {
+		timer := time.NewTimer(restartDelay(int(crashes)))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, p.contextError(ctx.Err(), attempt, operation)
+		}
+	}
+
+	cmd := osexec.Command(p.command, p.args...)
+	cmd.Dir = p.dir
+	cmd.Stderr = os.Stderr
+	proc, err := startProcess(cmd)
+	if err != nil {
+		p.crashes.Add(1)
+		return nil, simpleai.NewLLMError(simpleai.ErrConnectionFailed,
+			"cannot start plugin process", operation, true, attempt, err).
+			WithProvider(p.name, p.defaultModel)
+	}
+
+	initParams := map[string]any{"config": p.settings}
+	if err := p.roundTrip(ctx, proc, "initialize", initParams, nil, attempt, operation); err != nil {
+		proc.stop(time.Second)
+		if !errors.Is(err, simpleai.ErrInvalidConfig) {
+			p.crashes.Add(1)
+		}
+		return nil, err
+	}
+	return proc, nil
+}
+
+// restartDelay doubles from minRestartDelay with each consecutive crash
+func restartDelay(crashes int) time.Duration {
+	delay := minRestartDelay
+	for i := 1; i < crashes && delay < maxRestartDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRestartDelay)
+}
+
+// roundTrip sends a request to proc and waits for its response
+func (p *Provider) roundTrip(ctx context.Context, proc *process, method string, params, result any, attempt int, operation string) error {
+	id := p.nextID.Add(1)
+	ch := proc.register(id)
+	defer proc.unregister(id)
+
+	if err := proc.send(request{JSONRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		return simpleai.NewLLMError(simpleai.ErrConnectionFailed,
+			"cannot write to plugin process", operation, true, attempt, err).
+			WithProvider(p.name, p.defaultModel)
+	}
+
+	var resp response
+	select {
+	case resp = <-ch:
+	case <-proc.done:
+		// A response may have been delivered just before the process exited
+		select {
+		case resp = <-ch:
+		default:
+			return simpleai.NewLLMError(simpleai.ErrConnectionFailed,
+				"plugin process exited", operation, true, attempt, proc.err).
+				WithProvider(p.name, p.defaultModel)
+		}
+	case <-ctx.Done():
+		// Let the plugin stop working on the request; it may have exited already
+		proc.send(request{JSONRPC: "2.0", Method: "cancel", Params: map[string]int64{"id": id}})
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && proc.timeouts.Add(1) >= maxTimeouts {
+			// A plugin that stays alive but stops answering is restarted like a crashed one
+			proc.kill()
+		}
+		return p.contextError(ctx.Err(), attempt, operation)
+	}
+
+	// Any response shows the plugin is healthy again
+	p.crashes.Store(0)
+	proc.timeouts.Store(0)
+
+	if resp.Error != nil {
+		return p.convertError(resp.Error, attempt, operation)
+	}
+	if result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				fmt.Sprintf("invalid %s result from plugin", method), operation, true, attempt, err).
+				WithProvider(p.name, p.defaultModel)
+		}
+	}
+	return nil
+}
+
+// contextError classifies a cancelled or timed out call
+func (p *Provider) contextError(err error, attempt int, operation string) *simpleai.LLMError {
+	errType, retryable, _ := simpleai.ClassifyTransportError(err)
+	message := "request cancelled"
+	if errType == simpleai.ErrTimeout {
+		message = "plugin did not respond in time"
+	}
+	return simpleai.NewLLMError(errType, message, operation, retryable, attempt, err).
+		WithProvider(p.name, p.defaultModel)
+}
+
+// convertError classifies an error reported by the plugin from its data
+func (p *Provider) convertError(rpcErr *rpcError, attempt int, operation string) *simpleai.LLMError {
+	message := fmt.Sprintf("plugin error: %s", rpcErr.Message)
+	data := rpcErr.Data
+
+	var llmErr *simpleai.LLMError
+	switch {
+	case data == nil:
+		llmErr = simpleai.NewLLMError(simpleai.ErrOperationFailed, message, operation, false, attempt, rpcErr)
+	case data.Type != "":
+		errType, ok := simpleai.ErrorByName(data.Type)
+		if !ok {
+			errType = simpleai.ErrOperationFailed
+		}
+		llmErr = simpleai.NewLLMError(errType, message, operation, data.Retryable, attempt, rpcErr)
+	case data.StatusCode != 0:
+		llmErr = simpleai.NewStatusError(data.StatusCode, message, operation, attempt, rpcErr)
+	default:
+		llmErr = simpleai.NewLLMError(simpleai.ErrOperationFailed, message, operation, data.Retryable, attempt, rpcErr)
+	}
+
+	if data != nil {
+		if data.StatusCode != 0 {
+			llmErr = llmErr.WithStatusCode(data.StatusCode)
+		}
+		if data.RetryAfter > 0 {
+			llmErr = llmErr.WithRetryAfter(time.Duration(data.RetryAfter * float64(time.Second)))
+		}
+	}
+	return llmErr.WithProvider(p.name, p.defaultModel)
+}
+
+// methodNotFound reports whether err is the plugin saying it does not implement a method
+func methodNotFound(err error) bool {
+	var rpcErr *rpcError
+	return errors.As(err, &rpcErr) && rpcErr.Code == codeMethodNotFound
+}
@@ -0,0 +1,335 @@
+package exec
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"simpleai"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pluginEnv makes the test binary act as a plugin when it is started by the provider
+const pluginEnv = "SIMPLEAI_EXEC_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(pluginEnv) != "" {
+		runPlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runPlugin answers requests on stdin. The last message of a chat picks the behaviour:
+// "crash" exits, "crash-once:<file>" exits unless the file exists, "hang" never answers,
+// "throttle" fails with a rate limit, "pid" and "config" report on the process, "spawn"
+// starts a child holding stdout and reports its pid, and anything else is echoed. An "init_delay" setting delays initialize by that many seconds.
+func runPlugin() {
+	var config map[string]any
+	var writeMu sync.Mutex
+	reply := func(id int64, result any, rpcErr *rpcError) {
+		data, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "result": result, "error": rpcErr})
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		os.Stdout.Write(append(data, '\n'))
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			ID     int64           `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		json.Unmarshal(scanner.Bytes(), &req)
+
+		switch req.Method {
+		case "initialize":
+			var params struct {
+				Config map[string]any `json:"config"`
+			}
+			json.Unmarshal(req.Params, &params)
+			config = params.Config
+			if delay, ok := config["init_delay"].(float64); ok {
+				time.Sleep(time.Duration(delay * float64(time.Second)))
+			}
+			reply(req.ID, map[string]any{}, nil)
+		case "chat":
+			var params chatParams
+			json.Unmarshal(req.Params, &params)
+			last := params.Messages[len(params.Messages)-1].Content
+			switch {
+			case last == "crash":
+				os.Exit(3)
+			case len(last) > len("crash-once:") && last[:len("crash-once:")] == "crash-once:":
+				marker := last[len("crash-once:"):]
+				if _, err := os.Stat(marker); err != nil {
+					os.WriteFile(marker, nil, 0o644)
+					os.Exit(3)
+				}
+				reply(req.ID, simpleai.ChatResponse{Message: "recovered"}, nil)
+			case last == "hang":
+			case last == "throttle":
+				reply(req.ID, nil, &rpcError{Code: -32000, Message: "slow down",
+					Data: &errorData{Type: "rate_limit_exceeded", Retryable: true, RetryAfter: 2.5, StatusCode: 429}})
+			case last == "spawn":
+				child := osexec.Command("sleep", "30")
+				child.Stdout = os.Stdout
+				child.Start()
+				reply(req.ID, simpleai.ChatResponse{Message: strconv.Itoa(child.Process.Pid)}, nil)
+			case last == "pid":
+				reply(req.ID, simpleai.ChatResponse{Message: strconv.Itoa(os.Getpid())}, nil)
+			case last == "config":
+				data, _ := json.Marshal(config)
+				reply(req.ID, simpleai.ChatResponse{Message: string(data)}, nil)
+			case params.Schema != nil:
+				reply(req.ID, simpleai.ChatResponse{
+					Message: fmt.Sprintf(`{"model":%q,"schema_type":%q}`, params.Model, params.Schema["type"]),
+					Usage:   &simpleai.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5},
+				}, nil)
+			default:
+				reply(req.ID, simpleai.ChatResponse{Message: "echo: " + last}, nil)
+			}
+		case "list_models":
+			reply(req.ID, []simpleai.Model{{Name: "plugin-model"}}, nil)
+		case "features":
+			reply(req.ID, simpleai.ProviderFeatures{FunctionCalling: true, Streaming: true, SupportedRoles: []string{"user", "assistant"}}, nil)
+		case "cancel":
+		default:
+			reply(req.ID, nil, &rpcError{Code: codeMethodNotFound, Message: "method not found"})
+		}
+	}
+}
+
+// newTestProvider creates a provider running the test binary as its plugin
+func newTestProvider(t *testing.T, config map[string]interface{}) *Provider {
+	t.Helper()
+	t.Setenv(pluginEnv, "1")
+
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	config["command"] = os.Args[0]
+	provider, err := NewProvider(config)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	t.Cleanup(func() { provider.(*Provider).Close() })
+	return provider.(*Provider)
+}
+
+func chat(provider *Provider, content string) (simpleai.ChatResponse, error) {
+	return provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: content}}})
+}
+
+func TestChat(t *testing.T) {
+	provider := newTestProvider(t, map[string]interface{}{"default_model": "tiny", "api_key": "secret"})
+
+	response, err := chat(provider, "Hello")
+	if err != nil || response.Message != "echo: Hello" {
+		t.Fatalf("Expected an echo, got %q, %v", response.Message, err)
+	}
+
+	response, err = chat(provider, "config")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	var config map[string]any
+	json.Unmarshal([]byte(response.Message), &config)
+	if config["api_key"] != "secret" || config["command"] != nil {
+		t.Errorf("Expected the config without the command, got %v", config)
+	}
+
+	var target struct {
+		Model      string `json:"model"`
+		SchemaType string `json:"schema_type"`
+	}
+	response, err = provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Describe"}}, T: &target})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if target.Model != "tiny" || target.SchemaType != "object" || response.Usage == nil || response.Usage.TotalTokens != 5 {
+		t.Errorf("Expected the model and schema to reach the plugin, got %+v, usage %+v", target, response.Usage)
+	}
+}
+
+func TestModelsAvailabilityAndFeatures(t *testing.T) {
+	provider := newTestProvider(t, nil)
+
+	models, err := provider.ListModels()
+	if err != nil || len(models) != 1 || models[0].Name != "plugin-model" {
+		t.Errorf("Unexpected models %v, %v", models, err)
+	}
+	// The plugin does not implement is_available, so answering is enough
+	if !provider.IsAvailable() {
+		t.Error("Expected the plugin to be available")
+	}
+	features := provider.SupportedFeatures()
+	if !features.FunctionCalling || features.Streaming || len(features.SupportedRoles) != 2 {
+		t.Errorf("Unexpected features %+v", features)
+	}
+}
+
+func TestPluginErrors(t *testing.T) {
+	provider := newTestProvider(t, map[string]interface{}{"retry_attempts": 0})
+
+	_, err := chat(provider, "throttle")
+	var llmErr *simpleai.LLMError
+	if !errors.As(err, &llmErr) || !errors.Is(err, simpleai.ErrRateLimitExceeded) || !llmErr.Retryable {
+		t.Fatalf("Expected a retryable rate limit error, got %v", err)
+	}
+	if llmErr.RetryAfter != 2500*time.Millisecond || llmErr.StatusCode != 429 || llmErr.Provider != "exec" {
+		t.Errorf("Expected the plugin's retry hint and status, got %+v", llmErr)
+	}
+}
+
+func TestRestartAfterCrash(t *testing.T) {
+	provider := newTestProvider(t, map[string]interface{}{"retry_attempts": 0})
+
+	before, err := chat(provider, "pid")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	_, err = chat(provider, "crash")
+	if !errors.Is(err, simpleai.ErrConnectionFailed) || !simpleai.IsRetryable(err) {
+		t.Fatalf("Expected a retryable connection error, got %v", err)
+	}
+
+	after, err := chat(provider, "pid")
+	if err != nil {
+		t.Fatalf("Chat after the crash failed: %v", err)
+	}
+	if after.Message == before.Message {
+		t.Errorf("Expected a new process, got pid %s both times", after.Message)
+	}
+}
+
+func TestRetryAcrossRestart(t *testing.T) {
+	provider := newTestProvider(t, nil)
+	provider.UpdateRetryConfig(&simpleai.RetryConfig{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffFactor: 1})
+
+	marker := filepath.Join(t.TempDir(), "crashed")
+	response, err := chat(provider, "crash-once:"+marker)
+	if err != nil || response.Message != "recovered" {
+		t.Errorf("Expected the retry to reach a restarted plugin, got %q, %v", response.Message, err)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	provider := newTestProvider(t, map[string]interface{}{"timeout": 1, "retry_attempts": 0})
+
+	before, _ := chat(provider, "pid")
+	start := time.Now()
+	_, err := chat(provider, "hang")
+	if !errors.Is(err, simpleai.ErrTimeout) || time.Since(start) > 5*time.Second {
+		t.Fatalf("Expected a timeout after a second, got %v after %v", err, time.Since(start))
+	}
+
+	// A slow request does not take the plugin down
+	after, err := chat(provider, "pid")
+	if err != nil || after.Message != before.Message {
+		t.Errorf("Expected the same process to keep serving, got %q, %v", after.Message, err)
+	}
+}
+
+func TestHungPluginIsRestarted(t *testing.T) {
+	provider := newTestProvider(t, map[string]interface{}{"timeout": 1, "retry_attempts": 0})
+
+	before, err := chat(provider, "pid")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < maxTimeouts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chat(provider, "hang")
+		}()
+	}
+	wg.Wait()
+
+	after, err := chat(provider, "pid")
+	if err != nil || after.Message == before.Message {
+		t.Errorf("Expected a new process after %d timeouts in a row, got %q, %v", maxTimeouts, after.Message, err)
+	}
+}
+
+func TestChildHoldingStdoutDoesNotBlockStop(t *testing.T) {
+	if _, err := osexec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is not available")
+	}
+	provider := newTestProvider(t, nil)
+
+	for _, stop := range []func(){
+		func() {
+			provider.mu.Lock()
+			proc := provider.proc
+			provider.mu.Unlock()
+			proc.kill()
+		},
+		func() { provider.Close() },
+	} {
+		response, err := chat(provider, "spawn")
+		if err != nil {
+			t.Fatalf("Chat failed: %v", err)
+		}
+		if pid, err := strconv.Atoi(response.Message); err == nil {
+			t.Cleanup(func() {
+				if child, err := os.FindProcess(pid); err == nil {
+					child.Kill()
+				}
+			})
+		}
+
+		begin := time.Now()
+		stop()
+		if elapsed := time.Since(begin); elapsed > outputDelay+2*time.Second {
+			t.Errorf("Expected stopping to be bounded by %v, took %v", outputDelay, elapsed)
+		}
+	}
+}
+
+func TestWaitingForStartRespectsContext(t *testing.T) {
+	provider := newTestProvider(t, map[string]interface{}{"init_delay": 2, "retry_attempts": 0})
+
+	// One call starts the plugin, which takes two seconds to initialize
+	started := make(chan error, 1)
+	go func() {
+		_, err := chat(provider, "hello")
+		started <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := provider.ChatContext(ctx, simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "hello"}}})
+	if !errors.Is(err, simpleai.ErrTimeout) || time.Since(start) > time.Second {
+		t.Errorf("Expected the waiting call to time out on its own, got %v after %v", err, time.Since(start))
+	}
+
+	if err := <-started; err != nil {
+		t.Errorf("Expected the starting call to succeed, got %v", err)
+	}
+}
+
+func TestNewProviderInvalidConfig(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{},
+		{"command": "simpleai-no-such-plugin"},
+	} {
+		if _, err := NewProvider(config); !errors.Is(err, simpleai.ErrInvalidConfig) {
+			t.Errorf("Expected %v to be rejected, got %v", config, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package google
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"simpleai"
+	"simpleai/providertest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// geminiProtocol speaks the Gemini generateContent protocol for the conformance suite
+type geminiProtocol struct{}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+func (c geminiContent) text() string {
+	var text strings.Builder
+	for _, part := range c.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+func (geminiProtocol) Decode(r *http.Request) (providertest.Received, error) {
+	path := r.URL.Path
+	method := path[strings.LastIndexByte(path, ':')+1:]
+	if method != "generateContent" && method != "streamGenerateContent" {
+		return providertest.Received{}, errors.New("unknown endpoint " + path)
+	}
+
+	var request struct {
+		Contents          []geminiContent `json:"contents"`
+		SystemInstruction *geminiContent  `json:"systemInstruction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return providertest.Received{}, err
+	}
+
+	received := providertest.Received{
+		Model:  strings.TrimSuffix(path[strings.LastIndex(path, "/models/")+len("/models/"):], ":"+method),
+		Stream: method == "streamGenerateContent",
+	}
+	if request.SystemInstruction != nil {
+		received.System = request.SystemInstruction.text()
+	}
+	for _, content := range request.Contents {
+		role := content.Role
+		if role == "model" {
+			role = "assistant"
+		}
+		received.Messages = append(received.Messages, simpleai.Message{Role: role, Content: content.text()})
+	}
+	return received, nil
+}
+
+func (geminiProtocol) WriteText(w http.ResponseWriter, received providertest.Received, chunks []string) {
+	response := func(text string) map[string]any {
+		return map[string]any{
+			"candidates": []map[string]any{{
+				"content":      geminiContent{Role: "model", Parts: []geminiPart{{Text: text}}},
+				"finishReason": "STOP",
+			}},
+			"usageMetadata": map[string]int{"promptTokenCount": 5, "candidatesTokenCount": len(chunks), "totalTokenCount": 5 + len(chunks)},
+		}
+	}
+
+	if !received.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response(strings.Join(chunks, "")))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, chunk := range chunks {
+		data, _ := json.Marshal(response(chunk))
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		w.(http.Flusher).Flush()
+	}
+}
+
+func (geminiProtocol) WriteError(w http.ResponseWriter, received providertest.Received, status int, message string, retryAfter time.Duration) {
+	details := []map[string]any{}
+	if retryAfter > 0 {
+		details = append(details, map[string]any{
+			"@type":      "type.googleapis.com/google.rpc.RetryInfo",
+			"retryDelay": fmt.Sprintf("%gs", retryAfter.Seconds()),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":    status,
+			"message": message,
+			"status":  http.StatusText(status),
+			"details": details,
+		},
+	})
+}
+
+func TestConformance(t *testing.T) {
+	providertest.Run(t, providertest.Harness{
+		Protocol: geminiProtocol{},
+		NewProvider: func(t *testing.T, baseURL string) simpleai.Provider {
+			provider, err := NewProvider(map[string]interface{}{"api_key": "test-key", "host": baseURL})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			return provider
+		},
+	})
+}
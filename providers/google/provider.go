@@ -2,8 +2,9 @@ package google
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"simpleai"
 	"strings"
 	"time"
@@ -19,13 +20,22 @@ type Provider struct {
 	retryConfig  *simpleai.RetryConfig
 }
 
+// Config holds the settings of a Google provider. Google has no settings beyond the common
+// ones.
+type Config struct {
+	simpleai.ProviderSettings
+}
+
 // NewProvider creates a new Google provider instance
 func NewProvider(config map[string]interface{}) (simpleai.Provider, error) {
+	var settings Config
+	if err := simpleai.DecodeProviderConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
 	// Extract API key (required)
-	apiKey := ""
-	if key, ok := config["api_key"].(string); ok && key != "" {
-		apiKey = key
-	} else {
+	apiKey := settings.APIKey
+	if apiKey == "" {
 		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
 			"API key is required for Google provider",
 			"provider_creation", false, 0, nil)
@@ -33,38 +43,38 @@ func NewProvider(config map[string]interface{}) (simpleai.Provider, error) {
 
 	// Extract default model
 	defaultModel := "gemini-2.0-flash"
-	if m, ok := config["default_model"].(string); ok && m != "" {
-		defaultModel = m
+	if settings.DefaultModel != "" {
+		defaultModel = settings.DefaultModel
 	}
 
 	// Extract timeout
 	timeout := 60
-	if t, ok := config["timeout"].(int); ok && t > 0 {
-		timeout = t
+	if settings.Timeout > 0 {
+		timeout = settings.Timeout
 	}
 
 	// Extract retry attempts
 	retryAttempts := 3
-	if r, ok := config["retry_attempts"].(int); ok && r >= 0 {
-		retryAttempts = r
+	if r := settings.RetryAttempts; r != nil && *r >= 0 {
+		retryAttempts = *r
 	}
 
 	// Create retry configuration
-	retryConfig := &simpleai.RetryConfig{
-		MaxRetries:    retryAttempts,
-		BaseDelay:     2 * time.Second,
-		MaxDelay:      30 * time.Second,
-		BackoffFactor: 2.0,
-	}
+	retryConfig := simpleai.DefaultRetryConfig()
+	retryConfig.MaxRetries = retryAttempts
 
 	// Create context for client initialization
 	ctx := context.Background()
 
-	// Create Google Gen AI client
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+	// Create Google Gen AI client, optionally against a different endpoint
+	clientConfig := &genai.ClientConfig{
 		APIKey:  apiKey,
 		Backend: genai.BackendGeminiAPI,
-	})
+	}
+	if settings.Host != "" {
+		clientConfig.HTTPOptions.BaseURL = settings.Host
+	}
+	client, err := genai.NewClient(ctx, clientConfig)
 	if err != nil {
 		return nil, simpleai.NewLLMError(simpleai.ErrConnectionFailed,
 			"failed to create Google Gen AI client",
@@ -84,141 +94,203 @@ func (p *Provider) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, er
 	return p.ChatWithRetry(request, p.retryConfig)
 }
 
+// ChatContext sends a chat request, giving up when ctx is done
+func (p *Provider) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(ctx, request, p.retryConfig)
+}
+
 // ChatWithRetry executes a chat request with retry logic
 func (p *Provider) ChatWithRetry(request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
-	var lastErr error
-	var finalResponse string
-	var structuredData any
-	var targetType interface{} = request.T
+	return p.ChatContextWithRetry(context.Background(), request, retryConfig)
+}
 
-	for attempt := 0; attempt <= retryConfig.MaxRetries; attempt++ {
-		// Calculate delay for retry (exponential backoff)
-		if attempt > 0 {
-			delay := time.Duration(float64(retryConfig.BaseDelay) * pow(retryConfig.BackoffFactor, attempt-1))
-			if delay > retryConfig.MaxDelay {
-				delay = retryConfig.MaxDelay
-			}
-			time.Sleep(delay)
-		}
+// ChatContextWithRetry executes a cancellable chat request with retry logic
+func (p *Provider) ChatContextWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+	var targetType interface{} = request.T
 
-		// Reset response for each attempt
-		finalResponse = ""
-		structuredData = nil
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		finalResponse := ""
 
 		// Create context with timeout
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.timeout)*time.Second)
-
-		// Convert messages to genai.Content format
-		contents := convertMessages(request.Messages, request.SystemPrompt)
-
-		// Create generation config with system instruction if provided
-		var genConfig *genai.GenerateContentConfig
-		if request.SystemPrompt.Content != "" {
-			// Create Content for system instruction
-			systemContent := genai.NewContentFromText(request.SystemPrompt.Content, genai.RoleUser)
-			genConfig = &genai.GenerateContentConfig{
-				SystemInstruction: systemContent,
-			}
-		}
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		contents, genConfig := buildContents(request)
 
 		// Call GenerateContent
 		resp, err := p.client.Models.GenerateContent(ctx, p.defaultModel, contents, genConfig)
-		cancel()
-
 		if err != nil {
-			lastErr = p.classifyError(err, attempt, "chat")
-			if attempt < retryConfig.MaxRetries && simpleai.IsRetryable(lastErr) {
-				continue // Retry
-			}
-			break // No more retries or non-retryable error
+			return p.classifyError(err, attempt, "chat")
 		}
 
 		// Extract text from response
+		var usage *simpleai.Usage
 		if resp != nil {
 			finalResponse = resp.Text()
+			usage = usageFromMetadata(resp.UsageMetadata)
 		}
 
 		if finalResponse == "" {
-			lastErr = simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
 				"empty response from Google API",
-				"chat", true, attempt, nil)
-			if attempt < retryConfig.MaxRetries {
-				continue
-			}
-			break
+				"chat", true, attempt, nil).WithProvider("google", p.defaultModel)
 		}
 
 		// Handle structured data parsing after complete response
-		if targetType != nil {
-			// Try to extract JSON from the response
-			jsonStr := extractJSON(finalResponse)
-			if jsonStr == "" {
-				responsePreview := finalResponse
-				if len(responsePreview) > 200 {
-					responsePreview = responsePreview[:200] + "..."
-				}
-				lastErr = simpleai.NewLLMError(simpleai.ErrJSONParseFailed,
-					"no valid JSON found in response", "chat", true, attempt,
-					fmt.Errorf("extractJSON failed - response preview: %s", responsePreview))
-				if attempt < retryConfig.MaxRetries {
-					continue // Retry for JSON parsing issues
+		structuredData, err := simpleai.ParseStructuredOutput(finalResponse, targetType, request.Extractor, attempt)
+		if err != nil {
+			return err
+		}
+
+		response = simpleai.ChatResponse{Message: finalResponse, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		// All retries exhausted or non-retryable error
+		return simpleai.ChatResponse{}, err
+	}
+
+	return response, nil
+}
+
+// ChatStream streams a chat request, calling handler with each chunk of the response
+func (p *Provider) ChatStream(ctx context.Context, request simpleai.ChatRequest, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	return p.ChatStreamWithRetry(ctx, request, p.retryConfig, handler)
+}
+
+// ChatStreamWithRetry streams a chat request with retry logic. Attempts are only retried
+// until the first chunk reaches handler, so a response is never delivered twice.
+func (p *Provider) ChatStreamWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		text := ""
+		started := false
+		var usage *simpleai.Usage
+
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		contents, genConfig := buildContents(request)
+
+		for resp, err := range p.client.Models.GenerateContentStream(ctx, p.defaultModel, contents, genConfig) {
+			if err != nil {
+				classified := p.classifyError(err, attempt, "chat_stream")
+				if started {
+					return simpleai.StopRetrying(classified)
 				}
-				break
+				return classified
+			}
+			if resp == nil {
+				continue
+			}
+			// Usage metadata is cumulative, so the last chunk's counts are the totals
+			if chunkUsage := usageFromMetadata(resp.UsageMetadata); chunkUsage != nil {
+				usage = chunkUsage
 			}
 
-			if err := json.Unmarshal([]byte(jsonStr), targetType); err != nil {
-				jsonPreview := jsonStr
-				if len(jsonPreview) > 200 {
-					jsonPreview = jsonPreview[:200] + "..."
-				}
-				lastErr = simpleai.NewLLMError(simpleai.ErrJSONParseFailed,
-					"failed to parse JSON response", "chat", true, attempt,
-					fmt.Errorf("unmarshal error: %v - extracted JSON preview: %s", err, jsonPreview))
-				if attempt < retryConfig.MaxRetries {
-					continue // Retry for JSON parsing issues
-				}
-				break
+			delta := resp.Text()
+			if delta == "" {
+				continue
 			}
-			structuredData = targetType
+			text += delta
+			started = true
+			if err := handler(simpleai.StreamChunk{Delta: delta, Text: text}); err != nil {
+				// The caller stopped the stream; keep its error as the cause
+				return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+					"stream handler failed", "chat_stream", false, attempt, err)
+			}
+		}
+
+		if text == "" {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from Google API",
+				"chat_stream", true, attempt, nil).WithProvider("google", p.defaultModel)
+		}
+
+		// Gemini does not flag the last chunk, so completion is signalled separately
+		if err := handler(simpleai.StreamChunk{Text: text, Done: true}); err != nil {
+			return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+				"stream handler failed", "chat_stream", false, attempt, err)
+		}
+
+		structuredData, err := simpleai.ParseStructuredOutput(text, request.T, request.Extractor, attempt)
+		if err != nil {
+			// The response has already been streamed, so asking again would repeat it
+			return simpleai.StopRetrying(err)
 		}
 
-		// Success - return the response
-		return simpleai.ChatResponse{Message: finalResponse, Data: structuredData}, nil
+		response = simpleai.ChatResponse{Message: text, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
 	}
 
-	// All retries exhausted
-	return simpleai.ChatResponse{}, lastErr
+	return response, nil
 }
 
-// convertMessages converts simpleai messages to genai.Content format
-func convertMessages(messages []simpleai.Message, systemPrompt simpleai.SystemPrompt) []*genai.Content {
-	contents := make([]*genai.Content, 0, len(messages))
+// usageFromMetadata converts Gemini usage metadata
+func usageFromMetadata(metadata *genai.GenerateContentResponseUsageMetadata) *simpleai.Usage {
+	if metadata == nil {
+		return nil
+	}
+	return &simpleai.Usage{
+		PromptTokens:     int(metadata.PromptTokenCount),
+		CompletionTokens: int(metadata.CandidatesTokenCount),
+		TotalTokens:      int(metadata.TotalTokenCount),
+	}
+}
 
-	// Add system prompt as first message if provided and not already in messages
-	hasSystemInMessages := false
-	for _, msg := range messages {
-		if msg.Role == "system" {
-			hasSystemInMessages = true
-			break
+// buildContents converts a chat request to genai contents and generation config. The system
+// prompt and any system messages become the system instruction, since Gemini has no system
+// role in its contents.
+func buildContents(request simpleai.ChatRequest) ([]*genai.Content, *genai.GenerateContentConfig) {
+	var instructions []string
+	if request.SystemPrompt.Content != "" {
+		instructions = append(instructions, request.SystemPrompt.Content)
+	}
+	for _, msg := range request.Messages {
+		if msg.Role == "system" && msg.Content != "" {
+			instructions = append(instructions, msg.Content)
 		}
 	}
 
-	// Convert messages to contents
-	for _, msg := range messages {
-		// Skip system messages if we're handling it via SystemInstruction
-		if msg.Role == "system" && systemPrompt.Content != "" && !hasSystemInMessages {
-			continue
+	// Create generation config with system instruction if provided
+	var genConfig *genai.GenerateContentConfig
+	if len(instructions) > 0 {
+		// Create Content for system instruction
+		systemContent := genai.NewContentFromText(strings.Join(instructions, "\n\n"), genai.RoleUser)
+		genConfig = &genai.GenerateContentConfig{
+			SystemInstruction: systemContent,
+		}
+	}
+	if request.Temperature != nil {
+		if genConfig == nil {
+			genConfig = &genai.GenerateContentConfig{}
 		}
+		genConfig.Temperature = genai.Ptr(float32(*request.Temperature))
+	}
+	return convertMessages(request.Messages), genConfig
+}
+
+// convertMessages converts simpleai messages to genai.Content format, leaving out system
+// messages, which are sent as the system instruction
+func convertMessages(messages []simpleai.Message) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(messages))
 
+	// Convert messages to contents
+	for _, msg := range messages {
 		// Map role to genai role
 		var role genai.Role
 		switch msg.Role {
+		case "system":
+			continue
 		case "user":
 			role = genai.RoleUser
 		case "assistant":
 			role = genai.RoleModel
-		case "system":
-			role = genai.RoleUser // System messages are treated as user messages in content
 		default:
 			role = genai.RoleUser // Default to user role
 		}
@@ -278,238 +350,65 @@ func (p *Provider) SupportedFeatures() simpleai.ProviderFeatures {
 	}
 }
 
-// classifyError classifies errors and determines if they are retryable
-func (p *Provider) classifyError(err error, attempt int, operation string) *simpleai.LLMError {
-	errStr := err.Error()
-
-	// Check for specific error types
-	if strings.Contains(errStr, "connection refused") ||
-		strings.Contains(errStr, "no such host") ||
-		strings.Contains(errStr, "network is unreachable") {
-		return simpleai.NewLLMError(simpleai.ErrConnectionFailed,
-			"connection to Google API failed", operation, true, attempt, err)
-	}
-
-	if strings.Contains(errStr, "timeout") ||
-		strings.Contains(errStr, "context deadline exceeded") ||
-		strings.Contains(errStr, "deadline exceeded") {
-		return simpleai.NewLLMError(simpleai.ErrTimeout,
-			"request timed out", operation, true, attempt, err)
-	}
-
-	if strings.Contains(errStr, "authentication") ||
-		strings.Contains(errStr, "unauthorized") ||
-		strings.Contains(errStr, "API key") ||
-		strings.Contains(errStr, "invalid api key") {
-		return simpleai.NewLLMError(simpleai.ErrInvalidConfig,
-			"authentication failed", operation, false, attempt, err)
-	}
-
-	if strings.Contains(errStr, "rate limit") ||
-		strings.Contains(errStr, "quota exceeded") ||
-		strings.Contains(errStr, "resource exhausted") {
-		return simpleai.NewLLMError(simpleai.ErrRateLimitExceeded,
-			"rate limit exceeded", operation, true, attempt, err)
-	}
-
-	if strings.Contains(errStr, "model not found") ||
-		strings.Contains(errStr, "invalid model") ||
-		strings.Contains(errStr, "model is not available") {
-		return simpleai.NewLLMError(simpleai.ErrModelNotAvailable,
-			"requested model not available", operation, false, attempt, err)
-	}
-
-	// Default to retryable operation failure
-	return simpleai.NewLLMError(simpleai.ErrOperationFailed,
-		"operation failed", operation, true, attempt, err)
+// GetRetryConfig returns the retry configuration for this provider
+func (p *Provider) GetRetryConfig() *simpleai.RetryConfig {
+	return p.retryConfig
 }
 
-// pow calculates power for exponential backoff
-func pow(base float64, exp int) float64 {
-	result := 1.0
-	for i := 0; i < exp; i++ {
-		result *= base
+// UpdateRetryConfig allows updating the retry configuration
+func (p *Provider) UpdateRetryConfig(config *simpleai.RetryConfig) {
+	if config != nil {
+		p.retryConfig = config
 	}
-	return result
 }
 
-// extractJSON attempts to extract valid JSON from a string that may contain extra text
-// Reuses the same logic from ollama/client.go
-func extractJSON(response string) string {
-	// Strategy 1: Try the response as-is after basic cleanup
-	cleaned := strings.TrimSpace(response)
-	if isValidJSON(cleaned) {
-		return cleaned
-	}
-
-	// Strategy 2: Try repairing common JSON issues (e.g., unescaped newlines)
-	if repaired := repairCommonJSONIssues(cleaned); isValidJSON(repaired) {
-		return repaired
-	}
-
-	// Strategy 3: Check for markdown code blocks (```json ... ```)
-	if strings.Contains(response, "```json") {
-		if candidate := extractFromMarkdown(response, "```json"); candidate != "" {
-			return candidate
-		}
-	}
-
-	// Strategy 4: Check for generic code blocks (``` ... ```)
-	if strings.Contains(response, "```") {
-		if candidate := extractFromMarkdown(response, "```"); candidate != "" {
-			return candidate
+// classifyError classifies errors and determines if they are retryable
+func (p *Provider) classifyError(err error, attempt int, operation string) *simpleai.LLMError {
+	// Errors reported by the Gemini API carry the HTTP status code
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		message := apiErr.Message
+		if message == "" {
+			message = apiErr.Status
 		}
+		return simpleai.NewStatusError(apiErr.Code,
+			fmt.Sprintf("Google API error: %s", message), operation, attempt, err).
+			WithProvider("google", p.defaultModel).
+			WithRetryAfter(retryDelayFromError(err))
 	}
 
-	// Strategy 5: Use brace counting for complex responses
-	if candidate := extractWithBraceCounting(response); candidate != "" {
-		return candidate
-	}
-
-	// Strategy 6: Try to find JSON-like patterns with more aggressive cleanup
-	if candidate := extractWithAggressiveCleanup(response); candidate != "" {
-		return candidate
+	// Errors from reaching the API at all
+	if llmErr, ok := simpleai.NewTransportError(err, "Google API", operation, attempt); ok {
+		return llmErr.WithProvider("google", p.defaultModel)
 	}
 
-	return ""
-}
-
-// repairCommonJSONIssues fixes common JSON formatting issues from LLM responses
-func repairCommonJSONIssues(jsonStr string) string {
-	// Fix unescaped newlines in JSON strings
-	repaired := fixUnescapedNewlines(jsonStr)
-	return repaired
-}
-
-// fixUnescapedNewlines fixes literal newlines in JSON string values
-func fixUnescapedNewlines(jsonStr string) string {
-	var result strings.Builder
-	inString := false
-	escaped := false
-
-	for _, char := range jsonStr {
-		switch {
-		case char == '\\' && !escaped:
-			escaped = true
-			result.WriteRune(char)
-		case char == '"' && !escaped:
-			inString = !inString
-			result.WriteRune(char)
-		case char == '\n' && inString && !escaped:
-			result.WriteString("\\n")
-		case char == '\r' && inString && !escaped:
-			result.WriteString("\\r")
-		case char == '\t' && inString && !escaped:
-			result.WriteString("\\t")
-		default:
-			result.WriteRune(char)
-		}
-
-		if escaped && char != '\\' {
-			escaped = false
-		}
-	}
-
-	return result.String()
-}
-
-// isValidJSON checks if a string is valid JSON using Go's standard library
-func isValidJSON(s string) bool {
-	var temp interface{}
-	return json.Unmarshal([]byte(s), &temp) == nil
+	// Default to retryable operation failure
+	return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+		"operation failed", operation, true, attempt, err).
+		WithProvider("google", p.defaultModel)
 }
 
-// extractFromMarkdown extracts JSON from markdown code blocks
-func extractFromMarkdown(response, marker string) string {
-	start := strings.Index(response, marker)
-	if start == -1 {
-		return ""
+// retryDelayFromError extracts the RetryInfo delay Google attaches to 429 and 503 errors
+func retryDelayFromError(err error) time.Duration {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return 0
 	}
-
-	content := response[start+len(marker):]
-	end := strings.Index(content, "```")
-	if end == -1 {
-		return ""
-	}
-
-	candidate := strings.TrimSpace(content[:end])
-	if isValidJSON(candidate) {
-		return candidate
+	if apiErr.Code != http.StatusTooManyRequests && apiErr.Code != http.StatusServiceUnavailable {
+		return 0
 	}
-	return ""
-}
 
-// extractWithBraceCounting uses brace counting to find JSON boundaries
-func extractWithBraceCounting(response string) string {
-	return extractJSONBoundaries(response, '{', '}')
-}
-
-// extractJSONBoundaries finds JSON object/array boundaries using character counting
-func extractJSONBoundaries(response string, openChar, closeChar rune) string {
-	start := -1
-	count := 0
-	inString := false
-	escaped := false
-
-	for i, char := range response {
-		if char == '\\' && !escaped {
-			escaped = true
+	for _, detail := range apiErr.Details {
+		detailType, _ := detail["@type"].(string)
+		if !strings.HasSuffix(detailType, "google.rpc.RetryInfo") {
 			continue
 		}
-
-		if char == '"' && !escaped {
-			inString = !inString
-		}
-
-		escaped = false
-
-		if !inString {
-			if char == openChar {
-				if start == -1 {
-					start = i
-				}
-				count++
-			} else if char == closeChar {
-				count--
-				if count == 0 && start != -1 {
-					candidate := response[start : i+1]
-					if isValidJSON(candidate) {
-						return candidate
-					}
-					start = -1
-					count = 0
-				}
+		if delay, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(delay); err == nil {
+				return d
 			}
 		}
 	}
 
-	return ""
-}
-
-// extractWithAggressiveCleanup tries various cleanup strategies for malformed responses
-func extractWithAggressiveCleanup(response string) string {
-	prefixes := []string{"```json", "```", "Here's the JSON:", "JSON:", "{", "["}
-	suffixes := []string{"```", "}", "]"}
-
-	cleaned := response
-
-	for _, prefix := range prefixes {
-		if strings.HasPrefix(cleaned, prefix) {
-			cleaned = strings.TrimSpace(cleaned[len(prefix):])
-			break
-		}
-	}
-
-	for _, suffix := range suffixes {
-		if strings.HasSuffix(cleaned, suffix) && suffix != "}" && suffix != "]" {
-			cleaned = strings.TrimSpace(cleaned[:len(cleaned)-len(suffix)])
-			break
-		}
-	}
-
-	if isValidJSON(cleaned) {
-		return cleaned
-	}
-
-	return extractJSONBoundaries(cleaned, '{', '}')
+	return 0
 }
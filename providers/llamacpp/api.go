@@ -0,0 +1,143 @@
+package llamacpp
+
+import (
+	"reflect"
+	"simpleai"
+	"simpleai/schema"
+)
+
+// constraint restricts generation to JSON matching the structured output target. Both
+// endpoints accept the same fields.
+type constraint struct {
+	JSONSchema schema.Schema `json:"json_schema,omitempty"`
+	Grammar    string        `json:"grammar,omitempty"`
+}
+
+// chatRequest is the body of POST /v1/chat/completions
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []chatMessage  `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+	constraint
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatCompletion is a chat response, or one chunk of a streamed one
+type chatCompletion struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		Delta        chatMessage `json:"delta"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *usage `json:"usage"`
+}
+
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *usage) convert() *simpleai.Usage {
+	if u == nil {
+		return nil
+	}
+	return &simpleai.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+// completionRequest is the body of POST /completion
+type completionRequest struct {
+	Prompt      string   `json:"prompt"`
+	Stream      bool     `json:"stream,omitempty"`
+	CachePrompt bool     `json:"cache_prompt"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	constraint
+}
+
+// completionResponse is a /completion response, or one chunk of a streamed one
+type completionResponse struct {
+	Content         string `json:"content"`
+	Stop            bool   `json:"stop"`
+	TokensPredicted int    `json:"tokens_predicted"`
+	TokensEvaluated int    `json:"tokens_evaluated"`
+}
+
+func (c completionResponse) usage() *simpleai.Usage {
+	if c.TokensPredicted == 0 && c.TokensEvaluated == 0 {
+		return nil
+	}
+	return &simpleai.Usage{
+		PromptTokens:     c.TokensEvaluated,
+		CompletionTokens: c.TokensPredicted,
+		TotalTokens:      c.TokensEvaluated + c.TokensPredicted,
+	}
+}
+
+type templateRequest struct {
+	Messages []chatMessage `json:"messages"`
+}
+
+type templateResponse struct {
+	Prompt string `json:"prompt"`
+}
+
+type errorBody struct {
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+type modelList struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// convertMessages converts the system prompt and messages to chat messages. The system
+// prompt becomes the first system message.
+func convertMessages(request simpleai.ChatRequest) []chatMessage {
+	messages := make([]chatMessage, 0, len(request.Messages)+1)
+	if request.SystemPrompt.Content != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: request.SystemPrompt.Content})
+	}
+	for _, msg := range request.Messages {
+		messages = append(messages, chatMessage{Role: msg.Role, Content: msg.Content})
+	}
+	return messages
+}
+
+// buildConstraint builds the generation constraint for a structured output target
+func (p *Provider) buildConstraint(target any) (constraint, error) {
+	if target == nil || p.constraint == ConstraintNone {
+		return constraint{}, nil
+	}
+
+	t := reflect.TypeOf(target)
+	if p.constraint == ConstraintJSONSchema {
+		return constraint{JSONSchema: schema.Of(t)}, nil
+	}
+
+	grammar, err := Grammar(schema.Of(t))
+	if err != nil {
+		return constraint{}, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			"cannot build grammar for "+t.String(), "chat", false, 0, err).
+			WithProvider("llamacpp", p.defaultModel)
+	}
+	return constraint{Grammar: grammar}, nil
+}
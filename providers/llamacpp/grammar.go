@@ -0,0 +1,284 @@
+package llamacpp
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"simpleai/schema"
+	"sort"
+	"strings"
+)
+
+// primitiveRules are the GBNF rules shared by every grammar, following llama.cpp's json.gbnf
+var primitiveRules = map[string]string{
+	"ws":      `| " " | "\n" [ \t]{0,20}`,
+	"char":    `[^"\\\x7F\x00-\x1F] | [\\] (["\\bfnrt] | "u" [0-9a-fA-F]{4})`,
+	"string":  `"\"" char* "\"" ws`,
+	"integer": `"-"? ([0-9] | [1-9] [0-9]{0,15}) ws`,
+	"number":  `"-"? ([0-9] | [1-9] [0-9]{0,15}) ("." [0-9]+)? ([eE] [-+]? [0-9]+)? ws`,
+	"boolean": `("true" | "false") ws`,
+	"null":    `"null" ws`,
+	"value":   `object | array | string | number | boolean | null`,
+	"object":  `"{" ws ( string ":" ws value ( "," ws string ":" ws value )* )? "}" ws`,
+	"array":   `"[" ws ( value ( "," ws value )* )? "]" ws`,
+}
+
+// primitiveDependencies lists the shared rules each shared rule refers to
+var primitiveDependencies = map[string][]string{
+	"string":  {"char", "ws"},
+	"integer": {"ws"},
+	"number":  {"ws"},
+	"boolean": {"ws"},
+	"null":    {"ws"},
+	"value":   {"object", "array", "string", "number", "boolean", "null"},
+	"object":  {"ws", "string", "value"},
+	"array":   {"ws", "value"},
+}
+
+var invalidRuleChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// GrammarFor returns a GBNF grammar that only accepts JSON decodable into v's type
+func GrammarFor(v any) (string, error) {
+	return Grammar(schema.For(v))
+}
+
+// Grammar converts a JSON Schema to a GBNF grammar for llama.cpp. It supports the subset
+// the schema package generates, plus enum and type lists. Object properties are generated
+// with required properties first, in the order listed, followed by optional ones by name.
+func Grammar(s schema.Schema) (string, error) {
+	g := &grammarBuilder{rules: map[string]string{}, names: map[string]string{}}
+	root, err := g.rule(s, "root")
+	if err != nil {
+		return "", err
+	}
+
+	var grammar strings.Builder
+	fmt.Fprintf(&grammar, "root ::= %s\n", root)
+	for _, name := range g.order {
+		fmt.Fprintf(&grammar, "%s ::= %s\n", name, g.rules[name])
+	}
+	return grammar.String(), nil
+}
+
+type grammarBuilder struct {
+	rules map[string]string // Rule bodies by name
+	names map[string]string // Rule names by body, so identical rules are shared
+	order []string
+}
+
+// use adds a shared rule, and the rules it depends on, to the grammar
+func (g *grammarBuilder) use(name string) string {
+	if _, ok := g.rules[name]; ok {
+		return name
+	}
+	g.rules[name] = primitiveRules[name]
+	g.order = append(g.order, name)
+	for _, dependency := range primitiveDependencies[name] {
+		g.use(dependency)
+	}
+	return name
+}
+
+// add adds a rule with the given body, named after hint, and returns its name. Identical
+// rules are only added once.
+func (g *grammarBuilder) add(hint, body string) string {
+	if name, ok := g.names[body]; ok {
+		return name
+	}
+	base := strings.Trim(invalidRuleChars.ReplaceAllString(hint, "-"), "-")
+	if base == "" || base == "root" {
+		base = "item"
+	}
+	name := base
+	for i := 1; ; i++ {
+		if _, taken := g.rules[name]; !taken && primitiveRules[name] == "" {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	g.rules[name] = body
+	g.names[body] = name
+	g.order = append(g.order, name)
+	return name
+}
+
+// rule returns a GBNF expression matching values of schema s
+func (g *grammarBuilder) rule(s schema.Schema, hint string) (string, error) {
+	if values, ok := s["enum"].([]any); ok {
+		return g.enum(values, hint)
+	}
+	if values, ok := s["enum"].([]string); ok {
+		anyValues := make([]any, len(values))
+		for i, value := range values {
+			anyValues[i] = value
+		}
+		return g.enum(anyValues, hint)
+	}
+
+	switch t := s["type"].(type) {
+	case nil:
+		return g.use("value"), nil
+	case string:
+		return g.typed(s, t, hint)
+	case []any:
+		alternatives := make([]string, 0, len(t))
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("grammar: invalid type %v", item)
+			}
+			alternative, err := g.typed(s, name, hint)
+			if err != nil {
+				return "", err
+			}
+			alternatives = append(alternatives, alternative)
+		}
+		return g.add(hint, strings.Join(alternatives, " | ")), nil
+	default:
+		return "", fmt.Errorf("grammar: invalid type %v", t)
+	}
+}
+
+// typed returns a GBNF expression for a single schema type
+func (g *grammarBuilder) typed(s schema.Schema, t, hint string) (string, error) {
+	switch t {
+	case "string", "integer", "number", "boolean", "null":
+		return g.use(t), nil
+	case "array":
+		items, _ := s["items"].(schema.Schema)
+		if items == nil {
+			return g.use("array"), nil
+		}
+		item, err := g.rule(items, hint+"-item")
+		if err != nil {
+			return "", err
+		}
+		g.use("ws")
+		return g.add(hint, fmt.Sprintf(`"[" ws ( %s ( "," ws %s )* )? "]" ws`, item, item)), nil
+	case "object":
+		return g.object(s, hint)
+	default:
+		return "", fmt.Errorf("grammar: unsupported type %q", t)
+	}
+}
+
+// object returns a GBNF expression for an object schema. Properties appear in a fixed order
+// and optional ones may be left out.
+func (g *grammarBuilder) object(s schema.Schema, hint string) (string, error) {
+	properties, _ := s["properties"].(schema.Schema)
+	if len(properties) == 0 {
+		if additional, ok := s["additionalProperties"].(schema.Schema); ok {
+			value, err := g.rule(additional, hint+"-value")
+			if err != nil {
+				return "", err
+			}
+			g.use("string")
+			return g.add(hint, fmt.Sprintf(`"{" ws ( string ":" ws %s ( "," ws string ":" ws %s )* )? "}" ws`, value, value)), nil
+		}
+		if s["additionalProperties"] == false {
+			g.use("ws")
+			return g.add(hint, `"{" ws "}" ws`), nil
+		}
+		return g.use("object"), nil
+	}
+
+	required := map[string]bool{}
+	var names []string
+	for _, name := range stringList(s["required"]) {
+		if _, ok := properties[name]; ok && !required[name] {
+			required[name] = true
+			names = append(names, name)
+		}
+	}
+	var optional []string
+	for name := range properties {
+		if !required[name] {
+			optional = append(optional, name)
+		}
+	}
+	sort.Strings(optional)
+	names = append(names, optional...)
+
+	// members[i] matches "key": value for the i-th property
+	g.use("ws")
+	members := make([]string, len(names))
+	for i, name := range names {
+		property, _ := properties[name].(schema.Schema)
+		value, err := g.rule(property, name)
+		if err != nil {
+			return "", err
+		}
+		key, _ := json.Marshal(name)
+		members[i] = fmt.Sprintf(`%s ws ":" ws %s`, literal(string(key)), value)
+	}
+
+	// rest(i) matches the members after i, each preceded by a comma
+	rest := func(i int) string {
+		var parts []string
+		for j := i + 1; j < len(names); j++ {
+			if required[names[j]] {
+				parts = append(parts, fmt.Sprintf(`"," ws %s`, members[j]))
+			} else {
+				parts = append(parts, fmt.Sprintf(`( "," ws %s )?`, members[j]))
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+
+	// Any optional member before the first required one may be the first member
+	var alternatives []string
+	allOptional := true
+	for i, name := range names {
+		alternatives = append(alternatives, strings.TrimSpace(members[i]+" "+rest(i)))
+		if required[name] {
+			allOptional = false
+			break
+		}
+	}
+	body := strings.Join(alternatives, " | ")
+	if allOptional {
+		body = fmt.Sprintf(`( %s )?`, body)
+	} else if len(alternatives) > 1 {
+		body = fmt.Sprintf(`( %s )`, body)
+	}
+	return g.add(hint, fmt.Sprintf(`"{" ws %s "}" ws`, body)), nil
+}
+
+// enum returns a GBNF expression matching one of the given JSON values
+func (g *grammarBuilder) enum(values []any, hint string) (string, error) {
+	alternatives := make([]string, len(values))
+	for i, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("grammar: invalid enum value %v: %w", value, err)
+		}
+		alternatives[i] = literal(string(data))
+	}
+	g.use("ws")
+	return g.add(hint, fmt.Sprintf(`( %s ) ws`, strings.Join(alternatives, " | "))), nil
+}
+
+// literal quotes text as a GBNF string literal
+func literal(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + replacer.Replace(text) + `"`
+}
+
+// stringList reads a list of strings from a generated or decoded schema
+func stringList(value any) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []any:
+		strs := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,110 @@
+package llamacpp
+
+import (
+	"regexp"
+	"simpleai/schema"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	Street string `json:"street"`
+	Zip    string `json:"zip,omitempty"`
+}
+
+type person struct {
+	Name    string             `json:"name"`
+	Age     int                `json:"age"`
+	Emails  []string           `json:"emails"`
+	Home    address            `json:"home"`
+	Scores  map[string]float64 `json:"scores,omitempty"`
+	Partner *person            `json:"partner,omitempty"`
+}
+
+// parseGrammar splits a grammar into rule bodies by name
+func parseGrammar(t *testing.T, grammar string) map[string]string {
+	t.Helper()
+	rules := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(grammar), "\n") {
+		name, body, ok := strings.Cut(line, " ::= ")
+		if !ok {
+			t.Fatalf("Malformed rule %q", line)
+		}
+		if _, exists := rules[name]; exists {
+			t.Errorf("Rule %q defined twice", name)
+		}
+		rules[name] = body
+	}
+	return rules
+}
+
+// references returns the rule names a body refers to, ignoring literals and character classes
+func references(body string) []string {
+	stripped := regexp.MustCompile(`"(\\.|[^"\\])*"|\[(\\.|[^\]\\])*\]|\{[0-9,]+\}`).ReplaceAllString(body, " ")
+	return regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9-]*`).FindAllString(stripped, -1)
+}
+
+func TestGrammarForStruct(t *testing.T) {
+	grammar, err := GrammarFor(&person{})
+	if err != nil {
+		t.Fatalf("GrammarFor failed: %v", err)
+	}
+	rules := parseGrammar(t, grammar)
+
+	if _, ok := rules["root"]; !ok {
+		t.Fatal("Expected a root rule")
+	}
+	for name, body := range rules {
+		for _, ref := range references(body) {
+			if _, ok := rules[ref]; !ok {
+				t.Errorf("Rule %q refers to undefined rule %q", name, ref)
+			}
+		}
+	}
+
+	root := rules[rules["root"]]
+	for _, want := range []string{
+		`"{" ws "\"name\"" ws ":" ws string "," ws "\"age\"" ws ":" ws integer "," ws "\"emails\""`,
+		`( "," ws "\"partner\"" ws ":" ws`,
+		`( "," ws "\"scores\"" ws ":" ws`,
+	} {
+		if !strings.Contains(root, want) {
+			t.Errorf("Expected root object to contain %s, got %s", want, root)
+		}
+	}
+	if !strings.Contains(grammar, `"{" ws "\"street\"" ws ":" ws string ( "," ws "\"zip\"" ws ":" ws string )? "}" ws`) {
+		t.Errorf("Expected an optional zip in the address rule, got\n%s", grammar)
+	}
+}
+
+func TestGrammarAllOptionalAndEnum(t *testing.T) {
+	grammar, err := Grammar(schema.Schema{
+		"type": "object",
+		"properties": schema.Schema{
+			"color": schema.Schema{"enum": []any{"red", "green"}},
+			"size":  schema.Schema{"type": []any{"integer", "null"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Grammar failed: %v", err)
+	}
+	rules := parseGrammar(t, grammar)
+
+	if rules["color"] != `( "\"red\"" | "\"green\"" ) ws` {
+		t.Errorf("Unexpected enum rule %q", rules["color"])
+	}
+	if rules["size"] != `integer | null` {
+		t.Errorf("Unexpected type list rule %q", rules["size"])
+	}
+	// Either property may come first, or neither
+	want := `"{" ws ( "\"color\"" ws ":" ws color ( "," ws "\"size\"" ws ":" ws size )? | "\"size\"" ws ":" ws size )? "}" ws`
+	if rules[rules["root"]] != want {
+		t.Errorf("Expected %s, got %s", want, rules[rules["root"]])
+	}
+}
+
+func TestGrammarUnsupportedType(t *testing.T) {
+	if _, err := Grammar(schema.Schema{"type": "tuple"}); err == nil {
+		t.Error("Expected an error for an unknown type")
+	}
+}
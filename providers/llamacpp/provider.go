@@ -0,0 +1,300 @@
+// Package llamacpp implements simpleai.Provider against the llama.cpp server.
+//
+// Requests go to the OpenAI-style /v1/chat/completions endpoint, or to the native
+// /completion endpoint with the prompt formatted by the server's chat template. When the
+// request has a structured output target, its Go type is sent as a json_schema or GBNF
+// grammar constraint, so the model can only produce JSON that decodes into it. The
+// provider also exposes the server's slot and tokenize endpoints for token counting.
+package llamacpp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"simpleai"
+	"strings"
+	"time"
+)
+
+// Endpoints accepted by the "endpoint" config key
+const (
+	EndpointChat       = "chat"       // /v1/chat/completions (default)
+	EndpointCompletion = "completion" // /completion with the server's chat template
+)
+
+// Constraints accepted by the "constraint" config key
+const (
+	ConstraintJSONSchema = "json_schema" // Send the target's JSON Schema (default)
+	ConstraintGrammar    = "grammar"     // Send a GBNF grammar generated from the target
+	ConstraintNone       = "none"        // Rely on the prompt and JSON extraction only
+)
+
+// Provider implements the simpleai.Provider interface for the llama.cpp server
+type Provider struct {
+	api          *simpleai.APIClient
+	apiKey       string
+	defaultModel string
+	timeout      int
+	endpoint     string
+	constraint   string
+	retryConfig  *simpleai.RetryConfig
+}
+
+// Config holds the settings of a llama.cpp provider
+type Config struct {
+	simpleai.ProviderSettings
+	Endpoint   string `json:"endpoint,omitempty" description:"API to call: chat (default) or completion"`
+	Constraint string `json:"constraint,omitempty" description:"How structured output is constrained: json_schema (default), grammar or none"`
+}
+
+// NewProvider creates a new llama.cpp provider instance
+func NewProvider(config map[string]interface{}) (simpleai.Provider, error) {
+	var settings Config
+	if err := simpleai.DecodeProviderConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	// Apply defaults to unset values
+	host := "http://localhost:8080"
+	if settings.Host != "" {
+		host = settings.Host
+	}
+
+	// Only needed when the server was started with --api-key
+	apiKey := settings.APIKey
+
+	// The server answers with whichever model it loaded; the name is informational
+	defaultModel := "default"
+	if settings.DefaultModel != "" {
+		defaultModel = settings.DefaultModel
+	}
+
+	timeout := 120
+	if settings.Timeout > 0 {
+		timeout = settings.Timeout
+	}
+
+	retryAttempts := 3
+	if r := settings.RetryAttempts; r != nil && *r >= 0 {
+		retryAttempts = *r
+	}
+
+	endpoint := EndpointChat
+	if e := settings.Endpoint; e != "" {
+		if e != EndpointChat && e != EndpointCompletion {
+			return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+				fmt.Sprintf("unknown endpoint %q", e),
+				"provider_creation", false, 0, nil)
+		}
+		endpoint = e
+	}
+
+	constraint := ConstraintJSONSchema
+	if c := settings.Constraint; c != "" {
+		switch c {
+		case ConstraintJSONSchema, ConstraintGrammar, ConstraintNone:
+			constraint = c
+		default:
+			return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+				fmt.Sprintf("unknown constraint %q", c),
+				"provider_creation", false, 0, nil)
+		}
+	}
+
+	// Create retry configuration
+	retryConfig := simpleai.DefaultRetryConfig()
+	retryConfig.MaxRetries = retryAttempts
+
+	provider := &Provider{
+		apiKey:       apiKey,
+		defaultModel: defaultModel,
+		timeout:      timeout,
+		endpoint:     endpoint,
+		constraint:   constraint,
+		retryConfig:  retryConfig,
+	}
+	provider.api = &simpleai.APIClient{
+		BaseURL:        strings.TrimSuffix(host, "/"),
+		Authorize:      provider.authorize,
+		ClassifyStatus: provider.classifyStatusError,
+		Service:        "llama.cpp server",
+		Provider:       "llamacpp",
+		Model:          defaultModel,
+	}
+	return provider, nil
+}
+
+// Chat sends a chat request and returns a response
+func (p *Provider) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatWithRetry(request, p.retryConfig)
+}
+
+// ChatContext sends a chat request, giving up when ctx is done
+func (p *Provider) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(ctx, request, p.retryConfig)
+}
+
+// ChatWithRetry executes a chat request with retry logic
+func (p *Provider) ChatWithRetry(request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(context.Background(), request, retryConfig)
+}
+
+// ChatContextWithRetry executes a cancellable chat request with retry logic
+func (p *Provider) ChatContextWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+
+	constraint, err := p.buildConstraint(request.T)
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	err = simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		var text string
+		var usage *simpleai.Usage
+		if p.endpoint == EndpointCompletion {
+			prompt, err := p.applyTemplate(ctx, request, attempt)
+			if err != nil {
+				return err
+			}
+			var completion completionResponse
+			body := completionRequest{Prompt: prompt, Temperature: request.Temperature, constraint: constraint}
+			if err := p.api.Do(ctx, http.MethodPost, "/completion", body, &completion, attempt, "chat"); err != nil {
+				return err
+			}
+			text, usage = completion.Content, completion.usage()
+		} else {
+			var completion chatCompletion
+			body := chatRequest{Model: p.defaultModel, Messages: convertMessages(request), Temperature: request.Temperature, constraint: constraint}
+			if err := p.api.Do(ctx, http.MethodPost, "/v1/chat/completions", body, &completion, attempt, "chat"); err != nil {
+				return err
+			}
+			if len(completion.Choices) > 0 {
+				text = completion.Choices[0].Message.Content
+			}
+			usage = completion.Usage.convert()
+		}
+
+		if text == "" {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from llama.cpp server",
+				"chat", true, attempt, nil).WithProvider("llamacpp", p.defaultModel)
+		}
+
+		structuredData, err := simpleai.ParseStructuredOutput(text, request.T, request.Extractor, attempt)
+		if err != nil {
+			return err
+		}
+
+		response = simpleai.ChatResponse{Message: text, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	return response, nil
+}
+
+// ListModels returns the models the server has loaded
+func (p *Provider) ListModels() ([]simpleai.Model, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.timeout)*time.Second)
+	defer cancel()
+
+	var list modelList
+	if err := p.api.Do(ctx, http.MethodGet, "/v1/models", nil, &list, 0, "list_models"); err != nil {
+		return nil, err
+	}
+
+	models := make([]simpleai.Model, len(list.Data))
+	for i, model := range list.Data {
+		models[i] = simpleai.Model{Name: model.ID}
+	}
+	return models, nil
+}
+
+// Name returns the provider's name
+func (p *Provider) Name() string {
+	return "llamacpp"
+}
+
+// IsAvailable checks if the server is up and has finished loading its model
+func (p *Provider) IsAvailable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// /health answers 503 while the model is loading
+	return p.api.Do(ctx, http.MethodGet, "/health", nil, nil, 0, "health_check") == nil
+}
+
+// SupportedFeatures returns the capabilities supported by this provider
+func (p *Provider) SupportedFeatures() simpleai.ProviderFeatures {
+	return simpleai.ProviderFeatures{
+		StructuredOutput: true,  // Grammar-constrained JSON output
+		Streaming:        true,  // Server-sent events
+		Vision:           false, // Multimodal models are not supported
+		MaxTokens:        4096,  // Default context window (set by the server's --ctx-size)
+		SupportedRoles:   []string{"system", "user", "assistant"},
+		FunctionCalling:  false, // Tools are not sent
+		Temperature:      true,
+		TopP:             true,
+	}
+}
+
+// GetDefaultModel returns the default model for this provider
+func (p *Provider) GetDefaultModel() string {
+	return p.defaultModel
+}
+
+// GetRetryConfig returns the retry configuration for this provider
+func (p *Provider) GetRetryConfig() *simpleai.RetryConfig {
+	return p.retryConfig
+}
+
+// UpdateRetryConfig allows updating the retry configuration
+func (p *Provider) UpdateRetryConfig(config *simpleai.RetryConfig) {
+	if config != nil {
+		p.retryConfig = config
+	}
+}
+
+// applyTemplate formats the request's messages with the model's chat template
+func (p *Provider) applyTemplate(ctx context.Context, request simpleai.ChatRequest, attempt int) (string, error) {
+	var template templateResponse
+	body := templateRequest{Messages: convertMessages(request)}
+	if err := p.api.Do(ctx, http.MethodPost, "/apply-template", body, &template, attempt, "apply_template"); err != nil {
+		return "", err
+	}
+	return template.Prompt, nil
+}
+
+// authorize sends api_key, if set, as a bearer token
+func (p *Provider) authorize(req *http.Request) error {
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	return nil
+}
+
+// classifyStatusError classifies an error response by its status code, keeping the
+// server's message and retry hint
+func (p *Provider) classifyStatusError(resp *http.Response, attempt int, operation string) *simpleai.LLMError {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	message := http.StatusText(resp.StatusCode)
+	var body errorBody
+	if json.Unmarshal(data, &body) == nil && body.Error != nil && body.Error.Message != "" {
+		message = body.Error.Message
+	} else if text := strings.TrimSpace(string(data)); text != "" {
+		message = text
+	}
+
+	return simpleai.NewStatusError(resp.StatusCode,
+		fmt.Sprintf("llama.cpp server error: %s", message), operation, attempt, nil).
+		WithProvider("llamacpp", p.defaultModel).
+		WithRetryAfter(simpleai.ParseRetryAfter(resp.Header.Get("Retry-After")))
+}
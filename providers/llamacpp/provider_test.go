@@ -0,0 +1,191 @@
+package llamacpp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"simpleai"
+	"simpleai/providertest"
+	"strings"
+	"testing"
+)
+
+// newTestProvider creates a provider for a server answering with handler
+func newTestProvider(t *testing.T, config map[string]interface{}, handler http.HandlerFunc) *Provider {
+	t.Helper()
+	return providertest.NewProvider(t, NewProvider, config, handler).(*Provider)
+}
+
+type reading struct {
+	Sensor string  `json:"sensor"`
+	Value  float64 `json:"value"`
+}
+
+func TestChatSendsJSONSchema(t *testing.T) {
+	var body map[string]any
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"{\"sensor\":\"t1\",\"value\":21.5}"}}]}`)
+	})
+
+	var target reading
+	if _, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Read t1"}}, T: &target}); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if target != (reading{Sensor: "t1", Value: 21.5}) {
+		t.Errorf("Unexpected structured output: %+v", target)
+	}
+
+	jsonSchema, _ := body["json_schema"].(map[string]any)
+	if jsonSchema["type"] != "object" || body["grammar"] != nil {
+		t.Errorf("Expected only a json_schema constraint, got %v", body)
+	}
+}
+
+func TestCompletionEndpointWithGrammar(t *testing.T) {
+	var completion map[string]any
+	provider := newTestProvider(t, map[string]interface{}{"endpoint": EndpointCompletion, "constraint": ConstraintGrammar},
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/apply-template":
+				var request templateRequest
+				json.NewDecoder(r.Body).Decode(&request)
+				if len(request.Messages) != 2 || request.Messages[0].Role != "system" {
+					t.Errorf("Expected the system prompt and message, got %+v", request.Messages)
+				}
+				fmt.Fprint(w, `{"prompt":"<|system|>Be exact<|user|>Read t1<|assistant|>"}`)
+			case "/completion":
+				json.NewDecoder(r.Body).Decode(&completion)
+				fmt.Fprint(w, `{"content":"{\"sensor\":\"t1\",\"value\":3}","stop":true,"tokens_predicted":9,"tokens_evaluated":14}`)
+			default:
+				http.NotFound(w, r)
+			}
+		})
+
+	var target reading
+	response, err := provider.Chat(simpleai.ChatRequest{
+		SystemPrompt: simpleai.SystemPrompt{Content: "Be exact"},
+		Messages:     []simpleai.Message{{Role: "user", Content: "Read t1"}},
+		T:            &target,
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if target.Value != 3 || response.Usage == nil || response.Usage.TotalTokens != 23 {
+		t.Errorf("Unexpected result %+v, usage %+v", target, response.Usage)
+	}
+
+	if completion["prompt"] != "<|system|>Be exact<|user|>Read t1<|assistant|>" {
+		t.Errorf("Expected the templated prompt, got %v", completion["prompt"])
+	}
+	grammar, _ := completion["grammar"].(string)
+	if !strings.HasPrefix(grammar, "root ::= ") || !strings.Contains(grammar, `"\"sensor\""`) {
+		t.Errorf("Expected a grammar for the target, got %q", grammar)
+	}
+}
+
+func TestCompletionStream(t *testing.T) {
+	provider := newTestProvider(t, map[string]interface{}{"endpoint": EndpointCompletion}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/apply-template" {
+			fmt.Fprint(w, `{"prompt":"Hi"}`)
+			return
+		}
+		for _, chunk := range []string{
+			`{"content":"Hel","stop":false}`,
+			`{"content":"lo","stop":false}`,
+			`{"content":"","stop":true,"tokens_predicted":2,"tokens_evaluated":1}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+	})
+
+	var deltas []string
+	response, err := provider.ChatStream(context.Background(), simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hi"}}},
+		func(chunk simpleai.StreamChunk) error {
+			if chunk.Delta != "" {
+				deltas = append(deltas, chunk.Delta)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+	if response.Message != "Hello" || len(deltas) != 2 {
+		t.Errorf("Expected two deltas making Hello, got %q from %q", response.Message, deltas)
+	}
+	if response.Usage == nil || response.Usage.CompletionTokens != 2 {
+		t.Errorf("Expected usage from the final chunk, got %+v", response.Usage)
+	}
+}
+
+func TestStreamEndedEarly(t *testing.T) {
+	provider := newTestProvider(t, map[string]interface{}{"endpoint": EndpointCompletion}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/apply-template" {
+			fmt.Fprint(w, `{"prompt":"Hi"}`)
+			return
+		}
+		fmt.Fprint(w, "data: {\"content\":\"The answer is\",\"stop\":false}\n\n")
+	})
+
+	var done bool
+	_, err := provider.ChatStream(context.Background(), simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hi"}}},
+		func(chunk simpleai.StreamChunk) error {
+			done = done || chunk.Done
+			return nil
+		})
+	if !errors.Is(err, simpleai.ErrConnectionFailed) || simpleai.IsRetryable(err) {
+		t.Errorf("Expected a non-retryable ErrConnectionFailed after streamed text, got %v", err)
+	}
+	if done {
+		t.Error("Expected no chunk marked Done for a cut-off stream")
+	}
+}
+
+func TestSlotsAndTokens(t *testing.T) {
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slots":
+			fmt.Fprint(w, `[{"id":0,"n_ctx":8192,"is_processing":true},{"id":1,"n_ctx":8192,"is_processing":false}]`)
+		case "/apply-template":
+			fmt.Fprint(w, `{"prompt":"<s>one two three"}`)
+		case "/tokenize":
+			var request struct {
+				Content string `json:"content"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			tokens := make([]int, len(strings.Fields(request.Content)))
+			json.NewEncoder(w).Encode(map[string]any{"tokens": tokens})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	slots, err := provider.Slots(context.Background())
+	if err != nil {
+		t.Fatalf("Slots failed: %v", err)
+	}
+	if len(slots) != 2 || slots[0].ContextSize != 8192 || !slots[0].IsProcessing || slots[1].IsProcessing {
+		t.Errorf("Unexpected slots %+v", slots)
+	}
+
+	count, err := provider.CountTokens(context.Background(), simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "one two three"}}})
+	if err != nil {
+		t.Fatalf("CountTokens failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 tokens, got %d", count)
+	}
+}
+
+func TestNewProviderInvalidOptions(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"endpoint": "infill"},
+		{"constraint": "regex"},
+	} {
+		if _, err := NewProvider(config); !errors.Is(err, simpleai.ErrInvalidConfig) {
+			t.Errorf("Expected %v to be rejected", config)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package llamacpp
+
+import (
+	"context"
+	"net/http"
+	"simpleai"
+	"time"
+)
+
+// Slot is a server slot, which processes one request at a time
+type Slot struct {
+	ID           int  `json:"id"`
+	ContextSize  int  `json:"n_ctx"`         // Tokens of context available to the slot
+	IsProcessing bool `json:"is_processing"` // Whether the slot is busy
+}
+
+// Slots returns the server's slots. The server must not be started with --no-slots.
+func (p *Provider) Slots(ctx context.Context) ([]Slot, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+	defer cancel()
+
+	var slots []Slot
+	if err := p.api.Do(ctx, http.MethodGet, "/slots", nil, &slots, 0, "slots"); err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+// Tokenize returns the tokens of text in the loaded model's vocabulary
+func (p *Provider) Tokenize(ctx context.Context, text string) ([]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+	defer cancel()
+
+	var tokens struct {
+		Tokens []int `json:"tokens"`
+	}
+	body := map[string]any{"content": text, "add_special": true}
+	if err := p.api.Do(ctx, http.MethodPost, "/tokenize", body, &tokens, 0, "tokenize"); err != nil {
+		return nil, err
+	}
+	return tokens.Tokens, nil
+}
+
+// CountTokens returns the number of prompt tokens request would use, with its messages
+// formatted by the model's chat template
+func (p *Provider) CountTokens(ctx context.Context, request simpleai.ChatRequest) (int, error) {
+	templateCtx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+	defer cancel()
+
+	prompt, err := p.applyTemplate(templateCtx, request, 0)
+	if err != nil {
+		return 0, err
+	}
+	tokens, err := p.Tokenize(ctx, prompt)
+	if err != nil {
+		return 0, err
+	}
+	return len(tokens), nil
+}
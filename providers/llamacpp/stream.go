@@ -0,0 +1,162 @@
+package llamacpp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"simpleai"
+	"strings"
+	"time"
+)
+
+// ChatStream streams a chat request, calling handler with each chunk of the response
+func (p *Provider) ChatStream(ctx context.Context, request simpleai.ChatRequest, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	return p.ChatStreamWithRetry(ctx, request, p.retryConfig, handler)
+}
+
+// ChatStreamWithRetry streams a chat request with retry logic. Attempts are only retried
+// until the first chunk reaches handler, so a response is never delivered twice.
+func (p *Provider) ChatStreamWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+
+	constraint, err := p.buildConstraint(request.T)
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	err = simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		text := ""
+		started := false
+		var usage *simpleai.Usage
+
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		// Each endpoint has its own chunk format; decode returns a chunk's text and usage, and
+		// whether it is the last chunk of the response
+		var path string
+		var body any
+		var decode func(data []byte) (string, *simpleai.Usage, bool, error)
+		if p.endpoint == EndpointCompletion {
+			prompt, err := p.applyTemplate(ctx, request, attempt)
+			if err != nil {
+				return err
+			}
+			path = "/completion"
+			body = completionRequest{Prompt: prompt, Stream: true, Temperature: request.Temperature, constraint: constraint}
+			decode = func(data []byte) (string, *simpleai.Usage, bool, error) {
+				var chunk completionResponse
+				err := json.Unmarshal(data, &chunk)
+				return chunk.Content, chunk.usage(), chunk.Stop, err
+			}
+		} else {
+			path = "/v1/chat/completions"
+			body = chatRequest{
+				Model:         p.defaultModel,
+				Messages:      convertMessages(request),
+				Stream:        true,
+				StreamOptions: &streamOptions{IncludeUsage: true},
+				Temperature:   request.Temperature,
+				constraint:    constraint,
+			}
+			decode = func(data []byte) (string, *simpleai.Usage, bool, error) {
+				var chunk chatCompletion
+				if err := json.Unmarshal(data, &chunk); err != nil {
+					return "", nil, false, err
+				}
+				if len(chunk.Choices) == 0 {
+					return "", chunk.Usage.convert(), false, nil
+				}
+				choice := chunk.Choices[0]
+				return choice.Delta.Content, chunk.Usage.convert(), choice.FinishReason != "", nil
+			}
+		}
+
+		resp, err := p.api.Send(ctx, http.MethodPost, path, body, attempt, "chat_stream")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		fail := func(err error) error {
+			if started {
+				return simpleai.StopRetrying(err)
+			}
+			return err
+		}
+
+		finished := false
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				finished = true
+				break
+			}
+
+			delta, chunkUsage, last, err := decode([]byte(data))
+			if err != nil {
+				return fail(simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+					"invalid stream chunk from llama.cpp server", "chat_stream", true, attempt, err).
+					WithProvider("llamacpp", p.defaultModel))
+			}
+			if chunkUsage != nil {
+				usage = chunkUsage
+			}
+			finished = finished || last
+			if delta == "" {
+				continue
+			}
+			text += delta
+			started = true
+			if err := handler(simpleai.StreamChunk{Delta: delta, Text: text}); err != nil {
+				// The caller stopped the stream; keep its error as the cause
+				return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+					"stream handler failed", "chat_stream", false, attempt, err)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			}
+			return fail(p.api.TransportError(err, attempt, "chat_stream"))
+		}
+		if !finished {
+			// The connection was closed before the server finished the response
+			return fail(simpleai.NewLLMError(simpleai.ErrConnectionFailed,
+				"stream from llama.cpp server ended before the response was finished", "chat_stream", true, attempt, nil).
+				WithProvider("llamacpp", p.defaultModel))
+		}
+
+		if text == "" {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from llama.cpp server",
+				"chat_stream", true, attempt, nil).WithProvider("llamacpp", p.defaultModel)
+		}
+
+		if err := handler(simpleai.StreamChunk{Text: text, Done: true}); err != nil {
+			return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+				"stream handler failed", "chat_stream", false, attempt, err)
+		}
+
+		structuredData, err := simpleai.ParseStructuredOutput(text, request.T, request.Extractor, attempt)
+		if err != nil {
+			// The response has already been streamed, so asking again would repeat it
+			return simpleai.StopRetrying(err)
+		}
+
+		response = simpleai.ChatResponse{Message: text, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	return response, nil
+}
@@ -0,0 +1,95 @@
+package ollama
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"simpleai"
+	"simpleai/providertest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// ollamaProtocol speaks the Ollama /api/chat protocol for the conformance suite
+type ollamaProtocol struct{}
+
+func (ollamaProtocol) Decode(r *http.Request) (providertest.Received, error) {
+	if r.URL.Path != "/api/chat" {
+		return providertest.Received{}, errors.New("unknown endpoint " + r.URL.Path)
+	}
+
+	var request api.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return providertest.Received{}, err
+	}
+
+	received := providertest.Received{
+		Model:  request.Model,
+		Stream: request.Stream == nil || *request.Stream,
+	}
+	var system []string
+	for _, message := range request.Messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+			continue
+		}
+		received.Messages = append(received.Messages, simpleai.Message{Role: message.Role, Content: message.Content})
+	}
+	received.System = strings.Join(system, "\n\n")
+	return received, nil
+}
+
+func (ollamaProtocol) WriteText(w http.ResponseWriter, received providertest.Received, chunks []string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+
+	message := func(content string, done bool) api.ChatResponse {
+		response := api.ChatResponse{
+			Model:     received.Model,
+			CreatedAt: time.Now(),
+			Message:   api.Message{Role: "assistant", Content: content},
+			Done:      done,
+		}
+		if done {
+			response.DoneReason = "stop"
+			response.Metrics = api.Metrics{PromptEvalCount: 5, EvalCount: len(chunks)}
+		}
+		return response
+	}
+
+	if !received.Stream {
+		encoder.Encode(message(strings.Join(chunks, ""), true))
+		return
+	}
+	for _, chunk := range chunks {
+		encoder.Encode(message(chunk, false))
+		w.(http.Flusher).Flush()
+	}
+	encoder.Encode(message("", true))
+}
+
+func (ollamaProtocol) WriteError(w http.ResponseWriter, received providertest.Received, status int, message string, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(int(retryAfter.Seconds())))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func TestConformance(t *testing.T) {
+	providertest.Run(t, providertest.Harness{
+		Protocol: ollamaProtocol{},
+		NewProvider: func(t *testing.T, baseURL string) simpleai.Provider {
+			provider, err := NewProvider(map[string]interface{}{"host": baseURL})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			return provider
+		},
+	})
+}
@@ -0,0 +1,554 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"simpleai"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Load balancing strategies accepted by the "strategy" config key
+const (
+	StrategyRoundRobin    = "round_robin"     // Take turns (default)
+	StrategyLeastInFlight = "least_in_flight" // Pick the host with the fewest requests running
+	StrategyLatency       = "latency"         // Pick at random, weighted towards faster hosts
+)
+
+const (
+	resultWindow   = 10               // Recent results per host the error rate is computed over
+	minResults     = 5                // Results needed before a host can be ejected for its error rate
+	latencyWeight  = 0.3              // Weight of the newest sample in a host's average latency
+	runningTTL     = 5 * time.Second  // How long a host's running models are trusted
+	runningTimeout = 2 * time.Second  // Timeout for fetching a host's running models
+	defaultEject   = 30 * time.Second // How long an ejected host is left out by default
+)
+
+// PoolConfig holds the settings of an Ollama pool. The common settings apply to every host.
+type PoolConfig struct {
+	simpleai.ProviderSettings
+	Hosts        []string `json:"hosts,omitempty" description:"Ollama base URLs, a list or a comma- or space-separated string (required)"`
+	Strategy     string   `json:"strategy,omitempty" description:"round_robin (default), least_in_flight or latency"`
+	MaxErrorRate float64  `json:"max_error_rate,omitempty" description:"Share of a host's recent requests that may fail before it is ejected; defaults to 0.5"`
+	EjectSeconds int      `json:"eject_seconds,omitempty" description:"How long an ejected host is left out before it gets another chance; defaults to 30"`
+}
+
+// Pool implements simpleai.Provider by spreading requests over several Ollama hosts that
+// serve the same models. Hosts that already have the model loaded are preferred, then the
+// strategy picks among them. A request that fails with a connection, timeout or other
+// error worth falling back on is retried on the next host, unless a stream has already
+// delivered chunks. Hosts make a single attempt each; the pool's retry_attempts apply
+// once every host has failed, with backoff between the rounds. Hosts are ejected when too
+// many of their recent requests fail with connection, timeout, 5xx or 429 errors, or when
+// IsAvailable finds them unreachable, and get another chance once the ejection ends; a
+// failure during that probation ejects them again at once. Errors in the model's output,
+// such as unparseable JSON, are not held against the host.
+type Pool struct {
+	hosts        []*poolHost
+	strategy     string
+	maxErrorRate float64
+	ejectFor     time.Duration
+	defaultModel string
+	retryConfig  *simpleai.RetryConfig // Retries of a request that failed on every host
+	next         atomic.Uint64         // Round robin position
+
+	now    func() time.Time
+	random func() float64
+
+	refreshing atomic.Bool // Whether running models are being fetched
+}
+
+// poolHost tracks one host of a pool
+type poolHost struct {
+	url      string
+	provider *Provider
+
+	mu           sync.Mutex
+	inFlight     int
+	latency      time.Duration      // Moving average of successful request durations
+	results      [resultWindow]bool // Recent outcomes, true for failures
+	count        int                // Outcomes recorded in results
+	position     int                // Next slot in results
+	ejectedUntil time.Time          // Zero unless ejected
+	probation    bool               // Readmitted after an ejection; one failure ejects again
+	running      map[string]bool    // Models loaded on the host
+	runningAt    time.Time          // When running was fetched
+}
+
+// HostStatus describes a host of a pool
+type HostStatus struct {
+	Host        string        `json:"host"`
+	InFlight    int           `json:"in_flight"`
+	Latency     time.Duration `json:"latency"`    // Moving average, zero before the first success
+	ErrorRate   float64       `json:"error_rate"` // Over the recent requests
+	Ejected     bool          `json:"ejected"`
+	ModelLoaded bool          `json:"model_loaded"` // Whether the default model was running when last checked
+}
+
+// NewPool creates a provider that balances requests over the Ollama hosts in "hosts". The
+// other settings, such as default_model and timeout, apply to every host.
+func NewPool(config map[string]interface{}) (simpleai.Provider, error) {
+	var settings PoolConfig
+	if err := simpleai.DecodeProviderConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	// Hosts from ExtraSettings arrive as one string
+	var urls []string
+	for _, host := range append([]string{settings.Host}, settings.Hosts...) {
+		for _, url := range strings.Split(host, ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				urls = append(urls, url)
+			}
+		}
+	}
+	if len(urls) == 0 {
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			"hosts is required for Ollama pool provider",
+			"provider_creation", false, 0, nil)
+	}
+
+	strategy := StrategyRoundRobin
+	switch settings.Strategy {
+	case "":
+	case StrategyRoundRobin, StrategyLeastInFlight, StrategyLatency:
+		strategy = settings.Strategy
+	default:
+		return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+			fmt.Sprintf("unknown strategy %q", settings.Strategy),
+			"provider_creation", false, 0, nil)
+	}
+
+	maxErrorRate := 0.5
+	if settings.MaxErrorRate > 0 {
+		maxErrorRate = settings.MaxErrorRate
+	}
+	ejectFor := defaultEject
+	if settings.EjectSeconds > 0 {
+		ejectFor = time.Duration(settings.EjectSeconds) * time.Second
+	}
+
+	retryConfig := simpleai.DefaultRetryConfig()
+	if r := settings.RetryAttempts; r != nil && *r >= 0 {
+		retryConfig.MaxRetries = *r
+	}
+
+	pool := &Pool{
+		strategy:     strategy,
+		maxErrorRate: maxErrorRate,
+		ejectFor:     ejectFor,
+		retryConfig:  retryConfig,
+		now:          time.Now,
+		random:       rand.Float64,
+	}
+	seen := make(map[string]bool)
+	for _, url := range urls {
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+
+		memberConfig := make(map[string]interface{}, len(config))
+		for key, value := range config {
+			switch key {
+			case "hosts", "strategy", "max_error_rate", "eject_seconds":
+				continue
+			}
+			memberConfig[key] = value
+		}
+		memberConfig["host"] = url
+		// Retrying on one host would hold up failover to the others
+		memberConfig["retry_attempts"] = 0
+		member, err := NewProvider(memberConfig)
+		if err != nil {
+			return nil, err
+		}
+		provider := member.(*Provider)
+		pool.defaultModel = provider.defaultModel
+		pool.hosts = append(pool.hosts, &poolHost{url: url, provider: provider})
+	}
+	return pool, nil
+}
+
+// Chat sends a chat request to one of the hosts
+func (p *Pool) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatContext(context.Background(), request)
+}
+
+// ChatContext sends a chat request to one of the hosts, giving up when ctx is done
+func (p *Pool) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.do(ctx, func(member *Provider) (simpleai.ChatResponse, error) {
+		return member.ChatContext(ctx, request)
+	}, nil)
+}
+
+// ChatStream streams a chat request from one of the hosts. Once chunks have been delivered
+// the request is not moved to another host.
+func (p *Pool) ChatStream(ctx context.Context, request simpleai.ChatRequest, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	var streamed atomic.Bool
+	return p.do(ctx, func(member *Provider) (simpleai.ChatResponse, error) {
+		return member.ChatStream(ctx, request, func(chunk simpleai.StreamChunk) error {
+			streamed.Store(true)
+			return handler(chunk)
+		})
+	}, streamed.Load)
+}
+
+// do runs call through failover, and retries with backoff when every host failed.
+// started reports whether output was already delivered, which rules out trying again.
+func (p *Pool) do(ctx context.Context, call func(member *Provider) (simpleai.ChatResponse, error), started func() bool) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+	var streamErr error
+	err := simpleai.NewRetryExecutor(p.retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		var err error
+		response, err = p.failover(ctx, call, started)
+		if err != nil && started != nil && started() {
+			// Stop the executor without retrying
+			streamErr = err
+			return nil
+		}
+		return err
+	})
+	if streamErr != nil {
+		return response, streamErr
+	}
+	return response, err
+}
+
+// failover runs call on the best host, moving on to the next best while it fails with
+// errors worth falling back on
+func (p *Pool) failover(ctx context.Context, call func(member *Provider) (simpleai.ChatResponse, error), started func() bool) (simpleai.ChatResponse, error) {
+	tried := make(map[*poolHost]bool, len(p.hosts))
+	var lastErr error
+	for len(tried) < len(p.hosts) {
+		if err := ctx.Err(); err != nil && lastErr != nil {
+			return simpleai.ChatResponse{}, lastErr
+		}
+		host := p.pick(tried)
+		tried[host] = true
+
+		host.begin()
+		begin := p.now()
+		response, err := call(host.provider)
+		failed := err != nil && simpleai.ShouldFallback(err) && ctx.Err() == nil
+		p.finish(host, failed && hostFault(err), p.now().Sub(begin))
+
+		if !failed || (started != nil && started()) {
+			return response, err
+		}
+		lastErr = err
+	}
+	return simpleai.ChatResponse{}, lastErr
+}
+
+// hostFault reports whether err is the host's fault rather than the request's or the
+// model's: a connection failure, a timeout, or a 5xx or 429 response
+func hostFault(err error) bool {
+	if errors.Is(err, simpleai.ErrConnectionFailed) || errors.Is(err, simpleai.ErrTimeout) {
+		return true
+	}
+	var llmErr *simpleai.LLMError
+	if !errors.As(err, &llmErr) {
+		return false
+	}
+	return llmErr.StatusCode >= http.StatusInternalServerError || llmErr.StatusCode == http.StatusTooManyRequests
+}
+
+// pick returns the host for the next request among those not tried yet. Ejected hosts are
+// only used when no other host is left.
+func (p *Pool) pick(tried map[*poolHost]bool) *poolHost {
+	p.refreshRunning()
+
+	now := p.now()
+	var healthy, ejected []*poolHost
+	for _, host := range p.hosts {
+		if tried[host] {
+			continue
+		}
+		if host.admit(now) {
+			healthy = append(healthy, host)
+		} else {
+			ejected = append(ejected, host)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		candidates = ejected
+	}
+
+	// Hosts with the model in memory answer without loading it first
+	var loaded []*poolHost
+	for _, host := range candidates {
+		if host.hasModel(p.defaultModel, now) {
+			loaded = append(loaded, host)
+		}
+	}
+	if len(loaded) > 0 {
+		candidates = loaded
+	}
+
+	switch p.strategy {
+	case StrategyLeastInFlight:
+		start := int(p.next.Add(1) - 1)
+		best := candidates[start%len(candidates)]
+		for i := 1; i < len(candidates); i++ {
+			// Start at a rotating position so ties take turns
+			host := candidates[(start+i)%len(candidates)]
+			if host.load() < best.load() {
+				best = host
+			}
+		}
+		return best
+	case StrategyLatency:
+		return p.weighted(candidates)
+	default:
+		return candidates[int(p.next.Add(1)-1)%len(candidates)]
+	}
+}
+
+// weighted picks a host at random with odds inversely proportional to its latency. Hosts
+// without a measurement get the best odds, so they are measured soon.
+func (p *Pool) weighted(candidates []*poolHost) *poolHost {
+	weights := make([]float64, len(candidates))
+	best := 0.0
+	for i, host := range candidates {
+		if latency := host.averageLatency(); latency > 0 {
+			weights[i] = 1 / latency.Seconds()
+			best = max(best, weights[i])
+		}
+	}
+	if best == 0 {
+		best = 1
+	}
+	total := 0.0
+	for i := range weights {
+		if weights[i] == 0 {
+			weights[i] = best
+		}
+		total += weights[i]
+	}
+
+	target := p.random() * total
+	for i, weight := range weights {
+		if target < weight {
+			return candidates[i]
+		}
+		target -= weight
+	}
+	return candidates[len(candidates)-1]
+}
+
+// finish records the outcome of a request on host, ejecting it when it fails too often
+func (p *Pool) finish(host *poolHost, failed bool, elapsed time.Duration) {
+	host.mu.Lock()
+	defer host.mu.Unlock()
+
+	host.inFlight--
+	if !failed {
+		host.probation = false
+		if host.latency == 0 {
+			host.latency = elapsed
+		} else {
+			host.latency = time.Duration(latencyWeight*float64(elapsed) + (1-latencyWeight)*float64(host.latency))
+		}
+	}
+
+	host.results[host.position] = failed
+	host.position = (host.position + 1) % resultWindow
+	host.count = min(host.count+1, resultWindow)
+
+	if failed && (host.probation || host.count >= minResults && host.errorRateLocked() >= p.maxErrorRate) {
+		host.ejectLocked(p.now().Add(p.ejectFor))
+	}
+}
+
+// refreshRunning fetches the running models of every host in the background when they
+// are out of date. Requests meanwhile use what is known.
+func (p *Pool) refreshRunning() {
+	now := p.now()
+	stale := false
+	for _, host := range p.hosts {
+		host.mu.Lock()
+		stale = stale || now.Sub(host.runningAt) > runningTTL
+		host.mu.Unlock()
+	}
+	if !stale || !p.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer p.refreshing.Store(false)
+		p.fetchRunning(context.Background())
+	}()
+}
+
+// fetchRunning asks every host which models it has loaded
+func (p *Pool) fetchRunning(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, host := range p.hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, runningTimeout)
+			defer cancel()
+
+			var running map[string]bool
+			if response, err := host.provider.ollamaClient.ListRunning(ctx); err == nil {
+				running = make(map[string]bool, len(response.Models))
+				for _, model := range response.Models {
+					running[qualifiedModel(model.Name)] = true
+				}
+			}
+			host.mu.Lock()
+			host.running, host.runningAt = running, p.now()
+			host.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
+
+// qualifiedModel adds the tag Ollama assumes for models named without one
+func qualifiedModel(name string) string {
+	if !strings.Contains(name, ":") {
+		return name + ":latest"
+	}
+	return name
+}
+
+// ListModels returns the models of the first host that answers
+func (p *Pool) ListModels() ([]simpleai.Model, error) {
+	var lastErr error
+	for _, host := range p.hosts {
+		models, err := host.provider.ListModels()
+		if err == nil {
+			return models, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Name returns the provider's name
+func (p *Pool) Name() string {
+	return "ollama"
+}
+
+// IsAvailable checks every host, ejecting those that cannot be reached and readmitting
+// those that can. It reports whether any host is available.
+func (p *Pool) IsAvailable() bool {
+	var wg sync.WaitGroup
+	var available atomic.Bool
+	for _, host := range p.hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok := host.provider.IsAvailable()
+
+			host.mu.Lock()
+			defer host.mu.Unlock()
+			if ok {
+				host.ejectedUntil = time.Time{}
+				available.Store(true)
+			} else {
+				host.ejectLocked(p.now().Add(p.ejectFor))
+			}
+		}()
+	}
+	wg.Wait()
+	return available.Load()
+}
+
+// SupportedFeatures returns the capabilities supported by this provider
+func (p *Pool) SupportedFeatures() simpleai.ProviderFeatures {
+	return p.hosts[0].provider.SupportedFeatures()
+}
+
+// Status describes every host of the pool
+func (p *Pool) Status() []HostStatus {
+	now := p.now()
+	status := make([]HostStatus, len(p.hosts))
+	for i, host := range p.hosts {
+		host.mu.Lock()
+		status[i] = HostStatus{
+			Host:        host.url,
+			InFlight:    host.inFlight,
+			Latency:     host.latency,
+			ErrorRate:   host.errorRateLocked(),
+			Ejected:     now.Before(host.ejectedUntil),
+			ModelLoaded: host.running[qualifiedModel(p.defaultModel)],
+		}
+		host.mu.Unlock()
+	}
+	return status
+}
+
+// begin counts a request as in flight
+func (h *poolHost) begin() {
+	h.mu.Lock()
+	h.inFlight++
+	h.mu.Unlock()
+}
+
+// load returns the requests in flight
+func (h *poolHost) load() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.inFlight
+}
+
+// averageLatency returns the moving average latency
+func (h *poolHost) averageLatency() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.latency
+}
+
+// admit reports whether the host takes requests, ending an ejection that is over and
+// putting the host on probation
+func (h *poolHost) admit(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.ejectedUntil.IsZero() {
+		return true
+	}
+	if now.Before(h.ejectedUntil) {
+		return false
+	}
+	h.ejectedUntil = time.Time{}
+	h.probation = true
+	return true
+}
+
+// hasModel reports whether model was loaded on the host when last checked
+func (h *poolHost) hasModel(model string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return now.Sub(h.runningAt) <= 2*runningTTL && h.running[qualifiedModel(model)]
+}
+
+// errorRateLocked returns the share of recent requests that failed (h.mu must be held)
+func (h *poolHost) errorRateLocked() float64 {
+	if h.count == 0 {
+		return 0
+	}
+	failures := 0
+	for i := 0; i < h.count; i++ {
+		if h.results[i] {
+			failures++
+		}
+	}
+	return float64(failures) / float64(h.count)
+}
+
+// ejectLocked leaves the host out until the given time and forgets its results, so it
+// starts afresh when it returns (h.mu must be held)
+func (h *poolHost) ejectLocked(until time.Time) {
+	h.ejectedUntil = until
+	h.probation = false
+	h.results = [resultWindow]bool{}
+	h.count, h.position = 0, 0
+}
@@ -0,0 +1,216 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"simpleai"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// fakeHost is an Ollama server that counts the chat requests it answers
+type fakeHost struct {
+	*httptest.Server
+	chats   atomic.Int32
+	failing atomic.Bool
+	empty   atomic.Bool // Answer with no content, as a model sometimes does
+	running []string
+}
+
+func newFakeHost(t *testing.T, running ...string) *fakeHost {
+	host := &fakeHost{running: running}
+	host.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/ps":
+			var response api.ProcessResponse
+			for _, name := range host.running {
+				response.Models = append(response.Models, api.ProcessModelResponse{Name: name, Model: name})
+			}
+			json.NewEncoder(w).Encode(response)
+		case "/api/tags":
+			json.NewEncoder(w).Encode(api.ListResponse{Models: []api.ListModelResponse{{Name: "llama3.1:latest"}}})
+		case "/api/chat":
+			host.chats.Add(1)
+			if host.failing.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]string{"error": "overloaded"})
+				return
+			}
+			content := "hi"
+			if host.empty.Load() {
+				content = ""
+			}
+			json.NewEncoder(w).Encode(api.ChatResponse{
+				Model:      "llama3.1:latest",
+				CreatedAt:  time.Now(),
+				Message:    api.Message{Role: "assistant", Content: content},
+				Done:       true,
+				DoneReason: "stop",
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(host.Close)
+	return host
+}
+
+func newTestPool(t *testing.T, config map[string]interface{}) *Pool {
+	provider, err := NewPool(config)
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	pool := provider.(*Pool)
+	pool.fetchRunning(context.Background())
+	return pool
+}
+
+func chat(t *testing.T, pool *Pool) {
+	t.Helper()
+	request := simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "hello"}}}
+	if _, err := pool.Chat(request); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	a, b := newFakeHost(t), newFakeHost(t)
+	pool := newTestPool(t, map[string]interface{}{"hosts": []interface{}{a.URL, b.URL}})
+
+	for i := 0; i < 4; i++ {
+		chat(t, pool)
+	}
+	if a.chats.Load() != 2 || b.chats.Load() != 2 {
+		t.Errorf("Expected 2 requests per host, got %d and %d", a.chats.Load(), b.chats.Load())
+	}
+}
+
+func TestPoolPrefersLoadedModel(t *testing.T) {
+	cold, warm := newFakeHost(t), newFakeHost(t, "llama3.1")
+	pool := newTestPool(t, map[string]interface{}{
+		"hosts":         cold.URL + "," + warm.URL,
+		"default_model": "llama3.1:latest",
+		"strategy":      StrategyLeastInFlight,
+	})
+
+	for i := 0; i < 3; i++ {
+		chat(t, pool)
+	}
+	if cold.chats.Load() != 0 || warm.chats.Load() != 3 {
+		t.Errorf("Expected all requests on the host with the model loaded, got %d and %d", cold.chats.Load(), warm.chats.Load())
+	}
+	if status := pool.Status(); status[0].ModelLoaded || !status[1].ModelLoaded {
+		t.Errorf("Unexpected status %+v", status)
+	}
+}
+
+func TestPoolFailoverAndEjection(t *testing.T) {
+	bad, good := newFakeHost(t), newFakeHost(t)
+	bad.failing.Store(true)
+	pool := newTestPool(t, map[string]interface{}{"hosts": []interface{}{bad.URL, good.URL}})
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	// Failing over does not wait for retries on the bad host
+	begin := time.Now()
+	for i := 0; i < 10; i++ {
+		chat(t, pool)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Expected failover without backoff, took %v", elapsed)
+	}
+	if good.chats.Load() != 10 {
+		t.Errorf("Expected every request to succeed on the good host, got %d", good.chats.Load())
+	}
+	if got := bad.chats.Load(); got != minResults {
+		t.Errorf("Expected the bad host to be ejected after %d failures, got %d requests", minResults, got)
+	}
+	if !pool.Status()[0].Ejected {
+		t.Error("Expected the bad host to be ejected")
+	}
+
+	// After the ejection the host is on probation; one more failure ejects it again
+	now = now.Add(defaultEject + time.Second)
+	for i := 0; i < 4; i++ {
+		chat(t, pool)
+	}
+	if got := bad.chats.Load(); got != minResults+1 {
+		t.Errorf("Expected one probation request on the bad host, got %d", got-minResults)
+	}
+
+	// A recovered host is readmitted by IsAvailable
+	bad.failing.Store(false)
+	if !pool.IsAvailable() || pool.Status()[0].Ejected {
+		t.Error("Expected IsAvailable to readmit the recovered host")
+	}
+}
+
+func TestPoolBadOutputDoesNotEject(t *testing.T) {
+	empty, good := newFakeHost(t), newFakeHost(t)
+	empty.empty.Store(true)
+	pool := newTestPool(t, map[string]interface{}{"hosts": []interface{}{empty.URL, good.URL}})
+
+	for i := 0; i < 10; i++ {
+		chat(t, pool)
+	}
+	if good.chats.Load() != 10 {
+		t.Errorf("Expected requests to move on from empty output, got %d", good.chats.Load())
+	}
+	if status := pool.Status()[0]; status.Ejected || status.ErrorRate != 0 {
+		t.Errorf("Expected the host not to be blamed for the model's output, got %+v", status)
+	}
+}
+
+func TestPoolAllHostsFail(t *testing.T) {
+	a, b := newFakeHost(t), newFakeHost(t)
+	a.failing.Store(true)
+	b.failing.Store(true)
+	pool := newTestPool(t, map[string]interface{}{"hosts": []interface{}{a.URL, b.URL}})
+	pool.retryConfig.BaseDelay = time.Millisecond
+
+	_, err := pool.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "hello"}}})
+	if err == nil {
+		t.Fatal("Expected an error when every host fails")
+	}
+	// The default 3 retries apply to the pool, each trying every host once
+	if a.chats.Load() != 4 || b.chats.Load() != 4 {
+		t.Errorf("Expected 4 requests per host, got %d and %d", a.chats.Load(), b.chats.Load())
+	}
+}
+
+func TestPoolLatencyPrefersFasterHost(t *testing.T) {
+	slow, fast := newFakeHost(t), newFakeHost(t)
+	pool := newTestPool(t, map[string]interface{}{"hosts": []interface{}{slow.URL, fast.URL}, "strategy": StrategyLatency})
+	pool.hosts[0].latency = 900 * time.Millisecond
+	pool.hosts[1].latency = 100 * time.Millisecond
+
+	// Weights are 1/0.9 and 1/0.1, so the fast host covers draws above 0.1
+	pool.random = func() float64 { return 0.5 }
+	if host := pool.pick(map[*poolHost]bool{}); host.url != fast.URL {
+		t.Errorf("Expected the fast host, got %s", host.url)
+	}
+	pool.random = func() float64 { return 0.05 }
+	if host := pool.pick(map[*poolHost]bool{}); host.url != slow.URL {
+		t.Errorf("Expected the slow host, got %s", host.url)
+	}
+}
+
+func TestNewPoolInvalidConfig(t *testing.T) {
+	for name, config := range map[string]map[string]interface{}{
+		"no hosts":         {},
+		"unknown strategy": {"hosts": "http://localhost:11434", "strategy": "random"},
+	} {
+		_, err := NewPool(config)
+		var llmErr *simpleai.LLMError
+		if !errors.As(err, &llmErr) || llmErr.Type != simpleai.ErrInvalidConfig {
+			t.Errorf("%s: expected an invalid config error, got %v", name, err)
+		}
+	}
+}
@@ -16,42 +16,50 @@ import (
 type Provider struct {
 	client       *ollamaclient.Client
 	host         string
+	hostURL      *url.URL
 	defaultModel string
 	timeout      int
 	retryConfig  *simpleai.RetryConfig
 	ollamaClient *api.Client // Direct access for provider-specific operations
 }
 
+// Config holds the settings of an Ollama provider. Ollama has no settings beyond the common
+// ones.
+type Config struct {
+	simpleai.ProviderSettings
+}
+
 // NewProvider creates a new Ollama provider instance
 func NewProvider(config map[string]interface{}) (simpleai.Provider, error) {
-	// Extract configuration values with defaults
+	var settings Config
+	if err := simpleai.DecodeProviderConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	// Apply defaults to unset values
 	host := "http://localhost:11434"
-	if h, ok := config["host"].(string); ok && h != "" {
-		host = h
+	if settings.Host != "" {
+		host = settings.Host
 	}
 
 	defaultModel := "llama3.1:latest"
-	if m, ok := config["default_model"].(string); ok && m != "" {
-		defaultModel = m
+	if settings.DefaultModel != "" {
+		defaultModel = settings.DefaultModel
 	}
 
 	timeout := 60
-	if t, ok := config["timeout"].(int); ok && t > 0 {
-		timeout = t
+	if settings.Timeout > 0 {
+		timeout = settings.Timeout
 	}
 
 	retryAttempts := 3
-	if r, ok := config["retry_attempts"].(int); ok && r >= 0 {
-		retryAttempts = r
+	if r := settings.RetryAttempts; r != nil && *r >= 0 {
+		retryAttempts = *r
 	}
 
 	// Create retry configuration
-	retryConfig := &simpleai.RetryConfig{
-		MaxRetries:    retryAttempts,
-		BaseDelay:     2 * time.Second,
-		MaxDelay:      30 * time.Second,
-		BackoffFactor: 2.0,
-	}
+	retryConfig := simpleai.DefaultRetryConfig()
+	retryConfig.MaxRetries = retryAttempts
 
 	// Parse host URL for ollama client
 	hostURL, err := url.Parse(host)
@@ -66,11 +74,12 @@ func NewProvider(config map[string]interface{}) (simpleai.Provider, error) {
 
 	// Create the wrapped ollama client with default model
 	model := simpleai.Model{Name: defaultModel}
-	wrappedClient := ollamaclient.NewClient(model)
+	wrappedClient := ollamaclient.NewClientWithHost(hostURL, model).WithTimeout(time.Duration(timeout) * time.Second)
 
 	return &Provider{
 		client:       wrappedClient,
 		host:         host,
+		hostURL:      hostURL,
 		defaultModel: defaultModel,
 		timeout:      timeout,
 		retryConfig:  retryConfig,
@@ -84,26 +93,22 @@ func (p *Provider) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, er
 	return p.client.ChatWithRetry(request, p.retryConfig)
 }
 
+// ChatContext sends a chat request, giving up when ctx is done
+func (p *Provider) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.client.ChatContextWithRetry(ctx, request, p.retryConfig)
+}
+
+// ChatStream streams a chat request, calling handler with each chunk of the response
+func (p *Provider) ChatStream(ctx context.Context, request simpleai.ChatRequest, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	return p.client.ChatStreamWithRetry(ctx, request, p.retryConfig, handler)
+}
+
 // ListModels returns the available models for this provider
 func (p *Provider) ListModels() ([]simpleai.Model, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.timeout)*time.Second)
 	defer cancel()
 
-	// Use the direct ollama API client to list models
-	listResp, err := p.ollamaClient.List(ctx)
-	if err != nil {
-		return nil, simpleai.NewLLMError(simpleai.ErrOperationFailed,
-			"failed to list models from Ollama",
-			"list_models", true, 0, err)
-	}
-
-	// Convert ollama models to simpleai.Model format
-	models := make([]simpleai.Model, len(listResp.Models))
-	for i, model := range listResp.Models {
-		models[i] = simpleai.Model{Name: model.Name}
-	}
-
-	return models, nil
+	return p.client.ListModels(ctx)
 }
 
 // Name returns the provider's name
@@ -144,7 +149,7 @@ func (p *Provider) GetDefaultModel() string {
 // This method allows the provider to create model-specific clients when needed
 func (p *Provider) CreateClientWithModel(modelName string) *ollamaclient.Client {
 	model := simpleai.Model{Name: modelName}
-	return ollamaclient.NewClient(model)
+	return ollamaclient.NewClientWithHost(p.hostURL, model).WithTimeout(time.Duration(p.timeout) * time.Second)
 }
 
 // GetRetryConfig returns the retry configuration for this provider
@@ -0,0 +1,68 @@
+package ollama
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"simpleai"
+	"testing"
+	"time"
+)
+
+func TestChatUsesConfiguredTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the client gives up, which is only noticed once the body has been read
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(map[string]interface{}{
+		"host":           server.URL,
+		"timeout":        1,
+		"retry_attempts": 0,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	start := time.Now()
+	_, err = provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "hello"}}})
+	if err == nil {
+		t.Fatal("Expected the chat to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the 1 second timeout to apply, chat took %v", elapsed)
+	}
+}
+
+func TestListModelsClassifiesErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"error":"unauthorized"}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(map[string]interface{}{"host": server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	_, err = provider.ListModels()
+	var llmErr *simpleai.LLMError
+	if !errors.As(err, &llmErr) || !errors.Is(err, simpleai.ErrInvalidConfig) || llmErr.Retryable {
+		t.Fatalf("Expected a non-retryable ErrInvalidConfig for a 401, got %v", err)
+	}
+	if llmErr.StatusCode != http.StatusUnauthorized || llmErr.Provider != "ollama" {
+		t.Errorf("Expected the status code and provider on the error, got %+v", llmErr)
+	}
+
+	// A server that is gone refuses the connection
+	server.Close()
+	if _, err := provider.ListModels(); !errors.Is(err, simpleai.ErrConnectionFailed) {
+		t.Errorf("Expected ErrConnectionFailed for a refused connection, got %v", err)
+	}
+}
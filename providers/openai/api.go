@@ -0,0 +1,250 @@
+package openai
+
+import (
+	"reflect"
+	"simpleai"
+	"simpleai/schema"
+	"strings"
+)
+
+// chatRequest is the body of POST /chat/completions
+type chatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []chatMessage   `json:"messages"`
+	Stream         bool            `json:"stream,omitempty"`
+	StreamOptions  *streamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+	Tools          []tool          `json:"tools,omitempty"`
+	ToolChoice     any             `json:"tool_choice,omitempty"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type responseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *jsonSchema `json:"json_schema,omitempty"`
+}
+
+type jsonSchema struct {
+	Name   string        `json:"name"`
+	Schema schema.Schema `json:"schema"`
+}
+
+type tool struct {
+	Type     string       `json:"type"`
+	Function toolFunction `json:"function"`
+}
+
+type toolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+// chatMessage is a message in requests, responses and stream deltas. Content is a pointer
+// because the API uses null for assistant messages that only call tools.
+type chatMessage struct {
+	Role       string     `json:"role,omitempty"`
+	Content    *string    `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+func (m chatMessage) text() string {
+	if m.Content == nil {
+		return ""
+	}
+	return *m.Content
+}
+
+type toolCall struct {
+	Index    int          `json:"index,omitempty"` // Position of the call, in stream deltas only
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function functionCall `json:"function"`
+}
+
+type functionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// chatCompletion is a response, or one chunk of a streamed response
+type chatCompletion struct {
+	Choices []choice      `json:"choices"`
+	Usage   *usage        `json:"usage"`
+	Error   *errorDetails `json:"error"` // Some servers report failures inside a stream
+}
+
+type choice struct {
+	Message      chatMessage `json:"message"`
+	Delta        chatMessage `json:"delta"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *usage) convert() *simpleai.Usage {
+	if u == nil {
+		return nil
+	}
+	return &simpleai.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+type errorBody struct {
+	Error *errorDetails `json:"error"`
+}
+
+type errorDetails struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+type modelList struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// buildRequest converts a chat request to the API's request body
+func (p *Provider) buildRequest(request simpleai.ChatRequest, stream bool) chatRequest {
+	body := chatRequest{
+		Model:          p.defaultModel,
+		Messages:       convertMessages(request),
+		ResponseFormat: p.buildResponseFormat(request.T),
+		Tools:          convertTools(request.Tools),
+		ToolChoice:     convertToolChoice(request.ToolChoice),
+		Temperature:    request.Temperature,
+	}
+	if stream {
+		body.Stream = true
+		body.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	return body
+}
+
+// convertMessages converts the system prompt and messages to API messages. The system
+// prompt becomes the first system message.
+func convertMessages(request simpleai.ChatRequest) []chatMessage {
+	messages := make([]chatMessage, 0, len(request.Messages)+1)
+	if request.SystemPrompt.Content != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: &request.SystemPrompt.Content})
+	}
+
+	for _, msg := range request.Messages {
+		message := chatMessage{Role: msg.Role, ToolCallID: msg.ToolCallID}
+		if msg.Content != "" || len(msg.ToolCalls) == 0 {
+			content := msg.Content
+			message.Content = &content
+		}
+		for _, call := range msg.ToolCalls {
+			message.ToolCalls = append(message.ToolCalls, toolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: functionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// buildResponseFormat asks for JSON when the request has a structured output target. Only
+// JSON objects can be requested, so other targets rely on JSON extraction alone.
+func (p *Provider) buildResponseFormat(target any) *responseFormat {
+	if target == nil || p.responseFormat == FormatNone {
+		return nil
+	}
+
+	t := reflect.TypeOf(target)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && p.responseFormat == FormatJSONSchema:
+		return &responseFormat{
+			Type:       "json_schema",
+			JSONSchema: &jsonSchema{Name: schemaName(t), Schema: schema.Of(t)},
+		}
+	case t.Kind() == reflect.Struct, t.Kind() == reflect.Map:
+		return &responseFormat{Type: "json_object"}
+	default:
+		return nil
+	}
+}
+
+// schemaName derives a json_schema name from a type name, which may only contain letters,
+// digits, underscores and dashes
+func schemaName(t reflect.Type) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, t.Name())
+	if name == "" {
+		return "response"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// convertTools converts tools to function tools
+func convertTools(tools []simpleai.Tool) []tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	converted := make([]tool, len(tools))
+	for i, t := range tools {
+		parameters := t.Parameters
+		if parameters == nil {
+			// The API requires an object schema even for tools without arguments
+			parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		converted[i] = tool{
+			Type:     "function",
+			Function: toolFunction{Name: t.Name, Description: t.Description, Parameters: parameters},
+		}
+	}
+	return converted
+}
+
+// convertToolChoice passes the keyword choices through and turns anything else into a
+// choice of the named function
+func convertToolChoice(choice string) any {
+	switch choice {
+	case "":
+		return nil
+	case "auto", "none", "required":
+		return choice
+	default:
+		return map[string]any{"type": "function", "function": map[string]string{"name": choice}}
+	}
+}
+
+// convertToolCalls converts the tool calls in a response
+func convertToolCalls(calls []toolCall) []simpleai.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	converted := make([]simpleai.ToolCall, len(calls))
+	for i, call := range calls {
+		converted[i] = simpleai.ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments}
+	}
+	return converted
+}
@@ -0,0 +1,93 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"simpleai"
+	"simpleai/providertest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openaiProtocol speaks the Chat Completions protocol for the conformance suite
+type openaiProtocol struct{}
+
+func (openaiProtocol) Decode(r *http.Request) (providertest.Received, error) {
+	if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		return providertest.Received{}, errors.New("unknown endpoint " + r.URL.Path)
+	}
+
+	var request chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return providertest.Received{}, err
+	}
+
+	received := providertest.Received{Model: request.Model, Stream: request.Stream}
+	var system []string
+	for _, message := range request.Messages {
+		if message.Role == "system" {
+			system = append(system, message.text())
+			continue
+		}
+		received.Messages = append(received.Messages, simpleai.Message{Role: message.Role, Content: message.text()})
+	}
+	received.System = strings.Join(system, "\n\n")
+	return received, nil
+}
+
+func (openaiProtocol) WriteText(w http.ResponseWriter, received providertest.Received, chunks []string) {
+	usage := map[string]int{"prompt_tokens": 5, "completion_tokens": len(chunks), "total_tokens": 5 + len(chunks)}
+
+	if !received.Stream {
+		text := strings.Join(chunks, "")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{
+				"message":       map[string]any{"role": "assistant", "content": text},
+				"finish_reason": "stop",
+			}},
+			"usage": usage,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	write := func(value any) {
+		data, _ := json.Marshal(value)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		w.(http.Flusher).Flush()
+	}
+	for _, chunk := range chunks {
+		write(map[string]any{"choices": []map[string]any{{"delta": map[string]any{"content": chunk}}}})
+	}
+	write(map[string]any{"choices": []any{}, "usage": usage})
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func (openaiProtocol) WriteError(w http.ResponseWriter, received providertest.Received, status int, message string, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": message, "type": "server_error"},
+	})
+}
+
+func TestConformance(t *testing.T) {
+	providertest.Run(t, providertest.Harness{
+		Protocol: openaiProtocol{},
+		NewProvider: func(t *testing.T, baseURL string) simpleai.Provider {
+			provider, err := NewProvider(map[string]interface{}{"api_key": "test-key", "host": baseURL})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			return provider
+		},
+	})
+}
@@ -0,0 +1,318 @@
+// Package openai implements simpleai.Provider against the OpenAI Chat Completions API.
+//
+// Any server exposing the same API works, including vLLM, LM Studio and the llama.cpp
+// server; point "host" at its /v1 base URL. Structured output targets are sent as a
+// json_schema response format, tools as function tools, and streams are read as
+// server-sent events.
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"simpleai"
+	"strings"
+	"time"
+)
+
+// Response format modes accepted by the "response_format" config key
+const (
+	FormatJSONSchema = "json_schema" // Send the target's JSON Schema (default)
+	FormatJSONObject = "json_object" // Ask for any JSON object
+	FormatNone       = "none"        // Rely on the prompt and JSON extraction only
+)
+
+// Options adapt the provider to services that follow the Chat Completions API with their
+// own URLs, credentials or errors, such as Azure OpenAI. Unset fields keep the OpenAI
+// behaviour.
+type Options struct {
+	// Name is reported by Name and on errors ("openai" by default)
+	Name string
+
+	// URL returns the full URL of an API path such as "/chat/completions". By default the
+	// path is appended to the configured host.
+	URL func(path string) string
+
+	// Authorize adds credentials to a request. By default api_key is sent as a bearer token.
+	Authorize func(req *http.Request) error
+
+	// ClassifyError classifies an error response from its status and body. It returns nil
+	// to fall back to classifying by status code.
+	ClassifyError func(status int, body []byte, operation string, attempt int) *simpleai.LLMError
+}
+
+// Provider implements the simpleai.Provider interface for OpenAI-compatible servers
+type Provider struct {
+	options        Options
+	api            *simpleai.APIClient
+	apiKey         string
+	defaultModel   string
+	timeout        int
+	responseFormat string
+	retryConfig    *simpleai.RetryConfig
+}
+
+// Config holds the settings of an OpenAI provider
+type Config struct {
+	simpleai.ProviderSettings
+	ResponseFormat string `json:"response_format,omitempty" description:"How structured output is requested: json_schema (default), json_object or none"`
+}
+
+// NewProvider creates a new OpenAI provider instance
+func NewProvider(config map[string]interface{}) (simpleai.Provider, error) {
+	provider, err := NewProviderWithOptions(config, Options{})
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// NewProviderWithOptions creates a provider for a service that differs from OpenAI in the
+// ways options describe
+func NewProviderWithOptions(config map[string]interface{}, options Options) (*Provider, error) {
+	var settings Config
+	if err := simpleai.DecodeProviderConfig(config, &settings); err != nil {
+		return nil, err
+	}
+	return NewProviderWithConfig(settings, options)
+}
+
+// NewProviderWithConfig creates a provider from decoded settings, for services that embed
+// Config in their own
+func NewProviderWithConfig(settings Config, options Options) (*Provider, error) {
+	if options.Name == "" {
+		options.Name = "openai"
+	}
+
+	// Extract configuration values with defaults
+	host := "https://api.openai.com/v1"
+	if settings.Host != "" {
+		host = settings.Host
+	}
+
+	// Local servers usually need no key, so it is optional
+	apiKey := settings.APIKey
+
+	defaultModel := "gpt-4o-mini"
+	if settings.DefaultModel != "" {
+		defaultModel = settings.DefaultModel
+	}
+
+	timeout := 60
+	if settings.Timeout > 0 {
+		timeout = settings.Timeout
+	}
+
+	retryAttempts := 3
+	if r := settings.RetryAttempts; r != nil && *r >= 0 {
+		retryAttempts = *r
+	}
+
+	responseFormat := FormatJSONSchema
+	if f := settings.ResponseFormat; f != "" {
+		switch f {
+		case FormatJSONSchema, FormatJSONObject, FormatNone:
+			responseFormat = f
+		default:
+			return nil, simpleai.NewLLMError(simpleai.ErrInvalidConfig,
+				fmt.Sprintf("unknown response_format %q", f),
+				"provider_creation", false, 0, nil)
+		}
+	}
+
+	// Create retry configuration
+	retryConfig := simpleai.DefaultRetryConfig()
+	retryConfig.MaxRetries = retryAttempts
+
+	provider := &Provider{
+		options:        options,
+		apiKey:         apiKey,
+		defaultModel:   defaultModel,
+		timeout:        timeout,
+		responseFormat: responseFormat,
+		retryConfig:    retryConfig,
+	}
+	provider.api = &simpleai.APIClient{
+		BaseURL:        strings.TrimSuffix(host, "/"),
+		URL:            options.URL,
+		Authorize:      provider.authorize,
+		ClassifyStatus: provider.classifyStatusError,
+		Service:        "OpenAI API",
+		Provider:       options.Name,
+		Model:          defaultModel,
+	}
+	return provider, nil
+}
+
+// Chat sends a chat request and returns a response
+func (p *Provider) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatWithRetry(request, p.retryConfig)
+}
+
+// ChatContext sends a chat request, giving up when ctx is done
+func (p *Provider) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(ctx, request, p.retryConfig)
+}
+
+// ChatWithRetry executes a chat request with retry logic
+func (p *Provider) ChatWithRetry(request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	return p.ChatContextWithRetry(context.Background(), request, retryConfig)
+}
+
+// ChatContextWithRetry executes a cancellable chat request with retry logic. Responses that
+// call tools are returned without decoding structured output.
+func (p *Provider) ChatContextWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		var completion chatCompletion
+		if err := p.api.Do(ctx, http.MethodPost, "/chat/completions", p.buildRequest(request, false), &completion, attempt, "chat"); err != nil {
+			return err
+		}
+
+		var message chatMessage
+		if len(completion.Choices) > 0 {
+			if completion.Choices[0].FinishReason == "content_filter" {
+				return p.filteredError(attempt, "chat")
+			}
+			message = completion.Choices[0].Message
+		}
+		text := message.text()
+		toolCalls := convertToolCalls(message.ToolCalls)
+		usage := completion.Usage.convert()
+
+		if len(toolCalls) > 0 {
+			response = simpleai.ChatResponse{Message: text, ToolCalls: toolCalls, Usage: usage}
+			return nil
+		}
+		if text == "" {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from OpenAI API",
+				"chat", true, attempt, nil).WithProvider(p.options.Name, p.defaultModel)
+		}
+
+		structuredData, err := simpleai.ParseStructuredOutput(text, request.T, request.Extractor, attempt)
+		if err != nil {
+			return err
+		}
+
+		response = simpleai.ChatResponse{Message: text, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	return response, nil
+}
+
+// ListModels returns the models the server reports
+func (p *Provider) ListModels() ([]simpleai.Model, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.timeout)*time.Second)
+	defer cancel()
+
+	var list modelList
+	if err := p.api.Do(ctx, http.MethodGet, "/models", nil, &list, 0, "list_models"); err != nil {
+		return nil, err
+	}
+
+	models := make([]simpleai.Model, len(list.Data))
+	for i, model := range list.Data {
+		models[i] = simpleai.Model{Name: model.ID}
+	}
+	return models, nil
+}
+
+// Name returns the provider's name
+func (p *Provider) Name() string {
+	return p.options.Name
+}
+
+// IsAvailable checks if the provider is currently available/reachable
+func (p *Provider) IsAvailable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Listing models is cheap and needs the same credentials as chat
+	return p.api.Do(ctx, http.MethodGet, "/models", nil, nil, 0, "health_check") == nil
+}
+
+// SupportedFeatures returns the capabilities supported by this provider
+func (p *Provider) SupportedFeatures() simpleai.ProviderFeatures {
+	return simpleai.ProviderFeatures{
+		StructuredOutput: true,   // response_format json_schema and json_object
+		Streaming:        true,   // Server-sent events
+		Vision:           false,  // Image inputs are not sent
+		MaxTokens:        128000, // gpt-4o context window (varies by server and model)
+		SupportedRoles:   []string{"system", "user", "assistant", "tool"},
+		FunctionCalling:  true, // Function tools
+		Temperature:      true,
+		TopP:             true,
+	}
+}
+
+// GetDefaultModel returns the default model for this provider
+func (p *Provider) GetDefaultModel() string {
+	return p.defaultModel
+}
+
+// GetRetryConfig returns the retry configuration for this provider
+func (p *Provider) GetRetryConfig() *simpleai.RetryConfig {
+	return p.retryConfig
+}
+
+// UpdateRetryConfig allows updating the retry configuration
+func (p *Provider) UpdateRetryConfig(config *simpleai.RetryConfig) {
+	if config != nil {
+		p.retryConfig = config
+	}
+}
+
+// authorize adds credentials to a request
+func (p *Provider) authorize(req *http.Request) error {
+	if p.options.Authorize != nil {
+		return p.options.Authorize(req)
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	return nil
+}
+
+// filteredError reports a response stopped by the service's content filter
+func (p *Provider) filteredError(attempt int, operation string) *simpleai.LLMError {
+	return simpleai.NewLLMError(simpleai.ErrContentFiltered,
+		"response blocked by content filter", operation, false, attempt, nil).
+		WithProvider(p.options.Name, p.defaultModel)
+}
+
+// classifyStatusError classifies an error response by its status code, keeping the API's
+// message and retry hint
+func (p *Provider) classifyStatusError(resp *http.Response, attempt int, operation string) *simpleai.LLMError {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	retryAfter := simpleai.ParseRetryAfter(resp.Header.Get("Retry-After"))
+
+	if p.options.ClassifyError != nil {
+		if llmErr := p.options.ClassifyError(resp.StatusCode, data, operation, attempt); llmErr != nil {
+			return llmErr.WithProvider(p.options.Name, p.defaultModel).WithRetryAfter(retryAfter)
+		}
+	}
+
+	message := http.StatusText(resp.StatusCode)
+	var body errorBody
+	if json.Unmarshal(data, &body) == nil && body.Error != nil && body.Error.Message != "" {
+		message = body.Error.Message
+	} else if text := strings.TrimSpace(string(data)); text != "" {
+		message = text
+	}
+
+	return simpleai.NewStatusError(resp.StatusCode,
+		fmt.Sprintf("OpenAI API error: %s", message), operation, attempt, nil).
+		WithProvider(p.options.Name, p.defaultModel).
+		WithRetryAfter(retryAfter)
+}
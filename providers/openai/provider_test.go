@@ -0,0 +1,264 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"simpleai"
+	"simpleai/providertest"
+	"testing"
+)
+
+// newTestProvider creates a provider for a server answering with handler
+func newTestProvider(t *testing.T, config map[string]interface{}, handler http.HandlerFunc) *Provider {
+	t.Helper()
+	return providertest.NewProvider(t, NewProvider, config, handler).(*Provider)
+}
+
+func TestNewProviderInvalidResponseFormat(t *testing.T) {
+	_, err := NewProvider(map[string]interface{}{"response_format": "xml"})
+	if !errors.Is(err, simpleai.ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestStructuredOutputRequest(t *testing.T) {
+	type weather struct {
+		City        string  `json:"city" description:"City name"`
+		Temperature float64 `json:"temperature"`
+		Note        string  `json:"note,omitempty"`
+	}
+
+	var body map[string]any
+	provider := newTestProvider(t, map[string]interface{}{"api_key": "secret", "default_model": "local"}, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Expected bearer authorization, got %q", got)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"{\"city\":\"Oslo\",\"temperature\":4.5}"}}],"usage":{"prompt_tokens":9,"completion_tokens":6,"total_tokens":15}}`)
+	})
+
+	var target weather
+	temperature := 0.2
+	response, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Weather?"}}, T: &target, Temperature: &temperature})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if target.City != "Oslo" || target.Temperature != 4.5 {
+		t.Errorf("Unexpected structured output: %+v", target)
+	}
+	if response.Usage == nil || response.Usage.TotalTokens != 15 {
+		t.Errorf("Expected usage to be reported, got %+v", response.Usage)
+	}
+
+	if body["model"] != "local" || body["temperature"] != 0.2 {
+		t.Errorf("Expected the default model and temperature, got %v, %v", body["model"], body["temperature"])
+	}
+	format, _ := body["response_format"].(map[string]any)
+	if format["type"] != "json_schema" {
+		t.Fatalf("Expected a json_schema response format, got %v", body["response_format"])
+	}
+	jsonSchema := format["json_schema"].(map[string]any)
+	if jsonSchema["name"] != "weather" {
+		t.Errorf("Expected the schema to be named after the type, got %v", jsonSchema["name"])
+	}
+	required := jsonSchema["schema"].(map[string]any)["required"].([]any)
+	if len(required) != 2 {
+		t.Errorf("Expected city and temperature to be required, got %v", required)
+	}
+}
+
+func TestResponseFormatModes(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+	}
+	var list []item
+
+	for _, tc := range []struct {
+		mode   string
+		target any
+		want   any
+	}{
+		{FormatJSONSchema, &list, nil},
+		{FormatJSONSchema, &map[string]int{}, "json_object"},
+		{FormatJSONObject, &item{}, "json_object"},
+		{FormatNone, &item{}, nil},
+	} {
+		provider, err := NewProvider(map[string]interface{}{"response_format": tc.mode})
+		if err != nil {
+			t.Fatalf("Failed to create provider: %v", err)
+		}
+		format := provider.(*Provider).buildResponseFormat(tc.target)
+		var got any
+		if format != nil {
+			got = format.Type
+		}
+		if got != tc.want {
+			t.Errorf("%s with %T: expected %v, got %v", tc.mode, tc.target, tc.want, got)
+		}
+	}
+}
+
+func TestToolCalls(t *testing.T) {
+	var body chatRequest
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Oslo\"}"}}]},"finish_reason":"tool_calls"}]}`)
+	})
+
+	response, err := provider.Chat(simpleai.ChatRequest{
+		Messages: []simpleai.Message{
+			{Role: "user", Content: "Weather in Paris?"},
+			{Role: "assistant", ToolCalls: []simpleai.ToolCall{{ID: "call_0", Name: "get_weather", Arguments: `{"city":"Paris"}`}}},
+			{Role: "tool", Content: `{"temperature":12}`, ToolCallID: "call_0"},
+			{Role: "user", Content: "And Oslo?"},
+		},
+		Tools:      []simpleai.Tool{{Name: "get_weather", Description: "Current weather"}},
+		ToolChoice: "get_weather",
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	want := simpleai.ToolCall{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`}
+	if len(response.ToolCalls) != 1 || response.ToolCalls[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, response.ToolCalls)
+	}
+
+	if len(body.Tools) != 1 || body.Tools[0].Type != "function" || body.Tools[0].Function.Parameters["type"] != "object" {
+		t.Errorf("Expected a function tool with an object schema, got %+v", body.Tools)
+	}
+	if choice, _ := body.ToolChoice.(map[string]any); choice["type"] != "function" {
+		t.Errorf("Expected a named function tool choice, got %v", body.ToolChoice)
+	}
+	assistant := body.Messages[1]
+	if assistant.Content != nil || len(assistant.ToolCalls) != 1 || assistant.ToolCalls[0].Function.Name != "get_weather" {
+		t.Errorf("Expected the assistant's tool call with null content, got %+v", assistant)
+	}
+	if body.Messages[2].ToolCallID != "call_0" {
+		t.Errorf("Expected the tool result to reference its call, got %+v", body.Messages[2])
+	}
+}
+
+func TestStreamToolCallDeltas(t *testing.T) {
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"choices":[{"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"get_time","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Oslo\"}"}}]}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`,
+			`[DONE]`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+	})
+
+	var done bool
+	response, err := provider.ChatStream(context.Background(), simpleai.ChatRequest{
+		Messages: []simpleai.Message{{Role: "user", Content: "Weather and time?"}},
+		Tools:    []simpleai.Tool{{Name: "get_weather"}, {Name: "get_time"}},
+	}, func(chunk simpleai.StreamChunk) error {
+		done = done || chunk.Done
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+
+	want := []simpleai.ToolCall{
+		{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`},
+		{ID: "call_2", Name: "get_time", Arguments: `{}`},
+	}
+	if len(response.ToolCalls) != len(want) || response.ToolCalls[0] != want[0] || response.ToolCalls[1] != want[1] {
+		t.Errorf("Expected %+v, got %+v", want, response.ToolCalls)
+	}
+	if !done {
+		t.Error("Expected a final chunk marked Done")
+	}
+	if response.Usage == nil || response.Usage.TotalTokens != 7 {
+		t.Errorf("Expected usage from the last chunk, got %+v", response.Usage)
+	}
+}
+
+func TestListModels(t *testing.T) {
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"qwen2.5-7b","object":"model"},{"id":"llama-3.1-8b","object":"model"}]}`)
+	})
+
+	models, err := provider.ListModels()
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if len(models) != 2 || models[0].Name != "qwen2.5-7b" {
+		t.Errorf("Unexpected models: %+v", models)
+	}
+	if !provider.IsAvailable() {
+		t.Error("Expected the provider to be available")
+	}
+}
+
+func TestRegisterWithFactory(t *testing.T) {
+	factory := simpleai.NewLLMFactory()
+	factory.RegisterProvider("openai", NewProvider)
+
+	provider, err := factory.CreateProvider("openai", map[string]interface{}{"host": "http://localhost:8000/v1"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if provider.Name() != "openai" {
+		t.Errorf("Expected provider name 'openai', got %q", provider.Name())
+	}
+}
+
+func TestContentFilterFinishReason(t *testing.T) {
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":null},"finish_reason":"content_filter"}]}`)
+	})
+
+	_, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hello"}}})
+	if !errors.Is(err, simpleai.ErrContentFiltered) || simpleai.IsRetryable(err) {
+		t.Errorf("Expected a non-retryable ErrContentFiltered, got %v", err)
+	}
+}
+
+func TestStreamEndedEarly(t *testing.T) {
+	provider := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"The answer is\"}}]}\n\n")
+	})
+
+	var done bool
+	_, err := provider.ChatStream(context.Background(), simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Hello"}}},
+		func(chunk simpleai.StreamChunk) error {
+			done = done || chunk.Done
+			return nil
+		})
+	if !errors.Is(err, simpleai.ErrConnectionFailed) || simpleai.IsRetryable(err) {
+		t.Errorf("Expected a non-retryable ErrConnectionFailed after streamed text, got %v", err)
+	}
+	if done {
+		t.Error("Expected no chunk marked Done for a cut-off stream")
+	}
+}
+
+func TestStreamToolCallIndexOutOfRange(t *testing.T) {
+	provider := newTestProvider(t, map[string]interface{}{"retry_attempts": 0}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":1000000000,\"id\":\"call_1\",\"function\":{\"name\":\"get_weather\"}}]}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	_, err := provider.ChatStream(context.Background(), simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: "Weather?"}}},
+		func(simpleai.StreamChunk) error { return nil })
+	if !errors.Is(err, simpleai.ErrInvalidResponse) {
+		t.Errorf("Expected ErrInvalidResponse for a tool call index past the next call, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"simpleai"
+	"strings"
+	"time"
+)
+
+// ChatStream streams a chat request, calling handler with each chunk of the response
+func (p *Provider) ChatStream(ctx context.Context, request simpleai.ChatRequest, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	return p.ChatStreamWithRetry(ctx, request, p.retryConfig, handler)
+}
+
+// ChatStreamWithRetry streams a chat request with retry logic. Attempts are only retried
+// until the first chunk reaches handler, so a response is never delivered twice. Tool calls
+// are assembled from their deltas and returned in the response.
+func (p *Provider) ChatStreamWithRetry(ctx context.Context, request simpleai.ChatRequest, retryConfig *simpleai.RetryConfig, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	var response simpleai.ChatResponse
+
+	err := simpleai.NewRetryExecutor(retryConfig).Execute(ctx, func(ctx context.Context, attempt int) error {
+		text := ""
+		started := false
+		var usage *simpleai.Usage
+		var calls []toolCall
+		finished := false
+
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.timeout)*time.Second)
+		defer cancel()
+
+		resp, err := p.api.Send(ctx, http.MethodPost, "/chat/completions", p.buildRequest(request, true), attempt, "chat_stream")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		fail := func(err error) error {
+			if started {
+				return simpleai.StopRetrying(err)
+			}
+			return err
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				// Blank separators, comments and other event fields
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				finished = true
+				break
+			}
+
+			var chunk chatCompletion
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return fail(simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+					"invalid stream chunk from OpenAI API", "chat_stream", true, attempt, err).
+					WithProvider(p.options.Name, p.defaultModel))
+			}
+			if chunk.Error != nil {
+				return fail(simpleai.NewLLMError(simpleai.ErrOperationFailed,
+					fmt.Sprintf("OpenAI API error: %s", chunk.Error.Message), "chat_stream", true, attempt, nil).
+					WithProvider(p.options.Name, p.defaultModel))
+			}
+			// With include_usage the last chunk carries the totals and no choices
+			if chunkUsage := chunk.Usage.convert(); chunkUsage != nil {
+				usage = chunkUsage
+			}
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+
+			if chunk.Choices[0].FinishReason == "content_filter" {
+				// Whatever was streamed so far is incomplete
+				return fail(p.filteredError(attempt, "chat_stream"))
+			}
+			finished = finished || chunk.Choices[0].FinishReason != ""
+			delta := chunk.Choices[0].Delta
+			if calls, ok = mergeToolCalls(calls, delta.ToolCalls); !ok {
+				return fail(simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+					"tool call index out of order in OpenAI API stream", "chat_stream", true, attempt, nil).
+					WithProvider(p.options.Name, p.defaultModel))
+			}
+
+			if delta.text() == "" {
+				continue
+			}
+			text += delta.text()
+			started = true
+			if err := handler(simpleai.StreamChunk{Delta: delta.text(), Text: text}); err != nil {
+				// The caller stopped the stream; keep its error as the cause
+				return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+					"stream handler failed", "chat_stream", false, attempt, err)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			}
+			return fail(p.api.TransportError(err, attempt, "chat_stream"))
+		}
+		if !finished {
+			// The connection was closed before the server finished the response
+			return fail(simpleai.NewLLMError(simpleai.ErrConnectionFailed,
+				"stream from OpenAI API ended before the response was finished", "chat_stream", true, attempt, nil).
+				WithProvider(p.options.Name, p.defaultModel))
+		}
+
+		toolCalls := convertToolCalls(calls)
+		if text == "" && len(toolCalls) == 0 {
+			return simpleai.NewLLMError(simpleai.ErrInvalidResponse,
+				"empty response from OpenAI API",
+				"chat_stream", true, attempt, nil).WithProvider(p.options.Name, p.defaultModel)
+		}
+
+		if err := handler(simpleai.StreamChunk{Text: text, Done: true}); err != nil {
+			return simpleai.NewLLMError(simpleai.ErrOperationFailed,
+				"stream handler failed", "chat_stream", false, attempt, err)
+		}
+
+		if len(toolCalls) > 0 {
+			response = simpleai.ChatResponse{Message: text, ToolCalls: toolCalls, Usage: usage}
+			return nil
+		}
+
+		structuredData, err := simpleai.ParseStructuredOutput(text, request.T, request.Extractor, attempt)
+		if err != nil {
+			// The response has already been streamed, so asking again would repeat it
+			return simpleai.StopRetrying(err)
+		}
+
+		response = simpleai.ChatResponse{Message: text, Data: structuredData, Usage: usage}
+		return nil
+	})
+	if err != nil {
+		return simpleai.ChatResponse{}, err
+	}
+
+	return response, nil
+}
+
+// mergeToolCalls adds streamed tool call deltas to the calls assembled so far. The first
+// delta of a call carries its ID and name; later ones append to its arguments. Calls are
+// numbered in order, so ok is false for a delta that skips ahead of the next call.
+func mergeToolCalls(calls []toolCall, deltas []toolCall) (merged []toolCall, ok bool) {
+	for _, delta := range deltas {
+		if delta.Index < 0 {
+			continue
+		}
+		if delta.Index > len(calls) {
+			return calls, false
+		}
+		if delta.Index == len(calls) {
+			calls = append(calls, toolCall{Type: "function"})
+		}
+		call := &calls[delta.Index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Function.Name != "" {
+			call.Function.Name = delta.Function.Name
+		}
+		call.Function.Arguments += delta.Function.Arguments
+	}
+	return calls, true
+}
@@ -0,0 +1,449 @@
+// Package providertest is a conformance suite for simpleai.Provider implementations.
+//
+// The suite runs a provider against a local stand-in Server that speaks the provider's wire
+// protocol, and checks the behaviour every provider must share:
+//   - the system prompt and system messages reach the model as system instructions, and
+//     user and assistant turns keep their roles and order
+//   - structured output is decoded into T, and responses without usable JSON are retried
+//   - empty responses are retried as ErrInvalidResponse
+//   - HTTP errors are classified consistently, with retryable errors retried and others not
+//   - cancelling the context stops a request promptly without retrying it
+//   - SupportedFeatures matches what the provider implements
+//   - concurrent requests get their own responses
+//
+// A provider's tests implement a Protocol and call Run:
+//
+//	func TestConformance(t *testing.T) {
+//		providertest.Run(t, providertest.Harness{
+//			Protocol: ollamaProtocol{},
+//			NewProvider: func(t *testing.T, baseURL string) simpleai.Provider {
+//				provider, err := NewProvider(map[string]interface{}{"host": baseURL})
+//				...
+//			},
+//		})
+//	}
+package providertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"simpleai"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Harness adapts a provider implementation to the suite
+type Harness struct {
+	// Protocol speaks the provider's wire format on the stand-in server
+	Protocol Protocol
+
+	// NewProvider creates the provider under test, configured to use the stand-in server at
+	// baseURL
+	NewProvider func(t *testing.T, baseURL string) simpleai.Provider
+}
+
+// RetryConfigurable is implemented by providers whose retry behaviour can be changed. The
+// suite uses it to make retries fast; retry checks are skipped for other providers.
+type RetryConfigurable interface {
+	UpdateRetryConfig(config *simpleai.RetryConfig)
+}
+
+// testMaxRetries is the retry budget the suite configures
+const testMaxRetries = 2
+
+// Run runs the conformance suite against the harness's provider
+func Run(t *testing.T, harness Harness) {
+	t.Run("Roles", func(t *testing.T) { testRoles(t, harness) })
+	t.Run("SystemMessages", func(t *testing.T) { testSystemMessages(t, harness) })
+	t.Run("SupportedRoles", func(t *testing.T) { testSupportedRoles(t, harness) })
+	t.Run("StructuredOutput", func(t *testing.T) { testStructuredOutput(t, harness) })
+	t.Run("StructuredOutputRetry", func(t *testing.T) { testStructuredOutputRetry(t, harness) })
+	t.Run("EmptyResponse", func(t *testing.T) { testEmptyResponse(t, harness) })
+	t.Run("ErrorClassification", func(t *testing.T) { testErrorClassification(t, harness) })
+	t.Run("RetryAfter", func(t *testing.T) { testRetryAfter(t, harness) })
+	t.Run("Cancellation", func(t *testing.T) { testCancellation(t, harness) })
+	t.Run("Streaming", func(t *testing.T) { testStreaming(t, harness) })
+	t.Run("Features", func(t *testing.T) { testFeatures(t, harness) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, harness) })
+}
+
+// setup starts a stand-in server and creates a provider for it with fast retries
+func setup(t *testing.T, harness Harness, maxRetries int) (*Server, simpleai.Provider) {
+	t.Helper()
+	server := NewServer(t, harness.Protocol)
+	provider := harness.NewProvider(t, server.URL)
+
+	if configurable, ok := provider.(RetryConfigurable); ok {
+		configurable.UpdateRetryConfig(&simpleai.RetryConfig{
+			MaxRetries:    maxRetries,
+			BaseDelay:     time.Millisecond,
+			MaxDelay:      5 * time.Millisecond,
+			BackoffFactor: 2,
+			Jitter:        simpleai.JitterNone,
+		})
+	}
+	return server, provider
+}
+
+// requireRetries skips retry checks for providers whose retries cannot be configured
+func requireRetries(t *testing.T, provider simpleai.Provider) {
+	t.Helper()
+	if _, ok := provider.(RetryConfigurable); !ok {
+		t.Skipf("%s does not implement UpdateRetryConfig", provider.Name())
+	}
+}
+
+func userMessage(content string) simpleai.Message {
+	return simpleai.Message{Role: "user", Content: content}
+}
+
+func testRoles(t *testing.T, harness Harness) {
+	server, provider := setup(t, harness, 0)
+
+	messages := []simpleai.Message{
+		userMessage("Hi"),
+		{Role: "assistant", Content: "Hello! How can I help?"},
+		userMessage("Tell me a joke"),
+	}
+	if _, err := provider.Chat(simpleai.ChatRequest{
+		SystemPrompt: simpleai.SystemPrompt{Content: "Be terse."},
+		Messages:     messages,
+	}); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if _, err := provider.Chat(simpleai.ChatRequest{Messages: messages[:1]}); err != nil {
+		t.Fatalf("Chat without system prompt failed: %v", err)
+	}
+
+	received := server.Received()
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(received))
+	}
+	if received[0].System != "Be terse." {
+		t.Errorf("Expected system prompt %q, got %q", "Be terse.", received[0].System)
+	}
+	if !reflect.DeepEqual(received[0].Messages, messages) {
+		t.Errorf("Expected turns %+v, got %+v", messages, received[0].Messages)
+	}
+	if received[1].System != "" {
+		t.Errorf("Expected no system instructions without a system prompt, got %q", received[1].System)
+	}
+}
+
+func testSystemMessages(t *testing.T, harness Harness) {
+	server, provider := setup(t, harness, 0)
+	if !slices.Contains(provider.SupportedFeatures().SupportedRoles, "system") {
+		t.Skip("provider does not support system messages")
+	}
+
+	_, err := provider.Chat(simpleai.ChatRequest{
+		SystemPrompt: simpleai.SystemPrompt{Content: "Be terse."},
+		Messages:     []simpleai.Message{{Role: "system", Content: "Answer in French."}, userMessage("Hi")},
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	received := server.Received()[0]
+	if received.System != "Be terse.\n\nAnswer in French." {
+		t.Errorf("Expected system prompt followed by system messages, got %q", received.System)
+	}
+	if !reflect.DeepEqual(received.Messages, []simpleai.Message{userMessage("Hi")}) {
+		t.Errorf("Expected system messages to be left out of the turns, got %+v", received.Messages)
+	}
+}
+
+func testSupportedRoles(t *testing.T, harness Harness) {
+	_, provider := setup(t, harness, 0)
+
+	for _, role := range provider.SupportedFeatures().SupportedRoles {
+		messages := []simpleai.Message{{Role: role, Content: "A " + role + " message"}}
+		if role != "user" {
+			messages = append(messages, userMessage("Go on"))
+		}
+		if _, err := provider.Chat(simpleai.ChatRequest{Messages: messages}); err != nil {
+			t.Errorf("Supported role %q was rejected: %v", role, err)
+		}
+	}
+}
+
+type item struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+func testStructuredOutput(t *testing.T, harness Harness) {
+	server, provider := setup(t, harness, 0)
+	if !provider.SupportedFeatures().StructuredOutput {
+		t.Skip("provider does not support structured output")
+	}
+	server.Script(Text("Sure:\n```json\n{\"name\": \"apple\", \"price\": 3}\n```"))
+
+	var target item
+	response, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{userMessage("Price?")}, T: &target})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if target != (item{Name: "apple", Price: 3}) {
+		t.Errorf("Expected target to be decoded, got %+v", target)
+	}
+	if response.Data != &target {
+		t.Errorf("Expected Data to be the target pointer, got %#v", response.Data)
+	}
+	if response.Message == "" {
+		t.Error("Expected the raw message alongside the data")
+	}
+}
+
+func testStructuredOutputRetry(t *testing.T, harness Harness) {
+	server, provider := setup(t, harness, testMaxRetries)
+	requireRetries(t, provider)
+	if !provider.SupportedFeatures().StructuredOutput {
+		t.Skip("provider does not support structured output")
+	}
+	server.Script(Text("I'm not sure what you mean."), Text(`{"name": "pear", "price": 2}`))
+
+	var target item
+	if _, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{userMessage("Price?")}, T: &target}); err != nil {
+		t.Fatalf("Expected a retry after a response without JSON, got %v", err)
+	}
+	if target.Name != "pear" || server.Calls() != 2 {
+		t.Errorf("Expected the second response after 2 calls, got %+v after %d", target, server.Calls())
+	}
+
+	// Persistent failures end in a retryable parse error once retries are exhausted
+	server.SetDefault(func(Received) Reply { return Text("still no JSON") })
+	_, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{userMessage("Price?")}, T: &target})
+	if !errors.Is(err, simpleai.ErrJSONParseFailed) {
+		t.Errorf("Expected ErrJSONParseFailed, got %v", err)
+	}
+}
+
+func testEmptyResponse(t *testing.T, harness Harness) {
+	server, provider := setup(t, harness, testMaxRetries)
+	requireRetries(t, provider)
+	server.Script(Text(""), Text("hello"))
+
+	response, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{userMessage("Hi")}})
+	if err != nil || response.Message != "hello" {
+		t.Fatalf("Expected an empty response to be retried, got %q, %v", response.Message, err)
+	}
+
+	server.SetDefault(func(Received) Reply { return Text("") })
+	_, err = provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{userMessage("Hi")}})
+	if !errors.Is(err, simpleai.ErrInvalidResponse) || !simpleai.IsRetryable(err) {
+		t.Errorf("Expected a retryable ErrInvalidResponse, got %v", err)
+	}
+}
+
+func testErrorClassification(t *testing.T, harness Harness) {
+	tests := []struct {
+		status    int
+		errType   error
+		retryable bool
+	}{
+		{http.StatusUnauthorized, simpleai.ErrInvalidConfig, false},
+		{http.StatusForbidden, simpleai.ErrInvalidConfig, false},
+		{http.StatusNotFound, simpleai.ErrModelNotAvailable, false},
+		{http.StatusTooManyRequests, simpleai.ErrRateLimitExceeded, true},
+		{http.StatusInternalServerError, simpleai.ErrOperationFailed, true},
+		{http.StatusServiceUnavailable, simpleai.ErrConnectionFailed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status), func(t *testing.T) {
+			server, provider := setup(t, harness, testMaxRetries)
+			requireRetries(t, provider)
+			server.SetDefault(func(Received) Reply { return Status(tt.status, "scripted failure") })
+
+			_, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{userMessage("Hi")}})
+			if !errors.Is(err, tt.errType) {
+				t.Fatalf("Expected %v for status %d, got %v", tt.errType, tt.status, err)
+			}
+			if simpleai.IsRetryable(err) != tt.retryable {
+				t.Errorf("Expected retryable=%v, got %v", tt.retryable, err)
+			}
+
+			var llmErr *simpleai.LLMError
+			if errors.As(err, &llmErr) {
+				if llmErr.StatusCode != tt.status {
+					t.Errorf("Expected status code %d on the error, got %d", tt.status, llmErr.StatusCode)
+				}
+				if llmErr.Provider != provider.Name() {
+					t.Errorf("Expected provider %q on the error, got %q", provider.Name(), llmErr.Provider)
+				}
+			}
+
+			calls := 1
+			if tt.retryable {
+				calls = testMaxRetries + 1
+			}
+			if server.Calls() != calls {
+				t.Errorf("Expected %d calls, got %d", calls, server.Calls())
+			}
+		})
+	}
+
+	t.Run("Recovers", func(t *testing.T) {
+		server, provider := setup(t, harness, testMaxRetries)
+		requireRetries(t, provider)
+		server.Script(Status(http.StatusServiceUnavailable, "overloaded"), Text("back"))
+
+		response, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{userMessage("Hi")}})
+		if err != nil || response.Message != "back" {
+			t.Errorf("Expected success after a transient error, got %q, %v", response.Message, err)
+		}
+	})
+}
+
+func testRetryAfter(t *testing.T, harness Harness) {
+	server, provider := setup(t, harness, 0)
+	server.Script(Reply{Status: http.StatusTooManyRequests, Message: "slow down", RetryAfter: 7 * time.Second})
+
+	_, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{userMessage("Hi")}})
+	var llmErr *simpleai.LLMError
+	if !errors.As(err, &llmErr) {
+		t.Fatalf("Expected an LLMError, got %v", err)
+	}
+	if llmErr.RetryAfter != 7*time.Second {
+		t.Errorf("Expected the server's retry hint of 7s on the error, got %v", llmErr.RetryAfter)
+	}
+}
+
+func testCancellation(t *testing.T, harness Harness) {
+	server, provider := setup(t, harness, testMaxRetries)
+	if _, ok := provider.(simpleai.ContextProvider); !ok {
+		t.Skipf("%s does not implement ChatContext", provider.Name())
+	}
+	server.SetDefault(func(Received) Reply { return Reply{Hang: true} })
+
+	check := func(t *testing.T, call func(ctx context.Context) error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		before := server.Calls()
+		start := time.Now()
+		err := call(ctx)
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Expected cancellation to stop the request promptly, took %v", elapsed)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected an error wrapping context.Canceled, got %v", err)
+		}
+		if simpleai.IsRetryable(err) {
+			t.Errorf("Expected cancellation not to be retryable, got %v", err)
+		}
+		if calls := server.Calls() - before; calls != 1 {
+			t.Errorf("Expected a cancelled request not to be retried, got %d calls", calls)
+		}
+	}
+
+	request := simpleai.ChatRequest{Messages: []simpleai.Message{userMessage("Hi")}}
+	t.Run("Chat", func(t *testing.T) {
+		check(t, func(ctx context.Context) error {
+			_, err := simpleai.ChatContext(ctx, provider, request)
+			return err
+		})
+	})
+	if streaming, ok := provider.(simpleai.StreamingProvider); ok {
+		t.Run("Stream", func(t *testing.T) {
+			check(t, func(ctx context.Context) error {
+				_, err := streaming.ChatStream(ctx, request, func(simpleai.StreamChunk) error { return nil })
+				return err
+			})
+		})
+	}
+}
+
+func testStreaming(t *testing.T, harness Harness) {
+	server, provider := setup(t, harness, 0)
+	streaming, ok := provider.(simpleai.StreamingProvider)
+	if !provider.SupportedFeatures().Streaming {
+		t.Skip("provider does not support streaming")
+	}
+	if !ok {
+		t.Fatalf("%s reports streaming support but does not implement StreamingProvider", provider.Name())
+	}
+	server.Script(Text("one two three four"))
+
+	var chunks []simpleai.StreamChunk
+	response, err := streaming.ChatStream(context.Background(),
+		simpleai.ChatRequest{Messages: []simpleai.Message{userMessage("Count")}},
+		func(chunk simpleai.StreamChunk) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+	if !server.Received()[0].Stream {
+		t.Error("Expected a streaming request to ask the server to stream")
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+
+	text := ""
+	for i, chunk := range chunks {
+		text += chunk.Delta
+		if chunk.Text != text {
+			t.Errorf("Chunk %d: expected accumulated text %q, got %q", i, text, chunk.Text)
+		}
+		if chunk.Done != (i == len(chunks)-1) {
+			t.Errorf("Chunk %d: expected only the last chunk to be done", i)
+		}
+	}
+	if text != "one two three four" || response.Message != text {
+		t.Errorf("Expected chunks to form the response, got %q and %q", text, response.Message)
+	}
+}
+
+func testFeatures(t *testing.T, harness Harness) {
+	_, provider := setup(t, harness, 0)
+	features := provider.SupportedFeatures()
+
+	if !slices.Contains(features.SupportedRoles, "user") {
+		t.Errorf("Expected the user role to be supported, got %v", features.SupportedRoles)
+	}
+	if features.MaxTokens <= 0 {
+		t.Errorf("Expected a positive MaxTokens, got %d", features.MaxTokens)
+	}
+	if _, ok := provider.(simpleai.StreamingProvider); ok && !features.Streaming {
+		t.Error("Provider implements StreamingProvider but does not report streaming support")
+	}
+	if provider.Name() == "" {
+		t.Error("Expected a provider name")
+	}
+}
+
+func testConcurrency(t *testing.T, harness Harness) {
+	_, provider := setup(t, harness, 0)
+
+	const workers = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			content := fmt.Sprintf("request %d", i)
+			response, err := provider.Chat(simpleai.ChatRequest{Messages: []simpleai.Message{userMessage(content)}})
+			if err != nil {
+				errs <- err
+			} else if response.Message != "echo: "+content {
+				errs <- fmt.Errorf("request %d got response %q", i, response.Message)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,167 @@
+package providertest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"simpleai"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Received is a chat request as seen by the stand-in server
+type Received struct {
+	System   string             // System instructions, however the protocol carries them
+	Messages []simpleai.Message // Other turns, with roles mapped back to "user" and "assistant"
+	Model    string             // Model named in the request
+	Stream   bool               // Whether the client asked for a streamed response
+}
+
+// LastMessage returns the content of the last turn, or "" if there is none
+func (r Received) LastMessage() string {
+	if len(r.Messages) == 0 {
+		return ""
+	}
+	return r.Messages[len(r.Messages)-1].Content
+}
+
+// Reply scripts the stand-in server's answer to one request
+type Reply struct {
+	Text       string        // Text of a successful reply
+	Status     int           // HTTP status of an error reply; 0 for success
+	Message    string        // Message of an error reply
+	RetryAfter time.Duration // Retry hint sent with an error reply
+	Hang       bool          // Never answer; wait until the client gives up
+}
+
+// Text returns a successful reply
+func Text(text string) Reply {
+	return Reply{Text: text}
+}
+
+// Status returns an error reply with the given HTTP status
+func Status(status int, message string) Reply {
+	return Reply{Status: status, Message: message}
+}
+
+// Protocol translates between the stand-in server and a provider's wire format. Each
+// provider implements one in its tests.
+type Protocol interface {
+	// Decode reads a chat request. Requests it returns an error for are answered with 404.
+	Decode(r *http.Request) (Received, error)
+
+	// WriteText writes a successful reply. Streaming requests should write each chunk
+	// separately; the chunks concatenate to the reply text.
+	WriteText(w http.ResponseWriter, received Received, chunks []string)
+
+	// WriteError writes an error reply, including the retry hint if retryAfter is non-zero
+	WriteError(w http.ResponseWriter, received Received, status int, message string, retryAfter time.Duration)
+}
+
+// Server is a local stand-in for a provider's API. It answers chat requests with scripted
+// replies, in order, then with the default reply, which echoes the last message.
+type Server struct {
+	URL string
+
+	protocol Protocol
+	server   *httptest.Server
+
+	mu       sync.Mutex
+	script   []Reply
+	fallback func(Received) Reply
+	received []Received
+}
+
+// NewServer starts a stand-in server speaking protocol. It is closed when the test ends.
+func NewServer(t testing.TB, protocol Protocol) *Server {
+	s := &Server{
+		protocol: protocol,
+		fallback: func(received Received) Reply {
+			return Text("echo: " + received.LastMessage())
+		},
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.URL = s.server.URL
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// Script queues replies for the next requests
+func (s *Server) Script(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, replies...)
+}
+
+// SetDefault sets the reply used once the script is exhausted
+func (s *Server) SetDefault(fallback func(Received) Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = fallback
+}
+
+// Received returns the chat requests received so far
+func (s *Server) Received() []Received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Received(nil), s.received...)
+}
+
+// Calls returns the number of chat requests received
+func (s *Server) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.received)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	received, err := s.protocol.Decode(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	s.received = append(s.received, received)
+	var reply Reply
+	if len(s.script) > 0 {
+		reply, s.script = s.script[0], s.script[1:]
+	} else {
+		reply = s.fallback(received)
+	}
+	s.mu.Unlock()
+
+	switch {
+	case reply.Hang:
+		<-r.Context().Done()
+	case reply.Status != 0:
+		s.protocol.WriteError(w, received, reply.Status, reply.Message, reply.RetryAfter)
+	default:
+		chunks := []string{reply.Text}
+		if received.Stream && reply.Text != "" {
+			chunks = strings.SplitAfter(reply.Text, " ")
+		}
+		s.protocol.WriteText(w, received, chunks)
+	}
+}
+
+// NewProvider starts a server answering every request with handler and creates a provider
+// for it with constructor, for tests that check a wire format the scripted Server does not
+// cover, such as tool calls. config may be nil; its host is set to the server's URL. The
+// server is closed when the test ends.
+func NewProvider(t testing.TB, constructor simpleai.ProviderConstructor, config map[string]interface{}, handler http.HandlerFunc) simpleai.Provider {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	config["host"] = server.URL
+	provider, err := constructor(config)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	return provider
+}
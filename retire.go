@@ -0,0 +1,123 @@
+package simpleai
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// closingProvider wraps a provider that holds resources, such as a plugin process, and
+// counts the calls running on it, so the factory can close it once it has been dropped
+// from the cache and the last call has finished
+type closingProvider struct {
+	provider Provider
+	closer   io.Closer
+
+	mu      sync.Mutex
+	calls   int
+	retired bool
+}
+
+// trackCalls wraps provider in a closingProvider if it implements io.Closer
+func trackCalls(provider Provider) Provider {
+	closer, ok := provider.(io.Closer)
+	if !ok {
+		return provider
+	}
+	return &closingProvider{provider: provider, closer: closer}
+}
+
+// retireProvider closes a provider dropped from the factory's cache once its running calls
+// have finished. Providers that hold no resources are left alone. Closing runs in the
+// background, since providers are retired under the factory's lock and closing a plugin
+// can wait for it to exit.
+func retireProvider(provider Provider) {
+	if breaker, ok := provider.(*CircuitBreakerProvider); ok {
+		provider = breaker.Unwrap()
+	}
+	if closing, ok := provider.(*closingProvider); ok {
+		closing.retire()
+	}
+}
+
+// retire marks the provider as dropped and closes it if no call is running
+func (p *closingProvider) retire() {
+	p.mu.Lock()
+	p.retired = true
+	idle := p.calls == 0
+	p.mu.Unlock()
+
+	if idle {
+		go p.closer.Close()
+	}
+}
+
+// begin counts a call and returns the function that ends it. A call made after the provider
+// was retired, through a reference held by the caller, closes it again when it ends.
+func (p *closingProvider) begin() func() {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		p.calls--
+		idle := p.retired && p.calls == 0
+		p.mu.Unlock()
+
+		if idle {
+			go p.closer.Close()
+		}
+	}
+}
+
+// Chat sends a chat request to the wrapped provider
+func (p *closingProvider) Chat(request ChatRequest) (ChatResponse, error) {
+	defer p.begin()()
+	return p.provider.Chat(request)
+}
+
+// ChatContext sends a cancellable chat request to the wrapped provider
+func (p *closingProvider) ChatContext(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	defer p.begin()()
+	return ChatContext(ctx, p.provider, request)
+}
+
+// ChatStream streams a chat response from the wrapped provider. Providers that cannot
+// stream deliver the whole response as a single chunk.
+func (p *closingProvider) ChatStream(ctx context.Context, request ChatRequest, handler StreamHandler) (ChatResponse, error) {
+	defer p.begin()()
+	return chatStream(ctx, p.provider, request, handler)
+}
+
+// ListModels lists the wrapped provider's models
+func (p *closingProvider) ListModels() ([]Model, error) {
+	defer p.begin()()
+	return p.provider.ListModels()
+}
+
+// Name returns the wrapped provider's name
+func (p *closingProvider) Name() string {
+	return p.provider.Name()
+}
+
+// IsAvailable checks the wrapped provider
+func (p *closingProvider) IsAvailable() bool {
+	defer p.begin()()
+	return p.provider.IsAvailable()
+}
+
+// SupportedFeatures returns the wrapped provider's features
+func (p *closingProvider) SupportedFeatures() ProviderFeatures {
+	return p.provider.SupportedFeatures()
+}
+
+// Close closes the wrapped provider
+func (p *closingProvider) Close() error {
+	return p.closer.Close()
+}
+
+// Unwrap returns the wrapped provider
+func (p *closingProvider) Unwrap() Provider {
+	return p.provider
+}
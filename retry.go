@@ -0,0 +1,203 @@
+package simpleai
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JitterStrategy controls how backoff delays are randomized between attempts
+type JitterStrategy int
+
+const (
+	// JitterNone uses the plain exponential backoff delay
+	JitterNone JitterStrategy = iota
+	// JitterFull picks a random delay between zero and the exponential backoff delay
+	JitterFull
+	// JitterEqual keeps half of the exponential backoff delay and randomizes the other half
+	JitterEqual
+	// JitterDecorrelated picks a random delay between the base delay and three times the previous delay
+	JitterDecorrelated
+)
+
+// RetryPolicy overrides retry behavior for a specific error type
+type RetryPolicy struct {
+	NoRetry    bool          // Never retry errors of this type
+	MaxRetries int           // Overrides RetryConfig.MaxRetries when positive
+	BaseDelay  time.Duration // Overrides RetryConfig.BaseDelay when positive
+}
+
+// RetryEvent describes a retry that is about to happen
+type RetryEvent struct {
+	Attempt int           // Attempt that just failed (0 is the initial attempt)
+	Delay   time.Duration // How long the executor will wait before the next attempt
+	Elapsed time.Duration // Time spent since the first attempt started
+	Err     error         // Error returned by the failed attempt
+}
+
+// RetryExecutor runs operations with retry, backoff and jitter according to a RetryConfig
+type RetryExecutor struct {
+	config *RetryConfig
+}
+
+// NewRetryExecutor creates a retry executor; a nil config uses DefaultRetryConfig
+func NewRetryExecutor(config *RetryConfig) *RetryExecutor {
+	if config == nil {
+		config = DefaultRetryConfig()
+	}
+	return &RetryExecutor{config: config}
+}
+
+// Execute calls fn until it succeeds, returns a non-retryable error, runs out of
+// attempts or exceeds the elapsed time budget. The last error is returned.
+func (e *RetryExecutor) Execute(ctx context.Context, fn func(ctx context.Context, attempt int) error) error {
+	start := time.Now()
+	var prevDelay time.Duration
+
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx, attempt)
+		if err == nil {
+			return nil
+		}
+
+		policy := e.config.policyFor(err)
+		maxRetries := e.config.MaxRetries
+		if policy.MaxRetries > 0 {
+			maxRetries = policy.MaxRetries
+		}
+		if policy.NoRetry || !IsRetryable(err) || attempt >= maxRetries {
+			return err
+		}
+
+		baseDelay := e.config.BaseDelay
+		if policy.BaseDelay > 0 {
+			baseDelay = policy.BaseDelay
+		}
+
+		// Server-provided hints take precedence over a shorter computed backoff
+		delay := e.backoff(attempt, baseDelay, prevDelay)
+		if hint := retryAfterOf(err); hint > delay {
+			delay = hint
+		}
+
+		elapsed := time.Since(start)
+		if e.config.MaxElapsedTime > 0 && elapsed+delay > e.config.MaxElapsedTime {
+			return err
+		}
+		prevDelay = delay
+
+		if e.config.OnRetry != nil {
+			e.config.OnRetry(RetryEvent{Attempt: attempt, Delay: delay, Elapsed: elapsed, Err: err})
+		}
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// backoff calculates the delay before the retry following the given attempt
+func (e *RetryExecutor) backoff(attempt int, baseDelay, prevDelay time.Duration) time.Duration {
+	maxDelay := e.config.MaxDelay
+
+	if e.config.Jitter == JitterDecorrelated {
+		if prevDelay < baseDelay {
+			prevDelay = baseDelay
+		}
+		delay := baseDelay + randDuration(3*prevDelay-baseDelay)
+		if maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+		return delay
+	}
+
+	factor := e.config.BackoffFactor
+	if factor <= 0 {
+		factor = 1
+	}
+	delay := time.Duration(float64(baseDelay) * pow(factor, attempt))
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+
+	switch e.config.Jitter {
+	case JitterFull:
+		return randDuration(delay)
+	case JitterEqual:
+		return delay/2 + randDuration(delay-delay/2)
+	default:
+		return delay
+	}
+}
+
+// policyFor returns the policy registered for the error's type, if any
+func (c *RetryConfig) policyFor(err error) RetryPolicy {
+	if len(c.Policies) == 0 {
+		return RetryPolicy{}
+	}
+	if errType := errorType(err); errType != nil {
+		return c.Policies[errType]
+	}
+	return RetryPolicy{}
+}
+
+// retryAfterOf returns the server-provided retry hint carried by an error
+func retryAfterOf(err error) time.Duration {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		return llmErr.RetryAfter
+	}
+	return 0
+}
+
+// ParseRetryAfter parses a Retry-After header value given either in seconds or as an HTTP date
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// randDuration returns a random duration in [0, max]
+func randDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(max) + 1))
+}
+
+// pow calculates power for exponential backoff
+func pow(base float64, exp int) float64 {
+	result := 1.0
+	for i := 0; i < exp; i++ {
+		result *= base
+	}
+	return result
+}
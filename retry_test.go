@@ -0,0 +1,186 @@
+package simpleai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func testRetryConfig() *RetryConfig {
+	return &RetryConfig{
+		MaxRetries:    3,
+		BaseDelay:     time.Millisecond,
+		MaxDelay:      10 * time.Millisecond,
+		BackoffFactor: 2.0,
+	}
+}
+
+func TestRetryExecutorRetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	err := NewRetryExecutor(testRetryConfig()).Execute(context.Background(), func(ctx context.Context, attempt int) error {
+		calls++
+		if attempt < 2 {
+			return NewLLMError(ErrTimeout, "timed out", "chat", true, attempt, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryExecutorStopsOnNonRetryable(t *testing.T) {
+	calls := 0
+	err := NewRetryExecutor(testRetryConfig()).Execute(context.Background(), func(ctx context.Context, attempt int) error {
+		calls++
+		return NewLLMError(ErrInvalidConfig, "bad key", "chat", false, attempt, nil)
+	})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryExecutorExhaustsRetries(t *testing.T) {
+	calls := 0
+	err := NewRetryExecutor(testRetryConfig()).Execute(context.Background(), func(ctx context.Context, attempt int) error {
+		calls++
+		return NewLLMError(ErrConnectionFailed, "refused", "chat", true, attempt, nil)
+	})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, got %d", calls)
+	}
+}
+
+func TestRetryExecutorHonorsRetryAfter(t *testing.T) {
+	config := testRetryConfig()
+	var delays []time.Duration
+	config.OnRetry = func(event RetryEvent) {
+		delays = append(delays, event.Delay)
+	}
+
+	_ = NewRetryExecutor(config).Execute(context.Background(), func(ctx context.Context, attempt int) error {
+		if attempt == 0 {
+			return NewLLMError(ErrRateLimitExceeded, "slow down", "chat", true, attempt, nil).
+				WithRetryAfter(20 * time.Millisecond)
+		}
+		return nil
+	})
+
+	if len(delays) != 1 {
+		t.Fatalf("Expected 1 retry event, got %d", len(delays))
+	}
+	if delays[0] != 20*time.Millisecond {
+		t.Errorf("Expected Retry-After delay of 20ms, got %v", delays[0])
+	}
+}
+
+func TestRetryExecutorPolicies(t *testing.T) {
+	config := testRetryConfig()
+	config.Policies = map[error]RetryPolicy{
+		ErrTimeout:         {NoRetry: true},
+		ErrOperationFailed: {MaxRetries: 1},
+	}
+
+	calls := 0
+	_ = NewRetryExecutor(config).Execute(context.Background(), func(ctx context.Context, attempt int) error {
+		calls++
+		return NewLLMError(ErrTimeout, "timed out", "chat", true, attempt, nil)
+	})
+	if calls != 1 {
+		t.Errorf("Expected NoRetry policy to stop after 1 call, got %d", calls)
+	}
+
+	calls = 0
+	_ = NewRetryExecutor(config).Execute(context.Background(), func(ctx context.Context, attempt int) error {
+		calls++
+		return NewLLMError(ErrOperationFailed, "failed", "chat", true, attempt, nil)
+	})
+	if calls != 2 {
+		t.Errorf("Expected MaxRetries policy to allow 2 calls, got %d", calls)
+	}
+}
+
+func TestRetryExecutorMaxElapsedTime(t *testing.T) {
+	config := testRetryConfig()
+	config.MaxRetries = 100
+	config.BaseDelay = 5 * time.Millisecond
+	config.BackoffFactor = 1
+	config.MaxElapsedTime = 20 * time.Millisecond
+
+	start := time.Now()
+	err := NewRetryExecutor(config).Execute(context.Background(), func(ctx context.Context, attempt int) error {
+		return NewLLMError(ErrTimeout, "timed out", "chat", true, attempt, nil)
+	})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Expected elapsed time cap to stop retries, took %v", elapsed)
+	}
+}
+
+func TestRetryExecutorContextCancelled(t *testing.T) {
+	config := testRetryConfig()
+	config.BaseDelay = time.Second
+	config.MaxDelay = time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lastErr := NewLLMError(ErrTimeout, "timed out", "chat", true, 0, nil)
+	err := NewRetryExecutor(config).Execute(ctx, func(ctx context.Context, attempt int) error {
+		cancel()
+		return lastErr
+	})
+	if !errors.Is(err, lastErr) {
+		t.Errorf("Expected last attempt error, got %v", err)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	config := testRetryConfig()
+	config.BaseDelay = 100 * time.Millisecond
+	config.MaxDelay = time.Second
+
+	executor := NewRetryExecutor(config)
+	if delay := executor.backoff(2, config.BaseDelay, 0); delay != 400*time.Millisecond {
+		t.Errorf("Expected 400ms without jitter, got %v", delay)
+	}
+
+	config.Jitter = JitterFull
+	for i := 0; i < 50; i++ {
+		if delay := executor.backoff(2, config.BaseDelay, 0); delay < 0 || delay > 400*time.Millisecond {
+			t.Fatalf("Full jitter delay out of range: %v", delay)
+		}
+	}
+
+	config.Jitter = JitterDecorrelated
+	for i := 0; i < 50; i++ {
+		delay := executor.backoff(1, config.BaseDelay, 200*time.Millisecond)
+		if delay < config.BaseDelay || delay > 600*time.Millisecond {
+			t.Fatalf("Decorrelated jitter delay out of range: %v", delay)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := ParseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("Expected 3s, got %v", d)
+	}
+	if d := ParseRetryAfter(""); d != 0 {
+		t.Errorf("Expected 0 for empty header, got %v", d)
+	}
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d := ParseRetryAfter(future); d <= 0 || d > time.Minute {
+		t.Errorf("Expected HTTP date to parse to under a minute, got %v", d)
+	}
+}
@@ -0,0 +1,165 @@
+// Package schema generates JSON Schemas from Go types.
+//
+// Providers use it to send the structured output target's shape to APIs that can constrain
+// generation to a schema (OpenAI json_schema response formats, llama.cpp grammars, tool
+// parameters). Struct fields follow encoding/json rules: json tags name fields, "-" skips
+// them, and omitempty fields are optional. A `description` struct tag documents a field.
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema document
+type Schema = map[string]any
+
+// maxDepth bounds recursion for self-referencing types
+const maxDepth = 16
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// For returns the schema of v's type, looking through pointers, so both For(Item{}) and
+// For(&item) describe Item. A nil v yields an empty schema, which accepts anything.
+func For(v any) Schema {
+	if v == nil {
+		return Schema{}
+	}
+	return Of(reflect.TypeOf(v))
+}
+
+// Of returns the schema of type t
+func Of(t reflect.Type) Schema {
+	return generate(t, 0)
+}
+
+func generate(t reflect.Type, depth int) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if depth > maxDepth {
+		return Schema{}
+	}
+
+	switch {
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return Schema{}
+	case implements(t, jsonMarshalerType) || implements(t, jsonUnmarshalerType):
+		// Custom encodings can produce anything
+		return Schema{}
+	case implements(t, textMarshalerType):
+		return Schema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": generate(t.Elem(), depth+1)}
+	case reflect.Array:
+		return Schema{
+			"type":     "array",
+			"items":    generate(t.Elem(), depth+1),
+			"minItems": t.Len(),
+			"maxItems": t.Len(),
+		}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": generate(t.Elem(), depth+1)}
+	case reflect.Struct:
+		return structSchema(t, depth)
+	default:
+		return Schema{}
+	}
+}
+
+// structSchema describes a struct's JSON object, inlining embedded structs like encoding/json
+func structSchema(t reflect.Type, depth int) Schema {
+	properties := Schema{}
+	required := []string{}
+	addFields(t, depth, properties, &required)
+
+	schema := Schema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func addFields(t reflect.Type, depth int, properties Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, omitempty := parseTag(tag)
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addFields(embedded, depth, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := generate(field.Type, depth+1)
+		if description := field.Tag.Get("description"); description != "" {
+			property["description"] = description
+		}
+		properties[name] = property
+		if !omitempty {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// parseTag splits a json struct tag into its name and whether omitempty is set
+func parseTag(tag string) (name string, omitempty bool) {
+	name, options, _ := strings.Cut(tag, ",")
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" || option == "omitzero" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
+
+// implements reports whether t or *t implements iface
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
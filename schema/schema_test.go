@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Base struct {
+	ID string `json:"id"`
+}
+
+type recipe struct {
+	Base
+	Title    string             `json:"title" description:"Name of the dish"`
+	Servings int                `json:"servings,omitempty"`
+	Tags     []string           `json:"tags"`
+	Created  time.Time          `json:"created"`
+	Extra    map[string]float64 `json:"extra,omitempty"`
+	Next     *recipe            `json:"next,omitempty"`
+	Secret   string             `json:"-"`
+	internal string
+}
+
+func TestForStruct(t *testing.T) {
+	s := For(&recipe{})
+
+	if s["type"] != "object" || s["additionalProperties"] != false {
+		t.Fatalf("Expected a closed object, got %v", s)
+	}
+	properties := s["properties"].(Schema)
+	for _, name := range []string{"id", "title", "servings", "tags", "created", "extra", "next"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("Expected property %q", name)
+		}
+	}
+	for _, name := range []string{"Secret", "internal", "Base"} {
+		if _, ok := properties[name]; ok {
+			t.Errorf("Expected property %q to be left out", name)
+		}
+	}
+
+	if !reflect.DeepEqual(s["required"], []string{"id", "title", "tags", "created"}) {
+		t.Errorf("Unexpected required fields %v", s["required"])
+	}
+	if properties["title"].(Schema)["description"] != "Name of the dish" {
+		t.Errorf("Expected description on title, got %v", properties["title"])
+	}
+	if properties["created"].(Schema)["format"] != "date-time" {
+		t.Errorf("Expected date-time format, got %v", properties["created"])
+	}
+	if properties["tags"].(Schema)["items"].(Schema)["type"] != "string" {
+		t.Errorf("Expected string items, got %v", properties["tags"])
+	}
+
+	// Self-referencing types terminate and the result is valid JSON
+	if _, err := json.Marshal(s); err != nil {
+		t.Errorf("Expected schema to marshal, got %v", err)
+	}
+}
+
+func TestForScalarsAndCollections(t *testing.T) {
+	tests := []struct {
+		value any
+		want  Schema
+	}{
+		{0, Schema{"type": "integer"}},
+		{1.5, Schema{"type": "number"}},
+		{true, Schema{"type": "boolean"}},
+		{"", Schema{"type": "string"}},
+		{[]byte{}, Schema{"type": "string", "contentEncoding": "base64"}},
+		{[]int{}, Schema{"type": "array", "items": Schema{"type": "integer"}}},
+		{json.RawMessage{}, Schema{}},
+		{nil, Schema{}},
+	}
+	for _, tt := range tests {
+		if got := For(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("For(%T) = %v, expected %v", tt.value, got, tt.want)
+		}
+	}
+}
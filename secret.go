@@ -0,0 +1,175 @@
+package simpleai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces resolved secrets in error text
+const Redacted = "[REDACTED]"
+
+// minSecretLength is the shortest value redacted from error text, so placeholder keys such
+// as "none" that local servers accept do not garble messages
+const minSecretLength = 8
+
+// SecretResolver resolves secret references of one scheme. For api_key "vault:db/openai"
+// the resolver registered for "vault" is asked for "db/openai".
+type SecretResolver interface {
+	ResolveSecret(reference string) (string, error)
+}
+
+// SecretResolverFunc adapts a function to SecretResolver
+type SecretResolverFunc func(reference string) (string, error)
+
+// ResolveSecret calls f(reference)
+func (f SecretResolverFunc) ResolveSecret(reference string) (string, error) {
+	return f(reference)
+}
+
+// builtinSecretResolvers resolve the schemes every factory starts with, which are the only
+// ones a config resolves the same way in any factory
+var builtinSecretResolvers = defaultSecretResolvers()
+
+// redactKey returns an api_key value with a literal key replaced by Redacted. Values of
+// the form scheme:reference with a resolver for scheme are references and kept.
+func redactKey(key string, resolvers map[string]SecretResolver) string {
+	if key == "" {
+		return key
+	}
+	if scheme, _, found := strings.Cut(key, ":"); found && resolvers[scheme] != nil {
+		return key
+	}
+	return Redacted
+}
+
+// MarshalJSON encodes the config with a literal api_key replaced by Redacted, so configs
+// can be logged or served without leaking keys. env: and file: references are kept; other
+// schemes are registered per factory, so their values are redacted too. Use MarshalConfig
+// to write a config file with its keys.
+func (c ProviderConfig) MarshalJSON() ([]byte, error) {
+	type plain ProviderConfig
+	c.APIKey = redactKey(c.APIKey, builtinSecretResolvers)
+	return json.Marshal(plain(c))
+}
+
+// defaultSecretResolvers returns the resolvers every factory starts with: env:NAME reads an
+// environment variable and file:PATH reads a file, such as a Docker or Kubernetes secret
+func defaultSecretResolvers() map[string]SecretResolver {
+	return map[string]SecretResolver{
+		"env":  SecretResolverFunc(envSecret),
+		"file": SecretResolverFunc(fileSecret),
+	}
+}
+
+func envSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+func fileSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	// Secret files usually end with a newline that is not part of the secret
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return value, nil
+}
+
+// RegisterSecretResolver makes this factory resolve api_key values of the form
+// "scheme:reference" through resolver. It replaces the resolver for the scheme, including
+// the built-in env and file.
+func (f *LLMFactory) RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secretResolvers[scheme] = resolver
+}
+
+// secretResolverUnsafe returns the resolver of the secret reference in config's api_key,
+// or nil when api_key is a literal key (internal use only)
+func (f *LLMFactory) secretResolverUnsafe(config map[string]interface{}) SecretResolver {
+	key, _ := config["api_key"].(string)
+	scheme, _, found := strings.Cut(key, ":")
+	if !found {
+		return nil
+	}
+	return f.secretResolvers[scheme]
+}
+
+// resolveSecrets returns config with the secret reference in api_key replaced by the secret
+// resolver finds, along with the key the provider receives. A nil resolver means api_key
+// is a literal key. The caller's map is not modified, so the reference is all the factory
+// keeps.
+func resolveSecrets(providerName string, config map[string]interface{}, resolver SecretResolver) (map[string]interface{}, string, error) {
+	key, _ := config["api_key"].(string)
+	if resolver == nil {
+		return config, key, nil
+	}
+
+	_, reference, _ := strings.Cut(key, ":")
+	secret, err := resolver.ResolveSecret(reference)
+	if err != nil {
+		return nil, "", NewLLMError(ErrInvalidConfig,
+			fmt.Sprintf("failed to resolve api_key %s for provider %s", key, providerName),
+			"secret_resolution", false, 0, err)
+	}
+
+	resolved := make(map[string]interface{}, len(config))
+	for name, value := range config {
+		resolved[name] = value
+	}
+	resolved["api_key"] = secret
+	return resolved, secret, nil
+}
+
+// knownSecrets are the API keys held by cached providers, redacted from error text, with
+// the number of providers holding each
+var knownSecrets struct {
+	sync.RWMutex
+	values map[string]int
+}
+
+func rememberSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	knownSecrets.Lock()
+	defer knownSecrets.Unlock()
+	if knownSecrets.values == nil {
+		knownSecrets.values = make(map[string]int)
+	}
+	knownSecrets.values[value]++
+}
+
+// forgetSecret undoes rememberSecret once a provider holding value is dropped
+func forgetSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	knownSecrets.Lock()
+	defer knownSecrets.Unlock()
+	if knownSecrets.values[value]--; knownSecrets.values[value] <= 0 {
+		delete(knownSecrets.values, value)
+	}
+}
+
+// RedactSecrets replaces the API keys of the providers factories have cached with Redacted.
+// LLMError and ValidationError apply it to their text, so keys echoed by a provider, a
+// resolver or a validation message are not logged.
+func RedactSecrets(text string) string {
+	knownSecrets.RLock()
+	defer knownSecrets.RUnlock()
+	for secret := range knownSecrets.values {
+		text = strings.ReplaceAll(text, secret, Redacted)
+	}
+	return text
+}
@@ -0,0 +1,260 @@
+package simpleai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretReferences(t *testing.T) {
+	t.Setenv("SIMPLEAI_TEST_KEY", "env-secret-0123456789")
+	secretFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(secretFile, []byte("file-secret-0123456789\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	received := map[string]string{}
+	factory := NewLLMFactory()
+	for _, name := range []string{"env", "file", "vault", "literal"} {
+		name := name
+		factory.RegisterProvider(name, func(config map[string]interface{}) (Provider, error) {
+			received[name], _ = config["api_key"].(string)
+			return &stubProvider{name: name}, nil
+		})
+	}
+	factory.RegisterSecretResolver("vault", SecretResolverFunc(func(reference string) (string, error) {
+		return "vault-secret-for-" + reference, nil
+	}))
+
+	config := FactoryConfig{
+		DefaultProvider: "env",
+		Providers: map[string]ProviderConfig{
+			"env":     {APIKey: "env:SIMPLEAI_TEST_KEY", DefaultModel: "m", Timeout: 30},
+			"file":    {APIKey: "file:" + secretFile, DefaultModel: "m", Timeout: 30},
+			"vault":   {APIKey: "vault:openai", DefaultModel: "m", Timeout: 30},
+			"literal": {APIKey: "sk-literal", DefaultModel: "m", Timeout: 30},
+		},
+	}
+	if err := factory.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	for name := range config.Providers {
+		if _, err := factory.CreateProviderFromConfig(name); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	want := map[string]string{
+		"env":     "env-secret-0123456789",
+		"file":    "file-secret-0123456789",
+		"vault":   "vault-secret-for-openai",
+		"literal": "sk-literal",
+	}
+	for name, key := range want {
+		if received[name] != key {
+			t.Errorf("Expected %s to receive %q, got %q", name, key, received[name])
+		}
+	}
+
+	// Only the references are kept
+	data, _ := json.Marshal(factory.GetConfig())
+	for _, secret := range []string{"env-secret", "file-secret", "vault-secret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected the config not to contain %s, got %s", secret, data)
+		}
+	}
+
+	// Errors that echo a resolved key are redacted
+	err := NewLLMError(ErrOperationFailed, "bad key env-secret-0123456789", "chat", false, 0,
+		errors.New("upstream rejected file-secret-0123456789"))
+	if text := err.Error(); strings.Contains(text, "secret-0123456789") || !strings.Contains(text, Redacted) {
+		t.Errorf("Expected the error to be redacted, got %q", text)
+	}
+}
+
+func TestSecretReferenceUnresolved(t *testing.T) {
+	factory := NewLLMFactory()
+	factory.RegisterProvider("openai", func(config map[string]interface{}) (Provider, error) {
+		t.Error("Expected the provider not to be created")
+		return nil, nil
+	})
+
+	_, err := factory.CreateProvider("openai", map[string]interface{}{"api_key": "env:SIMPLEAI_TEST_UNSET"})
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "SIMPLEAI_TEST_UNSET is not set") {
+		t.Errorf("Expected an unresolved secret error, got %v", err)
+	}
+}
+
+func TestSecretResolverRunsWithoutLock(t *testing.T) {
+	factory := NewLLMFactory()
+	factory.RegisterProvider("vault", func(config map[string]interface{}) (Provider, error) {
+		return &stubProvider{name: "vault"}, nil
+	})
+	release := make(chan struct{})
+	resolving := make(chan struct{})
+	factory.RegisterSecretResolver("vault", SecretResolverFunc(func(reference string) (string, error) {
+		// A resolver may call back into the factory
+		factory.GetConfig()
+		close(resolving)
+		<-release
+		return "vault-secret-0123456789", nil
+	}))
+	config := FactoryConfig{
+		DefaultProvider: "vault",
+		Providers:       map[string]ProviderConfig{"vault": {APIKey: "vault:openai", DefaultModel: "m", Timeout: 30}},
+	}
+	if err := factory.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	created := make(chan struct{})
+	go func() {
+		defer close(created)
+		if _, err := factory.CreateProviderFromConfig("vault"); err != nil {
+			t.Error(err)
+		}
+	}()
+	await(t, resolving, "the resolver")
+
+	// Other callers are not held up by a slow resolver
+	listed := make(chan struct{})
+	go func() {
+		factory.GetConfig()
+		factory.ListProviders()
+		close(listed)
+	}()
+	await(t, listed, "the factory while a secret resolves")
+	close(release)
+	await(t, created, "the provider")
+}
+
+func TestLiteralKeysRedacted(t *testing.T) {
+	factory := NewLLMFactory()
+	factory.RegisterProvider("openai", func(config map[string]interface{}) (Provider, error) {
+		return &stubProvider{name: "openai"}, nil
+	})
+	config := FactoryConfig{
+		DefaultProvider: "openai",
+		Providers: map[string]ProviderConfig{
+			"openai": {APIKey: "sk-literal-0123456789", DefaultModel: "m", Timeout: 30},
+			"env":    {Type: "openai", APIKey: "env:OPENAI_API_KEY", DefaultModel: "m", Timeout: 30},
+		},
+	}
+	if err := factory.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	got := factory.GetConfig()
+	if got.Providers["openai"].APIKey != Redacted || got.Providers["env"].APIKey != "env:OPENAI_API_KEY" {
+		t.Errorf("Expected only the literal key to be redacted, got %+v", got.Providers)
+	}
+	if config.Providers["openai"].APIKey != "sk-literal-0123456789" {
+		t.Error("Expected GetConfig not to modify the loaded config")
+	}
+	data, _ := json.Marshal(config)
+	if strings.Contains(string(data), "sk-literal") || !strings.Contains(string(data), "env:OPENAI_API_KEY") {
+		t.Errorf("Expected the literal key to be redacted from JSON, got %s", data)
+	}
+	data, _ = MarshalConfig(config)
+	if !strings.Contains(string(data), "sk-literal-0123456789") {
+		t.Errorf("Expected MarshalConfig to keep the key, got %s", data)
+	}
+
+	// The provider still receives the key
+	if _, err := factory.CreateProviderFromConfig("openai"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplacedKeysForgotten(t *testing.T) {
+	factory := NewLLMFactory()
+	factory.RegisterProvider("openai", func(config map[string]interface{}) (Provider, error) {
+		return &stubProvider{name: "openai"}, nil
+	})
+	config := FactoryConfig{
+		DefaultProvider: "openai",
+		Providers:       map[string]ProviderConfig{"openai": {APIKey: "sk-old-key-0123456789", DefaultModel: "m", Timeout: 30}},
+	}
+	if err := factory.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	factory.CreateProviderFromConfig("openai")
+	factory.CreateProviderWithModel("openai", "other")
+	if RedactSecrets("sk-old-key-0123456789") != Redacted {
+		t.Fatal("Expected the key of a cached provider to be redacted")
+	}
+
+	config.Providers = map[string]ProviderConfig{"openai": {APIKey: "sk-new-key-0123456789", DefaultModel: "m", Timeout: 30}}
+	if _, err := factory.ReloadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	factory.CreateProviderFromConfig("openai")
+	if RedactSecrets("sk-old-key-0123456789") == Redacted || RedactSecrets("sk-new-key-0123456789") != Redacted {
+		t.Error("Expected only the key in use to be redacted")
+	}
+
+	factory.ClearProviderCache()
+	if RedactSecrets("sk-new-key-0123456789") == Redacted {
+		t.Error("Expected the keys of cleared providers to be forgotten")
+	}
+}
+
+func TestFailedCreationKeysForgotten(t *testing.T) {
+	factory := NewLLMFactory()
+	factory.RegisterProvider("openai", func(config map[string]interface{}) (Provider, error) {
+		return nil, NewLLMError(ErrInvalidConfig, fmt.Sprintf("key %s was rejected", config["api_key"]),
+			"provider_creation", false, 0, nil)
+	})
+	config := FactoryConfig{
+		DefaultProvider: "openai",
+		Providers:       map[string]ProviderConfig{"openai": {APIKey: "sk-bad-key-0123456789", DefaultModel: "m", Timeout: 30}},
+	}
+	if err := factory.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	for i := 0; i < 3; i++ {
+		_, err = factory.CreateProviderFromConfig("openai")
+	}
+	if err == nil || strings.Contains(err.Error(), "sk-bad-key") {
+		t.Fatalf("Expected a creation error with the key redacted, got %v", err)
+	}
+
+	config.Providers = map[string]ProviderConfig{"openai": {APIKey: "sk-new-key-0123456789", DefaultModel: "m", Timeout: 30}}
+	if _, err := factory.ReloadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if RedactSecrets("sk-bad-key-0123456789") == Redacted {
+		t.Error("Expected the key of failed creations to be forgotten once its config was replaced")
+	}
+}
+
+func TestSecretSchemesArePerFactory(t *testing.T) {
+	config := FactoryConfig{
+		DefaultProvider: "openai",
+		Providers:       map[string]ProviderConfig{"openai": {APIKey: "vault:openai", DefaultModel: "m", Timeout: 30}},
+	}
+	withVault, without := NewLLMFactory(), NewLLMFactory()
+	withVault.RegisterSecretResolver("vault", SecretResolverFunc(func(reference string) (string, error) {
+		return "vault-secret-0123456789", nil
+	}))
+	for _, factory := range []*LLMFactory{withVault, without} {
+		if err := factory.LoadConfig(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if key := withVault.GetConfig().Providers["openai"].APIKey; key != "vault:openai" {
+		t.Errorf("Expected the registering factory to keep its reference, got %q", key)
+	}
+	if key := without.GetConfig().Providers["openai"].APIKey; key != Redacted {
+		t.Errorf("Expected another factory to treat the value as a literal key, got %q", key)
+	}
+	if data, _ := json.Marshal(config); strings.Contains(string(data), "vault:openai") {
+		t.Errorf("Expected JSON to keep only built-in references, got %s", data)
+	}
+}
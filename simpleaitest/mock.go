@@ -0,0 +1,384 @@
+// Package simpleaitest provides a scriptable mock provider for testing code that uses simpleai.
+//
+// A MockProvider answers each call with the first matching rule, then the next scripted
+// reply, then its default reply. It records every request so tests can assert on what was
+// sent, can simulate latency, and implements simpleai.StreamingProvider.
+//
+//	mock := simpleaitest.NewMockProvider("mock").
+//		When(simpleaitest.MessageContains("weather"), simpleaitest.JSON(Weather{City: "Oslo"})).
+//		Reply(simpleaitest.Text("hello"), simpleaitest.Error(simpleai.ErrRateLimitExceeded))
+//
+//	factory.RegisterProvider("ollama", mock.Constructor())
+package simpleaitest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"simpleai"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reply is a scripted answer to one chat call
+type Reply struct {
+	Response simpleai.ChatResponse // Response returned when Err is nil
+	Err      error                 // Error returned instead of a response
+	Latency  time.Duration         // Delay before answering, overriding the provider's latency
+	Chunks   []string              // Streamed chunks; defaults to Response.Message split after spaces
+}
+
+// Text returns a reply with the given message
+func Text(message string) Reply {
+	return Reply{Response: simpleai.ChatResponse{Message: message}}
+}
+
+// JSON returns a reply whose message is value encoded as JSON. Requests with a T are decoded
+// into it, like a real provider would.
+func JSON(value any) Reply {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return Error(fmt.Errorf("simpleaitest: cannot encode reply: %w", err))
+	}
+	return Text(string(encoded))
+}
+
+// Error returns a reply that fails with err. Plain errors such as simpleai.ErrTimeout are
+// wrapped in a retryable LLMError of that type so they behave like provider errors; pass an
+// *simpleai.LLMError to control retryability, status code and the rest.
+func Error(err error) Reply {
+	return Reply{Err: err}
+}
+
+// Matcher selects requests a rule applies to
+type Matcher func(request simpleai.ChatRequest) bool
+
+// MessageContains matches requests whose last message contains substr
+func MessageContains(substr string) Matcher {
+	return func(request simpleai.ChatRequest) bool {
+		if len(request.Messages) == 0 {
+			return false
+		}
+		return strings.Contains(request.Messages[len(request.Messages)-1].Content, substr)
+	}
+}
+
+// SystemPromptContains matches requests whose system prompt contains substr
+func SystemPromptContains(substr string) Matcher {
+	return func(request simpleai.ChatRequest) bool {
+		return strings.Contains(request.SystemPrompt.Content, substr)
+	}
+}
+
+// rule answers requests accepted by match
+type rule struct {
+	match Matcher
+	reply Reply
+}
+
+// ErrNoReply is the cause of the error returned when a call has no scripted reply
+var ErrNoReply = errors.New("simpleaitest: no scripted reply for request")
+
+// MockProvider is a simpleai.Provider whose answers are scripted by the test
+type MockProvider struct {
+	mu           sync.Mutex
+	name         string
+	rules        []rule
+	script       []Reply
+	defaultReply *Reply
+	latency      time.Duration
+	models       []simpleai.Model
+	features     simpleai.ProviderFeatures
+	available    bool
+	requests     []simpleai.ChatRequest
+	configs      []map[string]interface{}
+}
+
+// NewMockProvider creates a mock provider reporting the given name
+func NewMockProvider(name string) *MockProvider {
+	return &MockProvider{
+		name:      name,
+		models:    []simpleai.Model{{Name: "mock-model"}},
+		available: true,
+		features: simpleai.ProviderFeatures{
+			StructuredOutput: true,
+			Streaming:        true,
+			MaxTokens:        4096,
+			SupportedRoles:   []string{"system", "user", "assistant"},
+			Temperature:      true,
+			TopP:             true,
+		},
+	}
+}
+
+// Reply queues replies that are returned in order, one per call
+func (m *MockProvider) Reply(replies ...Reply) *MockProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.script = append(m.script, replies...)
+	return m
+}
+
+// When answers every request accepted by match with reply. Rules are checked in the order
+// they were added, before scripted replies.
+func (m *MockProvider) When(match Matcher, reply Reply) *MockProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = append(m.rules, rule{match: match, reply: reply})
+	return m
+}
+
+// Default sets the reply used once no rule matches and the script is exhausted
+func (m *MockProvider) Default(reply Reply) *MockProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaultReply = &reply
+	return m
+}
+
+// WithLatency delays every answer, and every streamed chunk, by latency
+func (m *MockProvider) WithLatency(latency time.Duration) *MockProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latency = latency
+	return m
+}
+
+// WithModels sets the models returned by ListModels
+func (m *MockProvider) WithModels(models ...simpleai.Model) *MockProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models = models
+	return m
+}
+
+// WithFeatures sets the features returned by SupportedFeatures
+func (m *MockProvider) WithFeatures(features simpleai.ProviderFeatures) *MockProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.features = features
+	return m
+}
+
+// SetAvailable sets the result of IsAvailable
+func (m *MockProvider) SetAvailable(available bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.available = available
+}
+
+// Constructor returns a constructor that always yields this mock, so it can be registered
+// with LLMFactory.RegisterProvider under any name. The configs it receives are recorded.
+func (m *MockProvider) Constructor() simpleai.ProviderConstructor {
+	return func(config map[string]interface{}) (simpleai.Provider, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.configs = append(m.configs, config)
+		return m, nil
+	}
+}
+
+// Requests returns every chat request received, in order
+func (m *MockProvider) Requests() []simpleai.ChatRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]simpleai.ChatRequest(nil), m.requests...)
+}
+
+// LastRequest returns the most recent chat request
+func (m *MockProvider) LastRequest() (simpleai.ChatRequest, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.requests) == 0 {
+		return simpleai.ChatRequest{}, false
+	}
+	return m.requests[len(m.requests)-1], true
+}
+
+// Calls returns the number of chat requests received
+func (m *MockProvider) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.requests)
+}
+
+// Configs returns the configs passed to the provider's Constructor
+func (m *MockProvider) Configs() []map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]map[string]interface{}(nil), m.configs...)
+}
+
+// Reset clears recorded requests and any remaining scripted replies
+func (m *MockProvider) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = nil
+	m.script = nil
+}
+
+// Chat records the request and returns the next reply
+func (m *MockProvider) Chat(request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	reply, latency := m.next(request)
+	time.Sleep(latency)
+
+	if reply.Err != nil {
+		return simpleai.ChatResponse{}, m.wrapError(reply.Err)
+	}
+	return m.finish(request, reply.Response)
+}
+
+// ChatContext records the request and returns the next reply, giving up when ctx is done
+func (m *MockProvider) ChatContext(ctx context.Context, request simpleai.ChatRequest) (simpleai.ChatResponse, error) {
+	reply, latency := m.next(request)
+	if err := sleep(ctx, latency); err != nil {
+		return simpleai.ChatResponse{}, m.wrapError(err)
+	}
+
+	if reply.Err != nil {
+		return simpleai.ChatResponse{}, m.wrapError(reply.Err)
+	}
+	return m.finish(request, reply.Response)
+}
+
+// ChatStream records the request and streams the next reply's chunks
+func (m *MockProvider) ChatStream(ctx context.Context, request simpleai.ChatRequest, handler simpleai.StreamHandler) (simpleai.ChatResponse, error) {
+	reply, latency := m.next(request)
+
+	chunks := reply.Chunks
+	if chunks == nil && reply.Response.Message != "" {
+		chunks = strings.SplitAfter(reply.Response.Message, " ")
+	}
+
+	text := ""
+	for i, chunk := range chunks {
+		if err := sleep(ctx, latency); err != nil {
+			return simpleai.ChatResponse{}, m.wrapError(err)
+		}
+		text += chunk
+		last := i == len(chunks)-1 && reply.Err == nil
+		if err := handler(simpleai.StreamChunk{Delta: chunk, Text: text, Done: last}); err != nil {
+			return simpleai.ChatResponse{}, err
+		}
+	}
+
+	// An error after chunks simulates a stream that fails midway
+	if reply.Err != nil {
+		if len(chunks) == 0 {
+			if err := sleep(ctx, latency); err != nil {
+				return simpleai.ChatResponse{}, m.wrapError(err)
+			}
+		}
+		return simpleai.ChatResponse{}, m.wrapError(reply.Err)
+	}
+
+	response := reply.Response
+	if reply.Chunks != nil {
+		response.Message = text
+	}
+	return m.finish(request, response)
+}
+
+// ListModels returns the configured models
+func (m *MockProvider) ListModels() ([]simpleai.Model, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]simpleai.Model(nil), m.models...), nil
+}
+
+// Name returns the name the mock was created with
+func (m *MockProvider) Name() string {
+	return m.name
+}
+
+// IsAvailable returns the value set by SetAvailable, true by default
+func (m *MockProvider) IsAvailable() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.available
+}
+
+// SupportedFeatures returns the configured features
+func (m *MockProvider) SupportedFeatures() simpleai.ProviderFeatures {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.features
+}
+
+// next records request and picks its reply and latency
+func (m *MockProvider) next(request simpleai.ChatRequest) (Reply, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, request)
+
+	reply, found := Reply{}, false
+	for _, r := range m.rules {
+		if r.match(request) {
+			reply, found = r.reply, true
+			break
+		}
+	}
+	if !found && len(m.script) > 0 {
+		reply, found = m.script[0], true
+		m.script = m.script[1:]
+	}
+	if !found && m.defaultReply != nil {
+		reply, found = *m.defaultReply, true
+	}
+	if !found {
+		reply = Reply{Err: simpleai.NewLLMError(simpleai.ErrOperationFailed,
+			fmt.Sprintf("no reply scripted for call %d", len(m.requests)),
+			"chat", false, 0, ErrNoReply)}
+	}
+
+	latency := m.latency
+	if reply.Latency > 0 {
+		latency = reply.Latency
+	}
+	return reply, latency
+}
+
+// finish decodes structured output into the request's target, like a real provider
+func (m *MockProvider) finish(request simpleai.ChatRequest, response simpleai.ChatResponse) (simpleai.ChatResponse, error) {
+	if request.T == nil || response.Data != nil {
+		return response, nil
+	}
+
+	data, err := simpleai.ParseStructuredOutput(response.Message, request.T, request.Extractor, 0)
+	if err != nil {
+		return simpleai.ChatResponse{}, m.wrapError(err)
+	}
+	response.Data = data
+	return response, nil
+}
+
+// wrapError turns plain errors into LLMErrors so retry, fallback and circuit breaker logic
+// treats them like errors from a real provider
+func (m *MockProvider) wrapError(err error) error {
+	var llmErr *simpleai.LLMError
+	if errors.As(err, &llmErr) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		errType, retryable, _ := simpleai.ClassifyTransportError(err)
+		return simpleai.NewLLMError(errType, err.Error(), "chat", retryable, 0, err).WithProvider(m.name, "")
+	}
+	return simpleai.NewLLMError(err, err.Error(), "chat", true, 0, nil).WithProvider(m.name, "")
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package simpleaitest
+
+import (
+	"context"
+	"errors"
+	"simpleai"
+	"testing"
+	"time"
+)
+
+type weather struct {
+	City  string `json:"city"`
+	TempC int    `json:"temp_c"`
+}
+
+func userRequest(content string) simpleai.ChatRequest {
+	return simpleai.ChatRequest{Messages: []simpleai.Message{{Role: "user", Content: content}}}
+}
+
+func TestMockScriptedReplies(t *testing.T) {
+	mock := NewMockProvider("mock").Reply(Text("first"), Error(simpleai.ErrRateLimitExceeded), Text("third"))
+
+	if response, err := mock.Chat(userRequest("a")); err != nil || response.Message != "first" {
+		t.Errorf("Expected first reply, got %q, %v", response.Message, err)
+	}
+	_, err := mock.Chat(userRequest("b"))
+	if !errors.Is(err, simpleai.ErrRateLimitExceeded) || !simpleai.IsRetryable(err) {
+		t.Errorf("Expected retryable rate limit error, got %v", err)
+	}
+	if response, _ := mock.Chat(userRequest("c")); response.Message != "third" {
+		t.Errorf("Expected third reply, got %q", response.Message)
+	}
+
+	// An exhausted script fails loudly
+	if _, err := mock.Chat(userRequest("d")); !errors.Is(err, ErrNoReply) {
+		t.Errorf("Expected ErrNoReply, got %v", err)
+	}
+
+	if mock.Calls() != 4 {
+		t.Errorf("Expected 4 recorded calls, got %d", mock.Calls())
+	}
+	if last, ok := mock.LastRequest(); !ok || last.Messages[0].Content != "d" {
+		t.Errorf("Expected last request to be recorded, got %+v", last)
+	}
+}
+
+func TestMockMatchersAndStructuredOutput(t *testing.T) {
+	mock := NewMockProvider("mock").
+		When(MessageContains("weather"), JSON(weather{City: "Oslo", TempC: 12})).
+		Default(Text("fallback"))
+
+	var target weather
+	request := userRequest("what's the weather?")
+	request.T = &target
+	response, err := mock.Chat(request)
+	if err != nil || response.Data != &target || target.City != "Oslo" {
+		t.Errorf("Expected matched JSON decoded into target, got %+v, %v", target, err)
+	}
+
+	if response, _ := mock.Chat(userRequest("hello")); response.Message != "fallback" {
+		t.Errorf("Expected default reply, got %q", response.Message)
+	}
+}
+
+func TestMockStreaming(t *testing.T) {
+	mock := NewMockProvider("mock").
+		Reply(Reply{Chunks: []string{"Hel", "lo"}}, Reply{Chunks: []string{"par"}, Err: simpleai.ErrConnectionFailed})
+
+	var deltas []string
+	response, err := simpleai.ChatStream(context.Background(), mock, simpleai.ChatRequest{}, func(chunk simpleai.StreamChunk) error {
+		deltas = append(deltas, chunk.Delta)
+		return nil
+	})
+	if err != nil || response.Message != "Hello" || len(deltas) != 2 {
+		t.Errorf("Expected two chunks forming Hello, got %q (%q), %v", response.Message, deltas, err)
+	}
+
+	// Errors after chunks simulate a stream that breaks midway
+	deltas = nil
+	_, err = simpleai.ChatStream(context.Background(), mock, simpleai.ChatRequest{}, func(chunk simpleai.StreamChunk) error {
+		deltas = append(deltas, chunk.Delta)
+		return nil
+	})
+	if !errors.Is(err, simpleai.ErrConnectionFailed) || len(deltas) != 1 {
+		t.Errorf("Expected a chunk then a connection error, got %q, %v", deltas, err)
+	}
+}
+
+func TestMockLatencyHonorsContext(t *testing.T) {
+	mock := NewMockProvider("mock").WithLatency(time.Second).Default(Text("slow"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := mock.ChatStream(ctx, simpleai.ChatRequest{}, func(simpleai.StreamChunk) error { return nil })
+	if !errors.Is(err, simpleai.ErrTimeout) {
+		t.Errorf("Expected timeout, got %v", err)
+	}
+}
+
+func TestMockRegistersWithFactory(t *testing.T) {
+	mock := NewMockProvider("mock").Default(Text("from mock"))
+
+	factory := simpleai.NewLLMFactory()
+	factory.RegisterProvider("ollama", mock.Constructor())
+	provider, err := factory.CreateProvider("ollama", map[string]interface{}{"default_model": "llama3"})
+	if err != nil {
+		t.Fatalf("Expected provider, got %v", err)
+	}
+
+	if response, _ := provider.Chat(userRequest("hi")); response.Message != "from mock" {
+		t.Errorf("Expected mock reply through factory, got %q", response.Message)
+	}
+	if configs := mock.Configs(); len(configs) != 1 || configs[0]["default_model"] != "llama3" {
+		t.Errorf("Expected config to be recorded, got %v", configs)
+	}
+}
@@ -0,0 +1,105 @@
+package simpleai
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"simpleai/jsonextract"
+)
+
+// StreamChunk is one increment of a streamed chat response
+type StreamChunk struct {
+	Delta string `json:"delta"`          // Text added by this chunk
+	Text  string `json:"text"`           // Text received so far
+	Data  any    `json:"data,omitempty"` // Best-effort partial structured output if T was specified (set by ChatStream)
+	Done  bool   `json:"done"`           // Whether this is the last chunk
+}
+
+// StreamHandler receives chunks as they arrive. Returning an error stops the stream.
+type StreamHandler func(chunk StreamChunk) error
+
+// StreamingProvider is implemented by providers that can stream responses
+type StreamingProvider interface {
+	Provider
+
+	// ChatStream sends a chat request and calls handler with each chunk of the response.
+	// The returned response is the same as Chat would return, including structured data.
+	// Implementations only fill in Delta, Text and Done; use the ChatStream function to also
+	// receive partial structured data.
+	ChatStream(ctx context.Context, request ChatRequest, handler StreamHandler) (ChatResponse, error)
+}
+
+// ChatStream streams a chat response from provider. Providers that cannot stream are called
+// through Chat and deliver the whole response as a single chunk. When request.T is set, each
+// chunk's Data holds a freshly decoded best-effort value of T's type, with fields filled in as
+// they arrive; the returned response holds the strictly decoded value.
+func ChatStream(ctx context.Context, provider Provider, request ChatRequest, handler StreamHandler) (ChatResponse, error) {
+	if request.T != nil {
+		handler = partialDataHandler(request.T, handler)
+	}
+	return chatStream(ctx, provider, request, handler)
+}
+
+// chatStream streams from provider without decorating chunks, for wrappers whose caller
+// already went through ChatStream
+func chatStream(ctx context.Context, provider Provider, request ChatRequest, handler StreamHandler) (ChatResponse, error) {
+	if streaming, ok := provider.(StreamingProvider); ok {
+		return streaming.ChatStream(ctx, request, handler)
+	}
+
+	response, err := provider.Chat(request)
+	if err != nil {
+		return response, err
+	}
+	chunk := StreamChunk{Delta: response.Message, Text: response.Message, Done: true}
+	if err := handler(chunk); err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// partialDataHandler fills in each chunk's Data with the partial structured output
+func partialDataHandler(target any, handler StreamHandler) StreamHandler {
+	targetType := reflect.TypeOf(target)
+	if targetType.Kind() != reflect.Pointer {
+		return handler
+	}
+
+	return func(chunk StreamChunk) error {
+		partial := reflect.New(targetType.Elem()).Interface()
+		if jsonextract.DecodePartial(chunk.Text, partial) {
+			chunk.Data = partial
+		}
+		return handler(chunk)
+	}
+}
+
+// ChatStreamStructured streams a structured response of type T, calling onPartial whenever
+// the best-effort value changes. It returns the strictly decoded final value.
+func ChatStreamStructured[T any](ctx context.Context, provider Provider, request ChatRequest, onPartial func(partial T) error) (T, ChatResponse, error) {
+	var final T
+	request.T = &final
+	parser := jsonextract.NewPartialParser[T](request.Extractor)
+
+	// The parser decodes each partial value, so chunks skip partialDataHandler
+	response, err := chatStream(ctx, provider, request, func(chunk StreamChunk) error {
+		if partial, changed := parser.Write(chunk.Delta); changed && onPartial != nil {
+			return onPartial(partial)
+		}
+		return nil
+	})
+	return final, response, err
+}
+
+// StopRetrying returns err marked as not retryable. Streaming providers use it once chunks
+// have reached the handler, since a retry would deliver the response a second time.
+func StopRetrying(err error) error {
+	var llmErr *LLMError
+	if !errors.As(err, &llmErr) || !llmErr.Retryable {
+		return err
+	}
+
+	stopped := *llmErr
+	stopped.Retryable = false
+	return &stopped
+}
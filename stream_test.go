@@ -0,0 +1,104 @@
+package simpleai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// streamingStub streams a canned response in fixed-size chunks
+type streamingStub struct {
+	stubProvider
+	response string
+	size     int
+}
+
+func (p *streamingStub) ChatStream(ctx context.Context, request ChatRequest, handler StreamHandler) (ChatResponse, error) {
+	text := ""
+	for i := 0; i < len(p.response); i += p.size {
+		delta := p.response[i:min(i+p.size, len(p.response))]
+		text += delta
+		if err := handler(StreamChunk{Delta: delta, Text: text, Done: len(text) == len(p.response)}); err != nil {
+			return ChatResponse{}, err
+		}
+	}
+	data, err := ParseStructuredOutput(text, request.T, request.Extractor, 0)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return ChatResponse{Message: text, Data: data}, nil
+}
+
+type weather struct {
+	City  string `json:"city"`
+	TempC int    `json:"temp_c"`
+}
+
+func TestChatStreamPartialData(t *testing.T) {
+	provider := &streamingStub{response: `Result: {"city": "Oslo", "temp_c": 12}`, size: 5}
+
+	var target weather
+	var cities []string
+	response, err := ChatStream(context.Background(), provider, ChatRequest{T: &target}, func(chunk StreamChunk) error {
+		if partial, ok := chunk.Data.(*weather); ok {
+			cities = append(cities, partial.City)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected stream to succeed, got %v", err)
+	}
+	if target.City != "Oslo" || target.TempC != 12 || response.Data != &target {
+		t.Errorf("Expected final strict decode into target, got %+v", target)
+	}
+	if len(cities) == 0 || cities[len(cities)-1] != "Oslo" {
+		t.Errorf("Expected partial values to fill in the city, got %q", cities)
+	}
+	if cities[0] == "Oslo" {
+		t.Errorf("Expected the city to arrive incrementally, got %q", cities)
+	}
+}
+
+func TestChatStreamFallsBackToChat(t *testing.T) {
+	var chunks []StreamChunk
+	response, err := ChatStream(context.Background(), &stubProvider{name: "plain"}, ChatRequest{}, func(chunk StreamChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if len(chunks) != 1 || !chunks[0].Done || chunks[0].Text != response.Message {
+		t.Errorf("Expected a single final chunk, got %+v", chunks)
+	}
+}
+
+func TestChatStreamStructured(t *testing.T) {
+	provider := &streamingStub{response: "```json\n{\"city\": \"Lima\", \"temp_c\": 19}\n```", size: 4}
+
+	var partials []weather
+	final, _, err := ChatStreamStructured(context.Background(), provider, ChatRequest{}, func(partial weather) error {
+		partials = append(partials, partial)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if final != (weather{City: "Lima", TempC: 19}) {
+		t.Errorf("Unexpected final value %+v", final)
+	}
+	if len(partials) < 2 || partials[len(partials)-1] != final {
+		t.Errorf("Expected partial values converging on the final value, got %+v", partials)
+	}
+}
+
+func TestStopRetrying(t *testing.T) {
+	err := NewLLMError(ErrTimeout, "slow", "chat_stream", true, 0, nil)
+	stopped := StopRetrying(err)
+	if IsRetryable(stopped) || !errors.Is(stopped, ErrTimeout) {
+		t.Errorf("Expected a non-retryable timeout, got %v", stopped)
+	}
+	if !err.Retryable {
+		t.Error("Expected the original error to be left unchanged")
+	}
+}
@@ -0,0 +1,45 @@
+package simpleai
+
+import (
+	"encoding/json"
+	"fmt"
+	"simpleai/jsonextract"
+)
+
+// ParseStructuredOutput extracts JSON from a response and unmarshals it into target.
+// extractor may be nil to use the default jsonextract pipeline. Failures are returned as
+// retryable ErrJSONParseFailed errors so providers can ask the model again.
+func ParseStructuredOutput(response string, target any, extractor *jsonextract.Extractor, attempt int) (any, error) {
+	if target == nil {
+		return nil, nil
+	}
+	if extractor == nil {
+		extractor = jsonextract.Default()
+	}
+
+	// Try to extract JSON of the shape the target expects from the response
+	result, ok := extractor.ExtractFor(response, target)
+	if !ok {
+		// Enhanced error reporting for JSON extraction failures
+		return nil, NewLLMError(ErrJSONParseFailed,
+			"no valid JSON found in response", "chat", true, attempt,
+			fmt.Errorf("extractJSON failed - response preview: %s", preview(response)))
+	}
+
+	if err := json.Unmarshal([]byte(result.JSON), target); err != nil {
+		// Enhanced error reporting for JSON unmarshaling failures
+		return nil, NewLLMError(ErrJSONParseFailed,
+			"failed to parse JSON response", "chat", true, attempt,
+			fmt.Errorf("unmarshal error: %v - extracted JSON preview (strategy %s): %s", err, result.Strategy, preview(result.JSON)))
+	}
+
+	return target, nil
+}
+
+// preview truncates long text for error messages
+func preview(text string) string {
+	if len(text) > 200 {
+		return text[:200] + "..."
+	}
+	return text
+}
@@ -3,6 +3,7 @@ package simpleai
 import (
 	"errors"
 	"fmt"
+	"simpleai/jsonextract"
 	"time"
 )
 
@@ -20,14 +21,40 @@ type SystemPrompt struct {
 
 // Message represents a single message in a conversation
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // Tools the assistant asked to call
+	ToolCallID string     `json:"tool_call_id,omitempty"` // For "tool" messages, the call this result answers
+}
+
+// Tool describes a function the model may ask to call
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"` // JSON Schema of the arguments (see the schema package)
+}
+
+// ToolCall is a request from the model to call a tool
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
 }
 
 // ChatResponse represents the response from an LLM
 type ChatResponse struct {
 	Message string `json:"message"`
-	Data    any    `json:"data,omitempty"` // Structured output data if T was specified
+	Data    any    `json:"data,omitempty"`  // Structured output data if T was specified
+	Usage   *Usage `json:"usage,omitempty"` // Token usage, if reported by the provider
+
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"` // Tools the model asked to call instead of answering
+}
+
+// Usage reports the tokens consumed by a request
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 // ChatRequest represents a chat request to an LLM
@@ -35,6 +62,13 @@ type ChatRequest struct {
 	SystemPrompt SystemPrompt `json:"system_prompt"`
 	Messages     []Message    `json:"messages"`
 	T            any          `json:"-"` // Optional: structured output shape if desired
+
+	Extractor *jsonextract.Extractor `json:"-"` // Optional: JSON extraction pipeline for T (default pipeline if nil)
+
+	Tools      []Tool `json:"tools,omitempty"`       // Optional: tools the model may call, for providers with FunctionCalling
+	ToolChoice string `json:"tool_choice,omitempty"` // Optional: "auto", "none", "required" or a tool name
+
+	Temperature *float64 `json:"temperature,omitempty"` // Optional: sampling temperature, for providers with Temperature (provider default if nil)
 }
 
 // ProviderFeatures describes the capabilities supported by an LLM provider
@@ -51,8 +85,9 @@ type ProviderFeatures struct {
 
 // ProviderConfig holds configuration for a specific provider
 type ProviderConfig struct {
+	Type          string            `json:"type,omitempty"`           // Registered provider to create; defaults to the entry's name
 	Host          string            `json:"host,omitempty"`           // Provider host URL
-	APIKey        string            `json:"api_key,omitempty"`        // API key for authentication
+	APIKey        string            `json:"api_key,omitempty"`        // API key, or a secret reference such as env:NAME or file:PATH
 	DefaultModel  string            `json:"default_model"`           // Default model to use
 	Timeout       int               `json:"timeout"`                  // Request timeout in seconds
 	RetryAttempts int               `json:"retry_attempts"`          // Maximum retry attempts
@@ -63,7 +98,7 @@ type ProviderConfig struct {
 // FactoryConfig holds the complete factory configuration
 type FactoryConfig struct {
 	DefaultProvider   string                    `json:"default_provider"`             // Default provider to use
-	Providers         map[string]ProviderConfig `json:"providers"`                   // Provider configurations
+	Providers         map[string]ProviderConfig `json:"providers"`                   // Provider configurations, by instance name
 	ModelPreferences  map[string]string         `json:"model_preferences,omitempty"`  // Task-specific model preferences
 	FallbackProviders []string                  `json:"fallback_providers,omitempty"` // Provider fallback order
 }
@@ -79,8 +114,39 @@ var (
 	ErrRateLimitExceeded = errors.New("rate limit exceeded")
 	ErrInvalidConfig     = errors.New("invalid configuration")
 	ErrOperationFailed   = errors.New("operation failed")
+	ErrContentFiltered   = errors.New("content blocked by provider filter")
 )
 
+// errorNames are the stable names of the error types, for formats such as cassette files
+// and plugin replies
+var errorNames = map[error]string{
+	ErrConnectionFailed:  "connection_failed",
+	ErrTimeout:           "timeout",
+	ErrInvalidResponse:   "invalid_response",
+	ErrJSONParseFailed:   "json_parse_failed",
+	ErrModelNotAvailable: "model_not_available",
+	ErrRateLimitExceeded: "rate_limit_exceeded",
+	ErrInvalidConfig:     "invalid_config",
+	ErrOperationFailed:   "operation_failed",
+	ErrContentFiltered:   "content_filtered",
+}
+
+// ErrorName returns the stable name of an error type, such as "rate_limit_exceeded" for
+// ErrRateLimitExceeded, or "" if errType is not one of the error types above
+func ErrorName(errType error) string {
+	return errorNames[errType]
+}
+
+// ErrorByName returns the error type ErrorName names name, and whether there is one
+func ErrorByName(name string) (error, bool) {
+	for errType, typeName := range errorNames {
+		if typeName == name {
+			return errType, true
+		}
+	}
+	return nil, false
+}
+
 // LLMError represents an error from LLM operations
 type LLMError struct {
 	Type        error
@@ -89,16 +155,39 @@ type LLMError struct {
 	Operation   string
 	RetryCount  int
 	LastAttempt time.Time
+	StatusCode  int           // HTTP status code returned by the provider, if any
+	Provider    string        // Name of the provider that produced the error
+	Model       string        // Model the request was sent to
+	RetryAfter  time.Duration // Server-provided delay before retrying (Retry-After, RetryInfo)
 	Cause       error
 }
 
 func (e *LLMError) Error() string {
+	details := fmt.Sprintf("operation: %s, retryable: %v, attempts: %d", e.Operation, e.Retryable, e.RetryCount)
+	if e.Provider != "" {
+		details = fmt.Sprintf("provider: %s, ", e.Provider) + details
+	}
+	if e.Model != "" {
+		details += fmt.Sprintf(", model: %s", e.Model)
+	}
+	if e.StatusCode != 0 {
+		details += fmt.Sprintf(", status: %d", e.StatusCode)
+	}
+
 	if e.Cause != nil {
-		return fmt.Sprintf("%s: %s (operation: %s, retryable: %v, attempts: %d) - caused by: %v",
-			e.Type.Error(), e.Message, e.Operation, e.Retryable, e.RetryCount, e.Cause)
+		return RedactSecrets(fmt.Sprintf("%s: %s (%s) - caused by: %v", e.Type.Error(), e.Message, details, e.Cause))
 	}
-	return fmt.Sprintf("%s: %s (operation: %s, retryable: %v, attempts: %d)",
-		e.Type.Error(), e.Message, e.Operation, e.Retryable, e.RetryCount)
+	return RedactSecrets(fmt.Sprintf("%s: %s (%s)", e.Type.Error(), e.Message, details))
+}
+
+// Is reports whether the error is of the given type, so errors.Is(err, ErrTimeout) works
+func (e *LLMError) Is(target error) bool {
+	return e.Type != nil && e.Type == target
+}
+
+// Unwrap returns the underlying cause
+func (e *LLMError) Unwrap() error {
+	return e.Cause
 }
 
 // NewLLMError creates a new LLMError
@@ -114,23 +203,64 @@ func NewLLMError(errType error, message, operation string, retryable bool, retry
 	}
 }
 
+// WithStatusCode records the HTTP status code returned by the provider
+func (e *LLMError) WithStatusCode(statusCode int) *LLMError {
+	e.StatusCode = statusCode
+	return e
+}
+
+// WithProvider records the provider and model that produced the error
+func (e *LLMError) WithProvider(provider, model string) *LLMError {
+	e.Provider = provider
+	e.Model = model
+	return e
+}
+
+// WithRetryAfter records a server-provided retry delay on the error
+func (e *LLMError) WithRetryAfter(delay time.Duration) *LLMError {
+	e.RetryAfter = delay
+	return e
+}
+
 // IsRetryable checks if an error is retryable
 func IsRetryable(err error) bool {
-	if llmErr, ok := err.(*LLMError); ok {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
 		return llmErr.Retryable
 	}
 	return false
 }
 
+// errorType returns the type of the first LLMError in err's chain, or nil if there is none
+func errorType(err error) error {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		return llmErr.Type
+	}
+	return nil
+}
+
 // ValidationError represents a data validation error
 type ValidationError struct {
 	Field   string
 	Value   interface{}
 	Message string
+	File    string // Config file the value came from, if known
+	Line    int    // Line in File, if known
 }
 
 func (e *ValidationError) Error() string {
-	return fmt.Sprintf("validation error for field '%s': %s (value: %v)", e.Field, e.Message, e.Value)
+	msg := fmt.Sprintf("validation error for field '%s': %s (value: %v)", e.Field, e.Message, e.Value)
+	if e.Field == "" {
+		msg = "validation error: " + e.Message
+	}
+	switch {
+	case e.File != "" && e.Line > 0:
+		msg = fmt.Sprintf("%s:%d: %s", e.File, e.Line, msg)
+	case e.File != "":
+		msg = e.File + ": " + msg
+	}
+	return RedactSecrets(msg)
 }
 
 // NewValidationError creates a new ValidationError
@@ -144,10 +274,14 @@ func NewValidationError(field string, value interface{}, message string) *Valida
 
 // RetryConfig holds retry configuration
 type RetryConfig struct {
-	MaxRetries    int
-	BaseDelay     time.Duration
-	MaxDelay      time.Duration
-	BackoffFactor float64
+	MaxRetries     int
+	BaseDelay      time.Duration
+	MaxDelay       time.Duration
+	BackoffFactor  float64
+	Jitter         JitterStrategy        // Randomization applied to backoff delays
+	MaxElapsedTime time.Duration         // Total time budget across all attempts (0 = unlimited)
+	Policies       map[error]RetryPolicy // Per-error-type overrides keyed by error type (e.g. ErrRateLimitExceeded)
+	OnRetry        func(RetryEvent)      // Called before waiting for each retry
 }
 
 // DefaultRetryConfig returns default retry configuration
@@ -157,6 +291,7 @@ func DefaultRetryConfig() *RetryConfig {
 		BaseDelay:     2 * time.Second,
 		MaxDelay:      30 * time.Second,
 		BackoffFactor: 2.0,
+		Jitter:        JitterFull,
 	}
 }
 